@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/config"
+)
+
+type addonCmd struct {
+	optionsPath string
+	parallel    int
+}
+
+func (addonCmd) Name() string { return "addon" }
+
+func (addonCmd) Synopsis() string {
+	return "sync all the meters when running as a Home Assistant add-on"
+}
+
+func (addonCmd) Usage() string {
+	return `addon
+
+Like sync, but for the Home Assistant add-on in the addon folder: the
+configuration is read from the add-on options, and when it doesn't set
+a home_assistant server, Home Assistant is reached through the
+Supervisor with the SUPERVISOR_TOKEN it provides, so there is no
+long-lived token to create or port to expose.
+The local store is kept in the persistent /data folder.
+
+`
+}
+
+func (c *addonCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.optionsPath, "options", "/data/options.json", "the path of the add-on options")
+	fs.IntVar(&c.parallel, "parallel", 4, "how many meters to sync at the same time")
+}
+
+func (c *addonCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	b, err := os.ReadFile(c.optionsPath)
+	if err != nil {
+		slog.Error("cannot read the add-on options", "err", err)
+		return subcommands.ExitUsageError
+	}
+	cfg, err := config.ParseAddon(b, os.Getenv("SUPERVISOR_TOKEN"))
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	return syncAll(ctx, cfg, c.parallel)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// archiveDir is where the downloaded files are kept, set by
+	// -archive_dir, see archive.
+	archiveDir string
+	// archiveGzip compresses the archived files, set by -archive_gzip.
+	archiveGzip bool
+)
+
+// archive saves the file downloaded for a meter verbatim in archiveDir,
+// before it is parsed, so the data is not lost when ESB changes the
+// format and the parser fails: the files can be uploaded again once it
+// is fixed.
+//
+// The whole body is read, and closed, even if the parser would stop
+// early. It returns the archived file, to parse instead of the body.
+func archive(body io.ReadCloser, mprn string, now time.Time) (io.ReadCloser, error) {
+	defer body.Close()
+	name, tmp, f, err := createArchive(mprn, now)
+	if err != nil {
+		return nil, fmt.Errorf("cannot archive the download: %w", err)
+	}
+	var w io.Writer = f
+	var zw *gzip.Writer
+	if archiveGzip {
+		zw = gzip.NewWriter(f)
+		w = zw
+	}
+	_, err = io.Copy(w, body)
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("cannot archive the download: %w", err)
+	}
+	slog.Debug("Archived the download", "file", name)
+	return openArchived(name)
+}
+
+// createArchive creates the partial file of a download, and returns
+// the name to rename it to once complete.
+//
+// The names of the downloads in the same second, by this or another
+// process, get a suffix so no file is overwritten: the partial file is
+// created only if missing, and kept until renamed, then the complete
+// one is checked.
+func createArchive(mprn string, now time.Time) (name, tmp string, f *os.File, err error) {
+	for n := 1; ; n++ {
+		name = filepath.Join(archiveDir, archiveName(mprn, now, n))
+		// A partial file would look like a download with less data.
+		tmp = name + ".part"
+		f, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", "", nil, err
+		}
+		if _, err := os.Lstat(name); err == nil {
+			f.Close()
+			os.Remove(tmp)
+			continue
+		}
+		return name, tmp, f, nil
+	}
+}
+
+// archiveTimeFormat is the time of the download in the archived names.
+const archiveTimeFormat = "20060102T150405Z"
+
+// archiveTime returns the time of the download of an archived file
+// from its name, false if it isn't one, see archiveName. The partial
+// files left by a crash count too.
+func archiveTime(name string) (time.Time, bool) {
+	name = strings.TrimSuffix(name, ".part")
+	name = strings.TrimSuffix(name, ".gz")
+	name, ok := strings.CutSuffix(name, ".csv")
+	if !ok {
+		return time.Time{}, false
+	}
+	if i := strings.LastIndexByte(name, '_'); i >= 0 {
+		name = name[:i]
+	}
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(archiveTimeFormat, name[i+1:])
+	return t, err == nil
+}
+
+// pruneArchive deletes the files archived in dir before now minus keep,
+// the other files are left alone. It returns how many were deleted.
+func pruneArchive(dir string, keep time.Duration, now time.Time) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		t, ok := archiveTime(e.Name())
+		if e.IsDir() || !ok || !t.Before(now.Add(-keep)) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// archiveName returns the name of the n-th file archived for a meter at
+// a time, the names sort by time.
+func archiveName(mprn string, t time.Time, n int) string {
+	name := fmt.Sprintf("%s-%s", mprn, t.UTC().Format(archiveTimeFormat))
+	if n > 1 {
+		// After the first one, '_' sorts after '.'.
+		name += fmt.Sprintf("_%d", n)
+	}
+	name += ".csv"
+	if archiveGzip {
+		name += ".gz"
+	}
+	return name
+}
+
+// gzipFile is a gzip file being read.
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+// openArchived opens a CSV file, decompressing it if its name
+// ends with .gz, like the ones saved with -archive_gzip.
+func openArchived(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return gzipFile{zr, f}, nil
+}
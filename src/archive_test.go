@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// setArchive sets the archive flags for the test.
+func setArchive(t *testing.T, gzip bool) string {
+	t.Helper()
+	oldDir, oldGzip := archiveDir, archiveGzip
+	t.Cleanup(func() { archiveDir, archiveGzip = oldDir, oldGzip })
+	archiveDir, archiveGzip = t.TempDir(), gzip
+	return archiveDir
+}
+
+// dirNames returns the sorted names of the files in dir.
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() unexpected error: %v", err)
+	}
+	var ret []string
+	for _, e := range entries {
+		ret = append(ret, e.Name())
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func TestArchive_SameSecond(t *testing.T) {
+	dir := setArchive(t, false)
+	now := time.Date(2024, 3, 15, 6, 30, 0, 0, time.UTC)
+	// The download of another process, still in progress.
+	if err := os.WriteFile(filepath.Join(dir, "1-20240315T063000Z_2.csv.part"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{"first\n", "second\n", "third\n"} {
+		r, err := archive(io.NopCloser(strings.NewReader(data)), "1", now)
+		if err != nil {
+			t.Fatalf("archive() unexpected error: %v", err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(got) != data {
+			t.Errorf("archive() returned %q, %v, want %q", got, err, data)
+		}
+	}
+
+	want := []string{
+		"1-20240315T063000Z.csv",
+		"1-20240315T063000Z_2.csv.part",
+		"1-20240315T063000Z_3.csv",
+		"1-20240315T063000Z_4.csv",
+	}
+	if diff := cmp.Diff(want, dirNames(t, dir)); diff != "" {
+		t.Errorf("unexpected archived files (-want +got): %v", diff)
+	}
+	for name, want := range map[string]string{"1-20240315T063000Z.csv": "first\n", "1-20240315T063000Z_4.csv": "third\n"} {
+		if got, _ := os.ReadFile(filepath.Join(dir, name)); string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestOpenArchived(t *testing.T) {
+	for _, gzip := range []bool{false, true} {
+		dir := setArchive(t, gzip)
+		const data = "MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time\n"
+		r, err := archive(io.NopCloser(strings.NewReader(data)), "1", time.Date(2024, 3, 15, 6, 30, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("gzip=%v: archive() unexpected error: %v", gzip, err)
+		}
+		r.Close()
+
+		names := dirNames(t, dir)
+		want := "1-20240315T063000Z.csv"
+		if gzip {
+			want += ".gz"
+		}
+		if len(names) != 1 || names[0] != want {
+			t.Fatalf("gzip=%v: archived %v, want [%s]", gzip, names, want)
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed := string(raw) != data; compressed != gzip {
+			t.Errorf("gzip=%v: the file is compressed: %v", gzip, compressed)
+		}
+		f, err := openArchived(filepath.Join(dir, want))
+		if err != nil {
+			t.Fatalf("gzip=%v: openArchived() unexpected error: %v", gzip, err)
+		}
+		got, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(got) != data {
+			t.Errorf("gzip=%v: openArchived() read %q, %v, want %q", gzip, got, err, data)
+		}
+	}
+}
+
+func TestPruneArchive(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"1-20240101T000000Z.csv",
+		"1-20240101T000000Z_2.csv.gz",
+		"1-20240101T000000Z_3.csv.part",
+		"1-20240320T000000Z.csv",
+		"notes.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	n, err := pruneArchive(dir, 30*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("pruneArchive() unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("pruneArchive() deleted %d files, want 3", n)
+	}
+	want := []string{"1-20240320T000000Z.csv", "notes.txt"}
+	if diff := cmp.Diff(want, dirNames(t, dir)); diff != "" {
+		t.Errorf("unexpected files after pruneArchive() (-want +got): %v", diff)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+type billingCmd struct {
+	start    string
+	months   int
+	unitRate float64
+	charges  parse.Charges
+}
+
+func (billingCmd) Name() string { return "billing" }
+
+func (billingCmd) Synopsis() string {
+	return "report the electricity usage by billing cycle"
+}
+
+func (billingCmd) Usage() string {
+	return `billing -cycle_start <date> <flags>
+
+The CSV file is read from standard input.
+
+Prints the energy used in every billing cycle in the data, and the
+current cycle to date compared with the same days of the previous one.
+The days of the cycles missing some data are marked with *.
+Only complete days are compared, the data lags one or two days behind.
+With -unit_rate the cost includes the standing charge and the PSO levy
+of the complete days, and VAT, so it can be compared with the bills.
+
+`
+}
+
+func (c *billingCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.start, "cycle_start", "", "the first day of any billing cycle, like 2024-01-14")
+	fs.IntVar(&c.months, "cycle_months", 1, "how many months a billing cycle lasts, 2 for bi-monthly bills")
+	fs.Float64Var(&c.unitRate, "unit_rate", 0, "if set, add the cost computed with this price per kWh")
+	chargesFlags(fs, &c.charges)
+}
+
+// chargesFlags sets the flags of the fixed charges of the bills.
+func chargesFlags(fs *flag.FlagSet, c *parse.Charges) {
+	fs.Float64Var(&c.StandingPerDay, "standing_charge", 0, "EUR per day of standing charge added to the cost")
+	fs.Float64Var(&c.LevyPerMonth, "pso_levy", 0, "EUR per month of PSO levy added to the cost")
+	fs.Float64Var(&c.VAT, "vat", 0, "VAT rate applied to the cost, like 0.09 for 9%")
+}
+
+func (c *billingCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	cycle, err := c.cycle()
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	slog.Info("Reading from stdin...")
+
+	if err := c.report(cycle, os.Stdin, os.Stdout); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// cycle returns the billing cycle set by the flags.
+func (c *billingCmd) cycle() (parse.BillingCycle, error) {
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		return parse.BillingCycle{}, err
+	}
+	start, err := time.ParseInLocation(time.DateOnly, c.start, dublin)
+	if err != nil {
+		return parse.BillingCycle{}, fmt.Errorf("invalid -cycle_start: %w", err)
+	}
+	b := parse.BillingCycle{Start: start, Months: c.months}
+	return b, b.Validate()
+}
+
+func (c *billingCmd) report(cycle parse.BillingCycle, data io.Reader, out io.Writer) error {
+	parsed, err := readHDF(data)
+	if err != nil {
+		return err
+	}
+	days := parse.Daily(parsed)
+	if len(days) == 0 {
+		return errors.New("no data")
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := "FROM\tTO\tDAYS\tKWH"
+	if c.unitRate > 0 {
+		header += "\tCOST"
+	}
+	fmt.Fprintln(w, header)
+	for _, t := range cycle.Cycles(days) {
+		c.printRow(w, t)
+	}
+	w.Flush()
+
+	cur, prev, ok := cycle.ToDate(days)
+	if !ok {
+		return nil
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Cycle to date compared with the same days of the previous cycle:")
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, header)
+	c.printRow(w, cur)
+	c.printRow(w, prev)
+	w.Flush()
+	if prev.Days == cur.Days && prev.KWh > 0 {
+		fmt.Fprintf(out, "Change: %+.1f%%\n", (cur.KWh-prev.KWh)/prev.KWh*100)
+	} else {
+		fmt.Fprintln(out, "The previous cycle doesn't have the data of all the days to compare")
+	}
+	return nil
+}
+
+// printRow prints a cycle, the last day is inclusive.
+func (c *billingCmd) printRow(w io.Writer, t parse.CycleTotal) {
+	days := fmt.Sprint(t.Days)
+	if !t.Complete() {
+		days += "*"
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%.3f", t.From.Format(time.DateOnly), t.To.AddDate(0, 0, -1).Format(time.DateOnly), days, t.KWh)
+	if c.unitRate > 0 {
+		fmt.Fprintf(w, "\t%.2f", c.charges.Total(t.KWh*c.unitRate, t.Days))
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/store"
+)
+
+// downloadCache remembers the last download to avoid processing the same data twice.
+type downloadCache struct {
+	path      string
+	staleDays int
+}
+
+func (c *downloadCache) SetFlags(fs *flag.FlagSet) {
+	// The path is set from the -store flag of uploadCmd.
+	fs.IntVar(&c.staleDays, "stale_days", 3, "warn if ESB doesn't publish new data for this many days")
+}
+
+// unchanged records the download and returns true if the data is the
+// same already uploaded from the mprn to the sensor, see uploaded.
+//
+// The hash is the hex encoded sha256 of the data, see parseDownload.
+// It also returns for how long the data didn't change, and prints a
+// warning if this is more than the configured days.
+// Without a store configured it always returns false.
+func (c *downloadCache) unchanged(mprn, sensor, hash string) (bool, time.Duration, error) {
+	if c.path == "" {
+		return false, 0, nil
+	}
+
+	st, err := store.Open(c.path)
+	if err != nil {
+		return false, 0, fmt.Errorf("cannot open local store: %w", err)
+	}
+	defer st.Close()
+
+	d, err := st.RecordDownload(mprn, hash, time.Now())
+	if err != nil {
+		return false, 0, fmt.Errorf("cannot record download: %w", err)
+	}
+
+	stale := d.DownloadedAt.Sub(d.ChangedAt)
+	if days := int(stale.Hours() / 24); days >= c.staleDays {
+		slog.Warn("ESB has not published new data", "days", days)
+	}
+	// The download is recorded even if the upload fails, but the data
+	// is unchanged only once uploaded.
+	uploaded, ok, err := st.UploadedHash(mprn, sensor)
+	if err != nil {
+		return false, 0, fmt.Errorf("cannot read the last upload: %w", err)
+	}
+	return ok && uploaded == hash, stale, nil
+}
+
+// uploaded records that the download with the hash was uploaded from the
+// mprn to the sensor, so unchanged skips it from now on.
+//
+// Without a store configured it does nothing.
+func (c *downloadCache) uploaded(mprn, sensor, hash string) error {
+	if c.path == "" {
+		return nil
+	}
+	st, err := store.Open(c.path)
+	if err != nil {
+		return fmt.Errorf("cannot open local store: %w", err)
+	}
+	defer st.Close()
+	if err := st.RecordUploadedHash(mprn, sensor, hash); err != nil {
+		return fmt.Errorf("cannot record the upload: %w", err)
+	}
+	return nil
+}
+
+// recordReads keeps the downloaded reads in the store, the local
+// history of the meters, and warns about the ones ESB revised since
+// they were recorded.
+//
+// Without a store configured it does nothing.
+func (c *downloadCache) recordReads(parsed []parse.Result) error {
+	if c.path == "" {
+		return nil
+	}
+	var reads []store.Read
+	for _, r := range parsed {
+		for _, rd := range r.Reads {
+			if rd.Estimated {
+				continue
+			}
+			reads = append(reads, store.Read{MPRN: r.MPRN, Serial: r.MeterSerialNumber, ReadType: r.ReadTypes, EndTime: rd.EndTime, Value: rd.Value})
+		}
+	}
+	if len(reads) == 0 {
+		return nil
+	}
+
+	st, err := store.Open(c.path)
+	if err != nil {
+		return fmt.Errorf("cannot open local store: %w", err)
+	}
+	defer st.Close()
+
+	added, revised, err := st.RecordReads(reads, time.Now())
+	if err != nil {
+		return fmt.Errorf("cannot record the reads: %w", err)
+	}
+	slog.Debug("Recorded the reads", "new", added, "revised", len(revised))
+	if len(revised) > 0 {
+		slog.Warn("ESB revised reads already downloaded, resync fixes the sums in Home Assistant", "reads", len(revised), "first", revised[0].EndTime.Format(time.DateTime))
+	}
+	return nil
+}
+
+// publications returns when new data of the mprn was seen recently.
+//
+// Without a store configured it returns none.
+func (c *downloadCache) publications(mprn string) ([]time.Time, error) {
+	if c.path == "" {
+		return nil, nil
+	}
+
+	st, err := store.Open(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open local store: %w", err)
+	}
+	defer st.Close()
+
+	pubs, err := st.Publications(mprn, 30)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read publications: %w", err)
+	}
+	return pubs, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/provider"
+)
+
+type checkCmd struct {
+	ha  uploadCmd
+	esb downloadCmd
+	// failed is set by report when a check fails.
+	failed bool
+}
+
+func (checkCmd) Name() string { return "check" }
+
+func (checkCmd) Synopsis() string {
+	return "check the ESB account, the meters, Home Assistant and the sensors before the first sync"
+}
+
+func (checkCmd) Usage() string {
+	return `check <flags>
+
+All the non optional flags are required, but can be provided as environment variables as well.
+It takes the same flags of pipe, and uploads nothing.
+
+Checks, and reports PASS or FAIL for each:
+ - the ESB login, always a new one: -esb_session_file is ignored;
+ - every meter in -mprn, which must be linked to the account;
+ - the connection to Home Assistant, and the access token;
+ - the sensors, which must be valid statistic IDs and, if they exist
+   already, totals in the right unit; the entities of the non external
+   ones must exist in Home Assistant.
+The checks needing a failed one are skipped.
+The exit status is not zero if any check fails.
+
+`
+}
+
+func (c *checkCmd) SetFlags(fs *flag.FlagSet) {
+	c.ha.SetFlags(fs)
+	c.esb.SetFlags(fs)
+}
+
+func (c *checkCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	c.checkESB(ctx)
+	c.checkHA(ctx)
+	if c.failed {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// report prints the result of a check, detail is printed when it
+// passes.
+func (c *checkCmd) report(name, detail string, err error) bool {
+	if err != nil {
+		c.failed = true
+		fmt.Printf("FAIL  %s: %v\n", name, err)
+		return false
+	}
+	fmt.Printf("PASS  %s: %s\n", name, detail)
+	return true
+}
+
+// skipCheck prints a check which cannot run, because of a failed one.
+func skipCheck(name, reason string) {
+	fmt.Printf("SKIP  %s: %s\n", name, reason)
+}
+
+// checkESB logs in and checks that the meters are linked to the
+// account.
+func (c *checkCmd) checkESB(ctx context.Context) {
+	// A saved session would not check the password.
+	c.esb.sessionFile = ""
+	p, err := c.esb.login(ctx)
+	if !c.report("ESB login", "logged in as "+c.esb.user, err) {
+		for _, mprn := range c.esb.mprns() {
+			skipCheck("MPRN "+mprn, "needs the ESB login")
+		}
+		return
+	}
+
+	// Yesterday only, the providers downloading a period download less.
+	day := time.Now().AddDate(0, 0, -1).Format(time.DateOnly)
+	for _, mprn := range c.esb.mprns() {
+		body, err := openMPRN(ctx, p, mprn, period{from: day, to: day})
+		if err == nil {
+			body.Close()
+		} else if errors.Is(err, provider.ErrMeterNotFound) {
+			err = fmt.Errorf("not linked to the account of %s: add it on the ESB website, or fix -mprn", c.esb.user)
+		}
+		c.report("MPRN "+mprn, "linked to the account", err)
+	}
+}
+
+// checkHA connects to Home Assistant and checks the sensors.
+func (c *checkCmd) checkHA(ctx context.Context) {
+	energy := ha.StatisticMetadata{StatisticID: c.ha.sensor, UnitOfMeasurement: "kWh"}
+	c.ha.describe(&energy, "")
+	metadata := []ha.StatisticMetadata{energy}
+	c.ha.pairCost()
+	if c.ha.costSensor != "" {
+		metadata = append(metadata, ha.CostMetadata(energy, c.ha.costSensor, "EUR"))
+	}
+	if c.ha.exportSensor != "" {
+		export := ha.StatisticMetadata{StatisticID: c.ha.exportSensor, UnitOfMeasurement: "kWh"}
+		c.ha.describe(&export, "export")
+		metadata = append(metadata, export)
+	}
+	if c.ha.bandSensors != "" {
+		bands, err := c.ha.bandSensorList()
+		c.report("tariff bands", fmt.Sprintf("%d band sensors", len(bands)), err)
+		for _, bs := range bands {
+			band := ha.StatisticMetadata{StatisticID: bs[1], UnitOfMeasurement: "kWh"}
+			c.ha.describe(&band, bs[0])
+			metadata = append(metadata, band)
+		}
+	}
+
+	conn, err := ha.NewConnection(ctx, c.ha.server, c.ha.token)
+	if !c.report("Home Assistant", "connected to "+c.ha.server, err) {
+		for _, m := range metadata {
+			skipCheck("sensor "+m.StatisticID, "needs Home Assistant")
+		}
+		return
+	}
+	defer conn.Close()
+
+	existing, err := conn.ListStatisticIDs(ctx)
+	if err != nil {
+		err = fmt.Errorf("cannot list the statistics: %w", err)
+	}
+	if !c.report("Home Assistant statistics", fmt.Sprintf("%d statistics", len(existing)), err) {
+		for _, m := range metadata {
+			skipCheck("sensor "+m.StatisticID, "needs the statistics of Home Assistant")
+		}
+		return
+	}
+	for _, m := range metadata {
+		detail, err := c.checkSensor(ctx, m, existing)
+		c.report("sensor "+m.StatisticID, detail, err)
+	}
+}
+
+// checkSensor checks a statistic like uploadCmd.checkStatistics does,
+// and that the entity exists if it is not an external statistic.
+func (c *checkCmd) checkSensor(ctx context.Context, m ha.StatisticMetadata, existing map[string]ha.StatisticInfo) (string, error) {
+	if err := ha.ValidateStatisticID(m); err != nil {
+		return "", err
+	}
+	detail := "external statistic"
+	if !c.ha.external {
+		_, _, ok, err := ha.State(ctx, c.ha.server, c.ha.token, m.StatisticID)
+		if err != nil {
+			return "", fmt.Errorf("cannot read the entity: %w", err)
+		}
+		if !ok {
+			return "", errors.New("the entity doesn't exist in Home Assistant: create it, or use -ha_external")
+		}
+		detail = "the entity exists"
+	}
+	info, ok := existing[m.StatisticID]
+	if !ok {
+		return detail + ", the statistic will be created by the first upload", nil
+	}
+	if !info.HasSum {
+		return "", errors.New("not a total, like the ones of the energy dashboard, but a measurement: choose another sensor")
+	}
+	if info.Unit != m.UnitOfMeasurement {
+		return "", fmt.Errorf("in %s, not %s: choose another sensor, or fix the unit in Developer tools > Statistics", info.Unit, m.UnitOfMeasurement)
+	}
+	return detail + ", with statistics in " + info.Unit, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+func TestCheckSensor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch id := strings.TrimPrefix(r.URL.Path, "/api/states/"); id {
+		case "sensor.esb", "sensor.mean", "sensor.gas", "sensor.new":
+			w.Write([]byte(`{"entity_id":"` + id + `","state":"0","attributes":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	existing := map[string]ha.StatisticInfo{
+		"sensor.esb":         {HasSum: true, Unit: "kWh"},
+		"sensor.mean":        {HasMean: true, Unit: "kWh"},
+		"sensor.gas":         {HasSum: true, Unit: "m³"},
+		"esb2ha:consumption": {HasSum: true, Unit: "kWh"},
+	}
+	tests := []struct {
+		name     string
+		id       string
+		external bool
+		want     string
+		wantErr  bool
+	}{
+		{name: "existing", id: "sensor.esb", want: "the entity exists, with statistics in kWh"},
+		{name: "new", id: "sensor.new", want: "the entity exists, the statistic will be created by the first upload"},
+		{name: "external", id: "esb2ha:consumption", external: true, want: "external statistic, with statistics in kWh"},
+		{name: "invalid ID", id: "sensor.ESB", wantErr: true},
+		{name: "no entity", id: "sensor.missing", wantErr: true},
+		{name: "measurement", id: "sensor.mean", wantErr: true},
+		{name: "other unit", id: "sensor.gas", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c checkCmd
+			c.ha.server, c.ha.token, c.ha.external = host, "tok", tt.external
+			m := ha.StatisticMetadata{StatisticID: tt.id, UnitOfMeasurement: "kWh"}
+			c.ha.describe(&m, "")
+			got, err := c.checkSensor(t.Context(), m, existing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkSensor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("checkSensor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReport(t *testing.T) {
+	var c checkCmd
+	if !c.report("ok", "fine", nil) || c.failed {
+		t.Errorf("report(nil) = false or failed, want true and not failed")
+	}
+	if c.report("broken", "", errors.New("down")) || !c.failed {
+		t.Errorf("report(err) = true or not failed, want false and failed")
+	}
+	// A later success doesn't clear the failure.
+	if c.report("ok", "fine", nil); !c.failed {
+		t.Errorf("report(nil) after a failure cleared it")
+	}
+}
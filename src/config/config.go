@@ -0,0 +1,225 @@
+// Package config implements the esb2ha configuration file.
+//
+// The file is JSON, see documentation/config.example.json for an example.
+package config
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/provider"
+)
+
+// Config is the whole configuration.
+type Config struct {
+	HomeAssistant HomeAssistant `json:"home_assistant"`
+	Accounts      []Account     `json:"accounts"`
+	// Store is the path of the local database, optional.
+	Store string `json:"store,omitempty"`
+	// DSTFold is what to do with the hour repeated when the clocks go
+	// back, sum, first or last, optional.
+	DSTFold string `json:"dst_fold,omitempty"`
+	// Duplicates is what to do with the reads repeated in the ESB file,
+	// error, keep-first or keep-last, optional.
+	Duplicates string `json:"duplicates,omitempty"`
+	// Notifications sends the result of the syncs, optional.
+	Notifications Notifications `json:"notifications,omitzero"`
+}
+
+// HomeAssistant is the Home Assistant instance to upload data to.
+type HomeAssistant struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+	// Decimals is the number of decimals of the uploaded values, optional.
+	Decimals *int `json:"decimals,omitempty"`
+	// Align is how the half hours are grouped in hours, center or
+	// clock, optional.
+	Align string `json:"align,omitempty"`
+	// External uploads external statistics, with IDs like
+	// esb2ha:consumption, instead of the statistics of the sensors,
+	// optional.
+	External bool `json:"external_statistics,omitempty"`
+	// MeterState sends the cumulative energy as state, optional.
+	MeterState bool `json:"meter_state,omitempty"`
+	// Timezone sends the times in the timezone configured in Home
+	// Assistant, optional.
+	Timezone bool `json:"timezone,omitempty"`
+	// TLS connects with wss and https, like a server starting with
+	// https://, optional.
+	TLS bool `json:"tls,omitempty"`
+	// CACert is the PEM file with the CA certificate of Home
+	// Assistant, optional.
+	CACert string `json:"ca_cert,omitempty"`
+	// InsecureSkipVerify accepts any certificate, optional and only
+	// for testing.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// Precision returns the number of decimals of the uploaded values, or
+// -1 if they are not rounded.
+func (h HomeAssistant) Precision() int {
+	if h.Decimals == nil {
+		return -1
+	}
+	return *h.Decimals
+}
+
+// Notifications are where to send the result of every sync, all
+// optional.
+type Notifications struct {
+	// URL receives the result as JSON.
+	URL string `json:"url,omitempty"`
+	// HAService is the Home Assistant service notifying the result,
+	// like notify.mobile_app_phone or persistent_notification.create.
+	HAService string `json:"ha_service,omitempty"`
+	// NtfyURL is the ntfy topic, like https://ntfy.sh/mytopic, and
+	// NtfyToken its access token.
+	NtfyURL   string `json:"ntfy_url,omitempty"`
+	NtfyToken string `json:"ntfy_token,omitempty"`
+	// PushoverToken is the token of the Pushover application and
+	// PushoverUser the key of the user to notify.
+	PushoverToken string `json:"pushover_token,omitempty"`
+	PushoverUser  string `json:"pushover_user,omitempty"`
+	// On is always or failure, always if empty.
+	On string `json:"on,omitempty"`
+}
+
+// Account is a login on the website of a provider, esbnetworks.ie by default.
+type Account struct {
+	// Provider is the website the data is downloaded from, esb if empty.
+	Provider string  `json:"provider,omitempty"`
+	User     string  `json:"user"`
+	Password string  `json:"password"`
+	Meters   []Meter `json:"meters"`
+	// SessionFile keeps the login between runs, encrypted with the
+	// password, optional.
+	SessionFile string `json:"session_file,omitempty"`
+	// TOTPSecret is the secret of the authenticator app, for the
+	// accounts with multi-factor authentication, optional.
+	TOTPSecret string `json:"totp_secret,omitempty"`
+}
+
+// Meter is a smart meter linked to an account.
+type Meter struct {
+	MPRN string `json:"mprn"`
+	// Sensor is the Home Assistant sensor ID used to record power usage.
+	Sensor string `json:"sensor"`
+	// Name is the name of the statistics shown by Home Assistant,
+	// optional.
+	Name string `json:"name,omitempty"`
+	// ExportSensor is the Home Assistant sensor ID used to record the
+	// energy exported to the grid, optional.
+	ExportSensor string `json:"export_sensor,omitempty"`
+	// BandSensors are the Home Assistant sensor IDs used to record the
+	// energy of every tariff band, like day=sensor.esb_day,night=sensor.esb_night,
+	// optional. TariffBands are the bands, parse.DefaultBands if empty.
+	BandSensors string `json:"band_sensors,omitempty"`
+	TariffBands string `json:"tariff_bands,omitempty"`
+	// LagSensor is the Home Assistant diagnostic sensor ID where to
+	// report how many hours behind the data is, optional.
+	LagSensor string `json:"lag_sensor,omitempty"`
+	// StatusSensor is the Home Assistant diagnostic sensor ID where to
+	// report the result of the syncs, optional.
+	StatusSensor string `json:"status_sensor,omitempty"`
+}
+
+// Load reads and validates the configuration file.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse parses and validates the configuration.
+func Parse(b []byte) (*Config, error) {
+	return parse(b, func(*Config) {})
+}
+
+// AddonStore is the local database of the add-on, in its persistent
+// folder.
+const AddonStore = "/data/esb2ha.db"
+
+// ParseAddon parses and validates the options of the Home Assistant
+// add-on, which are a configuration where home_assistant and store can
+// be left out.
+//
+// Without a server, Home Assistant is reached through the Supervisor
+// with its token.
+func ParseAddon(b []byte, supervisorToken string) (*Config, error) {
+	return parse(b, func(c *Config) {
+		if c.HomeAssistant.Server == "" {
+			c.HomeAssistant.Server = ha.SupervisorHost
+			c.HomeAssistant.Token = cmp.Or(c.HomeAssistant.Token, supervisorToken)
+		}
+		c.Store = cmp.Or(c.Store, AddonStore)
+	})
+}
+
+// parse parses the configuration, sets the defaults and validates it.
+func parse(b []byte, defaults func(*Config)) (*Config, error) {
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	defaults(&c)
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &c, nil
+}
+
+func (c *Config) validate() error {
+	var errs []error
+	if c.HomeAssistant.Server == "" {
+		errs = append(errs, errors.New("missing home_assistant.server"))
+	}
+	if c.HomeAssistant.Token == "" {
+		errs = append(errs, errors.New("missing home_assistant.token"))
+	}
+	if a := c.HomeAssistant.Align; a != "" && a != "center" && a != "clock" {
+		errs = append(errs, fmt.Errorf("invalid home_assistant.align %q, want center or clock", a))
+	}
+	if f := c.DSTFold; f != "" && f != "sum" && f != "first" && f != "last" {
+		errs = append(errs, fmt.Errorf("invalid dst_fold %q, want sum, first or last", f))
+	}
+	if d := c.Duplicates; d != "" && d != "error" && d != "keep-first" && d != "keep-last" {
+		errs = append(errs, fmt.Errorf("invalid duplicates %q, want error, keep-first or keep-last", d))
+	}
+	if o := c.Notifications.On; o != "" && o != "always" && o != "failure" {
+		errs = append(errs, fmt.Errorf("invalid notifications.on %q, want always or failure", o))
+	}
+	if n := c.Notifications; (n.PushoverToken == "") != (n.PushoverUser == "") {
+		errs = append(errs, errors.New("notifications.pushover_token and notifications.pushover_user must be set together"))
+	}
+	if len(c.Accounts) == 0 {
+		errs = append(errs, errors.New("no accounts"))
+	}
+	mprns := map[string]bool{}
+	for i, a := range c.Accounts {
+		if a.Provider != "" && !provider.Valid(a.Provider) {
+			errs = append(errs, fmt.Errorf("account %d: unknown provider %q, want one of %v", i, a.Provider, provider.Names()))
+		}
+		if a.User == "" || a.Password == "" {
+			errs = append(errs, fmt.Errorf("account %d: missing user or password", i))
+		}
+		if len(a.Meters) == 0 {
+			errs = append(errs, fmt.Errorf("account %d: no meters", i))
+		}
+		for j, m := range a.Meters {
+			if m.MPRN == "" || m.Sensor == "" {
+				errs = append(errs, fmt.Errorf("account %d, meter %d: missing mprn or sensor", i, j))
+			}
+			if mprns[m.MPRN] {
+				errs = append(errs, fmt.Errorf("account %d, meter %d: duplicated mprn %q", i, j, m.MPRN))
+			}
+			mprns[m.MPRN] = true
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	const cfg = `{
+		"home_assistant": {"server": "ha:8123", "token": "tok", "decimals": 3},
+		"accounts": [
+			{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "sensor.home", "lag_sensor": "sensor.lag"}]},
+			{"user": "mum", "password": "pw2", "meters": [
+				{"mprn": "2", "sensor": "sensor.mum"},
+				{"mprn": "3", "sensor": "sensor.mum_flat"}
+			]}
+		]
+	}`
+	got, err := Parse([]byte(cfg))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	decimals := 3
+	want := &Config{
+		HomeAssistant: HomeAssistant{Server: "ha:8123", Token: "tok", Decimals: &decimals},
+		Accounts: []Account{
+			{User: "me", Password: "pw", Meters: []Meter{{MPRN: "1", Sensor: "sensor.home", LagSensor: "sensor.lag"}}},
+			{User: "mum", Password: "pw2", Meters: []Meter{{MPRN: "2", Sensor: "sensor.mum"}, {MPRN: "3", Sensor: "sensor.mum_flat"}}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Parse() unexpected diff (+got -want): %v", diff)
+	}
+	if got := got.HomeAssistant.Precision(); got != 3 {
+		t.Errorf("Precision() = %d, want 3", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  string
+	}{
+		{"not json", `{`},
+		{"unknown field type", `{"accounts": 1}`},
+		{"no accounts", `{"home_assistant": {"server": "ha", "token": "tok"}}`},
+		{"missing ha", `{"accounts": [{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "s"}]}]}`},
+		{"no meters", `{"home_assistant": {"server": "ha", "token": "tok"}, "accounts": [{"user": "me", "password": "pw"}]}`},
+		{"invalid align", `{"home_assistant": {"server": "ha", "token": "tok", "align": "left"}, "accounts": [{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "s"}]}]}`},
+		{"invalid dst_fold", `{"home_assistant": {"server": "ha", "token": "tok"}, "dst_fold": "both", "accounts": [{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "s"}]}]}`},
+		{"invalid duplicates", `{"home_assistant": {"server": "ha", "token": "tok"}, "duplicates": "keep", "accounts": [{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "s"}]}]}`},
+		{"invalid notifications.on", `{"home_assistant": {"server": "ha", "token": "tok"}, "notifications": {"url": "http://hook", "on": "success"}, "accounts": [{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "s"}]}]}`},
+		{"pushover without user", `{"home_assistant": {"server": "ha", "token": "tok"}, "notifications": {"pushover_token": "app"}, "accounts": [{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "s"}]}]}`},
+		{"unknown provider", `{"home_assistant": {"server": "ha", "token": "tok"}, "accounts": [{"provider": "nope", "user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "s"}]}]}`},
+		{"missing sensor", `{"home_assistant": {"server": "ha", "token": "tok"}, "accounts": [{"user": "me", "password": "pw", "meters": [{"mprn": "1"}]}]}`},
+		{"duplicated mprn", `{"home_assistant": {"server": "ha", "token": "tok"}, "accounts": [
+			{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "s"}]},
+			{"user": "you", "password": "pw", "meters": [{"mprn": "1", "sensor": "s2"}]}
+		]}`},
+	}
+	for _, tt := range tests {
+		if got, err := Parse([]byte(tt.cfg)); err == nil {
+			t.Errorf("Parse(%q) = %+v, want error", tt.name, got)
+		}
+	}
+}
+
+func TestParseAddon(t *testing.T) {
+	const opts = `{"accounts": [{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "sensor.home"}]}]}`
+	got, err := ParseAddon([]byte(opts), "supervisor-token")
+	if err != nil {
+		t.Fatalf("ParseAddon() unexpected error: %v", err)
+	}
+	want := &Config{
+		HomeAssistant: HomeAssistant{Server: "supervisor/core", Token: "supervisor-token"},
+		Accounts:      []Account{{User: "me", Password: "pw", Meters: []Meter{{MPRN: "1", Sensor: "sensor.home"}}}},
+		Store:         AddonStore,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseAddon() unexpected diff (+got -want): %v", diff)
+	}
+
+	// An explicit server keeps its token.
+	const remote = `{"home_assistant": {"server": "ha:8123", "token": "tok"}, "accounts": [{"user": "me", "password": "pw", "meters": [{"mprn": "1", "sensor": "s"}]}]}`
+	if got, err := ParseAddon([]byte(remote), "supervisor-token"); err != nil || got.HomeAssistant.Token != "tok" {
+		t.Errorf("ParseAddon(remote) = %+v, %v, want token tok", got, err)
+	}
+	if got, err := ParseAddon([]byte(opts), ""); err == nil {
+		t.Errorf("ParseAddon() without supervisor token = %+v, want error", got)
+	}
+}
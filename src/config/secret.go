@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// secretPrefix marks a secret value encrypted with age.
+//
+// The rest of the value is the base64 of the binary age file, or the
+// ASCII armored age file.
+const secretPrefix = "age:"
+
+// IsEncrypted returns if the configuration contains encrypted secrets.
+func (c *Config) IsEncrypted() bool {
+	for _, s := range c.secrets() {
+		if isEncrypted(*s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decrypt decrypts in place all the encrypted secrets using the identities.
+func (c *Config) Decrypt(identities []age.Identity) error {
+	for _, s := range c.secrets() {
+		if !isEncrypted(*s) {
+			continue
+		}
+		v, err := decryptSecret(*s, identities)
+		if err != nil {
+			return err
+		}
+		*s = v
+	}
+	return nil
+}
+
+// secrets returns pointers to all the fields which can be encrypted.
+func (c *Config) secrets() []*string {
+	ret := []*string{&c.HomeAssistant.Token, &c.Notifications.NtfyToken, &c.Notifications.PushoverToken}
+	for i := range c.Accounts {
+		ret = append(ret, &c.Accounts[i].Password, &c.Accounts[i].TOTPSecret)
+	}
+	return ret
+}
+
+func isEncrypted(s string) bool {
+	return strings.HasPrefix(s, secretPrefix)
+}
+
+func decryptSecret(s string, identities []age.Identity) (string, error) {
+	if len(identities) == 0 {
+		return "", errors.New("the configuration contains encrypted secrets but no age identity is provided")
+	}
+	enc := strings.TrimPrefix(s, secretPrefix)
+
+	var r io.Reader
+	if strings.HasPrefix(enc, armor.Header) {
+		r = armor.NewReader(strings.NewReader(enc))
+	} else {
+		b, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return "", fmt.Errorf("invalid encrypted secret: %w", err)
+		}
+		r = bytes.NewReader(b)
+	}
+
+	d, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt secret: %w", err)
+	}
+	b, err := io.ReadAll(d)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt secret: %w", err)
+	}
+	return string(b), nil
+}
+
+// EncryptSecret encrypts a secret value for the recipients, ready to be
+// put in the configuration file.
+func EncryptSecret(secret string, recipients []age.Recipient) (string, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, secret); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestSecrets(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := func(s string) string {
+		v, err := EncryptSecret(s, []age.Recipient{id.Recipient()})
+		if err != nil {
+			t.Fatalf("EncryptSecret() unexpected error: %v", err)
+		}
+		return v
+	}
+
+	c := &Config{
+		HomeAssistant: HomeAssistant{Server: "ha", Token: enc("tok")},
+		Accounts: []Account{
+			{User: "me", Password: "plain"},
+			{User: "mum", Password: enc("secret")},
+		},
+	}
+	if !c.IsEncrypted() {
+		t.Fatalf("IsEncrypted() = false, want true")
+	}
+
+	if err := c.Decrypt(nil); err == nil {
+		t.Errorf("Decrypt() without identities want error")
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	if err := c.Decrypt([]age.Identity{other}); err == nil {
+		t.Errorf("Decrypt() with the wrong identity want error")
+	}
+
+	if err := c.Decrypt([]age.Identity{id}); err != nil {
+		t.Fatalf("Decrypt() unexpected error: %v", err)
+	}
+	if got := c.HomeAssistant.Token; got != "tok" {
+		t.Errorf("decrypted token = %q, want tok", got)
+	}
+	if got := c.Accounts[0].Password; got != "plain" {
+		t.Errorf("plain password = %q, want plain", got)
+	}
+	if got := c.Accounts[1].Password; got != "secret" {
+		t.Errorf("decrypted password = %q, want secret", got)
+	}
+	if c.IsEncrypted() {
+		t.Errorf("IsEncrypted() after Decrypt() = true, want false")
+	}
+}
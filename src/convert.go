@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/sink"
+)
+
+type convertCmd struct {
+	format string
+	// align is how the half hours are grouped in hours by
+	// -format=statistics, like upload.
+	align string
+	// interval is the period of the statistics of -format=statistics,
+	// upload always uses hours.
+	interval string
+}
+
+func (convertCmd) Name() string { return "convert" }
+
+func (convertCmd) Synopsis() string {
+	return "convert a downloaded ESB file to JSON, hourly CSV, Home Assistant statistics CSV or Green Button XML"
+}
+
+func (convertCmd) Usage() string {
+	return `convert [-format ndjson|json|csv|statistics|greenbutton]
+
+Reads the ESB file from standard input and prints the reads, like
+download with the same -format, so other tools can use the data
+without parsing the ESB file.
+
+-format=csv prints the kWh of every complete hour, with the header
+mprn,start,end,kwh,direction, which spreadsheets and the Home
+Assistant statistics import integrations accept.
+-format=statistics prints the hourly statistics upload sends to Home
+Assistant, with the header timestamp,kwh,cumulative_kwh: the hours
+are grouped as set by -align and the cumulative sum continues across
+the holes, to check an import against a spreadsheet. With
+-interval=half_hour the reads are printed as they are, with
+-interval=day the complete days are; Home Assistant only imports hours.
+-format=greenbutton prints a Green Button (ESPI) Atom feed with the
+half hours, like the "Download My Data" files of many utilities.
+
+`
+}
+
+func (c *convertCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.format, "format", "json", "the output format, ndjson, json, csv, statistics or greenbutton")
+	fs.StringVar(&c.align, "align", "center", "with -format=statistics, how to group the half hours in hours: center, to match the ESB graph, or clock, to match bills")
+	fs.StringVar(&c.interval, "interval", "hour", "with -format=statistics, the period of the statistics: half_hour, hour or day")
+}
+
+func (c *convertCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	var out sink.Sink
+	switch c.format {
+	case "ndjson":
+		out = sink.NewNDJSON(os.Stdout)
+	case "json":
+		out = sink.NewJSON(os.Stdout)
+	case "csv":
+		out = sink.NewHourlyCSV(os.Stdout)
+	case "statistics":
+		align, err := parse.ParseAlignment(c.align)
+		if err != nil {
+			slog.Error(err.Error())
+			return subcommands.ExitUsageError
+		}
+		interval, err := parse.ParseInterval(c.interval)
+		if err != nil {
+			slog.Error(err.Error())
+			return subcommands.ExitUsageError
+		}
+		out = sink.NewStatisticsCSV(os.Stdout, parse.Options{Align: align, Interval: interval})
+	case "greenbutton":
+		out = sink.NewGreenButton(os.Stdout)
+	default:
+		slog.Error("unknown format", "format", c.format)
+		return subcommands.ExitUsageError
+	}
+
+	parsed, err := readHDF(os.Stdin)
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	for _, r := range parsed {
+		if err := out.Write(ctx, r); err != nil {
+			slog.Error("cannot write power consumption data", "err", err)
+			return subcommands.ExitFailure
+		}
+	}
+	if err := out.Close(); err != nil {
+		slog.Error("cannot write power consumption data", "err", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"text/template"
+
+	"github.com/google/subcommands"
+)
+
+type dashboardCmd struct {
+	title                              string
+	sensor, costSensor                 string
+	daySensor, nightSensor, peakSensor string
+	days                               int
+}
+
+func (dashboardCmd) Name() string { return "dashboard" }
+
+func (dashboardCmd) Synopsis() string {
+	return "print a Home Assistant dashboard view for the statistics uploaded by esb2ha"
+}
+
+func (dashboardCmd) Usage() string {
+	return `dashboard <flags>
+
+Prints the YAML of a Lovelace view with the energy cards and the
+statistics graphs of the uploaded sensors. Paste it in the raw
+configuration editor of a dashboard, under "views:".
+
+The energy cards show the sensors configured in the energy dashboard.
+
+`
+}
+
+func (c *dashboardCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.sensor, "ha_sensor", "", "Home Assistant sensor ID used to record power usage")
+	optionalStringVar(fs, &c.costSensor, "ha_cost_sensor", "", "Home Assistant sensor ID used to record the cost")
+	optionalStringVar(fs, &c.daySensor, "ha_day_sensor", "", "Home Assistant statistic ID of the energy used at the day rate, like a utility meter with tariffs")
+	optionalStringVar(fs, &c.nightSensor, "ha_night_sensor", "", "Home Assistant statistic ID of the energy used at the night rate")
+	optionalStringVar(fs, &c.peakSensor, "ha_peak_sensor", "", "Home Assistant statistic ID of the energy used at the peak rate")
+	fs.StringVar(&c.title, "title", "Electricity", "the title of the view")
+	fs.IntVar(&c.days, "days", 30, "how many days the graphs show")
+}
+
+func (c *dashboardCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if err := c.write(os.Stdout); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// tariffSensor is a statistic of the energy used at a rate.
+type tariffSensor struct {
+	Name, ID string
+}
+
+func (c *dashboardCmd) write(w io.Writer) error {
+	var tariffs []tariffSensor
+	for _, t := range []tariffSensor{{"Day", c.daySensor}, {"Night", c.nightSensor}, {"Peak", c.peakSensor}} {
+		if t.ID != "" {
+			tariffs = append(tariffs, t)
+		}
+	}
+	return dashboardTemplate.Execute(w, struct {
+		Title, Sensor, CostSensor string
+		Days                      int
+		Tariffs                   []tariffSensor
+	}{c.title, c.sensor, c.costSensor, c.days, tariffs})
+}
+
+// dashboardTemplate is the Lovelace view.
+//
+// Strings are quoted as JSON, which is valid YAML.
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"quote": func(s string) (string, error) {
+		b, err := json.Marshal(s)
+		return string(b), err
+	},
+}).Parse(`- title: {{quote .Title}}
+  path: esb2ha
+  cards:
+    - type: energy-date-selection
+    - type: energy-usage-graph
+    - type: statistics-graph
+      title: Daily usage
+      chart_type: bar
+      period: day
+      days_to_show: {{.Days}}
+      stat_types:
+        - change
+      entities:
+        - entity: {{quote .Sensor}}
+          name: Usage
+    - type: statistics-graph
+      title: Monthly usage
+      chart_type: bar
+      period: month
+      days_to_show: 365
+      stat_types:
+        - change
+      entities:
+        - entity: {{quote .Sensor}}
+          name: Usage
+{{- if .Tariffs}}
+    - type: statistics-graph
+      title: Day, night and peak
+      chart_type: bar
+      period: day
+      days_to_show: {{.Days}}
+      stat_types:
+        - change
+      entities:
+{{- range .Tariffs}}
+        - entity: {{quote .ID}}
+          name: {{.Name}}
+{{- end}}
+{{- end}}
+{{- if .CostSensor}}
+    - type: statistics-graph
+      title: Daily cost
+      chart_type: bar
+      period: day
+      days_to_show: {{.Days}}
+      stat_types:
+        - change
+      entities:
+        - entity: {{quote .CostSensor}}
+          name: Cost
+{{- end}}
+`))
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.yaml.in/yaml/v3"
+)
+
+func TestDashboard(t *testing.T) {
+	type card struct {
+		Type     string `yaml:"type"`
+		Title    string `yaml:"title"`
+		Days     int    `yaml:"days_to_show"`
+		Entities []struct {
+			Entity string `yaml:"entity"`
+			Name   string `yaml:"name"`
+		} `yaml:"entities"`
+	}
+	type view struct {
+		Title string `yaml:"title"`
+		Cards []card `yaml:"cards"`
+	}
+	// titles returns the title, or the type, of the cards and their
+	// entities.
+	titles := func(v view) []string {
+		var ret []string
+		for _, c := range v.Cards {
+			s := c.Type
+			if c.Title != "" {
+				s = c.Title
+			}
+			for _, e := range c.Entities {
+				s += " " + e.Name + "=" + e.Entity
+			}
+			ret = append(ret, s)
+		}
+		return ret
+	}
+
+	tests := []struct {
+		name string
+		cmd  dashboardCmd
+		want []string
+	}{
+		{
+			name: "energy only",
+			cmd:  dashboardCmd{title: "Electricity", sensor: "sensor.esb", days: 30},
+			want: []string{
+				"energy-date-selection",
+				"energy-usage-graph",
+				"Daily usage Usage=sensor.esb",
+				"Monthly usage Usage=sensor.esb",
+			},
+		},
+		{
+			name: "tariffs and cost",
+			cmd: dashboardCmd{
+				title: "Home: \"main\"", sensor: "sensor.esb", costSensor: "sensor.esb_cost",
+				daySensor: "sensor.day", peakSensor: "sensor.peak", days: 7,
+			},
+			want: []string{
+				"energy-date-selection",
+				"energy-usage-graph",
+				"Daily usage Usage=sensor.esb",
+				"Monthly usage Usage=sensor.esb",
+				"Day, night and peak Day=sensor.day Peak=sensor.peak",
+				"Daily cost Cost=sensor.esb_cost",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := tt.cmd.write(&b); err != nil {
+				t.Fatalf("write() unexpected error: %v", err)
+			}
+			var views []view
+			if err := yaml.Unmarshal([]byte(b.String()), &views); err != nil {
+				t.Fatalf("write() = %q, not valid YAML: %v", b.String(), err)
+			}
+			if len(views) != 1 {
+				t.Fatalf("write() returned %d views, want 1", len(views))
+			}
+			if views[0].Title != tt.cmd.title {
+				t.Errorf("write() title = %q, want %q", views[0].Title, tt.cmd.title)
+			}
+			if diff := cmp.Diff(tt.want, titles(views[0])); diff != "" {
+				t.Errorf("write() unexpected diff (+got -want): %v", diff)
+			}
+			if got := views[0].Cards[2].Days; got != tt.cmd.days {
+				t.Errorf("write() days_to_show = %d, want %d", got, tt.cmd.days)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/weather"
+)
+
+type degreeDaysCmd struct {
+	lat, lon, baseC float64
+}
+
+func (degreeDaysCmd) Name() string { return "degreedays" }
+
+func (degreeDaysCmd) Synopsis() string {
+	return "report how the electricity usage correlates with the heating degree days"
+}
+
+func (degreeDaysCmd) Usage() string {
+	return `degreedays <flags>
+
+The CSV file is read from standard input.
+
+The daily mean temperatures are downloaded from Open-Meteo for the
+location of the meter. For every complete day the report shows the
+consumption and the heating degree days (how many degrees the mean
+temperature is below -base_temp), then fits a line through them:
+the intercept is the consumption not related to heating, the slope
+how many kWh each degree day costs. Comparing the slope across
+winters is a way to judge the efficiency of a heat pump.
+
+`
+}
+
+func (c *degreeDaysCmd) SetFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&c.lat, "latitude", 0, "the latitude of the meter")
+	fs.Float64Var(&c.lon, "longitude", 0, "the longitude of the meter")
+	fs.Float64Var(&c.baseC, "base_temp", 15.5, "the base temperature in Celsius of the heating degree days")
+}
+
+func (c *degreeDaysCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if c.lat == 0 && c.lon == 0 {
+		slog.Error("-latitude and -longitude are required")
+		return subcommands.ExitUsageError
+	}
+
+	slog.Info("Reading from stdin...")
+
+	if err := c.report(ctx, weather.NewClient(), os.Stdin, os.Stdout); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *degreeDaysCmd) report(ctx context.Context, wc *weather.Client, data io.Reader, out io.Writer) error {
+	parsed, err := readHDF(data)
+	if err != nil {
+		return err
+	}
+	var days []parse.DailyTotal
+	for _, d := range parse.Daily(parsed) {
+		if d.Complete() {
+			days = append(days, d)
+		}
+	}
+	if len(days) == 0 {
+		return errors.New("no complete day in the data")
+	}
+
+	temps, err := wc.Daily(ctx, c.lat, c.lon, days[0].Date, days[len(days)-1].Date)
+	if err != nil {
+		return fmt.Errorf("cannot download the temperatures: %w", err)
+	}
+	byDate := make(map[string]weather.Day, len(temps))
+	for _, t := range temps {
+		byDate[t.Date.Format("2006-01-02")] = t
+	}
+
+	var hdds, kwhs []float64
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "DATE\tKWH\tMEAN °C\tHDD\t")
+	for _, d := range days {
+		date := d.Date.Format("2006-01-02")
+		t, ok := byDate[date]
+		if !ok {
+			fmt.Fprintf(w, "%s\t%.3f\t-\t-\t\n", date, d.KWh)
+			continue
+		}
+		hdd := t.HDD(c.baseC)
+		hdds, kwhs = append(hdds, hdd), append(kwhs, d.KWh)
+		fmt.Fprintf(w, "%s\t%.3f\t%.1f\t%.1f\t\n", date, d.KWh, t.MeanC, hdd)
+	}
+	w.Flush()
+
+	fit, ok := weather.LinearFit(hdds, kwhs)
+	if !ok {
+		fmt.Fprintln(out, "\nNot enough days with different temperatures to compute the correlation")
+		return nil
+	}
+	fmt.Fprintf(out, "\n%d days, base temperature %.1f °C\n", len(hdds), c.baseC)
+	fmt.Fprintf(out, "Base load:   %.3f kWh/day\n", fit.BaseKWh)
+	fmt.Fprintf(out, "Heating:     %.3f kWh per degree day\n", fit.KWhPerHDD)
+	fmt.Fprintf(out, "Correlation: %.2f (R²=%.2f)\n", fit.R, fit.R*fit.R)
+	return nil
+}
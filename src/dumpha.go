@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+type dumpHACmd struct {
+	server, token, sensor string
+	mprn, serial          string
+	period                period
+}
+
+func (dumpHACmd) Name() string { return "dump-ha" }
+
+func (dumpHACmd) Synopsis() string {
+	return "export the statistics from Home Assistant as an ESB CSV file"
+}
+
+func (dumpHACmd) Usage() string {
+	return `dump-ha <flags>
+
+All the non optional flags are required, but can be provided as environment variables as well.
+The CSV file is printed on standard output.
+
+Reads the hourly statistics uploaded by esb2ha back from Home Assistant
+and writes them in the same format of the file downloaded from ESB, so
+they can be uploaded to another Home Assistant instance, or kept after
+ESB stops serving them.
+Home Assistant only stores hourly values: each hour is split in two half
+hours with the same consumption.
+
+`
+}
+
+func (c *dumpHACmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.server, "ha_server", "", "Home Assistant server name or IP and optionally the port")
+	secretStringVar(fs, &c.token, "ha_token", "Home Assistant admin authentication token")
+	fs.StringVar(&c.sensor, "ha_sensor", "", "Home Assistant sensor ID used to record power usage")
+	fs.StringVar(&c.mprn, "mprn", "", "the mprn number to write in the file")
+	optionalStringVar(fs, &c.serial, "meter_serial_number", "", "the meter serial number to write in the file")
+	optionalStringVar(fs, &c.period.from, "from", "", "the first day to export, as YYYY-MM-DD, from the beginning if not set")
+	optionalStringVar(fs, &c.period.to, "to", "", "the last day to export, as YYYY-MM-DD, until now if not set")
+}
+
+func (c *dumpHACmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	from, to, err := c.times(time.Now())
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	if err := c.dump(ctx, os.Stdout, from, to); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// times returns the period to export, from the beginning until now
+// if not set.
+func (c *dumpHACmd) times(now time.Time) (from, to time.Time, err error) {
+	if from, to, err = c.period.times(); err != nil {
+		return from, to, err
+	}
+	if from.IsZero() {
+		from = time.Unix(0, 0)
+	}
+	if to.IsZero() {
+		to = now
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("-from must be before -to")
+	}
+	return from, to, nil
+}
+
+func (c *dumpHACmd) dump(ctx context.Context, out io.Writer, from, to time.Time) error {
+	conn, err := ha.NewConnection(ctx, c.server, c.token)
+	if err != nil {
+		return fmt.Errorf("cannot connect to Home Assistant: %w", err)
+	}
+	defer conn.Close()
+
+	// Statistics are read a year at a time, from the newest, to keep the
+	// memory bounded and write them in the same order of ESB files.
+	hw, err := parse.NewHDFWriter(out)
+	if err != nil {
+		return err
+	}
+	hours := 0
+	for end := to; end.After(from); end = end.AddDate(-1, 0, 0) {
+		start := end.AddDate(-1, 0, 0)
+		if start.Before(from) {
+			start = from
+		}
+		stats, err := conn.Statistics(ctx, c.sensor, start, end)
+		if err != nil {
+			return fmt.Errorf("cannot read statistics from Home Assistant: %w", err)
+		}
+		if err := hw.Write(parse.FromStatistics(c.mprn, c.serial, stats)); err != nil {
+			return err
+		}
+		hours += len(stats)
+	}
+	if err := hw.Flush(); err != nil {
+		return err
+	}
+	if hours == 0 {
+		return fmt.Errorf("no statistics for %s in Home Assistant", c.sensor)
+	}
+	slog.Info("Exported the statistics", "hours", hours)
+	return nil
+}
@@ -1,24 +1,61 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/subcommands"
-	"github.com/lorentz83/esb2ha/esblib"
 	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/keyring"
 	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/prices"
+	"github.com/lorentz83/esb2ha/provider"
+	"github.com/lorentz83/esb2ha/sink"
+	"github.com/lorentz83/esb2ha/store"
+	"github.com/lorentz83/esb2ha/tariff"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func init() {
-	subcommands.Register(&downloadCmd{}, "")
-	subcommands.Register(&uploadCmd{}, "")
+	subcommands.Register(&downloadFileCmd{}, "")
+	subcommands.Register(&convertCmd{}, "")
+	subcommands.Register(&uploadFileCmd{}, "")
 	subcommands.Register(&pipeCmd{}, "")
+	subcommands.Register(&checkCmd{}, "")
+	subcommands.Register(&serveCmd{}, "")
+	subcommands.Register(&webhookCmd{}, "")
+	subcommands.Register(&publishCmd{}, "")
+	subcommands.Register(&validateCmd{}, "")
+	subcommands.Register(&syncCmd{}, "")
+	subcommands.Register(&addonCmd{}, "")
+	subcommands.Register(&encryptCmd{}, "")
+	subcommands.Register(&authCmd{}, "")
+	subcommands.Register(&runsCmd{}, "")
+	subcommands.Register(&degreeDaysCmd{}, "")
+	subcommands.Register(&dumpHACmd{}, "")
+	subcommands.Register(&historyCmd{}, "")
+	subcommands.Register(&reimportCmd{}, "")
+	subcommands.Register(&resyncCmd{}, "")
+	subcommands.Register(&gapsCmd{}, "")
+	subcommands.Register(&pruneCmd{}, "")
+	subcommands.Register(&billingCmd{}, "")
+	subcommands.Register(&reportCmd{}, "")
+	subcommands.Register(&dashboardCmd{}, "")
 	subcommands.Register(subcommands.HelpCommand(), "")
 	baseExplain := subcommands.DefaultCommander.Explain
 	subcommands.DefaultCommander.Explain = func(w io.Writer) {
@@ -29,8 +66,73 @@ func init() {
 }
 
 func main() {
+	memoryLimitMB := flag.Int("memory_limit_mb", 0, "soft limit of the memory used by esb2ha, 0 means no limit")
+	readTypes := flag.String("read_types", "warn", "what to do with the lines of the ESB file with an unknown read type: strict, to fail, warn, to skip them, or collect, to also return them as they are from the parse APIs of serve")
+	rateReads := flag.String("rates", "merge", "what to do with the reads of the meters which split the consumption by rate (day, peak and night) in the parse APIs of serve: merge, in one series, or split, a series per rate")
+	fold := flag.String("dst_fold", "sum", "what to do with the hour repeated when the clocks go back: sum, to keep both, first or last, to keep only one")
+	duplicates := flag.String("duplicates", "keep-first", "what to do with the reads repeated in the ESB file with the same time: keep-first or keep-last, in the order of the file, or error, to fail")
+	haTLS := flag.Bool("ha_tls", false, "connect to Home Assistant with wss and https, like when -ha_server starts with https://")
+	haCACert := flag.String("ha_ca_cert", "", "PEM file with the CA certificate of Home Assistant, like for a self-signed certificate")
+	haInsecure := flag.Bool("ha_insecure_skip_verify", false, "accept any certificate from Home Assistant, only for testing")
+	verbose := flag.Bool("v", false, "log also the debug messages, like the phases of the login")
+	quiet := flag.Bool("quiet", false, "log only the warnings and the errors")
+	logFormat := flag.String("log_format", "text", "the format of the logs on standard error: text or json, one object per line")
+	flag.StringVar(&archiveDir, "archive_dir", "", "the directory where to keep every downloaded file verbatim, named after the meter and the time of the download, to upload it again if the parser fails")
+	flag.BoolVar(&archiveGzip, "archive_gzip", false, "compress the files saved in -archive_dir with gzip")
+	flag.BoolVar(&useKeyring, "use_keyring", false, "read the passwords and the tokens not set by flags, environment variables or files from the keyring of the operating system, see auth")
+	showProgress := flag.Bool("progress", true, "draw a progress bar of the downloads and of the uploads on standard error, when it is a terminal and the logs are text")
 	flag.Parse()
-	s := subcommands.Execute(context.Background())
+	if err := setupLogging(*verbose, *quiet, *logFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(int(subcommands.ExitUsageError))
+	}
+	setupProgress(*showProgress && !*quiet && *logFormat == "text")
+	var err error
+	ha.TLS = *haTLS
+	if *haCACert != "" || *haInsecure {
+		if ha.TLSConfig, err = ha.NewTLSConfig(*haCACert, *haInsecure); err != nil {
+			slog.Error("cannot read -ha_ca_cert", "err", err)
+			os.Exit(int(subcommands.ExitUsageError))
+		}
+	}
+	if readTypePolicy, err = parse.ParseReadTypePolicy(*readTypes); err != nil {
+		slog.Error(err.Error())
+		os.Exit(int(subcommands.ExitUsageError))
+	}
+	if rates, err = parse.ParseRates(*rateReads); err != nil {
+		slog.Error(err.Error())
+		os.Exit(int(subcommands.ExitUsageError))
+	}
+	if dstFold, err = parse.ParseDSTFold(*fold); err != nil {
+		slog.Error(err.Error())
+		os.Exit(int(subcommands.ExitUsageError))
+	}
+	if duplicatePolicy, err = parse.ParseDuplicatePolicy(*duplicates); err != nil {
+		slog.Error(err.Error())
+		os.Exit(int(subcommands.ExitUsageError))
+	}
+	if archiveDir != "" {
+		if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+			slog.Error("cannot create -archive_dir", "err", err)
+			os.Exit(int(subcommands.ExitUsageError))
+		}
+	}
+	if *memoryLimitMB > 0 {
+		debug.SetMemoryLimit(int64(*memoryLimitMB) << 20)
+	}
+	ctx := context.Background()
+
+	shutdown, err := setupTelemetry(ctx)
+	if err != nil {
+		slog.Error("cannot set up OpenTelemetry", "err", err)
+		os.Exit(int(subcommands.ExitFailure))
+	}
+
+	s := subcommands.Execute(ctx)
+	progress.clear()
+	if err := shutdown(ctx); err != nil {
+		slog.Error("cannot flush OpenTelemetry data", "err", err)
+	}
 	os.Exit(int(s))
 }
 
@@ -45,13 +147,102 @@ func flagsFromEnv(f *flag.FlagSet) {
 	})
 }
 
+// optionalFlags contains the name of the flags which can be left empty.
+var optionalFlags = map[string]bool{}
+
+// optionalStringVar defines a string flag which is not required.
+func optionalStringVar(fs *flag.FlagSet, p *string, name string, value string, usage string) {
+	optionalFlags[name] = true
+	fs.StringVar(p, name, value, usage+" (optional)")
+}
+
+// secretFlags contains the name of the flags which can be read from a
+// file, see secretStringVar.
+var secretFlags = map[string]bool{}
+
+// secretStringVar defines a required string flag for a password or a
+// token, with a second flag with the "_file" suffix to read it from a
+// file, like a Docker or Kubernetes secret, instead of passing it on the
+// command line where it shows in ps and in the shell history.
+func secretStringVar(fs *flag.FlagSet, p *string, name string, usage string) {
+	secretFlags[name] = true
+	fs.StringVar(p, name, "", usage)
+	optionalFlags[name+"_file"] = true
+	fs.String(name+"_file", "", "the file containing -"+name+", instead of the flag (optional)")
+}
+
+// optionalSecretStringVar is like secretStringVar, but the flag is not
+// required.
+func optionalSecretStringVar(fs *flag.FlagSet, p *string, name string, usage string) {
+	secretStringVar(fs, p, name, usage+" (optional)")
+	optionalFlags[name] = true
+}
+
+// secretsFromFiles sets the secret flags from their files.
+func secretsFromFiles(f *flag.FlagSet) error {
+	var errs []error
+	f.VisitAll(func(s *flag.Flag) {
+		path := f.Lookup(s.Name + "_file")
+		if !secretFlags[s.Name] || path == nil || path.Value.String() == "" {
+			return
+		}
+		if s.Value.String() != "" {
+			errs = append(errs, fmt.Errorf("both -%s and -%s_file are set", s.Name, s.Name))
+			return
+		}
+		data, err := os.ReadFile(path.Value.String())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot read -%s_file: %w", s.Name, err))
+			return
+		}
+		// Editors and echo add a newline at the end.
+		errs = append(errs, s.Value.Set(strings.TrimRight(string(data), "\r\n")))
+	})
+	return errors.Join(errs...)
+}
+
+// useKeyring is set by -use_keyring, see secretsFromKeyring.
+var useKeyring bool
+
+// secretsFromKeyring sets the secret flags still unset from the keyring,
+// where auth stores them with the name of the flag.
+func secretsFromKeyring(f *flag.FlagSet) error {
+	var errs []error
+	f.VisitAll(func(s *flag.Flag) {
+		if !secretFlags[s.Name] || s.Value.String() != "" {
+			return
+		}
+		secret, err := keyring.Get(keyring.Service, s.Name)
+		if errors.Is(err, keyring.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot read -%s: %w", s.Name, err))
+			return
+		}
+		errs = append(errs, s.Value.Set(secret))
+	})
+	return errors.Join(errs...)
+}
+
 // ensureFlagsAreSet checks if there are environment variables for the unset flag
 // and returns an error for the missing flags.
+//
+// The secret flags are read from their files too, and from the keyring
+// with -use_keyring.
 func ensureFlagsAreSet(f *flag.FlagSet) error {
 	flagsFromEnv(f)
+	if err := secretsFromFiles(f); err != nil {
+		return err
+	}
+	if useKeyring {
+		if err := secretsFromKeyring(f); err != nil {
+			return err
+		}
+	}
 	var missing []string
 	f.VisitAll(func(f *flag.Flag) {
-		if f.Value.String() == "" {
+		if f.Value.String() == "" && !optionalFlags[f.Name] {
 			missing = append(missing, f.Name)
 		}
 	})
@@ -62,7 +253,53 @@ func ensureFlagsAreSet(f *flag.FlagSet) error {
 }
 
 type downloadCmd struct {
+	// mprn can be a comma separated list, see mprns.
 	user, password, mprn string
+	// totpSecret generates the one time codes of the multi-factor
+	// authentication, see oneTimeCode.
+	totpSecret string
+	// provider is the name of the website to download from, see provider.New.
+	provider string
+	// session, if set, is used instead of logging in, to download more
+	// meters with the same login.
+	session provider.Provider
+	// sessionFile keeps the login between runs, see newLogin. resumed
+	// is set when the login was read from there.
+	sessionFile string
+	resumed     bool
+	// period limits the download, when the provider supports it, see
+	// openMPRN. The flags are set by the commands using it.
+	period period
+}
+
+// mprns returns the meters to download.
+func (c *downloadCmd) mprns() []string {
+	var ret []string
+	for _, m := range strings.Split(c.mprn, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// listFlag is a string flag which can be repeated, the values are
+// joined with commas. An empty value clears the list.
+type listFlag struct{ p *string }
+
+func (l listFlag) String() string {
+	if l.p == nil {
+		return ""
+	}
+	return *l.p
+}
+
+func (l listFlag) Set(v string) error {
+	if *l.p != "" && v != "" {
+		v = *l.p + "," + v
+	}
+	*l.p = v
+	return nil
 }
 
 func (downloadCmd) Name() string { return "download" }
@@ -75,59 +312,358 @@ func (downloadCmd) Usage() string {
 	return `download <flags>
 
 All the flags are required, but can be provided as environment variables as well.
-The file is printed on standard error.
+The file is printed on standard output.
+
+-mprn can be a comma separated list, or repeated, to download more
+meters of the same account with one login, one file after the other.
+
+With -format=ndjson the reads are parsed and printed as newline
+delimited JSON instead, one half an hour interval per line, as
+documented in documentation/interval.schema.json. -format=json prints
+the same intervals as a single JSON array.
 
 `
 }
 
 func (c *downloadCmd) SetFlags(fs *flag.FlagSet) {
 	fs.StringVar(&c.user, "esb_user", "", "the user name on esbnetworks.ie")
-	fs.StringVar(&c.password, "esb_password", "", "the user name on esbnetworks.ie")
-	fs.StringVar(&c.mprn, "mprn", "", "the mprn number on the electricity bill")
+	secretStringVar(fs, &c.password, "esb_password", "the password on esbnetworks.ie")
+	optionalSecretStringVar(fs, &c.totpSecret, "esb_totp_secret", "the secret of the authenticator app, when the account has multi-factor authentication")
+	fs.Var(listFlag{&c.mprn}, "mprn", "the mprn number on the electricity bill, repeated or comma separated for more meters of the same account")
+	fs.StringVar(&c.provider, "provider", provider.Default, "the website to download the data from, one of "+strings.Join(provider.Names(), ", "))
+	optionalStringVar(fs, &c.sessionFile, "esb_session_file", "", "the file where to keep the login between runs, encrypted with the password, to log in again only when it expires")
+}
+
+// downloadFileCmd is the download command, the flags which only make
+// sense when printing the file are kept out of downloadCmd, which is
+// reused by the other commands.
+type downloadFileCmd struct {
+	downloadCmd
+	format string
+	// out receives the parsed reads, unless the format is csv.
+	out sink.Sink
 }
 
-func (c *downloadCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+func (c *downloadFileCmd) SetFlags(fs *flag.FlagSet) {
+	c.downloadCmd.SetFlags(fs)
+	c.period.SetFlags(fs)
+	fs.StringVar(&c.format, "format", "csv", "the output format, csv, ndjson or json")
+}
+
+func (c *downloadFileCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
 	if err := ensureFlagsAreSet(f); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		slog.Error(err.Error())
 		return subcommands.ExitUsageError
 	}
-
-	data, err := c.download()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %s", err)
-		return subcommands.ExitFailure
+	switch c.format {
+	case "csv":
+	case "ndjson":
+		c.out = sink.NewNDJSON(os.Stdout)
+	case "json":
+		c.out = sink.NewJSON(os.Stdout)
+	default:
+		slog.Error("unknown format", "format", c.format)
+		return subcommands.ExitUsageError
+	}
+	if _, _, err := c.period.times(); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
 	}
 
-	if _, err := io.Copy(os.Stdout, bytes.NewReader(data)); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: Cannot write power consumption data: %v\n", err)
-		return subcommands.ExitFailure
+	mprns := c.mprns()
+	if len(mprns) > 1 {
+		p, err := c.login(ctx)
+		if err != nil {
+			slog.Error(err.Error())
+			return subcommands.ExitFailure
+		}
+		c.session = p
+	}
+	for i, mprn := range mprns {
+		esb := c.downloadCmd
+		esb.mprn = mprn
+		body, err := esb.open(ctx)
+		c.nextMeter(esb)
+		if err != nil {
+			slog.Error("cannot download", "mprn", mprn, "err", err)
+			return subcommands.ExitFailure
+		}
+		err = c.write(ctx, body, i == 0)
+		body.Close()
+		if err != nil {
+			slog.Error(err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+	if c.out != nil {
+		if err := c.out.Close(); err != nil {
+			slog.Error("cannot write power consumption data", "err", err)
+			return subcommands.ExitFailure
+		}
 	}
+	return subcommands.ExitSuccess
+}
 
+// write prints the file while it is downloaded, the header only if
+// first, so that the files of more meters make a single HDF file.
+//
+// With -from or -to the file is parsed, to drop the reads out of the
+// period, and written again.
+func (c *downloadFileCmd) write(ctx context.Context, body io.Reader, first bool) error {
+	if c.out != nil {
+		return writeParsed(ctx, c.out, body, c.period)
+	}
+	if c.period.set() {
+		parsed, err := readAllHDF(body)
+		if err != nil {
+			return err
+		}
+		if parsed, err = c.period.filter(parsed); err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := parse.WriteHDF(&buf, parsed); err != nil {
+			return err
+		}
+		body = &buf
+	}
+	r := bufio.NewReader(body)
+	if !first {
+		if _, err := r.ReadString('\n'); err != nil && err != io.EOF {
+			return fmt.Errorf("cannot download power consumption data: %w", err)
+		}
+	}
+	w := &lastByteWriter{w: os.Stdout}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("cannot download power consumption data: %w", err)
+	}
 	// The file doesn't have a newline at the end.
-	fmt.Fprintln(os.Stdout)
+	if w.last != '\n' {
+		fmt.Fprintln(os.Stdout)
+	}
+	return nil
+}
 
-	return subcommands.ExitSuccess
+// lastByteWriter remembers the last byte written.
+type lastByteWriter struct {
+	w    io.Writer
+	last byte
+}
+
+func (l *lastByteWriter) Write(p []byte) (int, error) {
+	n, err := l.w.Write(p)
+	if n > 0 {
+		l.last = p[n-1]
+	}
+	return n, err
 }
 
-func (c *downloadCmd) download() ([]byte, error) {
-	e, err := esblib.NewClient()
+// writeParsed parses the HDF file while it is read and writes the reads
+// in the period to the sink, without closing it.
+func writeParsed(ctx context.Context, s sink.Sink, data io.Reader, p period) error {
+	parsed, err := readHDF(data)
 	if err != nil {
-		return nil, fmt.Errorf("cannot connect to ESB website: %w", err)
+		return err
+	}
+	if parsed, err = p.filter(parsed); err != nil {
+		return err
+	}
+	for _, r := range parsed {
+		if err := s.Write(ctx, r); err != nil {
+			return fmt.Errorf("cannot write power consumption data: %w", err)
+		}
+	}
+	return nil
+}
+
+// open downloads the power consumption data, streaming it.
+//
+// The caller must close the returned reader.
+func (c *downloadCmd) open(ctx context.Context) (io.ReadCloser, error) {
+	e, err := c.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := openMPRN(ctx, e, c.mprn, c.period)
+	if c.resumed && errors.Is(err, provider.ErrLoginExpired) {
+		slog.Info("The saved login expired, logging in again")
+		// Without the file, newLogin logs in and saves the new login.
+		os.Remove(c.sessionFile)
+		shared := c.session != nil
+		c.session, c.resumed = nil, false
+		if e, err = c.login(ctx); err != nil {
+			return nil, err
+		}
+		if shared {
+			// The other meters use the new login too, see nextMeter.
+			c.session = e
+		}
+		body, err = openMPRN(ctx, e, c.mprn, c.period)
 	}
+	return body, err
+}
+
+// nextMeter keeps the login of the download of another meter, in case
+// it logged in again.
+func (c *downloadCmd) nextMeter(other downloadCmd) {
+	c.session, c.resumed = other.session, other.resumed
+}
 
-	if err := e.Login(c.user, c.password); err != nil {
+// login returns session, if set, or logs in.
+func (c *downloadCmd) login(ctx context.Context) (provider.Provider, error) {
+	if c.session != nil {
+		return c.session, nil
+	}
+	p, resumed, err := newLogin(ctx, c.provider, c.user, c.password, c.totpSecret, c.sessionFile)
+	if err != nil {
 		return nil, fmt.Errorf("cannot login: %w", err)
 	}
+	c.resumed = resumed
+	return p, nil
+}
+
+// warnRetry reports the transient errors of the provider, which are
+// retried.
+func warnRetry(err error, wait time.Duration) {
+	slog.Warn("retrying", "err", err, "wait", wait.Round(time.Second))
+}
+
+// spanReader ends the span when closed, with the first read error if any.
+type spanReader struct {
+	io.ReadCloser
+	end func(error) error
+	err error
+}
+
+func (r *spanReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
 
-	data, err := e.DownloadPowerConsumption(c.mprn, esblib.FormatIntervalKW)
+func (r *spanReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.end(r.err)
+	return err
+}
+
+// openMPRN streams the data of a meter with an already logged in
+// provider.
+//
+// Only the providers implementing provider.RangeDownloader download
+// just the period, the others download everything and the caller has
+// to filter the reads.
+//
+// The caller must close the returned reader.
+func openMPRN(ctx context.Context, p provider.Provider, mprn string, per period) (io.ReadCloser, error) {
+	ctx, end := startSpan(ctx, "download", attribute.String("mprn", mprn))
+	var (
+		body io.ReadCloser
+		err  error
+	)
+	if r, ok := p.(provider.RangeDownloader); ok && per.set() {
+		var from, to time.Time
+		if from, to, err = per.times(); err == nil {
+			body, err = r.DownloadIntervalRange(ctx, mprn, from, to)
+		}
+	} else {
+		body, err = p.DownloadInterval(ctx, mprn)
+	}
 	if err != nil {
+		end(err)
 		return nil, fmt.Errorf("cannot download power consumption data: %w", err)
 	}
-	return data, nil
+	if archiveDir != "" {
+		if body, err = archive(body, mprn, time.Now()); err != nil {
+			end(err)
+			return nil, err
+		}
+	}
+	return &spanReader{ReadCloser: body, end: end}, nil
 }
 
 type uploadCmd struct {
 	server, token, sensor string
+
+	// storePath is the local database where uploads are recorded, optional.
+	storePath string
+	// resumeAfter is the last hour sent by an interrupted upload.
+	resumeAfter time.Time
+	// forceUpload sends also the hours already uploaded.
+	forceUpload bool
+	// missingOnly sends only the hours after the last one in Home Assistant.
+	missingOnly bool
+	// previewDiff prints how the upload would change Home Assistant
+	// instead of uploading.
+	previewDiff bool
+
+	// align is how the half hours are grouped in hours, see parse.ParseAlignment.
+	align string
+	// fillGaps is the longest gap filled with estimated reads, see parse.FillGaps.
+	fillGaps time.Duration
+	// meterState sends the cumulative energy as state, see parse.MeterReadings.
+	meterState bool
+	// haTimezone sends the times in the timezone of Home Assistant,
+	// haLocation once read.
+	haTimezone bool
+	haLocation *time.Location
+	// external uploads external statistics, with externalSource, instead
+	// of the statistics of the sensors.
+	external bool
+	// statName is the friendly name of the statistics, optional.
+	statName string
+	// checked is the sensor whose statistics were checked by
+	// checkStatistics.
+	checked string
+	// precision is the number of decimals of the uploaded values,
+	// negative to keep them as they are.
+	precision int
+
+	// lagSensor is the Home Assistant entity reporting the data lag, optional.
+	lagSensor string
+	// statusSensor is the Home Assistant entity reporting the result of
+	// the syncs, optional.
+	statusSensor string
+	// firstRead and lastRead are the end of the first and of the last
+	// read downloaded, set by reportLag, for the notifications.
+	firstRead, lastRead time.Time
+
+	// Cost statistics, optional.
+	costSensor, entsoeToken string
+	priceAdder              float64
+	prices                  prices.Series
+	// rateEntity is the Home Assistant entity with the price per kWh,
+	// used instead of the day-ahead prices if set.
+	rateEntity string
+	// pricesCSV is the file with the prices, see prices.ReadCSV, used
+	// instead of the day-ahead prices if set.
+	pricesCSV string
+	// exportSensor is the statistic of the energy exported to the grid,
+	// optional. exported are the chunks to upload to it, see
+	// setAsideExport.
+	exportSensor string
+	exported     []parse.Result
+	// bandSensors are the statistics of the energy of every tariff
+	// band, as band=sensor,band=sensor, optional. bands are the bands,
+	// see parse.ParseBands, and band is set on the copies uploading one
+	// of them, see uploadBands.
+	bandSensors, bands, band string
+	parsedBands              parse.Bands
+	// rateSheet is the file with the time of use rates, see
+	// prices.ReadRateSheet, used instead of the day-ahead prices if set.
+	rateSheet string
+	// tariff is the file with the tariff plan, see tariff.Load, used
+	// instead of the day-ahead prices and the charges flags if set.
+	tariff string
+	// charges are added to the cost of every hour.
+	charges parse.Charges
+	// period is the reads to upload, all if not set.
+	period period
+	// out receives the statistics instead of Home Assistant, if set.
+	// Home Assistant is still read, like for -missing_only.
+	out sink.StatisticsSink
 }
 
 func (uploadCmd) Name() string { return "upload" }
@@ -139,7 +675,7 @@ func (uploadCmd) Synopsis() string {
 func (uploadCmd) Usage() string {
 	return `upload <flags>
 	
-All the flags are required, but can be provided as environment variables as well.
+All the non optional flags are required, but can be provided as environment variables as well.
 The CSV file is read from standard input.
 
 `
@@ -147,69 +683,982 @@ The CSV file is read from standard input.
 
 func (c *uploadCmd) SetFlags(fs *flag.FlagSet) {
 	fs.StringVar(&c.server, "ha_server", "", "Home Assistant server name or IP and optionally the port")
-	fs.StringVar(&c.token, "ha_token", "", "Home Assistant admin authentication token")
+	secretStringVar(fs, &c.token, "ha_token", "Home Assistant admin authentication token")
 	fs.StringVar(&c.sensor, "ha_sensor", "", "Home Assistant sensor ID used to record power usage")
+	optionalStringVar(fs, &c.storePath, "store", "", "the path of the local database, to keep an audit log of the uploads and skip them when ESB data didn't change")
+	optionalStringVar(fs, &c.costSensor, "ha_cost_sensor", "", "Home Assistant sensor ID used to record the cost computed with the day-ahead prices")
+	optionalStringVar(fs, &c.entsoeToken, "entsoe_token", "", "ENTSO-E API token to download the day-ahead prices, required by -ha_cost_sensor unless -ha_unit_rate_entity, -prices_csv, -rate_sheet or -tariff is set")
+	optionalStringVar(fs, &c.pricesCSV, "prices_csv", "", "CSV file with the EUR per kWh price of every half hour, like a dynamic tariff, to use instead of the day-ahead prices")
+	optionalStringVar(fs, &c.rateSheet, "rate_sheet", "", "CSV file with the day, night and peak EUR per kWh rates of the supplier, to use instead of the day-ahead prices")
+	optionalStringVar(fs, &c.tariff, "tariff", "", "JSON file with the tariff plan of the supplier: rates, standing charge, levy, VAT and discount, to use instead of the day-ahead prices and of the charges flags")
+	optionalStringVar(fs, &c.rateEntity, "ha_unit_rate_entity", "", "Home Assistant entity, like an input_number, with the EUR per kWh price to use instead of the day-ahead prices")
+	optionalStringVar(fs, &c.exportSensor, "ha_export_sensor", "", "Home Assistant sensor ID used to record the energy exported to the grid, like by solar panels")
+	optionalStringVar(fs, &c.bandSensors, "ha_band_sensors", "", "the Home Assistant sensor IDs used to record the energy of every tariff band, like day=sensor.esb_day,night=sensor.esb_night,peak=sensor.esb_peak")
+	fs.StringVar(&c.bands, "tariff_bands", parse.DefaultBands, "the tariff bands of -ha_band_sensors, as name=HH:MM-HH:MM in Irish time, the first band containing a half hour wins")
+	optionalStringVar(fs, &c.lagSensor, "ha_lag_sensor", "", "Home Assistant diagnostic sensor ID where to report how many hours behind the ESB data is")
+	optionalStringVar(fs, &c.statusSensor, "ha_status_sensor", "", "Home Assistant diagnostic sensor ID where to report the result of the syncs, like sensor.esb2ha_status")
+	fs.Float64Var(&c.priceAdder, "price_adder", 0, "EUR per kWh added to the day-ahead price, like supplier margin and taxes")
+	chargesFlags(fs, &c.charges)
+	fs.StringVar(&c.align, "align", "center", "how to group the half hours in hours: center, to match the ESB graph, or clock, to match bills")
+	fs.DurationVar(&c.fillGaps, "fill_gaps", 0, "fill the holes in the data up to this long with estimated reads, 0 to leave them")
+	fs.BoolVar(&c.external, "ha_external", false, "the sensor IDs are external statistics, like "+externalSource+":consumption, which need no sensor in the Home Assistant configuration")
+	optionalStringVar(fs, &c.statName, "ha_statistic_name", "", "the name of the statistics shown by Home Assistant, the cost and export ones have the cost and export suffix")
+	fs.BoolVar(&c.meterState, "meter_state", false, "send the cumulative kWh as state, like a physical meter, instead of the kWh of the hour")
+	fs.BoolVar(&c.haTimezone, "ha_timezone", false, "send the times in the timezone configured in Home Assistant, warning if it is not Europe/Dublin")
+	fs.IntVar(&c.precision, "precision", -1, "round the uploaded values to this many decimals, negative to keep them as they are")
+	fs.BoolVar(&c.missingOnly, "missing_only", false, "upload only the hours after the last one in Home Assistant, continuing its sum")
+	fs.BoolVar(&c.forceUpload, "force_upload", false, "with -store, upload also the hours which didn't change since the last upload")
+	fs.BoolVar(&c.previewDiff, "preview_diff", false, "print, hour by hour, whether the upload adds, keeps or changes the values in Home Assistant, without uploading")
+	c.period.SetFlags(fs)
+}
+
+// uploadFileCmd is the upload command, the flags which only make sense
+// when reading files are kept out of uploadCmd, which is reused by the
+// other commands.
+type uploadFileCmd struct {
+	uploadCmd
+	// input is a file, a directory or a glob pattern, see inputFiles.
+	input string
+}
+
+func (uploadFileCmd) Usage() string {
+	return `upload <flags>
+
+All the non optional flags are required, but can be provided as environment variables as well.
+The CSV file is read from standard input, or from -input.
+
+-input is a file, a directory, or a glob pattern like 'archive/100123-*.csv.gz'.
+All the .csv and .csv.gz files are read, in the order of their names, and
+merged: the reads of the later files win where they overlap, like the
+files saved by -archive_dir, which are named after the time of the
+download. The merged reads are uploaded as one series, to recover the
+weeks of failed uploads.
+
+`
+}
+
+func (c *uploadFileCmd) SetFlags(fs *flag.FlagSet) {
+	c.uploadCmd.SetFlags(fs)
+	optionalStringVar(fs, &c.input, "input", "", "the file, the directory or the glob pattern of the CSV files to upload instead of standard input")
 }
 
-func (c *uploadCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+func (c *uploadFileCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
 	if err := ensureFlagsAreSet(f); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		slog.Error(err.Error())
 		return subcommands.ExitUsageError
 	}
 
-	fmt.Println("Reading from stdin...")
-
-	return c.parseAndUpload(ctx, os.Stdin)
+	if c.input == "" {
+		slog.Info("Reading from stdin...")
+		return c.parseAndUpload(ctx, os.Stdin)
+	}
+	paths, err := inputFiles(c.input)
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	slog.Info("Reading the files...", "count", len(paths))
+	parsed, err := readFiles(ctx, paths)
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return c.uploadAll(ctx, c.setAsideExport(parsed))
 }
 
 func (c *uploadCmd) parseAndUpload(ctx context.Context, data io.Reader) subcommands.ExitStatus {
-	parsed, err := parse.HDF(data)
+	parsed, err := parseHDF(ctx, data)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return c.uploadAll(ctx, c.setAsideExport(parsed))
+}
+
+// parseHDF parses the HDF file in a "parse" span.
+//
+// The energy exported to the grid must be set aside with
+// uploadCmd.setAsideExport before processing the rest.
+func parseHDF(ctx context.Context, data io.Reader) ([]parse.Result, error) {
+	_, end := startSpan(ctx, "parse")
+	parsed, err := readAllHDF(data)
+	return parsed, end(err)
+}
+
+// readTypePolicy is what the parser does with the unknown read types,
+// set by -read_types.
+var readTypePolicy = parse.Warn
+
+// rates is what the parse APIs do with the reads split by rate, set by
+// -rates. The uploads always merge them, a sensor has one series.
+var rates = parse.MergeRates
+
+// dstFold is what to do with the hour repeated when the clocks go back,
+// set by -dst_fold or by the configuration file.
+var dstFold = parse.FoldSum
+
+// duplicatePolicy is what the parser does with the reads repeated in the
+// file, set by -duplicates or by the configuration file.
+var duplicatePolicy = parse.KeepFirst
+
+// readHDF parses the HDF file with readTypePolicy and duplicatePolicy,
+// warning about the unknown read types and the duplicates on standard
+// error, and applies dstFold.
+//
+// It only returns the energy imported from the grid, see readAllHDF.
+func readHDF(data io.Reader) ([]parse.Result, error) {
+	parsed, err := readAllHDF(data)
+	imported, _ := parse.SplitExport(parsed)
+	return imported, err
+}
+
+// readAllHDF is like readHDF, but it also returns the energy exported
+// to the grid.
+//
+// It never returns the reads of the unknown types, they can't be
+// converted to energy, see readRawHDF.
+func readAllHDF(data io.Reader) ([]parse.Result, error) {
+	parsed, err := readRawHDF(data)
+	if err != nil {
+		return nil, err
+	}
+	if parsed, err = parse.GroupRates(parsed, parse.MergeRates); err != nil {
+		return nil, err
+	}
+	var ret []parse.Result
+	for _, r := range parsed {
+		if r.Known() {
+			ret = append(ret, r)
+		}
+	}
+	return parse.FoldDST(ret, dstFold), nil
+}
+
+// readRawHDF is like readHDF, but with -read_types=collect it also
+// returns the reads of the unknown types as they are, and with
+// -rates=split a series per rate, for the APIs returning the parsed
+// file.
+func readRawHDF(data io.Reader) ([]parse.Result, error) {
+	parsed, err := parse.HDFWithPolicies(data, readTypePolicy, duplicatePolicy, parse.Hooks{
+		OnUnknownReadType: func(readType string, lines int) {
+			slog.Warn("skipped the lines with an unknown read type", "lines", lines, "read_type", readType)
+		},
+		OnDuplicate: func(kept, dropped parse.Read) {
+			slog.Warn("skipped a duplicated read", "end", kept.EndTime.Format(time.RFC3339), "value", dropped.Value, "kept", kept.Value)
+		},
+		OnProgress: func(lines int) {
+			slog.Debug("Parsing...", "lines", lines)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parse.GroupRates(parsed, rates)
+}
+
+// printFoldedHours prints the hours repeated when the clocks went back,
+// handled according to dstFold.
+func printFoldedHours(parsed []parse.Result) {
+	hours := parse.FoldedHours(parsed)
+	if len(hours) == 0 {
+		return
+	}
+	var ss []string
+	for _, h := range hours {
+		ss = append(ss, h.Format(time.RFC3339))
+	}
+	slog.Info("Hours repeated when the clocks went back", "hours", strings.Join(ss, ", "))
+}
+
+// parseDownload parses the HDF file while it is downloaded, and closes it.
+//
+// It also returns the hex encoded sha256 of the file, for downloadCache,
+// so the file is never kept in memory.
+func parseDownload(ctx context.Context, body io.ReadCloser) ([]parse.Result, string, error) {
+	defer body.Close()
+	hash := sha256.New()
+	parsed, err := parseHDF(ctx, io.TeeReader(body, hash))
+	return parsed, hex.EncodeToString(hash.Sum(nil)), err
+}
+
+// uploadAll uploads all the chunks, reporting the progress on standard output.
+func (c *uploadCmd) uploadAll(ctx context.Context, parsed []parse.Result) subcommands.ExitStatus {
+	var err error
+	if parsed, err = c.period.filter(parsed); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	if c.exported, err = c.period.filter(c.exported); err != nil {
+		slog.Error(err.Error())
 		return subcommands.ExitFailure
 	}
 	if len(parsed) == 0 {
-		fmt.Fprintf(os.Stderr, "ERROR: nothing to upload\n")
+		slog.Error("nothing to upload")
+		return subcommands.ExitFailure
+	}
+	parsed = c.fill(parsed)
+	printFoldedHours(parsed)
+
+	if err := c.loadPrices(ctx, parsed); err != nil {
+		slog.Error(err.Error())
 		return subcommands.ExitFailure
 	}
 
+	c.resume()
 	ret := subcommands.ExitSuccess
 	for _, chunk := range parsed {
-		fmt.Println("Uploading data...")
+		slog.Info("Uploading data...")
 		stat, err := c.upload(ctx, chunk)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			slog.Error(err.Error())
 			ret = subcommands.ExitFailure
+		} else if n := len(stat.Stats); n == 0 {
+			slog.Info("Nothing changed since the last upload")
+		} else if c.previewDiff {
+			slog.Info("Previewed the data points, nothing was sent", "points", n, "from", stat.Stats[0].Start, "to", stat.Stats[n-1].Start)
 		} else {
-			n := len(stat.Stats)
-			fmt.Printf("Sent %d data points from %s to %s\n", n, stat.Stats[0].Start, stat.Stats[n-1].Start)
+			slog.Info("Sent the data points", "points", n, "from", stat.Stats[0].Start, "to", stat.Stats[n-1].Start)
 		}
 	}
+	if n, err := c.uploadBands(ctx, parsed); err != nil {
+		slog.Error(err.Error())
+		ret = subcommands.ExitFailure
+	} else if n > 0 {
+		slog.Info("Sent the data points of the tariff bands", "points", n)
+	}
+	if n, err := c.uploadExport(ctx); err != nil {
+		slog.Error(err.Error())
+		ret = subcommands.ExitFailure
+	} else if n > 0 {
+		slog.Info("Sent the data points of exported energy", "points", n)
+	}
+	if ret == subcommands.ExitSuccess && !c.previewDiff {
+		c.finish()
+	}
 	return ret
 }
 
-func (c *uploadCmd) upload(ctx context.Context, data parse.Result) (ha.Statistics, error) {
-	stat, err := parse.Translate(data)
+// setAsideExport keeps the chunks of the energy exported to the grid,
+// to upload them to exportSensor after the others, and returns the
+// chunks of the imported energy.
+//
+// Without exportSensor the exported energy is dropped.
+func (c *uploadCmd) setAsideExport(parsed []parse.Result) []parse.Result {
+	parsed, c.exported = parse.SplitExport(parsed)
+	if c.exportSensor == "" {
+		c.exported = nil
+	}
+	return parsed
+}
+
+// bandSensorList returns the band and the sensor of every tariff band
+// in bandSensors, in order.
+func (c *uploadCmd) bandSensorList() ([][2]string, error) {
+	bands, err := parse.ParseBands(c.bands)
 	if err != nil {
-		return stat, fmt.Errorf("cannot parse data: %w", err)
+		return nil, err
+	}
+	var ret [][2]string
+	for _, kv := range strings.Split(c.bandSensors, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		band, sensor, ok := strings.Cut(kv, "=")
+		if !ok || sensor == "" {
+			return nil, fmt.Errorf("invalid band sensor %q, want band=sensor", kv)
+		}
+		if !slices.ContainsFunc(bands, func(b parse.Band) bool { return b.Name == band }) {
+			return nil, fmt.Errorf("unknown tariff band %q in -ha_band_sensors, not in -tariff_bands", band)
+		}
+		ret = append(ret, [2]string{band, sensor})
 	}
+	c.parsedBands = bands
+	return ret, nil
+}
 
+// uploadBands uploads the energy of every tariff band of bandSensors
+// and returns the number of points sent.
+//
+// Like the exported energy, the bands have no cost and their upload is
+// not resumed.
+func (c *uploadCmd) uploadBands(ctx context.Context, parsed []parse.Result) (int, error) {
+	if c.bandSensors == "" {
+		return 0, nil
+	}
+	list, err := c.bandSensorList()
+	if err != nil {
+		return 0, err
+	}
+	points := 0
+	var errs []error
+	for _, bs := range list {
+		b := *c
+		b.sensor, b.band, b.costSensor, b.resumeAfter, b.exported = bs[1], bs[0], "", time.Time{}, nil
+		if c.statName != "" {
+			b.statName = c.statName + " " + bs[0]
+		}
+		ok := true
+		for _, chunk := range parsed {
+			stat, err := b.upload(ctx, chunk)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s band: %w", bs[0], err))
+				ok = false
+				continue
+			}
+			points += len(stat.Stats)
+		}
+		if ok && !c.previewDiff {
+			b.finish()
+		}
+	}
+	return points, errors.Join(errs...)
+}
+
+// uploadExport uploads the chunks set aside by setAsideExport and
+// returns the number of points sent.
+//
+// The exported energy has no cost, and its upload is not resumed: the
+// hours already sent are skipped anyway, see skipUnchanged.
+func (c *uploadCmd) uploadExport(ctx context.Context) (int, error) {
+	if len(c.exported) == 0 {
+		return 0, nil
+	}
+	exp := *c
+	exp.sensor, exp.costSensor, exp.resumeAfter, exp.exported = c.exportSensor, "", time.Time{}, nil
+	if c.statName != "" {
+		exp.statName = c.statName + " export"
+	}
+	points := 0
+	var errs []error
+	for _, chunk := range c.exported {
+		stat, err := exp.upload(ctx, chunk)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("exported energy: %w", err))
+			continue
+		}
+		points += len(stat.Stats)
+	}
+	if len(errs) == 0 && !c.previewDiff {
+		exp.finish()
+	}
+	return points, errors.Join(errs...)
+}
+
+// uploadBatchHours is how many hours are sent, and acknowledged in the
+// local store, at once.
+const uploadBatchHours = 31 * 24
+
+func (c *uploadCmd) upload(ctx context.Context, data parse.Result) (stat ha.Statistics, err error) {
+	ctx, end := startSpan(ctx, "upload", attribute.String("sensor", c.sensor), attribute.Int("reads", len(data.Reads)))
+	defer func() { end(err) }()
+
+	opts, err := c.translateOptions()
+	if err != nil {
+		return stat, err
+	}
+
+	if c.band != "" {
+		stat, err = parse.TranslateBand(data, c.band, c.parsedBands, opts)
+	} else {
+		stat, err = parse.Translate(data, opts)
+	}
+	var skipped *parse.SkippedError
+	if errors.As(err, &skipped) {
+		if len(data.Reads) > 0 {
+			slog.Info("Skipping reads which don't complete an hour", "reads", skipped.Reads, "end", data.Reads[0].EndTime)
+		}
+		return stat, nil
+	}
+	if err != nil {
+		return stat, fmt.Errorf("cannot parse data: %w", err)
+	}
 	stat.Metadata.StatisticID = c.sensor
+	c.describe(&stat.Metadata, "")
+	parse.Round(stat.Stats, c.precision)
+
+	var cost ha.Statistics
+	if c.costSensor != "" {
+		cost, err = parse.TranslateCost(data, "EUR", opts, func(t time.Time) (float64, bool) {
+			p, ok := c.prices.At(t)
+			return p + c.priceAdder, ok
+		}, c.charges)
+		if err != nil {
+			return stat, fmt.Errorf("cannot compute cost: %w", err)
+		}
+		cost.Metadata = ha.CostMetadata(stat.Metadata, c.costSensor, "EUR")
+		parse.Round(cost.Stats, c.precision)
+	}
+
+	// Skip what was already sent, by a previous or an interrupted upload.
+	// Both statistics come from the same reads, so they have the same hours.
+	c.skipUnchanged(&stat, &cost)
+	stat.Stats, cost.Stats = c.notAcked(stat.Stats), c.notAcked(cost.Stats)
+	if len(stat.Stats) == 0 {
+		return stat, nil
+	}
 
 	conn, err := ha.NewConnection(ctx, c.server, c.token)
 	if err != nil {
 		return stat, fmt.Errorf("cannot connect to Home Assistant: %w", err)
 	}
 
-	if err := conn.SendStatistics(ctx, stat); err != nil {
-		return stat, fmt.Errorf("cannot send statistics to Home Assistant: %w", err)
+	defer conn.Close()
+	conn.Hooks.OnBatchProgress = progress.upload
+	defer progress.clear()
+
+	if err := c.loadHALocation(ctx, conn); err != nil {
+		return stat, err
+	}
+	if err := c.checkStatistics(ctx, conn, stat, cost); err != nil {
+		return stat, err
 	}
-	return stat, nil
+
+	if c.missingOnly {
+		if err := c.skipInHA(ctx, conn, &stat, &cost); err != nil {
+			return stat, err
+		}
+		if len(stat.Stats) == 0 {
+			return stat, nil
+		}
+	}
+
+	// Rebasing the sums adds floating point noise again.
+	parse.Round(stat.Stats, c.precision)
+	parse.Round(cost.Stats, c.precision)
+
+	if c.previewDiff {
+		return stat, c.preview(ctx, conn, stat, cost)
+	}
+
+	var out sink.StatisticsSink = conn
+	if c.out != nil {
+		out = c.out
+	}
+	return stat, c.send(ctx, out, stat, cost)
+}
+
+// send writes the statistics, and the cost ones if any, in batches of
+// uploadBatchHours, recording them in the local store.
+//
+// The batches are written together if out supports it, but recorded in
+// order up to the first failed one, so a resumed upload starts from
+// there.
+func (c *uploadCmd) send(ctx context.Context, out sink.StatisticsSink, stat, cost ha.Statistics) error {
+	var batches, toSend []ha.Statistics
+	for from := 0; from < len(stat.Stats); from += uploadBatchHours {
+		to := min(from+uploadBatchHours, len(stat.Stats))
+
+		batch := stat
+		batch.Stats = stat.Stats[from:to]
+		batches = append(batches, batch)
+		toSend = append(toSend, c.toSend(batch))
+		if c.costSensor != "" {
+			batch := cost
+			batch.Stats = cost.Stats[from:to]
+			batches = append(batches, batch)
+			toSend = append(toSend, c.toSend(batch))
+		}
+	}
+
+	errs := writeBatches(ctx, out, toSend)
+	for i, batch := range batches {
+		isCost := c.costSensor != "" && i%2 == 1
+		c.audit(batch, errs[i])
+		if errs[i] != nil {
+			promMetrics.haWriteFailed()
+			if isCost {
+				return fmt.Errorf("cannot send cost statistics to Home Assistant: %w", errs[i])
+			}
+			return fmt.Errorf("cannot send statistics to Home Assistant: %w", errs[i])
+		}
+		if !isCost {
+			pointsUploaded.Add(ctx, int64(len(batch.Stats)))
+			promMetrics.uploaded(c.sensor, len(batch.Stats))
+		}
+		c.recordHours(batch)
+
+		// The hours are done once both the statistics are written.
+		if c.costSensor == "" || isCost {
+			c.ack(batch.Stats[len(batch.Stats)-1].Start)
+		}
+	}
+	return nil
+}
+
+// errNotWritten is the error of the batches not written after a failed
+// one.
+var errNotWritten = errors.New("not written after a previous error")
+
+// writeBatches writes the batches, all together if out is a
+// sink.BatchStatisticsSink, and returns the error of each of them.
+func writeBatches(ctx context.Context, out sink.StatisticsSink, batches []ha.Statistics) []error {
+	if b, ok := out.(sink.BatchStatisticsSink); ok {
+		return b.WriteStatisticsBatch(ctx, batches)
+	}
+	errs := make([]error, len(batches))
+	for i, batch := range batches {
+		if errs[i] = out.WriteStatistics(ctx, batch); errs[i] != nil {
+			for j := i + 1; j < len(errs); j++ {
+				errs[j] = errNotWritten
+			}
+			break
+		}
+	}
+	return errs
+}
+
+// translateOptions returns the options of parse.Translate set by the flags.
+func (c *uploadCmd) translateOptions() (parse.Options, error) {
+	align, err := parse.ParseAlignment(c.align)
+	return parse.Options{Align: align}, err
+}
+
+// toSend returns the statistics as they must be sent to Home Assistant.
+//
+// The local store keeps recording the hourly states, see parse.MeterReadings.
+func (c *uploadCmd) toSend(stat ha.Statistics) ha.Statistics {
+	if c.meterState {
+		stat.Stats = parse.MeterReadings(stat.Stats)
+	}
+	if c.haLocation != nil {
+		stat = stat.In(c.haLocation)
+	}
+	return stat
+}
+
+// loadHALocation reads the timezone of Home Assistant, if requested and
+// not read yet.
+//
+// Home Assistant aggregates the hours by day in its own timezone, the
+// daily totals don't match the ESB ones if it is not Europe/Dublin.
+func (c *uploadCmd) loadHALocation(ctx context.Context, conn *ha.Connection) error {
+	if !c.haTimezone || c.haLocation != nil {
+		return nil
+	}
+	cfg, err := conn.Config(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot read the Home Assistant config: %w", err)
+	}
+	loc, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	if loc.String() != "Europe/Dublin" {
+		slog.Warn("Home Assistant timezone is not Europe/Dublin, the daily totals won't match the ESB ones", "timezone", loc)
+	}
+	c.haLocation = loc
+	return nil
+}
+
+// externalSource is the source of the external statistics.
+const externalSource = "esb2ha"
+
+// describe sets the source and the name, followed by what if not
+// empty, of the statistic.
+func (c *uploadCmd) describe(m *ha.StatisticMetadata, what string) {
+	if c.external {
+		m.Source = externalSource
+	}
+	if c.statName != "" {
+		m.Name = strings.TrimSpace(c.statName + " " + what)
+	}
+}
+
+// checkStatistics checks, once per sensor, that Home Assistant can
+// import the statistics, so a wrong sensor fails with an error telling
+// what to change instead of the invalid_format one of Home Assistant.
+// Empty statistics are ignored.
+func (c *uploadCmd) checkStatistics(ctx context.Context, conn *ha.Connection, stats ...ha.Statistics) error {
+	if c.checked == c.sensor {
+		return nil
+	}
+	var existing map[string]ha.StatisticInfo
+	for _, stat := range stats {
+		m := stat.Metadata
+		if m.StatisticID == "" {
+			continue
+		}
+		if err := ha.ValidateStatisticID(m); err != nil {
+			return err
+		}
+		if existing == nil {
+			var err error
+			if existing, err = conn.ListStatisticIDs(ctx); err != nil {
+				return fmt.Errorf("cannot list the statistics in Home Assistant: %w", err)
+			}
+		}
+		info, ok := existing[m.StatisticID]
+		if !ok {
+			slog.Info("The statistic will be created by the upload", "statistic_id", m.StatisticID)
+			continue
+		}
+		if !info.HasSum {
+			return fmt.Errorf("%s in Home Assistant is not a total, like the ones of the energy dashboard, but a measurement: choose another sensor", m.StatisticID)
+		}
+		if info.Unit != m.UnitOfMeasurement {
+			return fmt.Errorf("%s in Home Assistant is in %s, not %s: choose another sensor, or fix the unit in Developer tools > Statistics", m.StatisticID, info.Unit, m.UnitOfMeasurement)
+		}
+	}
+	c.checked = c.sensor
+	return nil
+}
+
+// skipUnchanged rebases the sums on the ones already uploaded and, unless
+// forced, removes the hours which didn't change since the last upload.
+//
+// The statistics must have the same hours, an hour is kept if it
+// changed in any of them. Empty statistics are ignored.
+func (c *uploadCmd) skipUnchanged(stats ...*ha.Statistics) {
+	if c.storePath == "" || len(stats[0].Stats) == 0 {
+		return
+	}
+	st, err := store.Open(c.storePath)
+	if err != nil {
+		slog.Warn("cannot read the uploaded hours, sending all of them", "err", err)
+		return
+	}
+	defer st.Close()
+
+	keep := make([]bool, len(stats[0].Stats))
+	for _, s := range stats {
+		if len(s.Stats) == 0 {
+			continue
+		}
+		uploaded, err := st.UploadedHours(s.Metadata.StatisticID, s.Stats[0].Start, s.Stats[len(s.Stats)-1].Start)
+		if err != nil {
+			slog.Warn("cannot read the uploaded hours, sending all of them", "err", err)
+			return
+		}
+		parse.Rebase(s.Stats, func(t time.Time) (float64, bool) {
+			h, ok := uploaded[t.Unix()]
+			return h.Sum, ok
+		})
+		for i, v := range s.Stats {
+			if c.forceUpload || uploaded[v.Start.Unix()].Hash != parse.HourHash(v) {
+				keep[i] = true
+			}
+		}
+	}
+
+	for _, s := range stats {
+		if len(s.Stats) == 0 {
+			continue
+		}
+		var changed []ha.StatisticValue
+		for i, v := range s.Stats {
+			if keep[i] {
+				changed = append(changed, v)
+			}
+		}
+		s.Stats = changed
+	}
+}
+
+// missingLookback is how far back Home Assistant is searched for the last
+// statistic before the uploaded data.
+const missingLookback = 31 * 24 * time.Hour
+
+// skipInHA removes the hours Home Assistant already has, and continues
+// the sums from the last one it has.
+//
+// The cost statistic, if not empty, is cut to the same hours of the energy.
+func (c *uploadCmd) skipInHA(ctx context.Context, conn *ha.Connection, stat, cost *ha.Statistics) error {
+	first, end := stat.Stats[0].Start, stat.Stats[len(stat.Stats)-1].Start
+	last, ok, err := lastStatistic(ctx, conn, stat.Metadata.StatisticID, first.Add(-missingLookback), end.Add(time.Hour))
+	if err != nil {
+		return fmt.Errorf("cannot read the last statistic from Home Assistant: %w", err)
+	}
+	stat.Stats = parse.ContinueFrom(stat.Stats, last, ok)
+	if len(stat.Stats) == 0 || len(cost.Stats) == 0 {
+		return nil
+	}
+
+	cost.Stats = cost.Stats[len(cost.Stats)-len(stat.Stats):]
+	first = cost.Stats[0].Start
+	last, ok, err = lastStatistic(ctx, conn, cost.Metadata.StatisticID, first.Add(-missingLookback), first)
+	if err != nil {
+		return fmt.Errorf("cannot read the last cost statistic from Home Assistant: %w", err)
+	}
+	cost.Stats = parse.ContinueFrom(cost.Stats, last, ok)
+	return nil
+}
+
+// lastStatistic returns the newest hourly statistic with start between
+// from and to, reading backwards a missingLookback at a time to keep the
+// Home Assistant responses small.
+func lastStatistic(ctx context.Context, conn *ha.Connection, id string, from, to time.Time) (ha.StatisticValue, bool, error) {
+	for end := to; end.After(from); end = end.Add(-missingLookback) {
+		start := end.Add(-missingLookback)
+		if start.Before(from) {
+			start = from
+		}
+		last, ok, err := conn.LastStatistic(ctx, id, start, end)
+		if err != nil || ok {
+			return last, ok, err
+		}
+	}
+	return ha.StatisticValue{}, false, nil
+}
+
+// recordHours records in the local store the hours sent to Home Assistant.
+func (c *uploadCmd) recordHours(stat ha.Statistics) {
+	hours := make([]store.UploadedHour, 0, len(stat.Stats))
+	for _, v := range stat.Stats {
+		hours = append(hours, store.UploadedHour{Start: v.Start, Hash: parse.HourHash(v), Sum: v.Sum})
+	}
+	c.progress(func(st *store.Store) error { return st.RecordUploadedHours(stat.Metadata.StatisticID, hours) })
+}
+
+// interrupted looks in the local store for an upload which was
+// interrupted, for example by a crash, and returns the last hour it sent.
+func (c *uploadCmd) interrupted() (time.Time, bool) {
+	if c.storePath == "" {
+		return time.Time{}, false
+	}
+	st, err := store.Open(c.storePath)
+	if err != nil {
+		slog.Warn("cannot read the upload progress", "err", err)
+		return time.Time{}, false
+	}
+	defer st.Close()
+	until, ok, err := st.UploadProgress(c.sensor)
+	if err != nil {
+		slog.Warn("cannot read the upload progress", "err", err)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+// pending returns true if an upload was interrupted, therefore there is
+// something to upload even if the data didn't change.
+func (c *uploadCmd) pending() bool {
+	_, ok := c.interrupted()
+	return ok
+}
+
+// resume makes the following uploads skip the hours already sent by
+// an interrupted upload.
+func (c *uploadCmd) resume() {
+	until, ok := c.interrupted()
+	if ok {
+		slog.Info("Resuming the interrupted upload", "after", until)
+	}
+	c.resumeAfter = until
+}
+
+// notAcked returns the values after the ones acknowledged by an
+// interrupted upload.
+func (c *uploadCmd) notAcked(vals []ha.StatisticValue) []ha.StatisticValue {
+	for i, v := range vals {
+		if v.Start.After(c.resumeAfter) {
+			return vals[i:]
+		}
+	}
+	return nil
+}
+
+// ack records in the local store that the hour starting at t was sent.
+//
+// Errors are only reported, the worst case is sending the data twice.
+func (c *uploadCmd) ack(t time.Time) {
+	c.progress(func(st *store.Store) error { return st.AckUpload(c.sensor, t) })
+}
+
+// finish records in the local store that the upload is complete, so the
+// next one starts from scratch.
+func (c *uploadCmd) finish() {
+	c.progress(func(st *store.Store) error { return st.FinishUpload(c.sensor) })
+	c.resumeAfter = time.Time{}
+}
+
+func (c *uploadCmd) progress(f func(*store.Store) error) {
+	if c.storePath == "" {
+		return
+	}
+	st, err := store.Open(c.storePath)
+	if err == nil {
+		err = f(st)
+		st.Close()
+	}
+	if err != nil {
+		slog.Warn("cannot record the upload progress", "err", err)
+	}
+}
+
+// audit records the upload in the local store, if configured.
+//
+// Errors are only reported, a failure here must not stop the upload.
+func (c *uploadCmd) audit(stat ha.Statistics, uploadErr error) {
+	if c.storePath == "" || len(stat.Stats) == 0 {
+		return
+	}
+	first, last := stat.Stats[0], stat.Stats[len(stat.Stats)-1]
+	u := store.Upload{
+		Time:        time.Now(),
+		Target:      "home_assistant:" + c.server,
+		StatisticID: stat.Metadata.StatisticID,
+		From:        first.Start,
+		To:          last.Start.Add(time.Hour),
+		Points:      len(stat.Stats),
+		SumBefore:   first.Sum - first.State,
+		SumAfter:    last.Sum,
+	}
+	if uploadErr != nil {
+		u.Error = uploadErr.Error()
+	}
+
+	st, err := store.Open(c.storePath)
+	if err == nil {
+		err = st.RecordUpload(u)
+		st.Close()
+	}
+	if err != nil {
+		slog.Warn("cannot record the upload in the audit log", "err", err)
+	}
+}
+
+// pairCost sets the cost statistic, if not set, when there is a tariff
+// plan: its ID is the one of the energy with the _cost suffix, see
+// ha.CostStatisticID.
+func (c *uploadCmd) pairCost() {
+	if c.costSensor != "" || c.tariff == "" || c.band != "" {
+		return
+	}
+	c.costSensor = ha.CostStatisticID(c.sensor)
+	slog.Info("Uploading the cost of the tariff too", "statistic_id", c.costSensor)
+}
+
+// loadPrices downloads the day-ahead prices covering the parsed data,
+// if cost statistics are requested.
+func (c *uploadCmd) loadPrices(ctx context.Context, parsed []parse.Result) error {
+	c.pairCost()
+	if c.costSensor == "" || len(parsed) == 0 {
+		return nil
+	}
+	first, last := parsed[0].Reads, parsed[len(parsed)-1].Reads
+	from := first[0].EndTime.Add(-30 * time.Minute)
+	to := last[len(last)-1].EndTime
+
+	if c.rateEntity != "" {
+		ctx, end := startSpan(ctx, "prices")
+		rate, err := c.unitRate(ctx)
+		if err := end(err); err != nil {
+			return err
+		}
+		c.prices = prices.Series{{Start: from, End: to, EURPerMWh: rate * 1000}}
+		return nil
+	}
+
+	if c.pricesCSV != "" {
+		s, err := readPricesCSV(c.pricesCSV)
+		if err != nil {
+			return err
+		}
+		c.prices = s
+		return nil
+	}
+
+	if c.rateSheet != "" {
+		rs, err := readRateSheet(c.rateSheet)
+		if err != nil {
+			return err
+		}
+		c.prices = rs.Series(from, to)
+		return nil
+	}
+
+	if c.tariff != "" {
+		plan, err := readTariff(c.tariff)
+		if err != nil {
+			return err
+		}
+		if c.charges != (parse.Charges{}) && c.charges != plan.Charges() {
+			return errors.New("-standing_charge, -pso_levy and -vat can't be used with -tariff, set them in the tariff file")
+		}
+		c.prices, c.charges = plan.Series(from, to), plan.Charges()
+		return nil
+	}
+
+	if c.entsoeToken == "" {
+		return errors.New("-entsoe_token, -prices_csv, -rate_sheet, -tariff or -ha_unit_rate_entity is required to compute the cost")
+	}
+
+	ctx, end := startSpan(ctx, "prices")
+	s, err := prices.NewClient(c.entsoeToken).DayAhead(ctx, from, to)
+	if err := end(err); err != nil {
+		return fmt.Errorf("cannot download day-ahead prices: %w", err)
+	}
+	c.prices = s
+	return nil
+}
+
+// readPricesCSV reads the price series in the file.
+func readPricesCSV(path string) (prices.Series, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := prices.ReadCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// readTariff reads the tariff plan in the file.
+func readTariff(path string) (*tariff.Plan, error) {
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		return nil, err
+	}
+	return tariff.Load(path, dublin)
+}
+
+// readRateSheet reads the supplier rates in the file.
+func readRateSheet(path string) (*prices.RateSheet, error) {
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rs, err := prices.ReadRateSheet(f, dublin)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// unitRate reads the price per kWh from the state of rateEntity.
+func (c *uploadCmd) unitRate(ctx context.Context) (float64, error) {
+	state, _, ok, err := ha.State(ctx, c.server, c.token, c.rateEntity)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read the unit rate: %w", err)
+	}
+	if !ok {
+		return 0, fmt.Errorf("cannot read the unit rate: %s doesn't exist", c.rateEntity)
+	}
+	rate, err := strconv.ParseFloat(state, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read the unit rate: %s is %q, want a number", c.rateEntity, state)
+	}
+	return rate, nil
 }
 
 type pipeCmd struct {
-	ha  uploadCmd
-	esb downloadCmd
+	ha      uploadCmd
+	esb     downloadCmd
+	cache   downloadCache
+	outages outageWatch
+	mqtt    mqttSink
+	notify  syncNotifications
+	// mprnSensors maps the meters to their sensors, as
+	// mprn=sensor,mprn=sensor, see sensors.
+	mprnSensors string
 }
 
 func (pipeCmd) Name() string { return "pipe" }
@@ -221,29 +1670,196 @@ func (pipeCmd) Synopsis() string {
 func (pipeCmd) Usage() string {
 	return `pipe  <flags>
 
-All the flags are required, but can be provided as environment variables as well.
+All the non optional flags are required, but can be provided as environment variables as well.
 It is the equivalent of piping download and upload.
 
+With more meters in -mprn, they are downloaded with one login and each
+is uploaded to its sensor in -mprn_sensors, or to -ha_sensor if not
+listed.
+
 `
 }
 
 func (c *pipeCmd) SetFlags(fs *flag.FlagSet) {
 	c.ha.SetFlags(fs)
 	c.esb.SetFlags(fs)
+	c.cache.SetFlags(fs)
+	c.outages.SetFlags(fs)
+	c.mqtt.SetFlags(fs)
+	c.notify.SetFlags(fs)
+	optionalStringVar(fs, &c.mprnSensors, "mprn_sensors", "", "the Home Assistant sensor ID of every meter in -mprn, like 100123=sensor.home,100456=sensor.flat, -ha_sensor is used for the meters not listed")
 }
 
 func (c *pipeCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
 	if err := ensureFlagsAreSet(f); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		slog.Error(err.Error())
 		return subcommands.ExitUsageError
 	}
 
-	fmt.Println("Downloading data...")
-	data, err := c.esb.download()
+	sensors, err := c.sensors()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if err := c.notify.validate(); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if _, _, err := c.ha.period.times(); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	c.esb.period = c.ha.period
+	if len(sensors) == 1 {
+		c.ha.sensor = sensors[0].sensor
+		return c.pipeMeter(ctx)
+	}
+
+	if c.esb.session, err = c.esb.login(ctx); err != nil {
+		slog.Error(err.Error())
 		return subcommands.ExitFailure
 	}
+	ret := subcommands.ExitSuccess
+	for _, s := range sensors {
+		slog.Info("Syncing...", "mprn", s.mprn, "sensor", s.sensor)
+		m := *c
+		m.esb.mprn, m.ha.sensor = s.mprn, s.sensor
+		// A failure on a meter doesn't stop the others.
+		if m.pipeMeter(ctx) != subcommands.ExitSuccess {
+			ret = subcommands.ExitFailure
+		}
+		c.esb.nextMeter(m.esb)
+	}
+	return ret
+}
+
+// meterSensor is a meter and the sensor where to upload its data.
+type meterSensor struct {
+	mprn, sensor string
+}
+
+// sensors returns the sensor of every meter in -mprn.
+func (c *pipeCmd) sensors() ([]meterSensor, error) {
+	mapping := map[string]string{}
+	for _, kv := range strings.Split(c.mprnSensors, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		mprn, sensor, ok := strings.Cut(kv, "=")
+		if !ok || mprn == "" || sensor == "" {
+			return nil, fmt.Errorf("invalid -mprn_sensors %q, want mprn=sensor", kv)
+		}
+		mapping[mprn] = sensor
+	}
 
-	return c.ha.parseAndUpload(ctx, bytes.NewReader(data))
+	var (
+		ret  []meterSensor
+		used = map[string]string{}
+	)
+	for _, mprn := range c.esb.mprns() {
+		sensor := cmp.Or(mapping[mprn], c.ha.sensor)
+		if other, ok := used[sensor]; ok {
+			return nil, fmt.Errorf("%s and %s would be uploaded to the same sensor %s, set -mprn_sensors", other, mprn, sensor)
+		}
+		used[sensor] = mprn
+		delete(mapping, mprn)
+		ret = append(ret, meterSensor{mprn, sensor})
+	}
+	for mprn := range mapping {
+		return nil, fmt.Errorf("-mprn_sensors maps %s which is not in -mprn", mprn)
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("-mprn is empty")
+	}
+	if len(ret) > 1 && cmp.Or(c.ha.costSensor, c.ha.exportSensor, c.ha.bandSensors, c.ha.lagSensor, c.ha.statusSensor) != "" {
+		return nil, errors.New("the cost, export, band, lag and status sensors can't be shared by more meters, use the sync command with a configuration file instead")
+	}
+	return ret, nil
+}
+
+// pipeMeter downloads and uploads a single meter, reporting the status
+// and notifying the result.
+func (c *pipeCmd) pipeMeter(ctx context.Context) subcommands.ExitStatus {
+	var lag time.Duration
+	err := c.pipe(ctx, &lag)
+	c.ha.reportStatus(ctx, c.esb.mprn, lag, err)
+	c.notify.send(ctx, &c.ha, c.esb.mprn, err)
+	if err != nil {
+		slog.Error("sync failed", "mprn", c.esb.mprn, "err", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// pipe downloads and uploads the data, setting the lag of the data once known.
+func (c *pipeCmd) pipe(ctx context.Context, lag *time.Duration) error {
+	slog.Info("Downloading data...")
+	body, err := c.esb.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	parsed, hash, err := parseDownload(ctx, body)
+	if err != nil {
+		return err
+	}
+	c.cache.path = c.ha.storePath
+	if err := c.cache.recordReads(parsed); err != nil {
+		// Not worth failing the upload for this.
+		slog.Warn(err.Error())
+	}
+	parsed = c.ha.setAsideExport(parsed)
+
+	if l, ok := c.ha.reportLag(ctx, c.esb.mprn, parsed, time.Now()); ok {
+		slog.Info("The latest read is old", "hours", int(l.Hours()))
+		*lag = l
+	}
+	if c.mqtt.enabled() {
+		// Today and yesterday move even when the data doesn't change.
+		if err := publishAll(ctx, &c.mqtt, parsed); err != nil {
+			slog.Warn("cannot publish the usage sensors to MQTT", "err", err)
+		}
+	}
+
+	if c.outages.enabled() {
+		if err := c.recordOutages(ctx); err != nil {
+			// Not worth failing the upload for this.
+			slog.Warn(err.Error())
+		}
+	}
+
+	// A preview doesn't upload, the download must not count as handled.
+	if !c.ha.previewDiff {
+		unchanged, _, err := c.cache.unchanged(c.esb.mprn, c.ha.sensor, hash)
+		if err != nil {
+			return err
+		}
+		if unchanged && !c.ha.pending() {
+			slog.Info("Data didn't change since the last download, nothing to upload")
+			return nil
+		}
+	}
+
+	if c.ha.uploadAll(ctx, parsed) != subcommands.ExitSuccess {
+		return errors.New("the upload failed, see the errors above")
+	}
+	if !c.ha.previewDiff {
+		if err := c.cache.uploaded(c.esb.mprn, c.ha.sensor, hash); err != nil {
+			// The next run uploads the same data again, nothing lost.
+			slog.Warn(err.Error())
+		}
+	}
+	return nil
+}
+
+func (c *pipeCmd) recordOutages(ctx context.Context) error {
+	if c.cache.path == "" {
+		return errors.New("-powercheck_api_key requires -store")
+	}
+	st, err := store.Open(c.cache.path)
+	if err != nil {
+		return fmt.Errorf("cannot open local store: %w", err)
+	}
+	defer st.Close()
+	return c.outages.record(ctx, st)
 }
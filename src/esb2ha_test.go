@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/sink"
+	"github.com/lorentz83/esb2ha/store"
+)
+
+func TestUnitRate(t *testing.T) {
+	states := map[string]string{
+		"input_number.unit_rate": `{"entity_id":"input_number.unit_rate","state":"0.3512","attributes":{}}`,
+		"sensor.other":           `{"entity_id":"sensor.other","state":"unrelated","attributes":{}}`,
+		"input_number.broken":    `{"entity_id":"input_number.broken","state":"unavailable","attributes":{}}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st, ok := states[strings.TrimPrefix(r.URL.Path, "/api/states/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(st))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	tests := []struct {
+		entity  string
+		want    float64
+		wantErr bool
+	}{
+		{entity: "input_number.unit_rate", want: 0.3512},
+		{entity: "input_number.broken", wantErr: true},
+		{entity: "input_number.missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entity, func(t *testing.T) {
+			c := uploadCmd{server: host, token: "tok", rateEntity: tt.entity}
+			got, err := c.unitRate(t.Context())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unitRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("unitRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMPRNFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-mprn", "100"}, []string{"100"}},
+		{[]string{"-mprn", "100, 200", "-mprn", "300"}, []string{"100", "200", "300"}},
+		{[]string{"-mprn", "100,,200,"}, []string{"100", "200"}},
+		{[]string{"-mprn", "100", "-mprn", "", "-mprn", "200"}, []string{"200"}},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		var c downloadCmd
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Var(listFlag{&c.mprn}, "mprn", "")
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatalf("Parse(%q) unexpected error: %v", tt.args, err)
+		}
+		if diff := cmp.Diff(tt.want, c.mprns()); diff != "" {
+			t.Errorf("Parse(%q) mprns() unexpected diff (+got -want): %v", tt.args, diff)
+		}
+	}
+}
+
+func TestPipeSensors(t *testing.T) {
+	tests := []struct {
+		name        string
+		mprn        string
+		mprnSensors string
+		costSensor  string
+		want        []meterSensor
+		wantErr     bool
+	}{
+		{
+			name: "single meter",
+			mprn: "100",
+			want: []meterSensor{{"100", "sensor.esb"}},
+		},
+		{
+			name:        "mapped meters",
+			mprn:        "100,200,300",
+			mprnSensors: "200=sensor.flat, 300=sensor.shop",
+			want:        []meterSensor{{"100", "sensor.esb"}, {"200", "sensor.flat"}, {"300", "sensor.shop"}},
+		},
+		{
+			name:    "same sensor",
+			mprn:    "100,200",
+			wantErr: true,
+		},
+		{
+			name:        "unknown meter",
+			mprn:        "100",
+			mprnSensors: "200=sensor.flat",
+			wantErr:     true,
+		},
+		{
+			name:        "invalid mapping",
+			mprn:        "100,200",
+			mprnSensors: "200",
+			wantErr:     true,
+		},
+		{
+			name:        "shared cost sensor",
+			mprn:        "100,200",
+			mprnSensors: "200=sensor.flat",
+			costSensor:  "sensor.cost",
+			wantErr:     true,
+		},
+		{
+			name:    "no meters",
+			mprn:    " , ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c pipeCmd
+			c.esb.mprn, c.mprnSensors = tt.mprn, tt.mprnSensors
+			c.ha.sensor, c.ha.costSensor = "sensor.esb", tt.costSensor
+			got, err := c.sensors()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sensors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(meterSensor{})); diff != "" {
+				t.Errorf("sensors() unexpected diff (+got -want): %v", diff)
+			}
+		})
+	}
+}
+
+func TestSecretsFromFiles(t *testing.T) {
+	// The flags are read from the environment too.
+	t.Setenv("password", "")
+	t.Setenv("password_file", "")
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secret, []byte("s3cret\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "flag", args: []string{"-password", "flag"}, want: "flag"},
+		{name: "file", args: []string{"-password_file", secret}, want: "s3cret"},
+		{name: "both", args: []string{"-password", "flag", "-password_file", secret}, wantErr: true},
+		{name: "missing file", args: []string{"-password_file", filepath.Join(dir, "missing")}, wantErr: true},
+		// The flag is required, like without the file.
+		{name: "none", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			secretStringVar(fs, &got, "password", "the password")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			err := ensureFlagsAreSet(fs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ensureFlagsAreSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ensureFlagsAreSet() set %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Home Assistant is written in batches when it can.
+var _ sink.BatchStatisticsSink = (*ha.Connection)(nil)
+
+// fakeSink records the statistic ID and the hours of the batches
+// written, and fails the failAt-th write, counting from 1.
+type fakeSink struct {
+	written []string
+	failAt  int
+}
+
+func (f *fakeSink) WriteStatistics(_ context.Context, stat ha.Statistics) error {
+	if len(f.written)+1 == f.failAt {
+		return errors.New("rejected")
+	}
+	f.written = append(f.written, fmt.Sprintf("%s:%d", stat.Metadata.StatisticID, len(stat.Stats)))
+	return nil
+}
+
+func TestUploadSend(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hour := func(i int) time.Time { return start.Add(time.Duration(i) * time.Hour) }
+	stats := func(id string, n int) ha.Statistics {
+		s := ha.Statistics{Metadata: ha.StatisticMetadata{StatisticID: id, HasSum: true}}
+		for i := range n {
+			s.Stats = append(s.Stats, ha.StatisticValue{Start: hour(i), State: 1, Sum: float64(i + 1)})
+		}
+		return s
+	}
+	const b = uploadBatchHours
+
+	tests := []struct {
+		name       string
+		hours      int
+		costSensor string
+		failAt     int
+		want       []string
+		// acked is the last hour recorded as uploaded, -1 for none.
+		acked   int
+		wantErr bool
+	}{
+		{
+			name:  "batches",
+			hours: 2*b + 1,
+			want:  []string{fmt.Sprint("sensor.esb:", b), fmt.Sprint("sensor.esb:", b), "sensor.esb:1"},
+			acked: 2 * b,
+		},
+		{
+			name:       "with cost",
+			hours:      b + 1,
+			costSensor: "sensor.cost",
+			want:       []string{fmt.Sprint("sensor.esb:", b), fmt.Sprint("sensor.cost:", b), "sensor.esb:1", "sensor.cost:1"},
+			acked:      b,
+		},
+		{
+			// The hours are acked once the cost is written too.
+			name:       "cost rejected",
+			hours:      b + 1,
+			costSensor: "sensor.cost",
+			failAt:     4,
+			want:       []string{fmt.Sprint("sensor.esb:", b), fmt.Sprint("sensor.cost:", b), "sensor.esb:1"},
+			acked:      b - 1,
+			wantErr:    true,
+		},
+		{
+			name:    "rejected",
+			hours:   b + 1,
+			failAt:  1,
+			acked:   -1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := uploadCmd{sensor: "sensor.esb", costSensor: tt.costSensor, storePath: filepath.Join(t.TempDir(), "esb2ha.db")}
+			var cost ha.Statistics
+			if tt.costSensor != "" {
+				cost = stats(tt.costSensor, tt.hours)
+			}
+			out := &fakeSink{failAt: tt.failAt}
+			err := c.send(t.Context(), out, stats(c.sensor, tt.hours), cost)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, out.written); diff != "" {
+				t.Errorf("send() written unexpected diff (+got -want): %v", diff)
+			}
+
+			st, err := store.Open(c.storePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer st.Close()
+			until, ok, err := st.UploadProgress(c.sensor)
+			if err != nil {
+				t.Fatalf("UploadProgress() unexpected error: %v", err)
+			}
+			if wantOK := tt.acked >= 0; ok != wantOK || (ok && !until.Equal(hour(tt.acked))) {
+				t.Errorf("UploadProgress() = %v, %v, want hour %d", until, ok, tt.acked)
+			}
+		})
+	}
+}
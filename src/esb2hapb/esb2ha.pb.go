@@ -0,0 +1,672 @@
+// Protocol buffer definitions for the esb2ha gRPC service.
+//
+// To regenerate the Go code run `go generate` in this directory.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.28.3
+// source: esb2ha.proto
+
+package esb2hapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DownloadRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The MPRN to download, if empty the one configured on the server is used.
+	Mprn          string `protobuf:"bytes,1,opt,name=mprn,proto3" json:"mprn,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadRequest) Reset() {
+	*x = DownloadRequest{}
+	mi := &file_esb2ha_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadRequest) ProtoMessage() {}
+
+func (x *DownloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadRequest.ProtoReflect.Descriptor instead.
+func (*DownloadRequest) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DownloadRequest) GetMprn() string {
+	if x != nil {
+		return x.Mprn
+	}
+	return ""
+}
+
+type DownloadChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadChunk) Reset() {
+	*x = DownloadChunk{}
+	mi := &file_esb2ha_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadChunk) ProtoMessage() {}
+
+func (x *DownloadChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadChunk.ProtoReflect.Descriptor instead.
+func (*DownloadChunk) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DownloadChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ParseRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// A piece of the HDF file, the file is the concatenation of all the chunks.
+	Data          []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParseRequest) Reset() {
+	*x = ParseRequest{}
+	mi := &file_esb2ha_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseRequest) ProtoMessage() {}
+
+func (x *ParseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseRequest.ProtoReflect.Descriptor instead.
+func (*ParseRequest) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ParseRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ParseResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The blocks of contiguous reads, see parse.HDF.
+	Results       []*Result `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParseResponse) Reset() {
+	*x = ParseResponse{}
+	mi := &file_esb2ha_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseResponse) ProtoMessage() {}
+
+func (x *ParseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseResponse.ProtoReflect.Descriptor instead.
+func (*ParseResponse) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ParseResponse) GetResults() []*Result {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type SyncRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The MPRN to download, if empty the one configured on the server is used.
+	Mprn string `protobuf:"bytes,1,opt,name=mprn,proto3" json:"mprn,omitempty"`
+	// The Home Assistant sensor to update, if empty the one configured on the server is used.
+	Sensor        string `protobuf:"bytes,2,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncRequest) Reset() {
+	*x = SyncRequest{}
+	mi := &file_esb2ha_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncRequest) ProtoMessage() {}
+
+func (x *SyncRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncRequest.ProtoReflect.Descriptor instead.
+func (*SyncRequest) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SyncRequest) GetMprn() string {
+	if x != nil {
+		return x.Mprn
+	}
+	return ""
+}
+
+func (x *SyncRequest) GetSensor() string {
+	if x != nil {
+		return x.Sensor
+	}
+	return ""
+}
+
+type SyncEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The statistics sent to Home Assistant.
+	Statistics *Statistics `protobuf:"bytes,1,opt,name=statistics,proto3" json:"statistics,omitempty"`
+	// The error, if any, uploading this chunk.
+	Error         string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncEvent) Reset() {
+	*x = SyncEvent{}
+	mi := &file_esb2ha_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncEvent) ProtoMessage() {}
+
+func (x *SyncEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncEvent.ProtoReflect.Descriptor instead.
+func (*SyncEvent) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SyncEvent) GetStatistics() *Statistics {
+	if x != nil {
+		return x.Statistics
+	}
+	return nil
+}
+
+func (x *SyncEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Read is a single line of the HDF.
+type Read struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         float64                `protobuf:"fixed64,1,opt,name=value,proto3" json:"value,omitempty"`
+	EndTime       *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Read) Reset() {
+	*x = Read{}
+	mi := &file_esb2ha_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Read) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Read) ProtoMessage() {}
+
+func (x *Read) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Read.ProtoReflect.Descriptor instead.
+func (*Read) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Read) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *Read) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+// Result is a block of contiguous reads.
+type Result struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Mprn              string                 `protobuf:"bytes,1,opt,name=mprn,proto3" json:"mprn,omitempty"`
+	MeterSerialNumber string                 `protobuf:"bytes,2,opt,name=meter_serial_number,json=meterSerialNumber,proto3" json:"meter_serial_number,omitempty"`
+	ReadTypes         string                 `protobuf:"bytes,3,opt,name=read_types,json=readTypes,proto3" json:"read_types,omitempty"`
+	Reads             []*Read                `protobuf:"bytes,4,rep,name=reads,proto3" json:"reads,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	mi := &file_esb2ha_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Result) GetMprn() string {
+	if x != nil {
+		return x.Mprn
+	}
+	return ""
+}
+
+func (x *Result) GetMeterSerialNumber() string {
+	if x != nil {
+		return x.MeterSerialNumber
+	}
+	return ""
+}
+
+func (x *Result) GetReadTypes() string {
+	if x != nil {
+		return x.ReadTypes
+	}
+	return ""
+}
+
+func (x *Result) GetReads() []*Read {
+	if x != nil {
+		return x.Reads
+	}
+	return nil
+}
+
+// StatisticValue is a single data point imported to Home Assistant.
+type StatisticValue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	State         float64                `protobuf:"fixed64,2,opt,name=state,proto3" json:"state,omitempty"`
+	Sum           float64                `protobuf:"fixed64,3,opt,name=sum,proto3" json:"sum,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatisticValue) Reset() {
+	*x = StatisticValue{}
+	mi := &file_esb2ha_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatisticValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatisticValue) ProtoMessage() {}
+
+func (x *StatisticValue) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatisticValue.ProtoReflect.Descriptor instead.
+func (*StatisticValue) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StatisticValue) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *StatisticValue) GetState() float64 {
+	if x != nil {
+		return x.State
+	}
+	return 0
+}
+
+func (x *StatisticValue) GetSum() float64 {
+	if x != nil {
+		return x.Sum
+	}
+	return 0
+}
+
+// Statistics is a bundle of metadata and values sent to Home Assistant.
+type Statistics struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	StatisticId       string                 `protobuf:"bytes,1,opt,name=statistic_id,json=statisticId,proto3" json:"statistic_id,omitempty"`
+	UnitOfMeasurement string                 `protobuf:"bytes,2,opt,name=unit_of_measurement,json=unitOfMeasurement,proto3" json:"unit_of_measurement,omitempty"`
+	Stats             []*StatisticValue      `protobuf:"bytes,3,rep,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Statistics) Reset() {
+	*x = Statistics{}
+	mi := &file_esb2ha_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Statistics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Statistics) ProtoMessage() {}
+
+func (x *Statistics) ProtoReflect() protoreflect.Message {
+	mi := &file_esb2ha_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Statistics.ProtoReflect.Descriptor instead.
+func (*Statistics) Descriptor() ([]byte, []int) {
+	return file_esb2ha_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Statistics) GetStatisticId() string {
+	if x != nil {
+		return x.StatisticId
+	}
+	return ""
+}
+
+func (x *Statistics) GetUnitOfMeasurement() string {
+	if x != nil {
+		return x.UnitOfMeasurement
+	}
+	return ""
+}
+
+func (x *Statistics) GetStats() []*StatisticValue {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+var File_esb2ha_proto protoreflect.FileDescriptor
+
+const file_esb2ha_proto_rawDesc = "" +
+	"\n" +
+	"\fesb2ha.proto\x12\x06esb2ha\x1a\x1fgoogle/protobuf/timestamp.proto\"%\n" +
+	"\x0fDownloadRequest\x12\x12\n" +
+	"\x04mprn\x18\x01 \x01(\tR\x04mprn\"#\n" +
+	"\rDownloadChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"\"\n" +
+	"\fParseRequest\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"9\n" +
+	"\rParseResponse\x12(\n" +
+	"\aresults\x18\x01 \x03(\v2\x0e.esb2ha.ResultR\aresults\"9\n" +
+	"\vSyncRequest\x12\x12\n" +
+	"\x04mprn\x18\x01 \x01(\tR\x04mprn\x12\x16\n" +
+	"\x06sensor\x18\x02 \x01(\tR\x06sensor\"U\n" +
+	"\tSyncEvent\x122\n" +
+	"\n" +
+	"statistics\x18\x01 \x01(\v2\x12.esb2ha.StatisticsR\n" +
+	"statistics\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"S\n" +
+	"\x04Read\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\x01R\x05value\x125\n" +
+	"\bend_time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\"\x8f\x01\n" +
+	"\x06Result\x12\x12\n" +
+	"\x04mprn\x18\x01 \x01(\tR\x04mprn\x12.\n" +
+	"\x13meter_serial_number\x18\x02 \x01(\tR\x11meterSerialNumber\x12\x1d\n" +
+	"\n" +
+	"read_types\x18\x03 \x01(\tR\treadTypes\x12\"\n" +
+	"\x05reads\x18\x04 \x03(\v2\f.esb2ha.ReadR\x05reads\"j\n" +
+	"\x0eStatisticValue\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\x01R\x05state\x12\x10\n" +
+	"\x03sum\x18\x03 \x01(\x01R\x03sum\"\x8d\x01\n" +
+	"\n" +
+	"Statistics\x12!\n" +
+	"\fstatistic_id\x18\x01 \x01(\tR\vstatisticId\x12.\n" +
+	"\x13unit_of_measurement\x18\x02 \x01(\tR\x11unitOfMeasurement\x12,\n" +
+	"\x05stats\x18\x03 \x03(\v2\x16.esb2ha.StatisticValueR\x05stats2\xb0\x01\n" +
+	"\x06ESB2HA\x12<\n" +
+	"\bDownload\x12\x17.esb2ha.DownloadRequest\x1a\x15.esb2ha.DownloadChunk0\x01\x126\n" +
+	"\x05Parse\x12\x14.esb2ha.ParseRequest\x1a\x15.esb2ha.ParseResponse(\x01\x120\n" +
+	"\x04Sync\x12\x13.esb2ha.SyncRequest\x1a\x11.esb2ha.SyncEvent0\x01B&Z$github.com/lorentz83/esb2ha/esb2hapbb\x06proto3"
+
+var (
+	file_esb2ha_proto_rawDescOnce sync.Once
+	file_esb2ha_proto_rawDescData []byte
+)
+
+func file_esb2ha_proto_rawDescGZIP() []byte {
+	file_esb2ha_proto_rawDescOnce.Do(func() {
+		file_esb2ha_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_esb2ha_proto_rawDesc), len(file_esb2ha_proto_rawDesc)))
+	})
+	return file_esb2ha_proto_rawDescData
+}
+
+var file_esb2ha_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_esb2ha_proto_goTypes = []any{
+	(*DownloadRequest)(nil),       // 0: esb2ha.DownloadRequest
+	(*DownloadChunk)(nil),         // 1: esb2ha.DownloadChunk
+	(*ParseRequest)(nil),          // 2: esb2ha.ParseRequest
+	(*ParseResponse)(nil),         // 3: esb2ha.ParseResponse
+	(*SyncRequest)(nil),           // 4: esb2ha.SyncRequest
+	(*SyncEvent)(nil),             // 5: esb2ha.SyncEvent
+	(*Read)(nil),                  // 6: esb2ha.Read
+	(*Result)(nil),                // 7: esb2ha.Result
+	(*StatisticValue)(nil),        // 8: esb2ha.StatisticValue
+	(*Statistics)(nil),            // 9: esb2ha.Statistics
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_esb2ha_proto_depIdxs = []int32{
+	7,  // 0: esb2ha.ParseResponse.results:type_name -> esb2ha.Result
+	9,  // 1: esb2ha.SyncEvent.statistics:type_name -> esb2ha.Statistics
+	10, // 2: esb2ha.Read.end_time:type_name -> google.protobuf.Timestamp
+	6,  // 3: esb2ha.Result.reads:type_name -> esb2ha.Read
+	10, // 4: esb2ha.StatisticValue.start:type_name -> google.protobuf.Timestamp
+	8,  // 5: esb2ha.Statistics.stats:type_name -> esb2ha.StatisticValue
+	0,  // 6: esb2ha.ESB2HA.Download:input_type -> esb2ha.DownloadRequest
+	2,  // 7: esb2ha.ESB2HA.Parse:input_type -> esb2ha.ParseRequest
+	4,  // 8: esb2ha.ESB2HA.Sync:input_type -> esb2ha.SyncRequest
+	1,  // 9: esb2ha.ESB2HA.Download:output_type -> esb2ha.DownloadChunk
+	3,  // 10: esb2ha.ESB2HA.Parse:output_type -> esb2ha.ParseResponse
+	5,  // 11: esb2ha.ESB2HA.Sync:output_type -> esb2ha.SyncEvent
+	9,  // [9:12] is the sub-list for method output_type
+	6,  // [6:9] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_esb2ha_proto_init() }
+func file_esb2ha_proto_init() {
+	if File_esb2ha_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_esb2ha_proto_rawDesc), len(file_esb2ha_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_esb2ha_proto_goTypes,
+		DependencyIndexes: file_esb2ha_proto_depIdxs,
+		MessageInfos:      file_esb2ha_proto_msgTypes,
+	}.Build()
+	File_esb2ha_proto = out.File
+	file_esb2ha_proto_goTypes = nil
+	file_esb2ha_proto_depIdxs = nil
+}
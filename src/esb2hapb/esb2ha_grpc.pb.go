@@ -0,0 +1,212 @@
+// Protocol buffer definitions for the esb2ha gRPC service.
+//
+// To regenerate the Go code run `go generate` in this directory.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             v5.28.3
+// source: esb2ha.proto
+
+package esb2hapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ESB2HA_Download_FullMethodName = "/esb2ha.ESB2HA/Download"
+	ESB2HA_Parse_FullMethodName    = "/esb2ha.ESB2HA/Parse"
+	ESB2HA_Sync_FullMethodName     = "/esb2ha.ESB2HA/Sync"
+)
+
+// ESB2HAClient is the client API for ESB2HA service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ESB2HA exposes the esb2ha pipeline to other services.
+type ESB2HAClient interface {
+	// Download streams the raw HDF file downloaded from esbnetworks.ie.
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadChunk], error)
+	// Parse parses an HDF file streamed by the client.
+	Parse(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ParseRequest, ParseResponse], error)
+	// Sync downloads the data from ESB and uploads it to Home Assistant,
+	// streaming an event for every chunk uploaded.
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SyncEvent], error)
+}
+
+type eSB2HAClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewESB2HAClient(cc grpc.ClientConnInterface) ESB2HAClient {
+	return &eSB2HAClient{cc}
+}
+
+func (c *eSB2HAClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ESB2HA_ServiceDesc.Streams[0], ESB2HA_Download_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DownloadRequest, DownloadChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ESB2HA_DownloadClient = grpc.ServerStreamingClient[DownloadChunk]
+
+func (c *eSB2HAClient) Parse(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ParseRequest, ParseResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ESB2HA_ServiceDesc.Streams[1], ESB2HA_Parse_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ParseRequest, ParseResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ESB2HA_ParseClient = grpc.ClientStreamingClient[ParseRequest, ParseResponse]
+
+func (c *eSB2HAClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SyncEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ESB2HA_ServiceDesc.Streams[2], ESB2HA_Sync_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SyncRequest, SyncEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ESB2HA_SyncClient = grpc.ServerStreamingClient[SyncEvent]
+
+// ESB2HAServer is the server API for ESB2HA service.
+// All implementations must embed UnimplementedESB2HAServer
+// for forward compatibility.
+//
+// ESB2HA exposes the esb2ha pipeline to other services.
+type ESB2HAServer interface {
+	// Download streams the raw HDF file downloaded from esbnetworks.ie.
+	Download(*DownloadRequest, grpc.ServerStreamingServer[DownloadChunk]) error
+	// Parse parses an HDF file streamed by the client.
+	Parse(grpc.ClientStreamingServer[ParseRequest, ParseResponse]) error
+	// Sync downloads the data from ESB and uploads it to Home Assistant,
+	// streaming an event for every chunk uploaded.
+	Sync(*SyncRequest, grpc.ServerStreamingServer[SyncEvent]) error
+	mustEmbedUnimplementedESB2HAServer()
+}
+
+// UnimplementedESB2HAServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedESB2HAServer struct{}
+
+func (UnimplementedESB2HAServer) Download(*DownloadRequest, grpc.ServerStreamingServer[DownloadChunk]) error {
+	return status.Error(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedESB2HAServer) Parse(grpc.ClientStreamingServer[ParseRequest, ParseResponse]) error {
+	return status.Error(codes.Unimplemented, "method Parse not implemented")
+}
+func (UnimplementedESB2HAServer) Sync(*SyncRequest, grpc.ServerStreamingServer[SyncEvent]) error {
+	return status.Error(codes.Unimplemented, "method Sync not implemented")
+}
+func (UnimplementedESB2HAServer) mustEmbedUnimplementedESB2HAServer() {}
+func (UnimplementedESB2HAServer) testEmbeddedByValue()                {}
+
+// UnsafeESB2HAServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ESB2HAServer will
+// result in compilation errors.
+type UnsafeESB2HAServer interface {
+	mustEmbedUnimplementedESB2HAServer()
+}
+
+func RegisterESB2HAServer(s grpc.ServiceRegistrar, srv ESB2HAServer) {
+	// If the following call panics, it indicates UnimplementedESB2HAServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ESB2HA_ServiceDesc, srv)
+}
+
+func _ESB2HA_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ESB2HAServer).Download(m, &grpc.GenericServerStream[DownloadRequest, DownloadChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ESB2HA_DownloadServer = grpc.ServerStreamingServer[DownloadChunk]
+
+func _ESB2HA_Parse_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ESB2HAServer).Parse(&grpc.GenericServerStream[ParseRequest, ParseResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ESB2HA_ParseServer = grpc.ClientStreamingServer[ParseRequest, ParseResponse]
+
+func _ESB2HA_Sync_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SyncRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ESB2HAServer).Sync(m, &grpc.GenericServerStream[SyncRequest, SyncEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ESB2HA_SyncServer = grpc.ServerStreamingServer[SyncEvent]
+
+// ESB2HA_ServiceDesc is the grpc.ServiceDesc for ESB2HA service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ESB2HA_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "esb2ha.ESB2HA",
+	HandlerType: (*ESB2HAServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Download",
+			Handler:       _ESB2HA_Download_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Parse",
+			Handler:       _ESB2HA_Parse_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Sync",
+			Handler:       _ESB2HA_Sync_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "esb2ha.proto",
+}
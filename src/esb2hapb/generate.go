@@ -0,0 +1,4 @@
+// Package esb2hapb contains the generated gRPC code of the esb2ha service.
+package esb2hapb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative esb2ha.proto
@@ -3,6 +3,7 @@ package esblib
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,16 +12,20 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"time"
 
 	"golang.org/x/net/html"
 	"golang.org/x/net/publicsuffix"
 )
 
+// DefaultBaseURL is the ESB website, see Client.BaseURL.
+const DefaultBaseURL = `https://myaccount.esbnetworks.ie`
+
+// Paths of the ESB website, under Client.BaseURL.
 const (
-	baseURL                = `https://myaccount.esbnetworks.ie`
-	dataURL                = `https://myaccount.esbnetworks.ie/DataHub/DownloadHdfPeriodic`
-	prepareURL             = `https://myaccount.esbnetworks.ie/af/t`
-	historicConsumptionURL = `https://myaccount.esbnetworks.ie/Api/HistoricConsumption`
+	dataPath                = `/DataHub/DownloadHdfPeriodic`
+	preparePath             = `/af/t`
+	historicConsumptionPath = `/Api/HistoricConsumption`
 )
 
 const (
@@ -51,7 +56,7 @@ func attributesToMap(aa []html.Attribute) map[string]string {
 
 // htmlFormToRequest parses an HTML fragment looking for a form and populating
 // an http.Request with the hidden data from the form.
-func htmlFormToRequest(fragment []byte) (*http.Request, error) {
+func htmlFormToRequest(ctx context.Context, fragment []byte) (*http.Request, error) {
 	doc, err := html.Parse(bytes.NewReader(fragment))
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse HTML: %w", err)
@@ -81,8 +86,11 @@ func htmlFormToRequest(fragment []byte) (*http.Request, error) {
 		}
 	}
 	traverse(doc)
+	if reqURL == "" {
+		return nil, fmt.Errorf("cannot find the login form: %w", ErrPortalChanged)
+	}
 
-	req, err := http.NewRequest(method, reqURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -91,42 +99,191 @@ func htmlFormToRequest(fragment []byte) (*http.Request, error) {
 	return req, nil
 }
 
+// Login phases reported by Hooks.OnLoginPhase, in order.
+const (
+	LoginPhaseLoadPage    = "load_page"
+	LoginPhaseCredentials = "credentials"
+	LoginPhaseRedirect    = "redirect"
+	// LoginPhaseMFA is only for the accounts with multi-factor
+	// authentication, see Client.OneTimeCode.
+	LoginPhaseMFA      = "mfa"
+	LoginPhaseFinalize = "finalize"
+)
+
+// Hooks are optional callbacks reporting what the client is doing, so
+// applications can drive progress UIs and metrics without parsing logs.
+//
+// They are called synchronously, any of them can be nil.
+type Hooks struct {
+	// OnLoginPhase is called when a phase of the login starts.
+	OnLoginPhase func(phase string)
+	// OnError is called with the errors returned by Login and
+	// OpenPowerConsumption.
+	OnError func(err error)
+	// OnRetry is called when a request failed with a transient error,
+	// before waiting to retry it.
+	OnRetry func(err error, wait time.Duration)
+	// OnDownloadProgress is called after every read of the data of
+	// OpenPowerConsumption, with the bytes read so far and the size of
+	// the file, -1 if unknown, like when it is split in pages.
+	OnDownloadProgress func(read, total int64)
+}
+
+func (h Hooks) loginPhase(phase string) {
+	if h.OnLoginPhase != nil {
+		h.OnLoginPhase(phase)
+	}
+}
+
+func (h Hooks) error(err error) {
+	if err != nil && h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
+// Errors wrapped by the ones of the client, to tell them apart with
+// errors.Is.
+var (
+	// ErrLoginExpired is returned by the downloads when the login
+	// expired, or there was none: log in again.
+	ErrLoginExpired = errors.New("login expired or invalid")
+	// ErrSessionExpired is another name of ErrLoginExpired.
+	ErrSessionExpired = ErrLoginExpired
+	// ErrInvalidCredentials is returned by Login when ESB refuses the
+	// user name, the password or the one time code: retrying doesn't
+	// help.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrMPRNNotFound is returned by the downloads when the meter is not
+	// linked to the account.
+	ErrMPRNNotFound = errors.New("mprn not found")
+	// ErrPortalChanged is returned when the pages of the website are not
+	// the ones the client knows: it needs an update.
+	ErrPortalChanged = errors.New("the ESB website changed")
+)
+
 // Client connects to esbnetworks.ie website to download usage data.
 type Client struct {
+	// Hooks can be set to follow the progress of the client.
+	Hooks Hooks
+	// Retry is how the failed downloads are retried, DefaultRetryPolicy
+	// unless changed.
+	Retry RetryPolicy
+	// OneTimeCode returns the code asked by Login after the password
+	// when the account has multi-factor authentication enabled, kind is
+	// MFAEmail or MFATOTP. Login fails with ErrMFARequired if nil.
+	OneTimeCode func(kind string) (string, error)
+	// BaseURL is the website the client connects to, DefaultBaseURL
+	// unless changed, like to the fake one of esbtest or with
+	// WithBaseURL. The login follows the redirects to wherever the
+	// website sends it.
+	BaseURL string
+	// Relogin makes Login remember the user name and the password, so
+	// the downloads log in again, once, when the login expired, instead
+	// of failing with ErrLoginExpired. It is off unless set, to not keep
+	// the password in memory.
+	Relogin bool
+
+	// user and password are the ones of the last Login, with Relogin.
+	user, password string
+
 	// Both the clients share the same cookie jar, but the second
 	// is configured to not follow redirects. It is useful to identify expired logins.
 	hc         *http.Client
 	noRedirect *http.Client
 }
 
+// clientOptions are set by the ClientOptions of NewClient.
+type clientOptions struct {
+	baseURL   string
+	hc        *http.Client
+	userAgent string
+}
+
+// ClientOption changes how NewClient connects to the ESB website.
+type ClientOption func(*clientOptions)
+
+// WithBaseURL connects to another website than DefaultBaseURL, like a
+// mirror or a test server.
+func WithBaseURL(u string) ClientOption {
+	return func(o *clientOptions) { o.baseURL = u }
+}
+
+// WithHTTPClient sends the requests with a copy of hc, like one with a
+// proxy or a timeout in its transport.
+//
+// The copy gets its own cookie jar if hc has none, the login needs it.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(o *clientOptions) { o.hc = hc }
+}
+
+// WithUserAgent sets the User-Agent header of all the requests.
+func WithUserAgent(ua string) ClientOption {
+	return func(o *clientOptions) { o.userAgent = ua }
+}
+
 // NewClient returns a new ESB client.
-func NewClient() (*Client, error) {
-	j, err := cookiejar.New(&cookiejar.Options{
-		PublicSuffixList: publicsuffix.List,
-	})
-	if err != nil {
-		return nil, err
+func NewClient(opts ...ClientOption) (*Client, error) {
+	o := clientOptions{baseURL: DefaultBaseURL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	hc := &http.Client{}
+	if o.hc != nil {
+		cp := *o.hc
+		hc = &cp
+	}
+	if hc.Jar == nil {
+		j, err := cookiejar.New(&cookiejar.Options{
+			PublicSuffixList: publicsuffix.List,
+		})
+		if err != nil {
+			return nil, err
+		}
+		hc.Jar = j
+	}
+	if o.userAgent != "" {
+		hc.Transport = userAgentTransport{base: hc.Transport, userAgent: o.userAgent}
+	}
+	noRedirect := *hc
+	noRedirect.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
 	}
 
 	return &Client{
-		hc: &http.Client{
-			Jar: j,
-		},
-		noRedirect: &http.Client{
-			Jar: j,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		},
+		Retry:      DefaultRetryPolicy,
+		BaseURL:    o.baseURL,
+		hc:         hc,
+		noRedirect: &noRedirect,
 	}, nil
 }
 
+// userAgentTransport sets the User-Agent of the requests sent by base,
+// http.DefaultTransport if nil.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// A RoundTripper must not change the request.
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return base.RoundTrip(req)
+}
+
 // Login logs in into esb.
 //
 // Note that login expires after a short amount of minutes (currently 20).
 // Unless you need to download usage data for multiple smart meters from the
 // same account, you should always call Login immediately before DownloadPowerConsumption.
-func (c *Client) Login(user, password string) error {
+func (c *Client) Login(ctx context.Context, user, password string) (err error) {
+	defer func() { c.Hooks.error(err) }()
+
 	if user == "" {
 		return errors.New("missing user name")
 	}
@@ -134,32 +291,95 @@ func (c *Client) Login(user, password string) error {
 		return errors.New("missing password")
 	}
 
-	pr, err := c.loadLoginPage()
+	c.Hooks.loginPhase(LoginPhaseLoadPage)
+	pr, err := c.loadLoginPage(ctx)
 	if err != nil {
 		return err
 	}
 
-	if err := c.postLogin(pr, user, password); err != nil {
+	c.Hooks.loginPhase(LoginPhaseCredentials)
+	if err := c.postLogin(ctx, pr, user, password); err != nil {
 		return err
 	}
 
-	req, err := c.getRedirect(pr)
+	c.Hooks.loginPhase(LoginPhaseRedirect)
+	req, mfa, err := c.getRedirect(ctx, pr)
 	if err != nil {
 		return err
 	}
+	if mfa != nil {
+		c.Hooks.loginPhase(LoginPhaseMFA)
+		if err := c.answerMFA(ctx, *mfa, user); err != nil {
+			return err
+		}
+		if req, mfa, err = c.getRedirect(ctx, mfa.settings); err != nil {
+			return err
+		}
+		if mfa != nil {
+			return errors.New("the one time code was not accepted")
+		}
+	}
 
-	if err := c.finalizeLogin(req); err != nil {
+	c.Hooks.loginPhase(LoginPhaseFinalize)
+	if err := c.finalizeLogin(ctx, req); err != nil {
 		return err
 	}
 
+	if c.Relogin {
+		c.user, c.password = user, password
+	}
+
 	return nil
 }
 
+// Cookies returns the cookies of the ESB website, to reuse the login in
+// another Client, with SetCookies, until it expires.
+func (c *Client) Cookies() []*http.Cookie {
+	return c.hc.Jar.Cookies(c.siteURL())
+}
+
+// SetCookies sets the cookies returned by Cookies, instead of logging in.
+func (c *Client) SetCookies(cookies []*http.Cookie) {
+	c.hc.Jar.SetCookies(c.siteURL(), cookies)
+}
+
+// withRelogin calls f, and calls it again after logging in if the login
+// expired, see Relogin.
+func (c *Client) withRelogin(ctx context.Context, f func() error) error {
+	err := f()
+	if !errors.Is(err, ErrLoginExpired) || !c.Relogin || c.user == "" {
+		return err
+	}
+	if err := c.Login(ctx, c.user, c.password); err != nil {
+		return fmt.Errorf("the login expired, cannot log in again: %w", err)
+	}
+	return f()
+}
+
+// siteURL is BaseURL, for the cookie jar.
+func (c *Client) siteURL() *url.URL {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		// The jar ignores the cookies of a nil URL.
+		return &url.URL{}
+	}
+	return u
+}
+
+// url returns the URL of a path of the website.
+func (c *Client) url(path string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + path
+}
+
 // loadLoginPage is the 1st step of the login process.
 //
 // It returns the login settings required by the next steps.
-func (c *Client) loadLoginPage() (loginSettings, error) {
-	rsp, err := c.hc.Get(baseURL)
+func (c *Client) loadLoginPage(ctx context.Context) (loginSettings, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL, nil)
+	if err != nil {
+		return loginSettings{}, err
+	}
+	rsp, err := c.hc.Do(req)
 	if err != nil {
 		return loginSettings{}, err
 	}
@@ -170,18 +390,35 @@ func (c *Client) loadLoginPage() (loginSettings, error) {
 		return loginSettings{}, err
 	}
 
+	sj, ok, err := parsePageSettings(b)
+	if err != nil {
+		return loginSettings{}, err
+	}
+	if !ok {
+		return loginSettings{}, fmt.Errorf("cannot find page settings: %w", ErrPortalChanged)
+	}
+
+	return loginSettings{
+		loginURL: rsp.Request.URL,
+		settings: sj,
+	}, nil
+}
+
+// parsePageSettings returns the SETTINGS of a login page, false if the
+// page has none.
+func parsePageSettings(page []byte) (pageSettings, bool, error) {
 	const settingsPrefix = "var SETTINGS = "
 	// Here we assume that SETTINGS is on a single line.
 	// To make it more robust we should use some JS parser.
 	var settings string
-	for _, l := range strings.Split(string(b), "\n") {
+	for _, l := range strings.Split(string(page), "\n") {
 		if strings.HasPrefix(l, settingsPrefix) {
 			settings = l
 			break
 		}
 	}
 	if settings == "" {
-		return loginSettings{}, errors.New("cannot find page settings")
+		return pageSettings{}, false, nil
 	}
 	settings = strings.TrimPrefix(settings, settingsPrefix)
 	settings = strings.TrimRightFunc(settings, func(r rune) bool {
@@ -190,27 +427,27 @@ func (c *Client) loadLoginPage() (loginSettings, error) {
 
 	var sj pageSettings
 	if err := json.Unmarshal(([]byte)(settings), &sj); err != nil {
-		return loginSettings{}, err
+		return pageSettings{}, false, fmt.Errorf("cannot parse page settings: %w: %w", ErrPortalChanged, err)
 	}
-
-	return loginSettings{
-		loginURL: rsp.Request.URL,
-		settings: sj,
-	}, nil
+	return sj, true, nil
 }
 
 // postLogin is the 2nd step of the login process.
 //
 // It is the one which actually sends the login information for authentication.
-func (c *Client) postLogin(ls loginSettings, user, password string) error {
-	u := ls.PostLoginURL()
-
+func (c *Client) postLogin(ctx context.Context, ls loginSettings, user, password string) error {
 	data := url.Values{}
 	data.Set("signInName", user)
 	data.Set("password", password)
 	data.Set("request_type", "RESPONSE")
 
-	req, err := http.NewRequest("POST", u.String(), strings.NewReader(data.Encode()))
+	return c.postSelfAsserted(ctx, ls.PostLoginURL(), ls, data)
+}
+
+// postSelfAsserted posts the data of a step of the login, and checks
+// the status in the response.
+func (c *Client) postSelfAsserted(ctx context.Context, u *url.URL, ls loginSettings, data url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(data.Encode()))
 	if err != nil {
 		return err
 	}
@@ -234,12 +471,16 @@ func (c *Client) postLogin(ls loginSettings, user, password string) error {
 
 	var rs struct{ Status, ErrorCode, Message string }
 	if err := json.Unmarshal(body, &rs); err != nil {
-		return fmt.Errorf("cannot parse response: %w", err)
-	}
-	if rs.Status != "200" {
-		if rs.Message == "" {
-			return fmt.Errorf("invalid status %v", string(body))
-		}
+		return fmt.Errorf("cannot parse response: %w: %w", ErrPortalChanged, err)
+	}
+	switch {
+	case rs.Status == "200":
+	case rs.Message == "":
+		return fmt.Errorf("invalid status %v", string(body))
+	case rs.Status == "400":
+		// The errors of what the user typed.
+		return fmt.Errorf("%w (error %s: %s)", ErrInvalidCredentials, rs.ErrorCode, rs.Message)
+	default:
 		return fmt.Errorf("error %s: %s", rs.ErrorCode, rs.Message)
 	}
 
@@ -251,27 +492,39 @@ func (c *Client) postLogin(ls loginSettings, user, password string) error {
 // It loads teh redirect page and parses its content to return
 // the last request required to move back the authentication results to
 // the ESB website.
-func (c *Client) getRedirect(pr loginSettings) (*http.Request, error) {
+// With multi-factor authentication, the page asks for the one time code
+// instead, and the challenge is returned.
+func (c *Client) getRedirect(ctx context.Context, pr loginSettings) (*http.Request, *mfaChallenge, error) {
 	url := pr.RedirectURL()
 
-	rsp, err := c.hc.Get(url.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	rsp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer rsp.Body.Close()
 
 	body, err := io.ReadAll(rsp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read http response: %w", err)
+		return nil, nil, fmt.Errorf("cannot read http response: %w", err)
 	}
 
-	return htmlFormToRequest(body)
+	mfa, err := parseMFAChallenge(rsp.Request.URL, body)
+	if err != nil || mfa != nil {
+		return nil, mfa, err
+	}
+	req, err = htmlFormToRequest(ctx, body)
+	return req, nil, err
 }
 
 // finalizeLogin is the 4th and last step of the login.
 //
 // Here we load the actual ESB website and authenticate on it.
-func (c *Client) finalizeLogin(req *http.Request) error {
+func (c *Client) finalizeLogin(ctx context.Context, req *http.Request) error {
+	req = req.WithContext(ctx)
 	rsp, err := c.hc.Do(req)
 	if err != nil {
 		return err
@@ -287,73 +540,171 @@ func (c *Client) finalizeLogin(req *http.Request) error {
 //
 // You have to had a successful call of login in the last few minutes
 // (currently 20) or you'll get an error here.
-func (c *Client) DownloadPowerConsumption(mprn string, format Format) ([]byte, error) {
+//
+// A connection dropped while reading the data is retried with the Retry
+// policy, downloading the data again.
+func (c *Client) DownloadPowerConsumption(ctx context.Context, mprn string, format Format) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		body, err := c.OpenPowerConsumption(ctx, mprn, format)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err == nil || attempt >= c.Retry.MaxAttempts || ctx.Err() != nil {
+			return data, err
+		}
+		w := c.Retry.wait(attempt)
+		if c.Hooks.OnRetry != nil {
+			c.Hooks.OnRetry(err, w)
+		}
+		sleep(ctx, w)
+	}
+}
+
+// OpenPowerConsumption is like DownloadPowerConsumption, but streams the
+// data instead of reading it all in memory.
+//
+// The caller must close the returned reader, which keeps the HTTP
+// request open until then. The requests are retried with the Retry
+// policy, but a connection dropped while reading is returned as error
+// by the reader, since the data already read can't be taken back.
+func (c *Client) OpenPowerConsumption(ctx context.Context, mprn string, format Format) (body io.ReadCloser, err error) {
+	defer func() { c.Hooks.error(err) }()
+
 	if mprn == "" {
 		return nil, errors.New("missing mprn")
 	}
 
-	xsrf, err := c.prepareDownload()
+	var xsrf string
+	params := map[string]string{"mprn": mprn, "searchType": format.String()}
+	rsp, err := c.postDownload(ctx, c.url(dataPath), params, &xsrf)
 	if err != nil {
 		return nil, err
 	}
+	body = rsp.Body
+	if c.Hooks.OnDownloadProgress != nil {
+		body = &progressBody{ReadCloser: body, total: rsp.ContentLength, progress: c.Hooks.OnDownloadProgress}
+	}
+	return body, nil
+}
 
-	reqBody, err := json.Marshal(map[string]string{"mprn": mprn, "searchType": format.String()})
-	if err != nil {
-		return nil, fmt.Errorf("cannot prepare JSON request: %v", err)
+// progressBody reports the bytes read to Hooks.OnDownloadProgress.
+type progressBody struct {
+	io.ReadCloser
+	read, total int64
+	progress    func(read, total int64)
+}
+
+func (p *progressBody) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.progress(p.read, p.total)
 	}
+	return n, err
+}
+
+// postDownload is postData for the downloads: it gets the token of the
+// downloads first, if xsrf is empty, and logs in again if the login
+// expired, see Relogin.
+//
+// The token is kept in xsrf for the next requests, a new login needs a
+// new one.
+func (c *Client) postDownload(ctx context.Context, url string, params map[string]string, xsrf *string) (rsp *http.Response, err error) {
+	err = c.withRelogin(ctx, func() error {
+		if *xsrf == "" {
+			if *xsrf, err = c.prepareDownload(ctx); err != nil {
+				return err
+			}
+		}
+		rsp, err = c.postData(ctx, url, params, *xsrf)
+		if errors.Is(err, ErrLoginExpired) {
+			*xsrf = ""
+		}
+		return err
+	})
+	return rsp, err
+}
 
-	req, err := http.NewRequest(http.MethodPost, dataURL, bytes.NewReader(reqBody))
+// postData posts the JSON params to a datahub endpoint, the body of the
+// response must be closed.
+func (c *Client) postData(ctx context.Context, url string, params map[string]string, xsrf string) (*http.Response, error) {
+	reqBody, err := json.Marshal(params)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create http request: %v", err)
+		return nil, fmt.Errorf("cannot prepare JSON request: %v", err)
 	}
 
-	req.Header.Add("content-type", "application/json")
-	req.Header.Add("x-returnurl", historicConsumptionURL)
-	req.Header.Add("Referer", historicConsumptionURL)
-	req.Header.Add("Origin", baseURL)
-	req.Header.Add("x-xsrf-token", xsrf)
+	rsp, err := c.retry(ctx, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, permanentError{fmt.Errorf("cannot create http request: %v", err)}
+		}
 
-	rsp, err := c.noRedirect.Do(req)
+		req.Header.Add("content-type", "application/json")
+		req.Header.Add("x-returnurl", c.url(historicConsumptionPath))
+		req.Header.Add("Referer", c.url(historicConsumptionPath))
+		req.Header.Add("Origin", c.BaseURL)
+		req.Header.Add("x-xsrf-token", xsrf)
 
+		return c.noRedirect.Do(req)
+	})
 	if err != nil {
 		return nil, err
 	}
 	switch rsp.StatusCode {
 	case http.StatusOK:
-		// No error, move on.
-		break
+		return rsp, nil
 	case http.StatusFound:
-		return nil, errors.New("login expired or invalid")
+		err = ErrLoginExpired
 	case http.StatusNotFound:
-		return nil, fmt.Errorf("not found, is the mprn %q correct and linked to this account?", mprn)
+		err = fmt.Errorf("%w, is the mprn %q correct and linked to this account?", ErrMPRNNotFound, params["mprn"])
 	default:
-		return nil, fmt.Errorf("status %v", rsp.Status)
+		err = fmt.Errorf("status %v", rsp.Status)
 	}
-	defer rsp.Body.Close()
+	rsp.Body.Close()
+	return nil, err
+}
 
-	// We could stream data to save some memory, but I don't like the idea of
-	// having a pending HTTP request around for too long.
-	// We can change and optimize later if needed.
-	body, err := io.ReadAll(rsp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+// retry sends the request with the retry policy of the client, until
+// ctx is done.
+func (c *Client) retry(ctx context.Context, send func() (*http.Response, error)) (*http.Response, error) {
+	return c.Retry.do(func() (*http.Response, error) {
+		rsp, err := send()
+		if err != nil && ctx.Err() != nil {
+			// Canceled, trying again would fail the same way.
+			return rsp, permanentError{err}
+		}
+		return rsp, err
+	}, func(d time.Duration) { sleep(ctx, d) }, c.Hooks.OnRetry)
 }
 
-func (c *Client) prepareDownload() (string, error) {
-	req, err := http.NewRequest("GET", prepareURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("cannot prepare request: %v", err)
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
 	}
+}
+
+func (c *Client) prepareDownload(ctx context.Context) (string, error) {
+	got, err := c.retry(ctx, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(preparePath), nil)
+		if err != nil {
+			return nil, permanentError{fmt.Errorf("cannot prepare request: %v", err)}
+		}
 
-	req.Header.Add("x-ReturnUrl", historicConsumptionURL)
-	req.Header.Add("Referer", historicConsumptionURL)
+		req.Header.Add("x-ReturnUrl", c.url(historicConsumptionPath))
+		req.Header.Add("Referer", c.url(historicConsumptionPath))
 
-	got, err := c.noRedirect.Do(req)
+		return c.noRedirect.Do(req)
+	})
 	if err != nil {
-		return "", fmt.Errorf("preparing download error: %v", err)
+		return "", fmt.Errorf("preparing download error: %w", err)
 	}
+	got.Body.Close()
 
 	for _, c := range got.Cookies() {
 		if c.Name == "XSRF-TOKEN" {
@@ -361,7 +712,7 @@ func (c *Client) prepareDownload() (string, error) {
 		}
 	}
 
-	return "", errors.New("cannot find XSRF-TOKEN while preparing for download")
+	return "", fmt.Errorf("cannot find XSRF-TOKEN while preparing for download: %w", ErrPortalChanged)
 }
 
 // pageSettings is a struct to de-serialize the "SETTINGS" json defined on top of the login page.
@@ -377,7 +728,7 @@ type pageSettings struct {
 }
 
 type loginSettings struct {
-	// LoginURL is actual login URL, which is the one we get redirected from baseURL.
+	// LoginURL is actual login URL, which is the one we get redirected from BaseURL.
 	loginURL *url.URL
 	// internal page settings.
 	settings pageSettings
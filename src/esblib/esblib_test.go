@@ -1,6 +1,9 @@
 package esblib
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -26,7 +29,7 @@ const fragment = `<!DOCTYPE html PUBLIC '-//W3C//DTD XHTML 1.0 Transitional//EN'
 //--></script></form></body></html>`
 
 func TestHTMLFormToRequest(t *testing.T) {
-	req, err := htmlFormToRequest(([]byte)(fragment))
+	req, err := htmlFormToRequest(t.Context(), ([]byte)(fragment))
 	if err != nil {
 		t.Errorf("htmlFormToRequest() unexpected error: %v", err)
 	}
@@ -40,3 +43,56 @@ func TestHTMLFormToRequest(t *testing.T) {
 	}
 	// TODO: it would be nice to test we pass the form data.
 }
+
+func TestCookies(t *testing.T) {
+	a, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	a.SetCookies([]*http.Cookie{{Name: "session", Value: "abc"}})
+
+	b, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	b.SetCookies(a.Cookies())
+	got := b.Cookies()
+	if len(got) != 1 || got[0].Name != "session" || got[0].Value != "abc" {
+		t.Errorf("Cookies() = %v, want session=abc", got)
+	}
+}
+
+// countingTransport counts the requests.
+type countingTransport struct{ n *int }
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	*t.n++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientOptions(t *testing.T) {
+	var gotUA, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA, gotPath = r.UserAgent(), r.URL.Path
+		http.Error(w, "not ESB", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	var n int
+	c, err := NewClient(WithBaseURL(srv.URL+"/mirror"), WithHTTPClient(&http.Client{Transport: countingTransport{&n}}), WithUserAgent("esb2ha-test"))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	if err := c.Login(t.Context(), "user", "password"); !errors.Is(err, ErrPortalChanged) {
+		t.Errorf("Login() on a website which is not ESB = %v, want ErrPortalChanged", err)
+	}
+	if gotPath != "/mirror" {
+		t.Errorf("Login() requested %q, want /mirror", gotPath)
+	}
+	if gotUA != "esb2ha-test" {
+		t.Errorf("Login() sent User-Agent %q, want esb2ha-test", gotUA)
+	}
+	if n != 1 {
+		t.Errorf("Login() sent %d requests through the HTTP client, want 1", n)
+	}
+}
@@ -0,0 +1,267 @@
+// Package esbtest implements a fake ESB website, to test the programs
+// using esblib without the real one.
+//
+// The fake implements the steps of the login and the downloads as
+// esblib sees them, not the pages shown to a browser.
+package esbtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"github.com/lorentz83/esb2ha/esblib"
+)
+
+// Paths of the fake login, on the same host of the website.
+const (
+	tenant = "/tenant"
+	policy = "B2C_1A_signup_signin"
+	api    = "CombinedSigninAndSignup"
+)
+
+// sessionCookie is the cookie of the logged in browsers.
+const sessionCookie = "session"
+
+// Server is a fake ESB website, listening on a local port.
+type Server struct {
+	*httptest.Server
+	// user and password are the account accepted by the login.
+	user, password string
+
+	mu sync.Mutex
+	// meters are the HDF files of the meters, by MPRN.
+	meters map[string][]byte
+	// tx are the logins in progress, true once the password was
+	// accepted.
+	tx map[string]bool
+	// codes are the codes of the redirect forms, sessions the logged
+	// in browsers.
+	codes, sessions map[string]bool
+	logins          int
+}
+
+// NewServer starts a fake ESB website accepting the login of user, the
+// caller must close it.
+func NewServer(user, password string) *Server {
+	s := &Server{
+		user:     user,
+		password: password,
+		meters:   map[string][]byte{},
+		tx:       map[string]bool{},
+		codes:    map[string]bool{},
+		sessions: map[string]bool{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.loginPage)
+	mux.HandleFunc("POST "+tenant+"/SelfAsserted", s.selfAsserted)
+	mux.HandleFunc("GET "+tenant+"/api/"+api+"/confirmed", s.confirmed)
+	// The redirect form posts with a lowercase method.
+	mux.HandleFunc("/signin-oidc", s.signIn)
+	mux.HandleFunc("GET /af/t", prepare)
+	mux.HandleFunc("POST /DataHub/DownloadHdfPeriodic", s.loggedIn(s.downloadHDF))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// NewClient returns an esblib client connecting to the fake, opts can
+// set the other options.
+func (s *Server) NewClient(opts ...esblib.ClientOption) (*esblib.Client, error) {
+	return esblib.NewClient(append(opts, esblib.WithBaseURL(s.URL))...)
+}
+
+// AddMeter adds a meter to the account, hdf is the file downloaded by
+// DownloadPowerConsumption, in any format.
+func (s *Server) AddMeter(mprn string, hdf []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.meters[mprn] = hdf
+}
+
+// Logins returns how many logins succeeded.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// ExpireLogins logs out all the clients, like ESB does after about 20
+// minutes: their downloads fail with esblib.ErrLoginExpired.
+func (s *Server) ExpireLogins() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = map[string]bool{}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><title>Sign in</title>
+<script>
+var SETTINGS = {{.}};
+</script>
+</head><body></body></html>
+`))
+
+// loginPage is the page with the SETTINGS of a new login.
+func (s *Server) loginPage(w http.ResponseWriter, r *http.Request) {
+	tx := newID()
+	s.mu.Lock()
+	s.tx[tx] = false
+	s.mu.Unlock()
+
+	settings := map[string]any{
+		"csrf":    "csrf-" + tx,
+		"transId": tx,
+		"api":     api,
+		"hosts":   map[string]string{"tenant": tenant, "policy": policy},
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	loginPage.Execute(w, settings)
+}
+
+// selfAsserted checks the user name and the password.
+func (s *Server) selfAsserted(w http.ResponseWriter, r *http.Request) {
+	tx := r.URL.Query().Get("tx")
+	s.mu.Lock()
+	_, ok := s.tx[tx]
+	s.mu.Unlock()
+	if !ok || r.Header.Get("X-CSRF-TOKEN") != "csrf-"+tx {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if r.PostFormValue("signInName") != s.user || r.PostFormValue("password") != s.password {
+		json.NewEncoder(w).Encode(map[string]string{"status": "400", "errorCode": "AADB2C90225", "message": "Invalid username or password."})
+		return
+	}
+	s.mu.Lock()
+	s.tx[tx] = true
+	s.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]string{"status": "200"})
+}
+
+var redirectForm = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html><head><title>Logging in...</title></head>
+<body><form id='auto' method='post' action='{{.Action}}'>
+<div><input type='hidden' name='state' id='state_id' value='state'/>
+<input type='hidden' name='code' id='code' value='{{.Code}}'/>
+</div></form></body></html>
+`))
+
+// confirmed returns the form bringing the login back to the website.
+func (s *Server) confirmed(w http.ResponseWriter, r *http.Request) {
+	tx := r.URL.Query().Get("tx")
+	code := newID()
+	s.mu.Lock()
+	ok := s.tx[tx]
+	delete(s.tx, tx)
+	if ok {
+		s.codes[code] = true
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "login not confirmed", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	redirectForm.Execute(w, map[string]string{"Action": s.URL + "/signin-oidc", "Code": code})
+}
+
+// signIn logs in the browser.
+func (s *Server) signIn(w http.ResponseWriter, r *http.Request) {
+	// ParseForm ignores the body of a lowercase post.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	code := form.Get("code")
+	session := newID()
+	s.mu.Lock()
+	ok := s.codes[code]
+	delete(s.codes, code)
+	if ok {
+		s.sessions[session] = true
+		s.logins++
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "invalid code", http.StatusUnauthorized)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: session, Path: "/"})
+	fmt.Fprintln(w, "Welcome")
+}
+
+// loggedIn redirects to the login the requests without a session, like
+// ESB does.
+func (s *Server) loggedIn(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(sessionCookie)
+		s.mu.Lock()
+		ok := err == nil && s.sessions[c.Value]
+		s.mu.Unlock()
+		if !ok {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// xsrfCookie is the cookie with the token of the downloads.
+const xsrfCookie = "XSRF-TOKEN"
+
+// prepare sets the token of the downloads, even without a login: the
+// downloads are the ones redirecting to the login.
+func prepare(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: xsrfCookie, Value: newID(), Path: "/"})
+}
+
+// dataRequest reads the parameters of a download, and checks its
+// token.
+func dataRequest(w http.ResponseWriter, r *http.Request) (map[string]string, bool) {
+	c, err := r.Cookie(xsrfCookie)
+	if err != nil || r.Header.Get("x-xsrf-token") != c.Value {
+		http.Error(w, "invalid XSRF token", http.StatusBadRequest)
+		return nil, false
+	}
+	var params map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return params, true
+}
+
+func (s *Server) downloadHDF(w http.ResponseWriter, r *http.Request) {
+	params, ok := dataRequest(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	hdf, ok := s.meters[params["mprn"]]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Write(hdf)
+}
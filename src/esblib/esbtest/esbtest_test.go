@@ -0,0 +1,117 @@
+package esbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/esblib"
+)
+
+const hdf = `MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time
+10000000000,000000000000,0.5,Active Import Interval (kW),01-01-2024 00:30
+`
+
+func newClient(t *testing.T, s *Server) *esblib.Client {
+	t.Helper()
+	c, err := s.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	c.Retry.MaxAttempts = 1
+	return c
+}
+
+func TestDownload(t *testing.T) {
+	s := NewServer("user@example.com", "secret")
+	defer s.Close()
+	s.AddMeter("10000000000", []byte(hdf))
+
+	c := newClient(t, s)
+	var read, total int64
+	c.Hooks.OnDownloadProgress = func(r, t int64) { read, total = r, t }
+	if err := c.Login(t.Context(), "user@example.com", "secret"); err != nil {
+		t.Fatalf("Login() unexpected error: %v", err)
+	}
+	got, err := c.DownloadPowerConsumption(t.Context(), "10000000000", esblib.FormatIntervalKW)
+	if err != nil {
+		t.Fatalf("DownloadPowerConsumption() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(hdf, string(got)); diff != "" {
+		t.Errorf("DownloadPowerConsumption() unexpected diff (-want +got): %v", diff)
+	}
+	if want := int64(len(hdf)); read != want || total != want {
+		t.Errorf("OnDownloadProgress() last got %d of %d, want %d of %d", read, total, want, want)
+	}
+	if _, err := c.DownloadPowerConsumption(t.Context(), "20000000000", esblib.FormatIntervalKW); !errors.Is(err, esblib.ErrMPRNNotFound) {
+		t.Errorf("DownloadPowerConsumption() of an unknown meter = %v, want ErrMPRNNotFound", err)
+	}
+
+	s.ExpireLogins()
+	if _, err := c.DownloadPowerConsumption(t.Context(), "10000000000", esblib.FormatIntervalKW); !errors.Is(err, esblib.ErrLoginExpired) {
+		t.Errorf("DownloadPowerConsumption() after ExpireLogins() = %v, want ErrLoginExpired", err)
+	}
+	if got := s.Logins(); got != 1 {
+		t.Errorf("Logins() = %d, want 1", got)
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	s := NewServer("user@example.com", "secret")
+	defer s.Close()
+
+	c := newClient(t, s)
+	if err := c.Login(t.Context(), "user@example.com", "wrong"); !errors.Is(err, esblib.ErrInvalidCredentials) {
+		t.Errorf("Login() with a wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := c.DownloadPowerConsumption(t.Context(), "10000000000", esblib.FormatIntervalKW); !errors.Is(err, esblib.ErrLoginExpired) {
+		t.Errorf("DownloadPowerConsumption() without login = %v, want ErrLoginExpired", err)
+	}
+	if got := s.Logins(); got != 0 {
+		t.Errorf("Logins() = %d, want 0", got)
+	}
+}
+
+func TestRelogin(t *testing.T) {
+	s := NewServer("user@example.com", "secret")
+	defer s.Close()
+	s.AddMeter("10000000000", []byte(hdf))
+
+	c := newClient(t, s)
+	c.Relogin = true
+	if err := c.Login(t.Context(), "user@example.com", "secret"); err != nil {
+		t.Fatalf("Login() unexpected error: %v", err)
+	}
+	s.ExpireLogins()
+	got, err := c.DownloadPowerConsumption(t.Context(), "10000000000", esblib.FormatIntervalKW)
+	if err != nil {
+		t.Fatalf("DownloadPowerConsumption() after ExpireLogins() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(hdf, string(got)); diff != "" {
+		t.Errorf("DownloadPowerConsumption() unexpected diff (-want +got): %v", diff)
+	}
+	if got := s.Logins(); got != 2 {
+		t.Errorf("Logins() = %d, want 2", got)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	s := NewServer("user@example.com", "secret")
+	defer s.Close()
+	s.AddMeter("10000000000", []byte(hdf))
+
+	c := newClient(t, s)
+	c.Retry.MaxAttempts = 5
+	if err := c.Login(t.Context(), "user@example.com", "secret"); err != nil {
+		t.Fatalf("Login() unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := c.DownloadPowerConsumption(ctx, "10000000000", esblib.FormatIntervalKW); !errors.Is(err, context.Canceled) {
+		t.Errorf("DownloadPowerConsumption() with a canceled context = %v, want context.Canceled", err)
+	}
+	if err := c.Login(ctx, "user@example.com", "secret"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Login() with a canceled context = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,156 @@
+package esblib
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Kinds of one time codes asked by the multi-factor authentication, see
+// Client.OneTimeCode.
+const (
+	MFAEmail = "email" // A code sent by ESB to the email of the account.
+	MFATOTP  = "totp"  // A code of an authenticator app, see TOTP.
+)
+
+// ErrMFARequired is returned by Login when the account has multi-factor
+// authentication enabled, but Client.OneTimeCode is not set.
+var ErrMFARequired = errors.New("multi-factor authentication required, a one time code is needed")
+
+// mfaChallenge is the login page asking for the one time code, after
+// the password.
+type mfaChallenge struct {
+	settings loginSettings
+	// kind is MFAEmail or MFATOTP.
+	kind string
+}
+
+// parseMFAChallenge returns the challenge if the page asks for a one
+// time code, nil if it is the form completing the login.
+//
+// Like the login page, the page of the code has the SETTINGS of its
+// step, the kind is told by its inputs.
+func parseMFAChallenge(u *url.URL, page []byte) (*mfaChallenge, error) {
+	settings, ok, err := parsePageSettings(page)
+	if err != nil || !ok {
+		return nil, err
+	}
+	kind, err := mfaKind(page)
+	if err != nil {
+		return nil, err
+	}
+	return &mfaChallenge{
+		settings: loginSettings{loginURL: u, settings: settings},
+		kind:     kind,
+	}, nil
+}
+
+// mfaKind returns the kind of the code asked by the page.
+func mfaKind(page []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return "", fmt.Errorf("cannot parse HTML: %w", err)
+	}
+
+	var kind string
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "input" {
+			switch attributesToMap(n.Attr)["id"] {
+			case "otpCode":
+				kind = MFATOTP
+			case "verificationCode":
+				kind = MFAEmail
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+
+	if kind == "" {
+		return "", fmt.Errorf("unknown login step, neither the ESB website nor multi-factor authentication: %w", ErrPortalChanged)
+	}
+	return kind, nil
+}
+
+// answerMFA sends the one time code, between the 3rd and the 4th step
+// of the login process.
+//
+// The email codes are sent by ESB when asked, then verified before
+// confirming the step.
+func (c *Client) answerMFA(ctx context.Context, mfa mfaChallenge, user string) error {
+	if c.OneTimeCode == nil {
+		return ErrMFARequired
+	}
+	ls := mfa.settings
+
+	data := url.Values{}
+	data.Set("request_type", "RESPONSE")
+	switch mfa.kind {
+	case MFAEmail:
+		send := url.Values{"email": {user}}
+		if err := c.postSelfAsserted(ctx, ls.DisplayControlURL("SendCode"), ls, send); err != nil {
+			return fmt.Errorf("cannot send the one time code: %w", err)
+		}
+		code, err := c.OneTimeCode(MFAEmail)
+		if err != nil {
+			return fmt.Errorf("cannot get the one time code: %w", err)
+		}
+		verify := url.Values{"email": {user}, "verificationCode": {code}}
+		if err := c.postSelfAsserted(ctx, ls.DisplayControlURL("VerifyCode"), ls, verify); err != nil {
+			return fmt.Errorf("invalid one time code: %w", err)
+		}
+		data.Set("email", user)
+	case MFATOTP:
+		code, err := c.OneTimeCode(MFATOTP)
+		if err != nil {
+			return fmt.Errorf("cannot get the one time code: %w", err)
+		}
+		data.Set("otpCode", code)
+	}
+	return c.postSelfAsserted(ctx, ls.PostLoginURL(), ls, data)
+}
+
+// DisplayControlURL is the URL of an action of the email verification,
+// SendCode or VerifyCode.
+func (ls loginSettings) DisplayControlURL(action string) *url.URL {
+	u := ls.PostLoginURL()
+	u.Path += "/DisplayControlAction/vbeta/emailVerificationControl/" + action
+	return u
+}
+
+// TOTP returns the code of an authenticator app at t, from the secret
+// shown when setting it up, as in RFC 6238: 6 digits, changing every 30
+// seconds.
+//
+// The secret is base32, spaces and case don't matter.
+func TOTP(secret string, t time.Time) (string, error) {
+	secret = strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0xf
+	v := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", v%1000000), nil
+}
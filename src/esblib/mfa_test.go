@@ -0,0 +1,90 @@
+package esblib
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTOTP(t *testing.T) {
+	// The SHA1 vectors of RFC 6238, appendix B, with 6 digits.
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tc := range tests {
+		got, err := TOTP(secret, time.Unix(tc.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTP(%d) unexpected error: %v", tc.unix, err)
+		}
+		if got != tc.want {
+			t.Errorf("TOTP(%d) = %q, want %q", tc.unix, got, tc.want)
+		}
+	}
+
+	if got, err := TOTP("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0)); err != nil || got != "287082" {
+		t.Errorf("TOTP() with spaces = %q, %v, want 287082", got, err)
+	}
+	if _, err := TOTP("not base32!", time.Unix(59, 0)); err == nil {
+		t.Error("TOTP() with an invalid secret = nil, want error")
+	}
+}
+
+const mfaPage = `<html><head>
+<script>
+var SETTINGS = {"csrf":"c2","transId":"tx2","api":"SelfAsserted","hosts":{"tenant":"/tenant","policy":"B2C_1A_signin"}};
+</script></head>
+<body><form id="attributeVerification">
+%s
+<button id="continue">Continue</button>
+</form></body></html>`
+
+func TestParseMFAChallenge(t *testing.T) {
+	u := &url.URL{Scheme: "https", Host: "login.esbnetworks.ie", Path: "/tenant/api/SelfAsserted/confirmed"}
+	tests := []struct {
+		name    string
+		page    string
+		want    string
+		wantErr bool
+	}{
+		{name: "redirect form", page: fragment},
+		{name: "totp", page: fmt.Sprintf(mfaPage, `<input type="text" id="otpCode" name="otpCode">`), want: MFATOTP},
+		{name: "email", page: fmt.Sprintf(mfaPage, `<input type="text" id="verificationCode" name="verificationCode">`), want: MFAEmail},
+		{name: "unknown", page: fmt.Sprintf(mfaPage, `<input type="text" id="captcha">`), wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseMFAChallenge(u, []byte(tc.page))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("parseMFAChallenge() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMFAChallenge() unexpected error: %v", err)
+			}
+			if tc.want == "" {
+				if got != nil {
+					t.Errorf("parseMFAChallenge() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.kind != tc.want {
+				t.Fatalf("parseMFAChallenge() = %v, want kind %q", got, tc.want)
+			}
+			const wantURL = "https://login.esbnetworks.ie/tenant/SelfAsserted/DisplayControlAction/vbeta/emailVerificationControl/SendCode?tx=tx2&p=B2C_1A_signin"
+			if got := got.settings.DisplayControlURL("SendCode").String(); got != wantURL {
+				t.Errorf("DisplayControlURL() = %q, want %q", got, wantURL)
+			}
+		})
+	}
+}
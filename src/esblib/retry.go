@@ -0,0 +1,101 @@
+package esblib
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// RetryPolicy is how the client retries the requests failing with
+// transient errors, like the 5xx the portal returns under load or the
+// connections it drops.
+//
+// Only the data downloads are retried, the login is not.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a request is tried, 0 or 1 to
+	// never retry.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled at every retry
+	// up to MaxBackoff. A random jitter of up to half the wait is
+	// removed, to not retry in lockstep with other clients.
+	Backoff, MaxBackoff time.Duration
+	// StatusCodes are the HTTP status codes to retry, connection errors
+	// are always retried.
+	StatusCodes []int
+}
+
+// DefaultRetryPolicy is the policy of the clients returned by NewClient.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	Backoff:     2 * time.Second,
+	MaxBackoff:  30 * time.Second,
+	StatusCodes: []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// wait returns how long to wait before the given retry, from 1.
+func (p RetryPolicy) wait(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 {
+		d = min(d, p.MaxBackoff)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d - rand.N(d/2+1)
+}
+
+// retryableStatusError is returned when the last attempt failed with
+// one of the StatusCodes of the policy.
+type retryableStatusError struct {
+	status string
+}
+
+func (e retryableStatusError) Error() string {
+	return fmt.Sprintf("status %v", e.status)
+}
+
+// do calls send until it succeeds, it fails with an error which is not
+// transient, or the attempts are over.
+//
+// send is called again for every attempt, since a request body can be
+// read only once. The responses with a status to retry are closed,
+// the others are returned to the caller.
+func (p RetryPolicy) do(send func() (*http.Response, error), sleep func(time.Duration), onRetry func(error, time.Duration)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		rsp, err := send()
+		if err == nil && slices.Contains(p.StatusCodes, rsp.StatusCode) {
+			rsp.Body.Close()
+			rsp, err = nil, retryableStatusError{rsp.Status}
+		}
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) {
+			return rsp, err
+		}
+		w := p.wait(attempt)
+		if onRetry != nil {
+			onRetry(err, w)
+		}
+		sleep(w)
+	}
+}
+
+// permanentError marks the errors of send which must not be retried.
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+func retryable(err error) bool {
+	var p permanentError
+	return !errors.As(err, &p)
+}
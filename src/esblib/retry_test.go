@@ -0,0 +1,120 @@
+package esblib
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func status(code int) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Status:     http.StatusText(code),
+		Body:       io.NopCloser(strings.NewReader("")),
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, Backoff: time.Second, StatusCodes: []int{http.StatusServiceUnavailable}}
+	dropped := errors.New("connection reset by peer")
+	type result struct {
+		rsp *http.Response
+		err error
+	}
+	tests := []struct {
+		name     string
+		results  []result
+		wantCode int // 0 if an error is expected.
+		attempts int
+	}{
+		{
+			name:     "success",
+			results:  []result{{rsp: status(http.StatusOK)}},
+			wantCode: http.StatusOK,
+			attempts: 1,
+		},
+		{
+			name:     "transient status, then success",
+			results:  []result{{rsp: status(http.StatusServiceUnavailable)}, {rsp: status(http.StatusOK)}},
+			wantCode: http.StatusOK,
+			attempts: 2,
+		},
+		{
+			name:     "dropped connection, then success",
+			results:  []result{{err: dropped}, {err: dropped}, {rsp: status(http.StatusOK)}},
+			wantCode: http.StatusOK,
+			attempts: 3,
+		},
+		{
+			name:     "attempts over",
+			results:  []result{{err: dropped}, {rsp: status(http.StatusServiceUnavailable)}, {rsp: status(http.StatusServiceUnavailable)}, {rsp: status(http.StatusOK)}},
+			attempts: 3,
+		},
+		{
+			name:     "status not to retry",
+			results:  []result{{rsp: status(http.StatusNotFound)}, {rsp: status(http.StatusOK)}},
+			wantCode: http.StatusNotFound,
+			attempts: 1,
+		},
+		{
+			name:     "permanent error",
+			results:  []result{{err: permanentError{errors.New("bad request")}}, {rsp: status(http.StatusOK)}},
+			attempts: 1,
+		},
+	}
+	for _, tc := range tests {
+		var (
+			attempts int
+			sleeps   []time.Duration
+			retries  int
+		)
+		rsp, err := p.do(func() (*http.Response, error) {
+			r := tc.results[attempts]
+			attempts++
+			return r.rsp, r.err
+		}, func(d time.Duration) {
+			sleeps = append(sleeps, d)
+		}, func(error, time.Duration) {
+			retries++
+		})
+		if tc.wantCode == 0 && err == nil {
+			t.Errorf("%s: do() = %v, want error", tc.name, rsp.Status)
+		}
+		if tc.wantCode != 0 && (err != nil || rsp.StatusCode != tc.wantCode) {
+			t.Errorf("%s: do() = %v, %v, want status %d", tc.name, rsp, err, tc.wantCode)
+		}
+		if attempts != tc.attempts {
+			t.Errorf("%s: do() tried %d times, want %d", tc.name, attempts, tc.attempts)
+		}
+		if len(sleeps) != attempts-1 || retries != attempts-1 {
+			t.Errorf("%s: do() slept %d times and reported %d retries, want %d", tc.name, len(sleeps), retries, attempts-1)
+		}
+	}
+}
+
+func TestRetryPolicy_Wait(t *testing.T) {
+	p := RetryPolicy{Backoff: 2 * time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, tc := range tests {
+		for range 10 {
+			if got := p.wait(tc.retry); got > tc.max || got < tc.max/2 {
+				t.Errorf("wait(%d) = %v, want between %v and %v", tc.retry, got, tc.max/2, tc.max)
+			}
+		}
+	}
+	if got := (RetryPolicy{}).wait(1); got != 0 {
+		t.Errorf("zero policy wait(1) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/store"
+)
+
+// fill fills the gaps in the data up to -fill_gaps long.
+//
+// The estimated reads are reported and, with a store, remembered to
+// report when ESB publishes the measured values, which are then
+// uploaded in their place like any other changed hour.
+func (c *uploadCmd) fill(parsed []parse.Result) []parse.Result {
+	parsed, gaps := parse.FillGaps(parsed, c.fillGaps)
+	for _, g := range gaps {
+		slog.Info("Estimated missing data", "duration", g.Duration(), "from", g.From, "to", g.To)
+	}
+	if c.storePath == "" || len(parsed) == 0 {
+		return parsed
+	}
+
+	var ends []time.Time
+	for _, r := range parsed {
+		for _, rd := range r.Reads {
+			if rd.Estimated {
+				ends = append(ends, rd.EndTime)
+			}
+		}
+	}
+	first, last := parsed[0].Reads, parsed[len(parsed)-1].Reads
+
+	st, err := store.Open(c.storePath)
+	if err == nil {
+		var measured []time.Time
+		measured, err = st.ReplaceEstimated(parsed[0].MPRN, first[0].EndTime, last[len(last)-1].EndTime, ends)
+		st.Close()
+		if n := len(measured); n > 0 {
+			slog.Info("ESB published half hours estimated before", "half_hours", n, "from", measured[0], "to", measured[n-1])
+		}
+	}
+	if err != nil {
+		slog.Warn("cannot record the estimated reads", "err", err)
+	}
+	return parsed
+}
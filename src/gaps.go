@@ -0,0 +1,212 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/grafana"
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+type gapsCmd struct {
+	ha      uploadCmd
+	format  string
+	grafana grafanaAnnotations
+}
+
+func (gapsCmd) Name() string { return "gaps" }
+
+func (gapsCmd) Synopsis() string {
+	return "report the holes in the ESB files and the hours missing in Home Assistant"
+}
+
+func (gapsCmd) Usage() string {
+	return `gaps <flags> [file.csv...]
+
+All the non optional flags are required, but can be provided as environment variables as well.
+The CSV files are read from the arguments, or from standard input if there are none.
+
+Compares the files with the statistics of -ha_sensor and lists:
+ - the holes in the files, missing in esb: the days to download again
+   once ESB publishes them, and how many of their hours Home Assistant
+   has anyway, like the ones estimated by -fill_gaps;
+ - the hours in the files which Home Assistant doesn't have, missing
+   in home_assistant: upload the files again to fill them.
+With -format=json the report is a JSON array, for scripts.
+With -grafana_url, the hours missing in Home Assistant are also added
+as annotations in Grafana, like validate does for the holes.
+
+`
+}
+
+func (c *gapsCmd) SetFlags(fs *flag.FlagSet) {
+	c.ha.SetFlags(fs)
+	c.grafana.SetFlags(fs)
+	fs.StringVar(&c.format, "format", "text", "the format of the report, text or json")
+}
+
+func (c *gapsCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if c.format != "text" && c.format != "json" {
+		slog.Error("unknown format", "format", c.format)
+		return subcommands.ExitUsageError
+	}
+
+	parsed, err := readFiles(ctx, f.Args())
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	if parsed, err = c.ha.period.filter(parsed); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	gaps, err := c.gaps(ctx, parsed)
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	if err := c.write(os.Stdout, gaps); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	if c.grafana.enabled() {
+		var as []grafana.Annotation
+		for _, g := range gaps {
+			if g.MissingIn == missingInHA {
+				as = append(as, c.grafana.annotation(g.From, g.To, "Missing in Home Assistant, upload the ESB data again", "gap"))
+			}
+		}
+		n, err := c.grafana.create(ctx, as)
+		if err != nil {
+			slog.Error("cannot annotate Grafana", "err", err)
+			return subcommands.ExitFailure
+		}
+		slog.Info("Created the Grafana annotations", "count", n)
+	}
+	return subcommands.ExitSuccess
+}
+
+// Where the data of a gapReport is missing.
+const (
+	missingInESB = "esb"
+	missingInHA  = "home_assistant"
+)
+
+// gapReport is a hole in the data, as reported by gaps.
+type gapReport struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Hours float64   `json:"hours"`
+	// Days are the days of the gap in Irish time, as YYYY-MM-DD, the
+	// -from and -to to download or upload again.
+	Days []string `json:"days"`
+	// MissingIn is missingInESB or missingInHA.
+	MissingIn string `json:"missing_in"`
+	// HoursInHA is how many hours of a hole in the ESB data Home
+	// Assistant has anyway.
+	HoursInHA int `json:"hours_in_home_assistant,omitempty"`
+}
+
+func newGapReport(g parse.Gap, missingIn string, dublin *time.Location) gapReport {
+	var days []string
+	last := g.To.Add(-time.Nanosecond).In(dublin).Format(time.DateOnly)
+	for d := g.From.In(dublin); ; d = d.AddDate(0, 0, 1) {
+		days = append(days, d.Format(time.DateOnly))
+		if d.Format(time.DateOnly) >= last {
+			break
+		}
+	}
+	return gapReport{From: g.From, To: g.To, Hours: g.Duration().Hours(), Days: days, MissingIn: missingIn}
+}
+
+// gaps compares the reads with the statistics in Home Assistant.
+func (c *gapsCmd) gaps(ctx context.Context, parsed []parse.Result) ([]gapReport, error) {
+	// Only the imported energy is uploaded to -ha_sensor.
+	parsed, _ = parse.SplitExport(parsed)
+	opts, err := c.ha.translateOptions()
+	if err != nil {
+		return nil, err
+	}
+	var stats []ha.StatisticValue
+	for _, r := range parsed {
+		stat, err := parse.Translate(r, opts)
+		var skipped *parse.SkippedError
+		if errors.As(err, &skipped) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse data: %w", err)
+		}
+		stats = append(stats, stat.Stats...)
+	}
+	if len(stats) == 0 {
+		return nil, errors.New("no complete hour in the data")
+	}
+
+	conn, err := ha.NewConnection(ctx, c.ha.server, c.ha.token)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to Home Assistant: %w", err)
+	}
+	defer conn.Close()
+	existing, err := readAllStatistics(ctx, conn, c.ha.sensor, stats[0].Start, stats[len(stats)-1].Start.Add(time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read statistics from Home Assistant: %w", err)
+	}
+
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		return nil, err
+	}
+	var ret []gapReport
+	for _, g := range parse.Gaps(parsed) {
+		r := newGapReport(g, missingInESB, dublin)
+		r.HoursInHA = parse.HoursIn(g, existing)
+		ret = append(ret, r)
+	}
+	for _, g := range parse.MissingHours(stats, existing) {
+		ret = append(ret, newGapReport(g, missingInHA, dublin))
+	}
+	slices.SortStableFunc(ret, func(a, b gapReport) int { return cmp.Compare(a.From.Unix(), b.From.Unix()) })
+	return ret, nil
+}
+
+func (c *gapsCmd) write(w io.Writer, gaps []gapReport) error {
+	if c.format == "json" {
+		if gaps == nil {
+			gaps = []gapReport{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(gaps)
+	}
+	if len(gaps) == 0 {
+		_, err := fmt.Fprintln(w, "No holes in the data")
+		return err
+	}
+	const format = "2006-01-02 15:04"
+	for _, g := range gaps {
+		what := "missing in Home Assistant, upload again"
+		if g.MissingIn == missingInESB {
+			what = fmt.Sprintf("missing in the ESB data, download again later (Home Assistant has %d hours)", g.HoursInHA)
+		}
+		if _, err := fmt.Fprintf(w, "%s -> %s (%v): %s\n", g.From.Format(format), g.To.Format(format), g.To.Sub(g.From), what); err != nil {
+			return err
+		}
+	}
+	return nil
+}
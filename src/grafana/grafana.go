@@ -0,0 +1,141 @@
+// Package grafana creates annotations with the Grafana HTTP API.
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Annotation is a Grafana annotation.
+//
+// Annotations with TimeEnd are regions.
+type Annotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	Time         int64    `json:"time"`
+	TimeEnd      int64    `json:"timeEnd,omitempty"`
+	Tags         []string `json:"tags"`
+	Text         string   `json:"text"`
+}
+
+// NewAnnotation returns an annotation, a region if end is not zero.
+func NewAnnotation(start, end time.Time, text string, tags ...string) Annotation {
+	a := Annotation{Time: start.UnixMilli(), Text: text, Tags: tags}
+	if !end.IsZero() {
+		a.TimeEnd = end.UnixMilli()
+	}
+	return a
+}
+
+// Client calls the Grafana HTTP API.
+type Client struct {
+	hc      *http.Client
+	baseURL string
+	token   string
+}
+
+// NewClient returns a client for the Grafana at baseURL, like http://host:3000.
+//
+// The token is a service account token with the annotations:write permission.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		hc:      http.DefaultClient,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+	}
+}
+
+// Annotations returns the annotations between from and to with all the tags.
+func (c *Client) Annotations(ctx context.Context, from, to time.Time, tags ...string) ([]Annotation, error) {
+	q := url.Values{}
+	q.Set("from", strconv.FormatInt(from.UnixMilli(), 10))
+	q.Set("to", strconv.FormatInt(to.UnixMilli(), 10))
+	q.Set("limit", "1000")
+	for _, t := range tags {
+		q.Add("tags", t)
+	}
+	var ret []Annotation
+	if err := c.do(ctx, http.MethodGet, "/api/annotations?"+q.Encode(), nil, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Create creates an annotation.
+func (c *Client) Create(ctx context.Context, a Annotation) error {
+	return c.do(ctx, http.MethodPost, "/api/annotations", a, nil)
+}
+
+// CreateMissing creates the annotations which don't exist yet.
+//
+// Annotations are the same if they have the same time range and text,
+// existing ones are searched among the ones with the given tags.
+// It returns how many annotations were created.
+func (c *Client) CreateMissing(ctx context.Context, as []Annotation, tags ...string) (int, error) {
+	if len(as) == 0 {
+		return 0, nil
+	}
+	from, to := as[0].Time, as[0].Time
+	for _, a := range as {
+		from, to = min(from, a.Time), max(to, a.Time, a.TimeEnd)
+	}
+	existing, err := c.Annotations(ctx, time.UnixMilli(from), time.UnixMilli(to), tags...)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read existing annotations: %w", err)
+	}
+
+	n := 0
+	for _, a := range as {
+		if slices.ContainsFunc(existing, func(e Annotation) bool {
+			return e.Time == a.Time && e.TimeEnd == a.TimeEnd && e.Text == a.Text
+		}) {
+			continue
+		}
+		if err := c.Create(ctx, a); err != nil {
+			return n, fmt.Errorf("cannot create annotation: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Grafana status %v: %s", rsp.Status, b)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,55 @@
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCreateMissing(t *testing.T) {
+	var created []Annotation
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+			t.Errorf("got Authorization %q, want Bearer tok", got)
+		}
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			if got := q.Get("from") + " " + q.Get("to") + " " + q.Get("tags"); got != "1000 5000 esb2ha" {
+				t.Errorf("got query %q, want 1000 5000 esb2ha", got)
+			}
+			io.WriteString(w, `[{"id":1,"time":1000,"timeEnd":2000,"text":"gap","tags":["esb2ha"]}]`)
+		case http.MethodPost:
+			var a Annotation
+			if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+				t.Errorf("cannot decode annotation: %v", err)
+			}
+			created = append(created, a)
+			io.WriteString(w, `{"id":2,"message":"Annotation added"}`)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{hc: srv.Client(), baseURL: srv.URL, token: "tok"}
+	as := []Annotation{
+		NewAnnotation(time.UnixMilli(1000), time.UnixMilli(2000), "gap", "esb2ha"),
+		NewAnnotation(time.UnixMilli(4000), time.UnixMilli(5000), "gap", "esb2ha"),
+		NewAnnotation(time.UnixMilli(3000), time.Time{}, "clock change", "esb2ha"),
+	}
+	n, err := c.CreateMissing(context.Background(), as, "esb2ha")
+	if err != nil {
+		t.Fatalf("CreateMissing() unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("CreateMissing() = %d, want 2", n)
+	}
+	if diff := cmp.Diff(as[1:], created); diff != "" {
+		t.Errorf("CreateMissing() created unexpected diff (+got -want): %v", diff)
+	}
+}
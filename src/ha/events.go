@@ -0,0 +1,99 @@
+package ha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"nhooyr.io/websocket/wsjson"
+)
+
+// Event is an event fired on the Home Assistant event bus.
+type Event struct {
+	EventType string         `json:"event_type"`
+	Data      map[string]any `json:"data"`
+	TimeFired time.Time      `json:"time_fired"`
+}
+
+// SubscribeEvents subscribes to the events of the given type, which are
+// then returned by NextEvent.
+//
+// The connection should not be used for other requests after this,
+// their replies would be interleaved with the events.
+func (c *Connection) SubscribeEvents(ctx context.Context, eventType string) (err error) {
+	defer func() { c.Hooks.error(err) }()
+
+	id := c.incMessageID()
+
+	msg := struct {
+		Type      string `json:"type"`
+		ID        int    `json:"id"`
+		EventType string `json:"event_type"`
+	}{
+		"subscribe_events",
+		id,
+		eventType,
+	}
+
+	if err := wsjson.Write(ctx, c.conn, msg); err != nil {
+		return err
+	}
+	_, err = c.waitResponse(ctx, id)
+	return err
+}
+
+// NextEvent waits for the next event of the subscriptions.
+//
+// This function is NOT safe for concurrent calls.
+func (c *Connection) NextEvent(ctx context.Context) (Event, error) {
+	for {
+		var msg struct {
+			MessageType string `json:"type"`
+			Event       Event  `json:"event"`
+		}
+		if err := wsjson.Read(ctx, c.conn, &msg); err != nil {
+			c.Hooks.error(err)
+			return Event{}, err
+		}
+		if msg.MessageType == "event" {
+			return msg.Event, nil
+		}
+	}
+}
+
+// FireEvent fires an event on the Home Assistant event bus via the REST
+// API, automations can trigger on it.
+//
+// The host has the same format of NewConnection.
+func FireEvent(ctx context.Context, host, accessToken, eventType string, data map[string]any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	url, err := restURL(host, "/api/events/"+eventType)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	rsp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return fmt.Errorf("status %v: %s", rsp.Status, msg)
+	}
+	return nil
+}
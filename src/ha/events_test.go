@@ -0,0 +1,42 @@
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFireEvent(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/api/events/esb2ha_sync_done" {
+			t.Errorf("got %s %s, want POST /api/events/esb2ha_sync_done", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("cannot decode request: %v", err)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	data := map[string]any{"mprn": "123", "points": float64(24)}
+	if err := FireEvent(context.Background(), host, "tok", "esb2ha_sync_done", data); err != nil {
+		t.Fatalf("FireEvent() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(data, got); diff != "" {
+		t.Errorf("FireEvent() sent unexpected data (-want +got): %v", diff)
+	}
+
+	if err := FireEvent(context.Background(), host, "bad", "esb2ha_sync_done", nil); err == nil {
+		t.Error("FireEvent() with a wrong token = nil, want error")
+	}
+}
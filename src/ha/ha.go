@@ -3,6 +3,7 @@ package ha
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -13,7 +14,10 @@ import (
 
 // StatisticMetadata is the metadata of a statistic value.
 type StatisticMetadata struct {
-	recorderSource
+	// Source is RecorderSource for the statistics of an entity, the
+	// default if empty, or the domain of the ID of an external
+	// statistic, see ValidateStatisticID.
+	Source string `json:"source"`
 
 	HasMean           bool   `json:"has_mean"`
 	HasSum            bool   `json:"has_sum"`
@@ -22,17 +26,17 @@ type StatisticMetadata struct {
 	UnitOfMeasurement string `json:"unit_of_measurement"`
 }
 
-// recorderSource is just a hack to force the json encoder to add a
-// constant field `"source":"recorder"`
-type recorderSource struct {
-	Source recorderString `json:"source"`
-}
-
-// recorderString is a string which is always marshalled in json as
-// the constant "recorder"
-type recorderString string
+// RecorderSource is the source of the statistics of an entity.
+const RecorderSource = "recorder"
 
-func (recorderString) MarshalText() ([]byte, error) { return []byte(`recorder`), nil }
+// MarshalJSON sets the default source.
+func (m StatisticMetadata) MarshalJSON() ([]byte, error) {
+	type plain StatisticMetadata
+	if m.Source == "" {
+		m.Source = RecorderSource
+	}
+	return json.Marshal(plain(m))
+}
 
 // StatisticValue is a single data point to import to Home Assistant.
 type StatisticValue struct {
@@ -53,35 +57,75 @@ type Statistics struct {
 }
 
 type response struct {
-	ID          int            `json:"id"`
-	MessageType string         `json:"type"`
-	Success     bool           `json:"success"`
-	Result      map[string]any `json:"result"`
+	ID          int             `json:"id"`
+	MessageType string          `json:"type"`
+	Success     bool            `json:"success"`
+	Result      json.RawMessage `json:"result"`
 	Error       struct {
 		Code    string `json:"code"`
 		Message string `json:"message"`
 	} `json:"error"`
 }
 
+// maxMessageSize is the biggest message accepted from Home Assistant.
+const maxMessageSize = 4 << 20
+
 // Connection is a websocket connection to Home Assistant.
 type Connection struct {
 	conn  *websocket.Conn
 	msgID int
 	// ServerVersion contains the version of the connected Home Assistant server.
 	ServerVersion string
+	// Hooks can be set to follow the progress of the connection.
+	Hooks Hooks
+}
+
+// Hooks are optional callbacks reporting what the connection is doing,
+// so applications can drive progress UIs and metrics.
+//
+// They are called synchronously, any of them can be nil.
+type Hooks struct {
+	// OnChunkUploaded is called after Home Assistant accepted the
+	// statistics sent by WriteStatistics or WriteStatisticsBatch.
+	OnChunkUploaded func(Statistics)
+	// OnBatchProgress is called by WriteStatisticsBatch after every
+	// reply of Home Assistant, with how many statistics were done,
+	// accepted or not, out of total.
+	OnBatchProgress func(done, total int)
+	// OnError is called with the errors returned by the requests to
+	// Home Assistant.
+	OnError func(error)
 }
 
+func (h Hooks) error(err error) {
+	if err != nil && h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
+// SupervisorHost is the host of Home Assistant from an add-on, the
+// access token is in the SUPERVISOR_TOKEN environment variable.
+const SupervisorHost = "supervisor/core"
+
 // NewConnection returns a new Connection.
 //
-// The host is just name:port, name, ip, ip:port without any protocol handler.
+// The host is just name:port, name, ip, ip:port, optionally after
+// http:// or https:// (see TLS otherwise), or SupervisorHost from an
+// add-on.
 // To get the token you can follow instructions at
 // https://www.home-assistant.io/docs/authentication/#your-account-profile
 func NewConnection(ctx context.Context, host, accessToken string) (*Connection, error) {
-	url := "ws://" + host + "/api/websocket"
-	ws, _, err := websocket.Dial(ctx, url, nil)
+	url, _, err := urls(host)
 	if err != nil {
 		return nil, err
 	}
+	ws, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPClient: httpClient()})
+	if err != nil {
+		return nil, err
+	}
+
+	// Statistics are read a year at a time, which is below 1MB.
+	ws.SetReadLimit(maxMessageSize)
 
 	ret := &Connection{
 		conn:  ws,
@@ -144,16 +188,98 @@ func (c *Connection) login(ctx context.Context, accessToken string) error {
 	return nil
 }
 
-// SendStatistics sends the statistic to home assistant.
+// WriteStatistics sends the statistic to home assistant, it implements
+// sink.StatisticsSink.
 //
 // This function is NOT safe for concurrent calls.
-func (c *Connection) SendStatistics(ctx context.Context, stat Statistics) error {
+func (c *Connection) WriteStatistics(ctx context.Context, stat Statistics) (err error) {
+	defer func() { c.Hooks.error(err) }()
+
 	// server: https://github.com/home-assistant/core/blob/dev/homeassistant/components/recorder/websocket_api.py#L449
 	// ex: https://gitlab.com/hydroqc/hydroqc2mqtt/-/blob/main/hydroqc2mqtt/hourly_consump_handler.py
 
 	id := c.incMessageID()
 
-	msg := struct {
+	if err := wsjson.Write(ctx, c.conn, importStatisticsMessage(id, stat)); err != nil {
+		return err
+	}
+
+	if _, err := c.waitResponse(ctx, id); err != nil {
+		return err
+	}
+	if c.Hooks.OnChunkUploaded != nil {
+		c.Hooks.OnChunkUploaded(stat)
+	}
+	return nil
+}
+
+// maxInFlight is how many statistics WriteStatisticsBatch sends before
+// waiting for the reply to the first one.
+const maxInFlight = 8
+
+// WriteStatisticsBatch sends the statistics to home assistant without
+// waiting for each reply before sending the next one, which is much
+// faster than WriteStatistics on a long history. It implements
+// sink.BatchStatisticsSink.
+//
+// It returns the error of each statistic, nil if Home Assistant
+// accepted it. If the connection breaks, all the statistics without a
+// reply fail.
+// This function is NOT safe for concurrent calls.
+func (c *Connection) WriteStatisticsBatch(ctx context.Context, stats []Statistics) []error {
+	errs := make([]error, len(stats))
+	// pending are the indexes of the statistics waiting for a reply, by
+	// message ID.
+	pending := map[int]int{}
+	failAll := func(err error, next int) []error {
+		for _, i := range pending {
+			errs[i] = err
+		}
+		for i := next; i < len(stats); i++ {
+			errs[i] = err
+		}
+		c.Hooks.error(err)
+		return errs
+	}
+
+	next := 0
+	for next < len(stats) || len(pending) > 0 {
+		if next < len(stats) && len(pending) < maxInFlight {
+			id := c.incMessageID()
+			if err := wsjson.Write(ctx, c.conn, importStatisticsMessage(id, stats[next])); err != nil {
+				return failAll(err, next)
+			}
+			pending[id] = next
+			next++
+			continue
+		}
+
+		// Home Assistant handles the messages concurrently, the replies
+		// can be in any order.
+		var rsp response
+		if err := wsjson.Read(ctx, c.conn, &rsp); err != nil {
+			return failAll(err, next)
+		}
+		i, ok := pending[rsp.ID]
+		if !ok {
+			return failAll(fmt.Errorf("protocol out of sync: got unexpected ack for %d", rsp.ID), next)
+		}
+		delete(pending, rsp.ID)
+		if errs[i] = rsp.err(); errs[i] != nil {
+			c.Hooks.error(errs[i])
+		} else if c.Hooks.OnChunkUploaded != nil {
+			c.Hooks.OnChunkUploaded(stats[i])
+		}
+		if c.Hooks.OnBatchProgress != nil {
+			c.Hooks.OnBatchProgress(next-len(pending), len(stats))
+		}
+	}
+	return errs
+}
+
+// importStatisticsMessage returns the message importing the statistic.
+func importStatisticsMessage(id int, stat Statistics) any {
+	return struct {
 		Type string `json:"type"`
 		ID   int    `json:"id"`
 		Statistics
@@ -162,15 +288,219 @@ func (c *Connection) SendStatistics(ctx context.Context, stat Statistics) error
 		id,
 		stat,
 	}
+}
+
+// ClearStatistics deletes all the values of the statistics.
+//
+// Home Assistant deletes them in the background, after replying.
+// This function is NOT safe for concurrent calls.
+func (c *Connection) ClearStatistics(ctx context.Context, statisticIDs ...string) (err error) {
+	defer func() { c.Hooks.error(err) }()
+
+	id := c.incMessageID()
+
+	msg := struct {
+		Type         string   `json:"type"`
+		ID           int      `json:"id"`
+		StatisticIDs []string `json:"statistic_ids"`
+	}{
+		"recorder/clear_statistics",
+		id,
+		statisticIDs,
+	}
 
 	if err := wsjson.Write(ctx, c.conn, msg); err != nil {
 		return err
 	}
 
-	_, err := c.waitResponse(ctx, id)
+	_, err = c.waitResponse(ctx, id)
 	return err
 }
 
+// AdjustSumStatistics adds adjustment, in unit, to the sum of the hour
+// starting at start and of all the following ones, like "Adjust a
+// statistic" in the developer tools. The states are not changed.
+//
+// This function is NOT safe for concurrent calls.
+func (c *Connection) AdjustSumStatistics(ctx context.Context, statisticID string, start time.Time, adjustment float64, unit string) (err error) {
+	defer func() { c.Hooks.error(err) }()
+
+	id := c.incMessageID()
+
+	msg := struct {
+		Type        string    `json:"type"`
+		ID          int       `json:"id"`
+		StatisticID string    `json:"statistic_id"`
+		StartTime   time.Time `json:"start_time"`
+		Adjustment  float64   `json:"adjustment"`
+		Unit        string    `json:"adjustment_unit_of_measurement"`
+	}{
+		"recorder/adjust_sum_statistics",
+		id,
+		statisticID,
+		start,
+		adjustment,
+		unit,
+	}
+
+	if err := wsjson.Write(ctx, c.conn, msg); err != nil {
+		return err
+	}
+
+	_, err = c.waitResponse(ctx, id)
+	return err
+}
+
+// Statistics reads the hourly statistics with start between from and to.
+//
+// Only State and Sum are set in the returned values.
+// This function is NOT safe for concurrent calls.
+func (c *Connection) Statistics(ctx context.Context, statisticID string, from, to time.Time) (_ []StatisticValue, err error) {
+	defer func() { c.Hooks.error(err) }()
+
+	// server: https://github.com/home-assistant/core/blob/dev/homeassistant/components/recorder/websocket_api.py
+	id := c.incMessageID()
+
+	msg := struct {
+		Type         string    `json:"type"`
+		ID           int       `json:"id"`
+		StartTime    time.Time `json:"start_time"`
+		EndTime      time.Time `json:"end_time"`
+		StatisticIDs []string  `json:"statistic_ids"`
+		Period       string    `json:"period"`
+		Types        []string  `json:"types"`
+	}{
+		"recorder/statistics_during_period",
+		id,
+		from,
+		to,
+		[]string{statisticID},
+		"hour",
+		[]string{"state", "sum"},
+	}
+
+	if err := wsjson.Write(ctx, c.conn, msg); err != nil {
+		return nil, err
+	}
+	rsp, err := c.waitResponse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return parseStatistics(rsp.Result, statisticID)
+}
+
+// LastStatistic returns the newest hourly statistic with start between
+// from and to, and false if there is none.
+//
+// This function is NOT safe for concurrent calls.
+func (c *Connection) LastStatistic(ctx context.Context, statisticID string, from, to time.Time) (StatisticValue, bool, error) {
+	stats, err := c.Statistics(ctx, statisticID, from, to)
+	if err != nil || len(stats) == 0 {
+		return StatisticValue{}, false, err
+	}
+	return stats[len(stats)-1], true, nil
+}
+
+// Config is the part of the configuration of Home Assistant used by esb2ha.
+type Config struct {
+	// TimeZone is the IANA name of the timezone of the instance, like
+	// Europe/Dublin, used for the daily and monthly aggregations.
+	TimeZone string `json:"time_zone"`
+	Version  string `json:"version"`
+}
+
+// Location returns the timezone of the instance.
+func (c Config) Location() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return nil, errors.New("the Home Assistant timezone is not set")
+	}
+	return time.LoadLocation(c.TimeZone)
+}
+
+// Config returns the configuration of Home Assistant.
+//
+// This function is NOT safe for concurrent calls.
+func (c *Connection) Config(ctx context.Context) (_ Config, err error) {
+	defer func() { c.Hooks.error(err) }()
+
+	id := c.incMessageID()
+
+	msg := struct {
+		Type string `json:"type"`
+		ID   int    `json:"id"`
+	}{
+		"get_config",
+		id,
+	}
+
+	if err := wsjson.Write(ctx, c.conn, msg); err != nil {
+		return Config{}, err
+	}
+	rsp, err := c.waitResponse(ctx, id)
+	if err != nil {
+		return Config{}, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(rsp.Result, &cfg); err != nil {
+		return Config{}, fmt.Errorf("cannot parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// In returns a copy of the statistics with the times in loc.
+//
+// The times don't change, only the offset they are sent with.
+func (s Statistics) In(loc *time.Location) Statistics {
+	stats := make([]StatisticValue, len(s.Stats))
+	for i, v := range s.Stats {
+		v.Start = v.Start.In(loc)
+		if !v.LastReset.IsZero() {
+			v.LastReset = v.LastReset.In(loc)
+		}
+		stats[i] = v
+	}
+	s.Stats = stats
+	return s
+}
+
+// parseStatistics parses the result of recorder/statistics_during_period.
+func parseStatistics(result json.RawMessage, statisticID string) ([]StatisticValue, error) {
+	var res map[string][]struct {
+		// Recent versions send milliseconds since epoch, old ones an ISO string.
+		Start any      `json:"start"`
+		State *float64 `json:"state"`
+		Sum   *float64 `json:"sum"`
+	}
+	if err := json.Unmarshal(result, &res); err != nil {
+		return nil, fmt.Errorf("cannot parse statistics: %w", err)
+	}
+
+	var ret []StatisticValue
+	for _, v := range res[statisticID] {
+		var sv StatisticValue
+		switch s := v.Start.(type) {
+		case float64:
+			sv.Start = time.UnixMilli(int64(s))
+		case string:
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse statistics: %w", err)
+			}
+			sv.Start = t
+		default:
+			return nil, fmt.Errorf("cannot parse statistics: unexpected start %v", v.Start)
+		}
+		if v.State != nil {
+			sv.State = *v.State
+		}
+		if v.Sum != nil {
+			sv.Sum = *v.Sum
+		}
+		ret = append(ret, sv)
+	}
+	return ret, nil
+}
+
 func (c *Connection) waitResponse(ctx context.Context, id int) (rsp response, err error) {
 	if err := wsjson.Read(ctx, c.conn, &rsp); err != nil {
 		return rsp, err
@@ -178,8 +508,13 @@ func (c *Connection) waitResponse(ctx context.Context, id int) (rsp response, er
 	if rsp.ID != id {
 		return rsp, fmt.Errorf("protocol out of sync: got ack for %d, want %d", rsp.ID, id)
 	}
-	if rsp.MessageType != "result" || !rsp.Success {
-		return rsp, fmt.Errorf("error %s: %s", rsp.Error.Code, rsp.Error.Message)
+	return rsp, rsp.err()
+}
+
+// err returns the error reported by Home Assistant, if any.
+func (r response) err() error {
+	if r.MessageType != "result" || !r.Success {
+		return fmt.Errorf("error %s: %s", r.Error.Code, r.Error.Message)
 	}
-	return rsp, nil
+	return nil
 }
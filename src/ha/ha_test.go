@@ -0,0 +1,151 @@
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+func TestParseStatistics(t *testing.T) {
+	tests := []struct {
+		name   string
+		result string
+	}{
+		{
+			name:   "milliseconds",
+			result: `{"sensor.esb":[{"start":1689375600000.0,"end":1689379200000.0,"state":1.5,"sum":10},{"start":1689379200000.0,"end":1689382800000.0,"state":0.5,"sum":10.5}]}`,
+		},
+		{
+			name:   "iso",
+			result: `{"sensor.esb":[{"start":"2023-07-14T23:00:00+00:00","state":1.5,"sum":10},{"start":"2023-07-15T00:00:00+00:00","state":0.5,"sum":10.5}]}`,
+		},
+	}
+	want := []StatisticValue{
+		{Start: time.Date(2023, 07, 14, 23, 0, 0, 0, time.UTC), State: 1.5, Sum: 10},
+		{Start: time.Date(2023, 07, 15, 0, 0, 0, 0, time.UTC), State: 0.5, Sum: 10.5},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseStatistics([]byte(tc.result), "sensor.esb")
+			if err != nil {
+				t.Fatalf("parseStatistics() unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+				t.Errorf("parseStatistics() unexpected diff (+got -want): %v", diff)
+			}
+		})
+	}
+}
+
+func TestStatistics_In(t *testing.T) {
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 7, 15, 10, 0, 0, 0, time.UTC)
+	s := Statistics{Stats: []StatisticValue{{Start: start, Sum: 1}}}
+
+	got := s.In(dublin)
+	if v := got.Stats[0]; !v.Start.Equal(start) || v.Start.Location() != dublin || !v.LastReset.IsZero() || v.Sum != 1 {
+		t.Errorf("In() = %+v, want the same values in Europe/Dublin", v)
+	}
+	if b, _ := json.Marshal(got.Stats[0].Start); string(b) != `"2023-07-15T11:00:00+01:00"` {
+		t.Errorf("In() start is marshalled as %s, want 11:00+01:00", b)
+	}
+	if s.Stats[0].Start.Location() != time.UTC {
+		t.Error("In() changed the original statistics")
+	}
+}
+
+// fakeImports is a Home Assistant server which reads all the
+// import_statistics messages before replying to any of them, in reverse
+// order, rejecting the statistic named reject.
+func fakeImports(t *testing.T, n int, reject string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Errorf("cannot accept the websocket: %v", err)
+			return
+		}
+		defer ws.Close(websocket.StatusNormalClosure, "")
+		ctx := r.Context()
+
+		wsjson.Write(ctx, ws, map[string]string{"type": "auth_required", "ha_version": "2024.1.0"})
+		var auth map[string]string
+		if err := wsjson.Read(ctx, ws, &auth); err != nil {
+			t.Errorf("cannot read auth: %v", err)
+			return
+		}
+		wsjson.Write(ctx, ws, map[string]string{"type": "auth_ok", "ha_version": "2024.1.0"})
+
+		type message struct {
+			ID       int               `json:"id"`
+			Metadata StatisticMetadata `json:"metadata"`
+		}
+		var msgs []message
+		for range n {
+			var m message
+			if err := wsjson.Read(ctx, ws, &m); err != nil {
+				t.Errorf("cannot read import_statistics: %v", err)
+				return
+			}
+			msgs = append(msgs, m)
+		}
+		for i := len(msgs) - 1; i >= 0; i-- {
+			rsp := map[string]any{"id": msgs[i].ID, "type": "result", "success": true}
+			if msgs[i].Metadata.StatisticID == reject {
+				rsp["success"] = false
+				rsp["error"] = map[string]string{"code": "invalid_format", "message": "rejected"}
+			}
+			wsjson.Write(ctx, ws, rsp)
+		}
+	}))
+}
+
+func TestWriteStatisticsBatch(t *testing.T) {
+	stats := []Statistics{
+		{Metadata: StatisticMetadata{StatisticID: "sensor.a"}},
+		{Metadata: StatisticMetadata{StatisticID: "sensor.b"}},
+		{Metadata: StatisticMetadata{StatisticID: "sensor.c"}},
+	}
+	srv := fakeImports(t, len(stats), "sensor.b")
+	defer srv.Close()
+
+	ctx := context.Background()
+	conn, err := NewConnection(ctx, srv.URL, "tok")
+	if err != nil {
+		t.Fatalf("NewConnection() unexpected error: %v", err)
+	}
+	defer conn.Close()
+	var uploaded []string
+	conn.Hooks.OnChunkUploaded = func(s Statistics) { uploaded = append(uploaded, s.Metadata.StatisticID) }
+	var progress []int
+	conn.Hooks.OnBatchProgress = func(done, total int) {
+		if total != len(stats) {
+			t.Errorf("OnBatchProgress() got total %d, want %d", total, len(stats))
+		}
+		progress = append(progress, done)
+	}
+
+	errs := conn.WriteStatisticsBatch(ctx, stats)
+	var got []bool
+	for _, err := range errs {
+		got = append(got, err == nil)
+	}
+	if diff := cmp.Diff([]bool{true, false, true}, got); diff != "" {
+		t.Errorf("WriteStatisticsBatch() unexpected successes (-want +got): %v", diff)
+	}
+	if diff := cmp.Diff([]string{"sensor.c", "sensor.a"}, uploaded); diff != "" {
+		t.Errorf("OnChunkUploaded() unexpected calls (-want +got): %v", diff)
+	}
+	if diff := cmp.Diff([]int{1, 2, 3}, progress); diff != "" {
+		t.Errorf("OnBatchProgress() unexpected calls (-want +got): %v", diff)
+	}
+}
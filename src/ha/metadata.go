@@ -0,0 +1,126 @@
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"nhooyr.io/websocket/wsjson"
+)
+
+// StatisticInfo is the metadata of a statistic already in Home
+// Assistant.
+type StatisticInfo struct {
+	StatisticID string `json:"statistic_id"`
+	Source      string `json:"source"`
+	Name        string `json:"name"`
+	HasSum      bool   `json:"has_sum"`
+	HasMean     bool   `json:"has_mean"`
+	// Unit is the unit the values are stored in.
+	Unit string `json:"statistics_unit_of_measurement"`
+}
+
+// ListStatisticIDs returns the statistics in Home Assistant, by ID.
+//
+// This function is NOT safe for concurrent calls.
+func (c *Connection) ListStatisticIDs(ctx context.Context) (_ map[string]StatisticInfo, err error) {
+	defer func() { c.Hooks.error(err) }()
+
+	id := c.incMessageID()
+
+	msg := struct {
+		Type string `json:"type"`
+		ID   int    `json:"id"`
+	}{
+		"recorder/list_statistic_ids",
+		id,
+	}
+
+	if err := wsjson.Write(ctx, c.conn, msg); err != nil {
+		return nil, err
+	}
+	rsp, err := c.waitResponse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return parseStatisticIDs(rsp.Result)
+}
+
+func parseStatisticIDs(result json.RawMessage) (map[string]StatisticInfo, error) {
+	var infos []StatisticInfo
+	if err := json.Unmarshal(result, &infos); err != nil {
+		return nil, fmt.Errorf("cannot parse statistic IDs: %w", err)
+	}
+	ret := make(map[string]StatisticInfo, len(infos))
+	for _, i := range infos {
+		ret[i.StatisticID] = i
+	}
+	return ret, nil
+}
+
+// CostStatisticID returns the ID of the cost statistic paired to an
+// energy one, with the _cost suffix like the costs computed by Home
+// Assistant: sensor.esb_electricity_cost or esb2ha:consumption_cost.
+func CostStatisticID(energyID string) string {
+	return energyID + "_cost"
+}
+
+// CostMetadata returns the metadata of the cost statistic paired to the
+// energy one: a total in the currency, with the same source, and named
+// after it if it has a name.
+func CostMetadata(energy StatisticMetadata, id, currency string) StatisticMetadata {
+	m := StatisticMetadata{
+		Source:            energy.Source,
+		HasSum:            true,
+		StatisticID:       id,
+		UnitOfMeasurement: currency,
+	}
+	if energy.Name != "" {
+		m.Name = energy.Name + " cost"
+	}
+	return m
+}
+
+// ValidateStatisticID checks that Home Assistant accepts the ID of the
+// statistic with the source of the metadata.
+//
+// Statistics with the recorder source belong to an entity, their ID is
+// an entity ID, like sensor.esb_electricity. The others are external
+// statistics, their ID is the source, a colon and the object ID, like
+// esb2ha:consumption. Both parts are lowercase letters, digits and
+// single underscores, not at the ends.
+func ValidateStatisticID(m StatisticMetadata) error {
+	id, source := m.StatisticID, m.Source
+	if source == "" || source == RecorderSource {
+		if strings.Contains(id, ":") {
+			return fmt.Errorf("%q is an external statistic ID, the statistics of a sensor have an entity ID like sensor.esb_electricity", id)
+		}
+		domain, object, ok := strings.Cut(id, ".")
+		if !ok || !validSlug(domain) || !validSlug(object) {
+			return fmt.Errorf("%q is not a valid entity ID: it must be like sensor.esb_electricity, with only lowercase letters, digits and single underscores", id)
+		}
+		return nil
+	}
+	domain, object, ok := strings.Cut(id, ":")
+	if !ok || !validSlug(domain) || !validSlug(object) {
+		return fmt.Errorf("%q is not a valid external statistic ID: it must be like %s:consumption, with only lowercase letters, digits and single underscores", id, source)
+	}
+	if domain != source {
+		return fmt.Errorf("the external statistic %q must start with %q", id, source+":")
+	}
+	return nil
+}
+
+// validSlug returns if s is a valid part of an ID.
+func validSlug(s string) bool {
+	if s == "" || s[0] == '_' || s[len(s)-1] == '_' || strings.Contains(s, "__") {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package ha
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseStatisticIDs(t *testing.T) {
+	result := `[
+		{"statistic_id":"sensor.esb","display_unit_of_measurement":"kWh","has_mean":false,"has_sum":true,"name":null,"source":"recorder","statistics_unit_of_measurement":"kWh","unit_class":"energy"},
+		{"statistic_id":"sensor.temperature","has_mean":true,"has_sum":false,"source":"recorder","statistics_unit_of_measurement":"°C"}
+	]`
+	got, err := parseStatisticIDs([]byte(result))
+	if err != nil {
+		t.Fatalf("parseStatisticIDs() unexpected error: %v", err)
+	}
+	want := map[string]StatisticInfo{
+		"sensor.esb":         {StatisticID: "sensor.esb", Source: "recorder", HasSum: true, Unit: "kWh"},
+		"sensor.temperature": {StatisticID: "sensor.temperature", Source: "recorder", HasMean: true, Unit: "°C"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseStatisticIDs() unexpected diff (+got -want): %v", diff)
+	}
+}
+
+func TestValidateStatisticID(t *testing.T) {
+	tests := []struct {
+		id, source string
+		valid      bool
+	}{
+		{id: "sensor.esb_electricity", valid: true},
+		{id: "sensor.esb_electricity", source: "recorder", valid: true},
+		{id: "sensor.esb2", valid: true},
+		{id: "esb_electricity"},
+		{id: "sensor.ESB"},
+		{id: "sensor.esb__electricity"},
+		{id: "sensor._esb"},
+		{id: "sensor.esb_"},
+		{id: "sensor.esb-electricity"},
+		{id: "esb2ha:consumption"},
+		{id: "esb2ha:consumption", source: "esb2ha", valid: true},
+		{id: "other:consumption", source: "esb2ha"},
+		{id: "sensor.consumption", source: "esb2ha"},
+		{id: "esb2ha:", source: "esb2ha"},
+	}
+	for _, tc := range tests {
+		err := ValidateStatisticID(StatisticMetadata{StatisticID: tc.id, Source: tc.source})
+		if got := err == nil; got != tc.valid {
+			t.Errorf("ValidateStatisticID(%q, source %q) = %v, want valid %v", tc.id, tc.source, err, tc.valid)
+		}
+	}
+}
+
+func TestCostMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		energy StatisticMetadata
+		want   StatisticMetadata
+	}{
+		{
+			name:   "entity",
+			energy: StatisticMetadata{HasSum: true, StatisticID: "sensor.esb", UnitOfMeasurement: "kWh"},
+			want:   StatisticMetadata{HasSum: true, StatisticID: "sensor.esb_cost", UnitOfMeasurement: "EUR"},
+		},
+		{
+			name:   "external with a name",
+			energy: StatisticMetadata{Source: "esb2ha", HasSum: true, Name: "Home", StatisticID: "esb2ha:consumption", UnitOfMeasurement: "kWh"},
+			want:   StatisticMetadata{Source: "esb2ha", HasSum: true, Name: "Home cost", StatisticID: "esb2ha:consumption_cost", UnitOfMeasurement: "EUR"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := CostMetadata(tc.energy, CostStatisticID(tc.energy.StatisticID), "EUR")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("CostMetadata() unexpected diff (+got -want): %v", diff)
+			}
+			if err := ValidateStatisticID(got); err != nil {
+				t.Errorf("ValidateStatisticID(%q) unexpected error: %v", got.StatisticID, err)
+			}
+		})
+	}
+}
+
+func TestStatisticMetadata_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		m    StatisticMetadata
+		want string
+	}{
+		{
+			m:    StatisticMetadata{HasSum: true, StatisticID: "sensor.esb", UnitOfMeasurement: "kWh"},
+			want: `{"source":"recorder","has_mean":false,"has_sum":true,"name":"","statistic_id":"sensor.esb","unit_of_measurement":"kWh"}`,
+		},
+		{
+			m:    StatisticMetadata{Source: "esb2ha", HasSum: true, Name: "ESB", StatisticID: "esb2ha:consumption", UnitOfMeasurement: "kWh"},
+			want: `{"source":"esb2ha","has_mean":false,"has_sum":true,"name":"ESB","statistic_id":"esb2ha:consumption","unit_of_measurement":"kWh"}`,
+		},
+	}
+	for _, tc := range tests {
+		got, err := json.Marshal(Statistics{Metadata: tc.m})
+		if err != nil {
+			t.Fatalf("json.Marshal() unexpected error: %v", err)
+		}
+		want := `{"metadata":` + tc.want + `,"stats":null}`
+		if string(got) != want {
+			t.Errorf("json.Marshal() = %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package ha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// CallService calls a Home Assistant service, like notify.notify, via
+// the REST API.
+//
+// The service is the domain and the name of the service separated by a
+// dot. The host has the same format of NewConnection.
+func CallService(ctx context.Context, host, accessToken, service string, data map[string]any) error {
+	domain, name, ok := strings.Cut(service, ".")
+	if !ok || domain == "" || name == "" {
+		return fmt.Errorf("invalid service %q, want domain.service", service)
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	url, err := restURL(host, "/api/services/"+domain+"/"+name)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	rsp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return fmt.Errorf("status %v: %s", rsp.Status, msg)
+	}
+	return nil
+}
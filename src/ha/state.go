@@ -0,0 +1,86 @@
+package ha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SetState sets the state and the attributes of an entity via the
+// Home Assistant REST API.
+//
+// The entity is created if it doesn't exist, but it is not persisted:
+// Home Assistant forgets it at restart until the next update.
+// The host has the same format of NewConnection.
+func SetState(ctx context.Context, host, accessToken, entityID, state string, attributes map[string]any) error {
+	body, err := json.Marshal(struct {
+		State      string         `json:"state"`
+		Attributes map[string]any `json:"attributes,omitempty"`
+	}{state, attributes})
+	if err != nil {
+		return err
+	}
+
+	url, err := restURL(host, "/api/states/"+entityID)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	rsp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	// 201 when the entity is created, 200 when it is updated.
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return fmt.Errorf("status %v: %s", rsp.Status, msg)
+	}
+	return nil
+}
+
+// State returns the state and the attributes of an entity, and false if
+// it doesn't exist.
+//
+// The host has the same format of NewConnection.
+func State(ctx context.Context, host, accessToken, entityID string) (string, map[string]any, bool, error) {
+	url, err := restURL(host, "/api/states/"+entityID)
+	if err != nil {
+		return "", nil, false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", nil, false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	rsp, err := httpClient().Do(req)
+	if err != nil {
+		return "", nil, false, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNotFound {
+		return "", nil, false, nil
+	}
+	if rsp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return "", nil, false, fmt.Errorf("status %v: %s", rsp.Status, msg)
+	}
+	var st struct {
+		State      string         `json:"state"`
+		Attributes map[string]any `json:"attributes"`
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(&st); err != nil {
+		return "", nil, false, fmt.Errorf("cannot parse state: %w", err)
+	}
+	return st.State, st.Attributes, true, nil
+}
@@ -0,0 +1,60 @@
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestState(t *testing.T) {
+	states := map[string]json.RawMessage{
+		"sensor.other": json.RawMessage(`{"entity_id":"sensor.other","state":"unrelated","attributes":{}}`),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+			t.Errorf("Authorization = %q, want Bearer tok", got)
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/states/")
+		switch r.Method {
+		case http.MethodGet:
+			st, ok := states[id]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(st)
+		case http.MethodPost:
+			var st json.RawMessage
+			if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+				t.Errorf("cannot decode request: %v", err)
+			}
+			states[id] = st
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	ctx := context.Background()
+
+	if _, _, ok, err := State(ctx, host, "tok", "sensor.status"); ok || err != nil {
+		t.Fatalf("State() of a missing entity = %v, %v, want false, nil", ok, err)
+	}
+	if err := SetState(ctx, host, "tok", "sensor.status", "ok", map[string]any{"lag_hours": 30}); err != nil {
+		t.Fatalf("SetState() unexpected error: %v", err)
+	}
+	state, attrs, ok, err := State(ctx, host, "tok", "sensor.status")
+	if err != nil || !ok {
+		t.Fatalf("State() = %v, %v, want true, nil", ok, err)
+	}
+	if state != "ok" {
+		t.Errorf("State() = %q, want ok", state)
+	}
+	if diff := cmp.Diff(map[string]any{"lag_hours": 30.0}, attrs); diff != "" {
+		t.Errorf("State() attributes unexpected diff (+got -want): %v", diff)
+	}
+}
@@ -0,0 +1,99 @@
+package ha
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	// TLS makes the connections to the hosts without a scheme use wss
+	// and https, like the ones with https://.
+	TLS bool
+	// TLSConfig is used by the TLS connections to Home Assistant, like
+	// for a private CA, nil for the system defaults.
+	TLSConfig *tls.Config
+)
+
+// NewTLSConfig returns the TLS configuration trusting the PEM
+// certificates in caCertFile, if not empty, besides the system ones.
+//
+// insecureSkipVerify accepts any certificate, it is meant only for
+// testing, since anybody in the middle can read the token.
+func NewTLSConfig(caCertFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caCertFile == "" {
+		return c, nil
+	}
+	pem, err := os.ReadFile(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates in %s", caCertFile)
+	}
+	c.RootCAs = pool
+	return c, nil
+}
+
+// urls returns the URL of the websocket API and the base URL of the
+// REST API, with the scheme of the host if any.
+func urls(host string) (ws, rest string, err error) {
+	if host == SupervisorHost {
+		// The Supervisor proxies the REST API under /core/api, but
+		// not the websocket one.
+		return "ws://supervisor/core/websocket", "http://supervisor/core", nil
+	}
+	secure := TLS
+	if h, ok := strings.CutPrefix(host, "https://"); ok {
+		host, secure = h, true
+	} else if h, ok := strings.CutPrefix(host, "http://"); ok {
+		host, secure = h, false
+	} else if strings.Contains(host, "://") {
+		return "", "", errors.New("the Home Assistant server can only start with http:// or https://")
+	}
+	host = strings.TrimSuffix(host, "/")
+	if secure {
+		return "wss://" + host + "/api/websocket", "https://" + host, nil
+	}
+	return "ws://" + host + "/api/websocket", "http://" + host, nil
+}
+
+// restURL returns the URL of an endpoint of the REST API, like
+// /api/states.
+func restURL(host, path string) (string, error) {
+	_, rest, err := urls(host)
+	return rest + path, err
+}
+
+var (
+	clientMu sync.Mutex
+	// client is the client for TLSConfig, reused to keep the
+	// connections alive.
+	client    *http.Client
+	clientTLS *tls.Config
+)
+
+// httpClient returns the client for the connections to Home Assistant.
+func httpClient() *http.Client {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if TLSConfig == nil {
+		return http.DefaultClient
+	}
+	if client == nil || clientTLS != TLSConfig {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = TLSConfig
+		client, clientTLS = &http.Client{Transport: t}, TLSConfig
+	}
+	return client
+}
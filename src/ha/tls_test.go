@@ -0,0 +1,66 @@
+package ha
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestURLs(t *testing.T) {
+	tests := []struct {
+		host     string
+		tls      bool
+		ws, rest string
+	}{
+		{"ha:8123", false, "ws://ha:8123/api/websocket", "http://ha:8123"},
+		{"ha:8123", true, "wss://ha:8123/api/websocket", "https://ha:8123"},
+		{"https://ha.example.com/", false, "wss://ha.example.com/api/websocket", "https://ha.example.com"},
+		{"http://ha:8123", true, "ws://ha:8123/api/websocket", "http://ha:8123"},
+		{SupervisorHost, true, "ws://supervisor/core/websocket", "http://supervisor/core"},
+	}
+	defer func() { TLS = false }()
+	for _, tc := range tests {
+		TLS = tc.tls
+		ws, rest, err := urls(tc.host)
+		if err != nil || ws != tc.ws || rest != tc.rest {
+			t.Errorf("urls(%q) with TLS %v = %q, %q, %v, want %q, %q", tc.host, tc.tls, ws, rest, err, tc.ws, tc.rest)
+		}
+	}
+	if _, _, err := urls("ftp://ha"); err == nil {
+		t.Error("urls(ftp://ha) = nil error, want error")
+	}
+}
+
+func TestState_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"state": "ok"}`))
+	}))
+	defer srv.Close()
+	defer func() { TLSConfig = nil }()
+	ctx := context.Background()
+
+	if _, _, _, err := State(ctx, srv.URL, "tok", "sensor.status"); err == nil {
+		t.Fatal("State() with an unknown CA = nil error, want error")
+	}
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(ca, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if TLSConfig, err = NewTLSConfig(ca, false); err != nil {
+		t.Fatalf("NewTLSConfig() unexpected error: %v", err)
+	}
+	if state, _, ok, err := State(ctx, srv.URL, "tok", "sensor.status"); err != nil || !ok || state != "ok" {
+		t.Errorf("State() = %q, %v, %v, want ok, true, nil", state, ok, err)
+	}
+
+	if _, err := NewTLSConfig(filepath.Join(t.TempDir(), "missing.pem"), false); err == nil {
+		t.Error("NewTLSConfig() of a missing file = nil error, want error")
+	}
+}
@@ -0,0 +1,45 @@
+package ha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SendWebhook posts the payload as JSON to a Home Assistant webhook.
+//
+// Webhooks don't require authentication, therefore they can be used to
+// push data to trigger-based template sensors without an admin token.
+// The host has the same format of NewConnection.
+func SendWebhook(ctx context.Context, host, webhookID string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	url, err := restURL(host, "/api/webhook/"+webhookID)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	// Home Assistant returns 200 even for unknown webhooks, to avoid leaking
+	// which ones exist, so there is not much more to check here.
+	if rsp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return fmt.Errorf("status %v: %s", rsp.Status, msg)
+	}
+	return nil
+}
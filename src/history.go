@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/store"
+)
+
+type historyCmd struct {
+	storePath, mprn string
+	period          period
+}
+
+func (historyCmd) Name() string { return "history" }
+
+func (historyCmd) Synopsis() string {
+	return "export the reads kept in the local store as an ESB CSV file"
+}
+
+func (historyCmd) Usage() string {
+	return `history <flags>
+
+All the non optional flags are required, but can be provided as environment variables as well.
+The CSV file is printed on standard output.
+
+pipe, serve and sync keep every read downloaded in -store, also after
+ESB stops serving it. history writes them back in the same format of
+the file downloaded from ESB, with the latest value of the revised
+ones, to upload them again, like to another sensor or destination:
+
+  esb2ha history -store esb2ha.db -mprn 10012345678 | esb2ha upload [...]
+
+`
+}
+
+func (c *historyCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.storePath, "store", "", "the local database where the reads are kept")
+	fs.StringVar(&c.mprn, "mprn", "", "the mprn number of the meter")
+	c.period.SetFlags(fs)
+}
+
+func (c *historyCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	from, to, err := c.period.times()
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if from.IsZero() {
+		from = time.Unix(0, 0)
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	if err := c.export(os.Stdout, from, to); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// export writes the reads of the meter ending between from and to.
+func (c *historyCmd) export(out io.Writer, from, to time.Time) error {
+	st, err := store.Open(c.storePath)
+	if err != nil {
+		return fmt.Errorf("cannot open local store: %w", err)
+	}
+	defer st.Close()
+
+	reads, err := st.Reads(c.mprn, from, to)
+	if err != nil {
+		return fmt.Errorf("cannot read the reads: %w", err)
+	}
+	if len(reads) == 0 {
+		return fmt.Errorf("no reads of %s in the store", c.mprn)
+	}
+	// A result per read type, the reads are already sorted by time.
+	var res []parse.Result
+	byType := map[string]int{}
+	for _, r := range reads {
+		i, ok := byType[r.ReadType]
+		if !ok {
+			i = len(res)
+			byType[r.ReadType] = i
+			res = append(res, parse.Result{MPRN: r.MPRN, MeterSerialNumber: r.Serial, ReadTypes: r.ReadType})
+		}
+		res[i].Reads = append(res[i].Reads, parse.Read{Value: r.Value, EndTime: r.EndTime})
+	}
+	if err := parse.WriteHDF(out, res); err != nil {
+		return err
+	}
+	slog.Info("Exported", "reads", len(reads))
+	return nil
+}
@@ -0,0 +1,27 @@
+// Package keyring stores the passwords and the tokens in the keyring of
+// the operating system: the Keychain on macOS, the Credential Manager on
+// Windows and the Secret Service, like GNOME Keyring or KWallet, on the
+// others.
+//
+// On macOS and on the Secret Service it runs the security and the
+// secret-tool commands, which must be installed.
+package keyring
+
+import "errors"
+
+// Service is the name of the entries of esb2ha in the keyring.
+const Service = "esb2ha"
+
+// ErrNotFound is returned by Get when the keyring has no such entry.
+var ErrNotFound = errors.New("secret not found in the keyring")
+
+// Get returns the secret of the user of service.
+func Get(service, user string) (string, error) {
+	return get(service, user)
+}
+
+// Set stores the secret of the user of service, replacing the previous
+// one.
+func Set(service, user, secret string) error {
+	return set(service, user, secret)
+}
@@ -0,0 +1,42 @@
+//go:build !darwin && !windows
+
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretTool is the command of libsecret, a variable for the tests.
+var secretTool = "secret-tool"
+
+func get(service, user string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(secretTool, "lookup", "service", service, "username", user)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	// lookup fails without a message when the item doesn't exist.
+	if errors.As(err, &exit) && len(out) == 0 && stderr.Len() == 0 {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read the keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+func set(service, user, secret string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(secretTool, "store", "--label="+service+" "+user, "service", service, "username", user)
+	// The secret is read from standard input, without the newline.
+	cmd.Stdin = strings.NewReader(secret)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cannot write the keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
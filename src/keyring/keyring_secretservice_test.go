@@ -0,0 +1,55 @@
+//go:build !darwin && !windows
+
+package keyring
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSecretTool replaces secret-tool with a script keeping the secrets
+// in files of a temporary directory.
+func fakeSecretTool(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+dir="` + dir + `"
+case "$1" in
+store) cat > "$dir/$4-$6" ;;
+lookup) [ -f "$dir/$3-$5" ] || exit 1; cat "$dir/$3-$5" ;;
+*) echo "unknown command $1" >&2; exit 2 ;;
+esac
+`
+	path := filepath.Join(dir, "secret-tool")
+	if err := os.WriteFile(path, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	old := secretTool
+	secretTool = path
+	t.Cleanup(func() { secretTool = old })
+}
+
+func TestKeyring(t *testing.T) {
+	fakeSecretTool(t)
+
+	if _, err := Get(Service, "ha_token"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() before Set() error = %v, want ErrNotFound", err)
+	}
+	for _, secret := range []string{"first", "second\nwith a newline"} {
+		if err := Set(Service, "ha_token", secret); err != nil {
+			t.Fatalf("Set() unexpected error: %v", err)
+		}
+		got, err := Get(Service, "ha_token")
+		if err != nil {
+			t.Fatalf("Get() unexpected error: %v", err)
+		}
+		if got != secret {
+			t.Errorf("Get() = %q, want %q", got, secret)
+		}
+	}
+	if _, err := Get(Service, "esb_password"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of another user error = %v, want ErrNotFound", err)
+	}
+}
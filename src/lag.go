@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// reportLag returns how far behind now the latest read is.
+//
+// The lag is recorded in the OpenTelemetry and Prometheus metrics and, if configured, sent to the Home
+// Assistant lag sensor, so it is clear whether missing data is ESB's
+// fault. It returns false if there are no reads.
+// The period of the reads is kept in firstRead and lastRead.
+func (c *uploadCmd) reportLag(ctx context.Context, mprn string, parsed []parse.Result, now time.Time) (time.Duration, bool) {
+	c.firstRead, c.lastRead = readPeriod(parsed)
+	latest := c.lastRead
+	if latest.IsZero() {
+		return 0, false
+	}
+	lag := now.Sub(latest)
+	dataLag.Record(ctx, lag.Hours(), metric.WithAttributes(attribute.String("mprn", mprn)))
+	promMetrics.lagged(mprn, lag)
+
+	if c.lagSensor != "" {
+		attrs := map[string]any{
+			"unit_of_measurement": "h",
+			"device_class":        "duration",
+			"entity_category":     "diagnostic",
+			"latest_read":         latest.Format(time.RFC3339),
+			"mprn":                mprn,
+		}
+		if err := ha.SetState(ctx, c.server, c.token, c.lagSensor, strconv.Itoa(int(lag.Hours())), attrs); err != nil {
+			// Not worth failing the upload for this.
+			slog.Warn("cannot update the lag sensor", "sensor", c.lagSensor, "err", err)
+		}
+	}
+	return lag, true
+}
+
+// readPeriod returns the end time of the oldest and of the most recent
+// read, or zero if there are none.
+func readPeriod(parsed []parse.Result) (first, latest time.Time) {
+	for _, res := range parsed {
+		for _, rd := range res.Reads {
+			if first.IsZero() || rd.EndTime.Before(first) {
+				first = rd.EndTime
+			}
+			if rd.EndTime.After(latest) {
+				latest = rd.EndTime
+			}
+		}
+	}
+	return first, latest
+}
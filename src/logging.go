@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// setupLogging sets the default slog logger, which writes the progress,
+// the warnings and the errors on standard error, so standard output
+// only has the data.
+//
+// verbose adds the debug messages, like the phases of the login, quiet
+// keeps only the warnings and the errors. The format is text, for
+// humans, or json, one object per line.
+func setupLogging(verbose, quiet bool, format string) error {
+	if verbose && quiet {
+		return fmt.Errorf("-v and -quiet are mutually exclusive")
+	}
+	level := slog.LevelInfo
+	switch {
+	case verbose:
+		level = slog.LevelDebug
+	case quiet:
+		level = slog.LevelWarn
+	}
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = &textHandler{w: os.Stderr, mu: &sync.Mutex{}, level: level}
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	default:
+		return fmt.Errorf("unknown -log_format %q, want text or json", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// textHandler writes the messages like esb2ha always did, one per line
+// without the time, prefixed by the level unless it is info.
+//
+// The err attribute follows the message after a colon, the others are
+// appended as key=value.
+type textHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	level slog.Level
+	attrs []slog.Attr
+}
+
+func (h *textHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("ERROR: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("WARNING: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("DEBUG: ")
+	}
+	b.WriteString(r.Message)
+
+	var errs, others []slog.Attr
+	add := func(a slog.Attr) bool {
+		if a.Key == "err" {
+			errs = append(errs, a)
+		} else {
+			others = append(others, a)
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+	for _, a := range errs {
+		fmt.Fprintf(&b, ": %v", a.Value)
+	}
+	for _, a := range others {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+	}
+	b.WriteByte('\n')
+
+	progress.clear()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(c.attrs[:len(c.attrs):len(c.attrs)], attrs...)
+	return &c
+}
+
+// WithGroup is not supported, the attributes are written without the
+// group, which is not used by esb2ha.
+func (h *textHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// loginPhases reports the phases of the login as debug messages and
+// as events of the span in ctx.
+func loginPhases(ctx context.Context) func(phase string) {
+	event := spanEvent(ctx)
+	return func(phase string) {
+		slog.Debug("Login phase", "phase", phase)
+		event(phase)
+	}
+}
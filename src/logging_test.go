@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTextHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		level slog.Level
+		log   func(l *slog.Logger)
+		want  string
+	}{
+		{
+			name: "info",
+			log:  func(l *slog.Logger) { l.Info("Uploaded", "points", 24, "sensor", "sensor.esb") },
+			want: "Uploaded points=24 sensor=sensor.esb\n",
+		},
+		{
+			name: "error after the message",
+			log:  func(l *slog.Logger) { l.Warn("cannot upload", "sensor", "sensor.esb", "err", errors.New("timeout")) },
+			want: "WARNING: cannot upload: timeout sensor=sensor.esb\n",
+		},
+		{
+			name: "with attributes",
+			log:  func(l *slog.Logger) { l.With("mprn", "100").Error("failed", "err", errors.New("login")) },
+			want: "ERROR: failed: login mprn=100\n",
+		},
+		{
+			name: "debug hidden",
+			log:  func(l *slog.Logger) { l.Debug("Login phase", "phase", "token") },
+		},
+		{
+			name:  "debug",
+			level: slog.LevelDebug,
+			log:   func(l *slog.Logger) { l.Debug("Login phase", "phase", "token") },
+			want:  "DEBUG: Login phase phase=token\n",
+		},
+		{
+			name:  "quiet",
+			level: slog.LevelWarn,
+			log: func(l *slog.Logger) {
+				l.Info("Uploaded")
+				l.Warn("stale")
+			},
+			want: "WARNING: stale\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			tt.log(slog.New(&textHandler{w: &b, mu: &sync.Mutex{}, level: tt.level}))
+			if got := b.String(); got != tt.want {
+				t.Errorf("logged %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetupLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	tests := []struct {
+		verbose, quiet bool
+		format         string
+		wantErr        bool
+	}{
+		{format: "text"},
+		{verbose: true, format: "json"},
+		{quiet: true, format: "text"},
+		{verbose: true, quiet: true, format: "text", wantErr: true},
+		{format: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := setupLogging(tt.verbose, tt.quiet, tt.format); (err != nil) != tt.wantErr {
+			t.Errorf("setupLogging(%v, %v, %q) error = %v, wantErr %v", tt.verbose, tt.quiet, tt.format, err, tt.wantErr)
+		}
+	}
+}
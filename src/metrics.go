@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// promMetrics are served in the Prometheus text format at /metrics by
+// serve, to alert when the syncs fail. Unlike the OpenTelemetry ones,
+// they are always collected.
+var promMetrics = &syncMetrics{
+	lastSync:    map[string]time.Time{},
+	lastSuccess: map[string]time.Time{},
+	unpublished: map[string]time.Duration{},
+	lag:         map[string]time.Duration{},
+	points:      map[string]int64{},
+}
+
+// syncMetrics counts what happened since the start.
+type syncMetrics struct {
+	mu sync.Mutex
+	// lastSync and lastSuccess are the end of the last sync, and of the
+	// last successful one, by MPRN.
+	lastSync, lastSuccess map[string]time.Time
+	// unpublished is how long ESB has not published new data, by MPRN,
+	// see downloadCache.unchanged.
+	unpublished map[string]time.Duration
+	// lag is how far behind the sync the latest read was, by MPRN, like
+	// the esb2ha.data.lag OpenTelemetry gauge.
+	lag map[string]time.Duration
+	// points are the statistics uploaded, by sensor.
+	points        map[string]int64
+	loginFailures int64
+	haWriteErrors int64
+}
+
+func (m *syncMetrics) synced(mprn string, end time.Time, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSync[mprn] = end
+	if err == nil {
+		m.lastSuccess[mprn] = end
+	}
+}
+
+func (m *syncMetrics) stale(mprn string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unpublished[mprn] = d
+}
+
+func (m *syncMetrics) lagged(mprn string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lag[mprn] = d
+}
+
+func (m *syncMetrics) uploaded(sensor string, points int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.points[sensor] += int64(points)
+}
+
+func (m *syncMetrics) loginFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loginFailures++
+}
+
+func (m *syncMetrics) haWriteFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.haWriteErrors++
+}
+
+// write writes the metrics in the Prometheus text exposition format.
+func (m *syncMetrics) write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pw := &promWriter{w: w}
+	pw.family("esb2ha_last_sync_timestamp_seconds", "gauge", "When the last sync of the meter ended.")
+	for _, mprn := range slices.Sorted(maps.Keys(m.lastSync)) {
+		pw.sample("esb2ha_last_sync_timestamp_seconds", "mprn", mprn, unixSeconds(m.lastSync[mprn]))
+	}
+	pw.family("esb2ha_last_success_timestamp_seconds", "gauge", "When the last successful sync of the meter ended.")
+	for _, mprn := range slices.Sorted(maps.Keys(m.lastSuccess)) {
+		pw.sample("esb2ha_last_success_timestamp_seconds", "mprn", mprn, unixSeconds(m.lastSuccess[mprn]))
+	}
+	pw.family("esb2ha_unpublished_seconds", "gauge", "How long ESB has not published new data for the meter, as of the last sync.")
+	for _, mprn := range slices.Sorted(maps.Keys(m.unpublished)) {
+		pw.sample("esb2ha_unpublished_seconds", "mprn", mprn, strconv.FormatFloat(m.unpublished[mprn].Seconds(), 'f', -1, 64))
+	}
+	pw.family("esb2ha_data_lag_seconds", "gauge", "How far behind the last sync the latest read from ESB was.")
+	for _, mprn := range slices.Sorted(maps.Keys(m.lag)) {
+		pw.sample("esb2ha_data_lag_seconds", "mprn", mprn, strconv.FormatFloat(m.lag[mprn].Seconds(), 'f', -1, 64))
+	}
+	pw.family("esb2ha_points_uploaded_total", "counter", "Number of statistics sent to Home Assistant.")
+	for _, sensor := range slices.Sorted(maps.Keys(m.points)) {
+		pw.sample("esb2ha_points_uploaded_total", "sensor", sensor, strconv.FormatInt(m.points[sensor], 10))
+	}
+	pw.family("esb2ha_esb_login_failures_total", "counter", "Number of failed logins to ESB.")
+	pw.sample("esb2ha_esb_login_failures_total", "", "", strconv.FormatInt(m.loginFailures, 10))
+	pw.family("esb2ha_ha_write_errors_total", "counter", "Number of statistics writes rejected by, or not delivered to, Home Assistant.")
+	pw.sample("esb2ha_ha_write_errors_total", "", "", strconv.FormatInt(m.haWriteErrors, 10))
+	return pw.err
+}
+
+func (m *syncMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// promWriter writes the lines of the Prometheus text format, keeping
+// the first error.
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *promWriter) printf(format string, args ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+func (p *promWriter) family(name, typ, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a value with at most a label, none if the name is empty.
+func (p *promWriter) sample(name, label, value, v string) {
+	if label == "" {
+		p.printf("%s %s\n", name, v)
+		return
+	}
+	p.printf("%s{%s=%s} %s\n", name, label, strconv.Quote(value), v)
+}
+
+func unixSeconds(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
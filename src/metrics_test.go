@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncMetrics(t *testing.T) {
+	m := &syncMetrics{
+		lastSync:    map[string]time.Time{},
+		lastSuccess: map[string]time.Time{},
+		unpublished: map[string]time.Duration{},
+		lag:         map[string]time.Duration{},
+		points:      map[string]int64{},
+	}
+	end := time.Unix(1700000000, 0)
+	m.synced("100", end, nil)
+	m.synced("100", end.Add(time.Hour), errors.New("failed"))
+	m.stale("100", 4*24*time.Hour)
+	m.lagged("100", 30*time.Hour)
+	m.uploaded("sensor.home", 24)
+	m.loginFailed()
+
+	var b strings.Builder
+	if err := m.write(&b); err != nil {
+		t.Fatalf("write() unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE esb2ha_last_sync_timestamp_seconds gauge\n",
+		`esb2ha_last_sync_timestamp_seconds{mprn="100"} 1700003600` + "\n",
+		`esb2ha_last_success_timestamp_seconds{mprn="100"} 1700000000` + "\n",
+		"# TYPE esb2ha_unpublished_seconds gauge\n",
+		`esb2ha_unpublished_seconds{mprn="100"} 345600` + "\n",
+		"# TYPE esb2ha_data_lag_seconds gauge\n",
+		`esb2ha_data_lag_seconds{mprn="100"} 108000` + "\n",
+		`esb2ha_points_uploaded_total{sensor="sensor.home"} 24` + "\n",
+		"esb2ha_esb_login_failures_total 1\n",
+		"esb2ha_ha_write_errors_total 0\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("write() = %q, want it to contain %q", b.String(), want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package notification sends the result of the syncs to people: to a webhook,
+// to a Home Assistant notification service, to ntfy or to Pushover.
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+// Result is the result of the sync of a meter.
+type Result struct {
+	MPRN string `json:"mprn"`
+	OK   bool   `json:"ok"`
+	// Error is why the sync failed, empty if OK.
+	Error string `json:"error,omitempty"`
+	// From is the end of the first read downloaded and To of the last
+	// one, zero if the sync failed before downloading any.
+	From time.Time `json:"from,omitzero"`
+	To   time.Time `json:"to,omitzero"`
+	// Time is when the sync ended.
+	Time time.Time `json:"time"`
+}
+
+// Title returns a one line summary of the result.
+func (r Result) Title() string {
+	if r.OK {
+		return "esb2ha: " + r.MPRN + " synced"
+	}
+	return "esb2ha: the sync of " + r.MPRN + " failed"
+}
+
+// Message returns the details of the result.
+func (r Result) Message() string {
+	var lines []string
+	if !r.OK {
+		lines = append(lines, "Error: "+r.Error)
+	}
+	if r.To.IsZero() {
+		lines = append(lines, "No data downloaded.")
+	} else {
+		const format = "2006-01-02 15:04"
+		lines = append(lines, fmt.Sprintf("Data from %s to %s.", r.From.Format(format), r.To.Format(format)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Notifier sends the result of a sync.
+type Notifier interface {
+	Notify(ctx context.Context, r Result) error
+}
+
+// All sends the result to every notifier, it doesn't stop at the first
+// failure.
+type All []Notifier
+
+func (a All) Notify(ctx context.Context, r Result) error {
+	var errs []error
+	for _, n := range a {
+		errs = append(errs, n.Notify(ctx, r))
+	}
+	return errors.Join(errs...)
+}
+
+// Webhook posts the result as JSON to a URL.
+type Webhook struct {
+	URL string
+}
+
+func (w Webhook) Notify(ctx context.Context, r Result) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(req, "webhook")
+}
+
+// HomeAssistant calls a Home Assistant service with the title and the
+// message, like notify.mobile_app_phone for a push notification or
+// persistent_notification.create for one in the sidebar.
+type HomeAssistant struct {
+	// Server has the format of ha.NewConnection.
+	Server, Token string
+	Service       string
+}
+
+func (h HomeAssistant) Notify(ctx context.Context, r Result) error {
+	data := map[string]any{"title": r.Title(), "message": r.Message()}
+	if h.Service == "persistent_notification.create" {
+		// Replaces the notification of the previous sync.
+		data["notification_id"] = "esb2ha_" + r.MPRN
+	}
+	if err := ha.CallService(ctx, h.Server, h.Token, h.Service, data); err != nil {
+		return fmt.Errorf("Home Assistant %s: %w", h.Service, err)
+	}
+	return nil
+}
+
+// Ntfy publishes the result to a ntfy topic.
+type Ntfy struct {
+	// URL is the URL of the topic, like https://ntfy.sh/mytopic.
+	URL string
+	// Token is the access token of the protected topics, optional.
+	Token string
+}
+
+func (n Ntfy) Notify(ctx context.Context, r Result) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(r.Message()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", r.Title())
+	if r.OK {
+		req.Header.Set("Tags", "white_check_mark")
+	} else {
+		req.Header.Set("Tags", "warning")
+		req.Header.Set("Priority", "high")
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return do(req, "ntfy")
+}
+
+// PushoverURL is the endpoint of the Pushover API.
+const PushoverURL = "https://api.pushover.net/1/messages.json"
+
+// Pushover sends the result with the Pushover API.
+type Pushover struct {
+	// Token is the token of the application, User the key of the user
+	// or of the group to notify.
+	Token, User string
+	// URL is PushoverURL if empty.
+	URL string
+}
+
+func (p Pushover) Notify(ctx context.Context, r Result) error {
+	form := url.Values{
+		"token":   {p.Token},
+		"user":    {p.User},
+		"title":   {r.Title()},
+		"message": {r.Message()},
+	}
+	if !r.OK {
+		form.Set("priority", "1")
+	}
+	endpoint := p.URL
+	if endpoint == "" {
+		endpoint = PushoverURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return do(req, "Pushover")
+}
+
+// do sends the request, any status but 2xx is an error.
+func do(req *http.Request, service string) error {
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", service, err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return fmt.Errorf("%s: status %v: %s", service, rsp.Status, msg)
+	}
+	return nil
+}
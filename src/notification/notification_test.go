@@ -0,0 +1,116 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// request is what the test server received.
+type request struct {
+	path    string
+	headers http.Header
+	body    string
+}
+
+func testServer(t *testing.T, got *[]request) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read the request: %v", err)
+		}
+		*got = append(*got, request{r.URL.Path, r.Header, string(body)})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestResult_Message(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		r    Result
+		want string
+	}{
+		{"ok", Result{OK: true, From: from, To: from.Add(24 * time.Hour)}, "Data from 2024-03-01 00:30 to 2024-03-02 00:30."},
+		{"failed download", Result{Error: "cannot login"}, "Error: cannot login\nNo data downloaded."},
+	}
+	for _, tc := range tests {
+		if got := tc.r.Message(); got != tc.want {
+			t.Errorf("%s: Message() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	var got []request
+	srv := testServer(t, &got)
+	r := Result{MPRN: "123", Error: "boom", Time: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)}
+
+	n := All{
+		Webhook{URL: srv.URL + "/hook"},
+		HomeAssistant{Server: strings.TrimPrefix(srv.URL, "http://"), Token: "tok", Service: "persistent_notification.create"},
+		Ntfy{URL: srv.URL + "/topic", Token: "ntfy"},
+		Pushover{Token: "app", User: "me", URL: srv.URL + "/pushover"},
+	}
+	if err := n.Notify(context.Background(), r); err != nil {
+		t.Fatalf("Notify() unexpected error: %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("Notify() sent %d requests, want 4", len(got))
+	}
+
+	var hook map[string]any
+	if err := json.Unmarshal([]byte(got[0].body), &hook); err != nil {
+		t.Fatalf("webhook: cannot decode %q: %v", got[0].body, err)
+	}
+	wantHook := map[string]any{"mprn": "123", "ok": false, "error": "boom", "time": "2024-03-02T10:00:00Z"}
+	if diff := cmp.Diff(wantHook, hook); diff != "" {
+		t.Errorf("webhook unexpected diff (+got -want): %v", diff)
+	}
+
+	if got[1].path != "/api/services/persistent_notification/create" || got[1].headers.Get("Authorization") != "Bearer tok" {
+		t.Errorf("Home Assistant request = %+v, want the persistent_notification.create service with the token", got[1])
+	}
+	var service map[string]any
+	if err := json.Unmarshal([]byte(got[1].body), &service); err != nil {
+		t.Fatalf("Home Assistant: cannot decode %q: %v", got[1].body, err)
+	}
+	if service["notification_id"] != "esb2ha_123" || service["title"] != r.Title() {
+		t.Errorf("Home Assistant data = %v, want the title and the notification ID of the meter", service)
+	}
+
+	if h := got[2].headers; h.Get("Title") != r.Title() || h.Get("Priority") != "high" || h.Get("Authorization") != "Bearer ntfy" {
+		t.Errorf("ntfy headers = %v, want the title, high priority and the token", h)
+	}
+	if got[2].body != r.Message() {
+		t.Errorf("ntfy body = %q, want %q", got[2].body, r.Message())
+	}
+
+	form, err := url.ParseQuery(got[3].body)
+	if err != nil {
+		t.Fatalf("Pushover: cannot decode %q: %v", got[3].body, err)
+	}
+	if form.Get("token") != "app" || form.Get("user") != "me" || form.Get("priority") != "1" {
+		t.Errorf("Pushover form = %v, want the token, the user and high priority", form)
+	}
+}
+
+func TestNotify_Status(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	err := Pushover{URL: srv.URL}.Notify(context.Background(), Result{OK: true})
+	if err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("Notify() = %v, want the error of the server", err)
+	}
+}
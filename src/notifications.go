@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"time"
+
+	"github.com/lorentz83/esb2ha/config"
+	"github.com/lorentz83/esb2ha/notification"
+)
+
+// syncNotifications sends the result of every sync, so that the
+// scheduled ones failing are noticed, see notification.
+type syncNotifications struct {
+	url, haService, ntfyURL, ntfyToken, pushoverToken, pushoverUser string
+	// on is always or failure.
+	on string
+}
+
+func (n *syncNotifications) SetFlags(fs *flag.FlagSet) {
+	optionalStringVar(fs, &n.url, "notify_url", "", "the URL where to post the result of every sync as JSON")
+	optionalStringVar(fs, &n.haService, "notify_ha_service", "", "the Home Assistant service notifying the result of every sync, like notify.mobile_app_phone or persistent_notification.create")
+	optionalStringVar(fs, &n.ntfyURL, "notify_ntfy_url", "", "the ntfy topic where to publish the result of every sync, like https://ntfy.sh/mytopic")
+	optionalSecretStringVar(fs, &n.ntfyToken, "notify_ntfy_token", "the access token of -notify_ntfy_url")
+	optionalSecretStringVar(fs, &n.pushoverToken, "notify_pushover_token", "the token of the Pushover application sending the result of every sync")
+	optionalStringVar(fs, &n.pushoverUser, "notify_pushover_user", "", "the Pushover user key receiving the result of every sync")
+	fs.StringVar(&n.on, "notify_on", "always", "when to send the notifications: always or failure")
+}
+
+// notificationsFromConfig returns the notifications of the
+// configuration file.
+func notificationsFromConfig(c config.Notifications) syncNotifications {
+	on := c.On
+	if on == "" {
+		on = "always"
+	}
+	return syncNotifications{
+		url:           c.URL,
+		haService:     c.HAService,
+		ntfyURL:       c.NtfyURL,
+		ntfyToken:     c.NtfyToken,
+		pushoverToken: c.PushoverToken,
+		pushoverUser:  c.PushoverUser,
+		on:            on,
+	}
+}
+
+// validate returns an error if the flags are inconsistent.
+func (n *syncNotifications) validate() error {
+	if n.on != "always" && n.on != "failure" {
+		return errors.New("-notify_on must be always or failure")
+	}
+	if (n.pushoverToken == "") != (n.pushoverUser == "") {
+		return errors.New("-notify_pushover_token and -notify_pushover_user must be set together")
+	}
+	return nil
+}
+
+// notifiers returns where to send the notifications, the Home Assistant
+// service is called on the server of up.
+func (n *syncNotifications) notifiers(up *uploadCmd) notification.All {
+	var ret notification.All
+	if n.url != "" {
+		ret = append(ret, notification.Webhook{URL: n.url})
+	}
+	if n.haService != "" {
+		ret = append(ret, notification.HomeAssistant{Server: up.server, Token: up.token, Service: n.haService})
+	}
+	if n.ntfyURL != "" {
+		ret = append(ret, notification.Ntfy{URL: n.ntfyURL, Token: n.ntfyToken})
+	}
+	if n.pushoverToken != "" {
+		ret = append(ret, notification.Pushover{Token: n.pushoverToken, User: n.pushoverUser})
+	}
+	return ret
+}
+
+// send notifies the result of the sync of a meter, with the reads
+// downloaded by up. Errors are only logged, like for the status sensor.
+func (n *syncNotifications) send(ctx context.Context, up *uploadCmd, mprn string, syncErr error) {
+	all := n.notifiers(up)
+	if len(all) == 0 || (syncErr == nil && n.on == "failure") {
+		return
+	}
+	r := notification.Result{MPRN: mprn, OK: syncErr == nil, From: up.firstRead, To: up.lastRead, Time: time.Now()}
+	if syncErr != nil {
+		r.Error = syncErr.Error()
+	}
+	if err := all.Notify(ctx, r); err != nil {
+		slog.Warn("cannot notify the result of the sync", "mprn", mprn, "err", err)
+	}
+}
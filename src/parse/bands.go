@@ -0,0 +1,108 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+// DefaultBands are the bands of the Irish smart tariffs, see ParseBands.
+const DefaultBands = "peak=17:00-19:00,day=08:00-23:00,night=23:00-08:00"
+
+// Band is a band of the day of a tariff, like the night.
+type Band struct {
+	Name string
+	// Start and End are the time from midnight, in Irish time. End is
+	// excluded, a band ending before its start crosses midnight, and
+	// one ending at its start is the whole day.
+	Start, End time.Duration
+}
+
+// contains returns whether the band contains the time from midnight.
+func (b Band) contains(d time.Duration) bool {
+	if b.Start < b.End {
+		return d >= b.Start && d < b.End
+	}
+	return d >= b.Start || d < b.End
+}
+
+// Bands are the bands of a tariff. When more bands contain a time the
+// first wins, so a narrow band, like the peak, can be listed before the
+// day.
+type Bands []Band
+
+// ParseBands parses bands like DefaultBands: a comma separated list of
+// name=HH:MM-HH:MM.
+func ParseBands(s string) (Bands, error) {
+	var ret Bands
+	seen := map[string]bool{}
+	for _, kv := range strings.Split(s, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		name, span, ok := strings.Cut(kv, "=")
+		start, end, ok2 := strings.Cut(span, "-")
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("invalid tariff band %q, want name=HH:MM-HH:MM", kv)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("tariff band %q listed twice", name)
+		}
+		seen[name] = true
+		b := Band{Name: name}
+		var err error
+		if b.Start, err = parseClock(start); err != nil {
+			return nil, fmt.Errorf("invalid tariff band %q: %w", kv, err)
+		}
+		if b.End, err = parseClock(end); err != nil {
+			return nil, fmt.Errorf("invalid tariff band %q: %w", kv, err)
+		}
+		ret = append(ret, b)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no tariff bands in %q", s)
+	}
+	return ret, nil
+}
+
+// parseClock parses HH:MM as the time from midnight, the reads are half
+// hours so the minutes must be 00 or 30.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	if t.Minute()%30 != 0 {
+		return 0, fmt.Errorf("invalid time %q, the reads are half hours: want HH:00 or HH:30", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Of returns the name of the band of the half hour starting at t, empty
+// if no band contains it.
+func (bb Bands) Of(t time.Time) string {
+	t = t.In(irelandTimezone)
+	d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	for _, b := range bb {
+		if b.contains(d) {
+			return b.Name
+		}
+	}
+	return ""
+}
+
+// TranslateBand translates ESB data into the Home Assistant statistics
+// of a tariff band, like Translate: the reads of the other bands count
+// as zero, so the statistics of all the bands add up to the one of
+// Translate.
+func TranslateBand(raw Result, band string, bands Bands, opts Options) (ha.Statistics, error) {
+	q := raw.Quantity()
+	return translate(raw, q.Unit(), opts, func(r Read) (float64, error) {
+		if bands.Of(r.EndTime.Add(-30*time.Minute)) != band {
+			return 0, nil
+		}
+		return q.Amount(r.Value), nil
+	})
+}
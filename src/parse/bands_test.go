@@ -0,0 +1,89 @@
+package parse
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseBands(t *testing.T) {
+	bands, err := ParseBands(DefaultBands)
+	if err != nil {
+		t.Fatalf("ParseBands(%q) unexpected error: %v", DefaultBands, err)
+	}
+	at := func(h, m int) time.Time { return time.Date(2024, 1, 15, h, m, 0, 0, irelandTimezone) }
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{at(0, 0), "night"},
+		{at(7, 30), "night"},
+		{at(8, 0), "day"},
+		{at(16, 30), "day"},
+		{at(17, 0), "peak"},
+		{at(18, 30), "peak"},
+		{at(19, 0), "day"},
+		{at(22, 30), "day"},
+		{at(23, 0), "night"},
+		// Summer time.
+		{time.Date(2024, 7, 15, 17, 0, 0, 0, irelandTimezone), "peak"},
+	}
+	for _, tc := range tests {
+		if got := bands.Of(tc.t); got != tc.want {
+			t.Errorf("Of(%v) = %q, want %q", tc.t, got, tc.want)
+		}
+	}
+
+	if got := (Bands{{Name: "day", Start: 8 * time.Hour, End: 23 * time.Hour}}).Of(at(3, 0)); got != "" {
+		t.Errorf("Of() outside the bands = %q, want empty", got)
+	}
+	if got := (Bands{{Name: "all"}}).Of(at(3, 0)); got != "all" {
+		t.Errorf("Of() of a band of the whole day = %q, want all", got)
+	}
+
+	for _, s := range []string{"", "day", "day=08:00", "day=8am-11pm", "day=08:15-23:00", "=08:00-23:00", "day=08:00-23:00,day=23:00-08:00"} {
+		if got, err := ParseBands(s); err == nil {
+			t.Errorf("ParseBands(%q) = %v, want error", s, got)
+		}
+	}
+}
+
+func TestTranslateBand(t *testing.T) {
+	bands, err := ParseBands(DefaultBands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := Result{MPRN: "123", ReadTypes: wantReadType}
+	start := time.Date(2024, 1, 15, 0, 30, 0, 0, irelandTimezone)
+	for i := range 48 {
+		raw.Reads = append(raw.Reads, Read{Value: float64(i%5 + 1), EndTime: start.Add(time.Duration(i) * 30 * time.Minute)})
+	}
+
+	all, err := Translate(raw, Options{})
+	if err != nil {
+		t.Fatalf("Translate() unexpected error: %v", err)
+	}
+	total := make([]float64, len(all.Stats))
+	for _, band := range []string{"day", "night", "peak"} {
+		got, err := TranslateBand(raw, band, bands, Options{})
+		if err != nil {
+			t.Fatalf("TranslateBand(%q) unexpected error: %v", band, err)
+		}
+		if len(got.Stats) != len(all.Stats) || got.Metadata.UnitOfMeasurement != "kWh" {
+			t.Fatalf("TranslateBand(%q) = %d hours in %s, want %d in kWh", band, len(got.Stats), got.Metadata.UnitOfMeasurement, len(all.Stats))
+		}
+		for i, s := range got.Stats {
+			total[i] += s.State
+			// The hour starting at 17:00 has the reads ending at 17:00
+			// and 17:30, the first in the day band.
+			if band == "peak" && s.Start.Hour() == 17 && s.State != raw.Reads[34].Value/2 {
+				t.Errorf("TranslateBand(peak) at 17:00 = %v, want only the read ending at 17:30", s.State)
+			}
+		}
+	}
+	for i, s := range all.Stats {
+		if math.Abs(total[i]-s.State) > 1e-9 {
+			t.Errorf("the bands at %v add up to %v, want %v", s.Start, total[i], s.State)
+		}
+	}
+}
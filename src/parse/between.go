@@ -0,0 +1,27 @@
+package parse
+
+import "time"
+
+// Between returns the reads of the half hours from from to to, dropping
+// the results left without reads. A zero from or to leaves that side of
+// the period open.
+//
+// The reads are not copied, HDF shares them between the results.
+func Between(res []Result, from, to time.Time) []Result {
+	var ret []Result
+	for _, r := range res {
+		first, last := 0, len(r.Reads)
+		for first < last && !from.IsZero() && r.Reads[first].EndTime.Add(-30*time.Minute).Before(from) {
+			first++
+		}
+		for last > first && !to.IsZero() && r.Reads[last-1].EndTime.After(to) {
+			last--
+		}
+		if first == last {
+			continue
+		}
+		r.Reads = r.Reads[first:last]
+		ret = append(ret, r)
+	}
+	return ret
+}
@@ -0,0 +1,64 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBetween(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	ts := func(h, m int) time.Time { return time.Date(2023, 01, 15, h, m, 0, 0, gmt) }
+	res := []Result{
+		{MPRN: "1", Reads: []Read{{Value: 1, EndTime: ts(10, 0)}, {Value: 2, EndTime: ts(10, 30)}}},
+		{MPRN: "1", Reads: []Read{{Value: 3, EndTime: ts(12, 0)}, {Value: 4, EndTime: ts(12, 30)}, {Value: 5, EndTime: ts(13, 0)}}},
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []Result
+	}{
+		{
+			name: "open period",
+			want: res,
+		},
+		{
+			name: "from",
+			from: ts(10, 0),
+			want: []Result{
+				{MPRN: "1", Reads: []Read{{Value: 2, EndTime: ts(10, 30)}}},
+				res[1],
+			},
+		},
+		{
+			name: "to",
+			to:   ts(12, 15),
+			want: []Result{
+				res[0],
+				{MPRN: "1", Reads: []Read{{Value: 3, EndTime: ts(12, 0)}}},
+			},
+		},
+		{
+			name: "both",
+			from: ts(11, 0),
+			to:   ts(12, 30),
+			want: []Result{
+				{MPRN: "1", Reads: []Read{{Value: 3, EndTime: ts(12, 0)}, {Value: 4, EndTime: ts(12, 30)}}},
+			},
+		},
+		{
+			name: "nothing",
+			from: ts(14, 0),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Between(res, tc.from, tc.to)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Between() unexpected diff (-want +got): %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package parse
+
+import (
+	"errors"
+	"time"
+)
+
+// BillingCycle is how the supplier splits the bills.
+type BillingCycle struct {
+	// Start is the first day of any billing cycle, it sets the day of
+	// the month when the cycles start and, for cycles longer than a
+	// month, which months.
+	Start time.Time
+	// Months is how long a cycle is, 1 for monthly bills and 2 for
+	// bi-monthly ones.
+	Months int
+}
+
+// Validate returns an error if the cycle can't be used.
+func (b BillingCycle) Validate() error {
+	if b.Months < 1 {
+		return errors.New("billing cycles must be at least a month long")
+	}
+	// Shorter months would move the start.
+	if d := b.Start.Day(); d > 28 {
+		return errors.New("billing cycles must start between the 1st and the 28th")
+	}
+	return nil
+}
+
+// Period returns the start of the billing cycle containing t and the
+// start of the next one, at midnight in Europe/Dublin.
+func (b BillingCycle) Period(t time.Time) (time.Time, time.Time) {
+	t = t.In(irelandTimezone)
+	anchor := b.Start.In(irelandTimezone)
+	months := (t.Year()-anchor.Year())*12 + int(t.Month()-anchor.Month())
+	// Floor division, t can be before the anchor.
+	n := months / b.Months
+	if months%b.Months < 0 {
+		n--
+	}
+	from := b.start(anchor, n*b.Months)
+	if from.After(t) {
+		n--
+		from = b.start(anchor, n*b.Months)
+	}
+	return from, b.start(anchor, (n+1)*b.Months)
+}
+
+// start returns the start of the cycle the given months after the anchor.
+func (b BillingCycle) start(anchor time.Time, months int) time.Time {
+	return time.Date(anchor.Year(), anchor.Month()+time.Month(months), anchor.Day(), 0, 0, 0, 0, irelandTimezone)
+}
+
+// CycleTotal is the energy consumed in a billing cycle.
+type CycleTotal struct {
+	// From is the first day of the cycle, To the first day of the next.
+	From, To time.Time
+	// KWh is the energy consumed in the days with data.
+	KWh float64
+	// Days is the number of complete days in the data.
+	Days int
+}
+
+// Complete returns if the total includes all the days of the cycle.
+func (c CycleTotal) Complete() bool {
+	return c.Days == int(c.To.Sub(c.From).Round(24*time.Hour)/(24*time.Hour))
+}
+
+// Cycles aggregates the daily totals by billing cycle.
+//
+// Days must be sorted as returned by Daily.
+func (b BillingCycle) Cycles(days []DailyTotal) []CycleTotal {
+	var ret []CycleTotal
+	for _, d := range days {
+		from, to := b.Period(d.Date)
+		if n := len(ret); n == 0 || !ret[n-1].From.Equal(from) {
+			ret = append(ret, CycleTotal{From: from, To: to})
+		}
+		c := &ret[len(ret)-1]
+		c.KWh += d.KWh
+		if d.Complete() {
+			c.Days++
+		}
+	}
+	return ret
+}
+
+// ToDate compares the energy used in the current cycle, up to the last
+// complete day, with the same number of days at the start of the
+// previous cycle.
+//
+// It returns false if there is no complete day in the current cycle.
+func (b BillingCycle) ToDate(days []DailyTotal) (current, previous CycleTotal, ok bool) {
+	var last time.Time
+	for _, d := range days {
+		if d.Complete() {
+			last = d.Date
+		}
+	}
+	if last.IsZero() {
+		return current, previous, false
+	}
+	current.From, _ = b.Period(last)
+	current.To = last.AddDate(0, 0, 1)
+	previous.From, _ = b.Period(current.From.AddDate(0, 0, -1))
+	elapsed := int(current.To.Sub(current.From).Round(24*time.Hour) / (24 * time.Hour))
+	previous.To = previous.From.AddDate(0, 0, elapsed)
+
+	for _, d := range days {
+		if !d.Complete() {
+			continue
+		}
+		for _, c := range []*CycleTotal{&current, &previous} {
+			if !d.Date.Before(c.From) && d.Date.Before(c.To) {
+				c.KWh += d.KWh
+				c.Days++
+			}
+		}
+	}
+	return current, previous, true
+}
+
+// Charges are the parts of a bill which don't depend on the energy used.
+//
+// The zero value adds nothing.
+type Charges struct {
+	// StandingPerDay is the standing charge, per day.
+	StandingPerDay float64
+	// LevyPerMonth is the Public Service Obligation levy, per month.
+	LevyPerMonth float64
+	// VAT is the rate applied to the whole bill, like 0.09 for 9%.
+	VAT float64
+}
+
+// PerDay returns the fixed charges of a day, before VAT.
+//
+// The levy is prorated on the days of an average year, like most
+// suppliers do when a bill doesn't cover whole months.
+func (c Charges) PerDay() float64 {
+	return c.StandingPerDay + c.LevyPerMonth*12/365
+}
+
+// Total returns the cost of the given days, given the cost of the
+// energy used in them, including the fixed charges and VAT.
+func (c Charges) Total(energy float64, days int) float64 {
+	return (energy + c.PerDay()*float64(days)) * (1 + c.VAT)
+}
@@ -0,0 +1,95 @@
+package parse
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBillingCycle_Period(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, irelandTimezone) }
+	tests := []struct {
+		name     string
+		cycle    BillingCycle
+		t        time.Time
+		from, to time.Time
+	}{
+		{
+			name:  "monthly, after the start day",
+			cycle: BillingCycle{Start: day(2024, 1, 14), Months: 1},
+			t:     day(2024, 3, 20).Add(5 * time.Hour),
+			from:  day(2024, 3, 14),
+			to:    day(2024, 4, 14),
+		},
+		{
+			name:  "monthly, before the start day",
+			cycle: BillingCycle{Start: day(2024, 1, 14), Months: 1},
+			t:     day(2024, 3, 2),
+			from:  day(2024, 2, 14),
+			to:    day(2024, 3, 14),
+		},
+		{
+			name:  "bi-monthly, off month",
+			cycle: BillingCycle{Start: day(2024, 1, 14), Months: 2},
+			t:     day(2024, 4, 20),
+			from:  day(2024, 3, 14),
+			to:    day(2024, 5, 14),
+		},
+		{
+			name:  "bi-monthly, across the year before the anchor",
+			cycle: BillingCycle{Start: day(2024, 1, 14), Months: 2},
+			t:     day(2023, 12, 1),
+			from:  day(2023, 11, 14),
+			to:    day(2024, 1, 14),
+		},
+	}
+	for _, tc := range tests {
+		from, to := tc.cycle.Period(tc.t)
+		if !from.Equal(tc.from) || !to.Equal(tc.to) {
+			t.Errorf("%s: Period(%v) = %v, %v, want %v, %v", tc.name, tc.t, from, to, tc.from, tc.to)
+		}
+	}
+}
+
+func TestBillingCycle_Cycles(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, irelandTimezone) }
+	b := BillingCycle{Start: day(1, 14), Months: 1}
+	var days []DailyTotal
+	for d := day(2, 10); d.Before(day(3, 18)); d = d.AddDate(0, 0, 1) {
+		days = append(days, DailyTotal{Date: d, KWh: 10, Reads: 48})
+	}
+
+	got := b.Cycles(days)
+	if len(got) != 3 {
+		t.Fatalf("Cycles() returned %d cycles, want 3: %+v", len(got), got)
+	}
+	if c := got[1]; !c.From.Equal(day(2, 14)) || c.KWh != 290 || !c.Complete() {
+		t.Errorf("Cycles()[1] = %+v, want the complete cycle from Feb 14 with 290 kWh", c)
+	}
+	if got[2].Complete() {
+		t.Errorf("Cycles()[2] = %+v, want incomplete", got[2])
+	}
+
+	cur, prev, ok := b.ToDate(days)
+	if !ok {
+		t.Fatal("ToDate() = false, want true")
+	}
+	// From Mar 14 to Mar 17 and from Feb 14 to Feb 17.
+	if cur.Days != 4 || cur.KWh != 40 || !cur.From.Equal(day(3, 14)) {
+		t.Errorf("ToDate() current = %+v, want 4 days and 40 kWh from Mar 14", cur)
+	}
+	if prev.Days != 4 || prev.KWh != 40 || !prev.From.Equal(day(2, 14)) || !prev.To.Equal(day(2, 18)) {
+		t.Errorf("ToDate() previous = %+v, want 4 days and 40 kWh from Feb 14 to Feb 18", prev)
+	}
+}
+
+func TestCharges_Total(t *testing.T) {
+	c := Charges{StandingPerDay: 0.5, LevyPerMonth: 36.5 / 12, VAT: 0.1}
+	// 10 days: 100 of energy, 5 of standing charge and 1 of levy.
+	if got, want := c.Total(100, 10), 106*1.1; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Total() = %v, want %v", got, want)
+	}
+	if got := (Charges{}).Total(100, 10); got != 100 {
+		t.Errorf("Total() without charges = %v, want 100", got)
+	}
+}
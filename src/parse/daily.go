@@ -0,0 +1,119 @@
+package parse
+
+import (
+	"time"
+)
+
+// DailyTotal is the energy consumed in a day.
+type DailyTotal struct {
+	// Date is the midnight of the day in Europe/Dublin timezone.
+	Date time.Time
+	// KWh is the energy consumed in the day.
+	KWh float64
+	// Reads is the number of half an hour reads in the day.
+	Reads int
+	// Folded is set when the day has the reads of only one of the
+	// repeated hours, when the clocks went back, see FoldDST.
+	Folded bool
+}
+
+// Complete returns if the total includes all the reads of the day.
+func (d DailyTotal) Complete() bool {
+	next := d.Date.AddDate(0, 0, 1)
+	// Days are 23 or 25 hours long when the clock changes.
+	want := int(next.Sub(d.Date) / (30 * time.Minute))
+	if d.Folded {
+		want -= 2
+	}
+	return d.Reads == want
+}
+
+// Daily aggregates the reads by day.
+//
+// Reads are assigned to the day when their half an hour period starts,
+// so the one ending at midnight belongs to the previous day.
+// Results must be sorted as returned by HDF.
+func Daily(res []Result) []DailyTotal {
+	var ret []DailyTotal
+	// The reads of the day in the first and in the second repeated hour.
+	var occurrences [3]int
+	fold := func() {
+		if n := len(ret); n > 0 {
+			ret[n-1].Folded = (occurrences[1] == 0) != (occurrences[2] == 0)
+		}
+		occurrences = [3]int{}
+	}
+	for _, r := range res {
+		q := r.Quantity()
+		for _, rd := range r.Reads {
+			start := rd.EndTime.Add(-30 * time.Minute).In(irelandTimezone)
+			day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, irelandTimezone)
+			if n := len(ret); n == 0 || !ret[n-1].Date.Equal(day) {
+				fold()
+				ret = append(ret, DailyTotal{Date: day})
+			}
+			d := &ret[len(ret)-1]
+			d.KWh += q.Amount(rd.Value)
+			d.Reads++
+			occurrences[folded(start)]++
+		}
+	}
+	fold()
+	return ret
+}
+
+// Gap is a period without reads.
+type Gap struct {
+	// From is the end of the last read before the gap.
+	From time.Time
+	// To is the start of the first read after the gap.
+	To time.Time
+}
+
+// Duration returns how long the gap is.
+func (g Gap) Duration() time.Duration {
+	return g.To.Sub(g.From)
+}
+
+// Gaps returns the holes between the results, as split by HDF.
+func Gaps(res []Result) []Gap {
+	var ret []Gap
+	for i := 1; i < len(res); i++ {
+		prev, next := res[i-1].Reads, res[i].Reads
+		if len(prev) == 0 || len(next) == 0 {
+			continue
+		}
+		ret = append(ret, Gap{
+			From: prev[len(prev)-1].EndTime,
+			To:   next[0].EndTime.Add(-30 * time.Minute),
+		})
+	}
+	return ret
+}
+
+// ClockChange is a switch between summer and winter time in the data.
+type ClockChange struct {
+	// At is when the clock changed.
+	At time.Time
+	// Forward is true when moving to summer time, the day is 23 hours long.
+	// Otherwise the day is 25 hours long and an hour happens twice.
+	Forward bool
+}
+
+// ClockChanges returns the clock changes between contiguous reads.
+func ClockChanges(res []Result) []ClockChange {
+	var ret []ClockChange
+	for _, r := range res {
+		for i := 1; i < len(r.Reads); i++ {
+			// The end of a read is already in the new timezone
+			// when the clock changes, its start isn't.
+			start := r.Reads[i].EndTime.Add(-30 * time.Minute).In(irelandTimezone)
+			_, prev := r.Reads[i-1].EndTime.Add(-30 * time.Minute).In(irelandTimezone).Zone()
+			_, cur := start.Zone()
+			if prev != cur {
+				ret = append(ret, ClockChange{At: start, Forward: cur > prev})
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,123 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDaily(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	read := func(v float64, d, h, m int) Read {
+		return Read{Value: v, EndTime: time.Date(2023, 01, d, h, m, 0, 0, gmt)}
+	}
+	res := []Result{
+		{Reads: []Read{read(1, 15, 23, 30), read(2, 16, 0, 0), read(4, 16, 0, 30)}},
+		{Reads: []Read{read(6, 16, 10, 0)}},
+	}
+
+	got := Daily(res)
+	want := []DailyTotal{
+		{Date: time.Date(2023, 01, 15, 0, 0, 0, 0, irelandTimezone), KWh: 1.5, Reads: 2},
+		{Date: time.Date(2023, 01, 16, 0, 0, 0, 0, irelandTimezone), KWh: 5, Reads: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Daily() unexpected diff (+got -want): %v", diff)
+	}
+
+	// Energy reads are already the kWh of the half an hour.
+	for i := range res {
+		res[i].ReadTypes = "Gas Interval (kWh)"
+	}
+	got = Daily(res)
+	want[0].KWh, want[1].KWh = 3, 10
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Daily() of energy unexpected diff (+got -want): %v", diff)
+	}
+}
+
+func TestDailyTotal_Complete(t *testing.T) {
+	tests := []struct {
+		date   time.Time
+		reads  int
+		folded bool
+		want   bool
+	}{
+		{time.Date(2023, 01, 15, 0, 0, 0, 0, irelandTimezone), 48, false, true},
+		{time.Date(2023, 01, 15, 0, 0, 0, 0, irelandTimezone), 47, false, false},
+		{time.Date(2023, 03, 26, 0, 0, 0, 0, irelandTimezone), 46, false, true},
+		{time.Date(2023, 10, 29, 0, 0, 0, 0, irelandTimezone), 50, false, true},
+		{time.Date(2023, 10, 29, 0, 0, 0, 0, irelandTimezone), 48, false, false},
+		{time.Date(2023, 10, 29, 0, 0, 0, 0, irelandTimezone), 48, true, true},
+		{time.Date(2023, 10, 29, 0, 0, 0, 0, irelandTimezone), 47, true, false},
+	}
+	for _, tt := range tests {
+		d := DailyTotal{Date: tt.date, Reads: tt.reads, Folded: tt.folded}
+		if got := d.Complete(); got != tt.want {
+			t.Errorf("%+v.Complete() = %v, want %v", d, got, tt.want)
+		}
+	}
+}
+
+func TestDaily_Folded(t *testing.T) {
+	// The clocks went back at 01:00 UTC on 29 Oct 2023.
+	day := time.Date(2023, 10, 28, 23, 0, 0, 0, time.UTC)
+	var reads []Read
+	for i := 1; i <= 50; i++ {
+		reads = append(reads, Read{Value: 1, EndTime: day.Add(time.Duration(i) * 30 * time.Minute)})
+	}
+	rr := []Result{{MPRN: "1", Reads: reads}}
+
+	for _, f := range []DSTFold{FoldSum, FoldFirst, FoldLast} {
+		got := Daily(FoldDST(rr, f))
+		if len(got) != 1 {
+			t.Fatalf("Daily(FoldDST(%v)) returned %d days, want 1", f, len(got))
+		}
+		if !got[0].Complete() {
+			t.Errorf("Daily(FoldDST(%v)) = %+v, want a complete day", f, got[0])
+		}
+		if want := f != FoldSum; got[0].Folded != want {
+			t.Errorf("Daily(FoldDST(%v)).Folded = %v, want %v", f, got[0].Folded, want)
+		}
+	}
+}
+
+func TestGaps(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	ts := func(h, m int) time.Time { return time.Date(2023, 01, 15, h, m, 0, 0, gmt) }
+	res := []Result{
+		{Reads: []Read{{EndTime: ts(1, 0)}, {EndTime: ts(1, 30)}}},
+		{Reads: []Read{{EndTime: ts(3, 0)}}},
+		{Reads: []Read{{EndTime: ts(4, 0)}}},
+	}
+
+	got := Gaps(res)
+	want := []Gap{
+		{From: ts(1, 30), To: ts(2, 30)},
+		{From: ts(3, 0), To: ts(3, 30)},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Gaps() unexpected diff (+got -want): %v", diff)
+	}
+}
+
+func TestClockChanges(t *testing.T) {
+	utc := func(mo, d, h, m int) Read {
+		return Read{Value: 1, EndTime: time.Date(2023, time.Month(mo), d, h, m, 0, 0, time.UTC).In(irelandTimezone)}
+	}
+	res := []Result{
+		{Reads: []Read{utc(3, 26, 0, 30), utc(3, 26, 1, 0), utc(3, 26, 1, 30)}},
+		{Reads: []Read{utc(10, 29, 0, 30), utc(10, 29, 1, 0), utc(10, 29, 1, 30)}},
+		{Reads: []Read{utc(11, 5, 0, 30), utc(11, 5, 1, 0)}},
+	}
+
+	got := ClockChanges(res)
+	want := []ClockChange{
+		{At: time.Date(2023, 3, 26, 1, 0, 0, 0, time.UTC).In(irelandTimezone), Forward: true},
+		{At: time.Date(2023, 10, 29, 1, 0, 0, 0, time.UTC).In(irelandTimezone), Forward: false},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ClockChanges() unexpected diff (+got -want): %v", diff)
+	}
+}
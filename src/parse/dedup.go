@@ -0,0 +1,69 @@
+package parse
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+// Rebase shifts the sums so they continue the ones already uploaded.
+//
+// Translate starts the sums from zero at the beginning of the data, but
+// ESB only serves a rolling window, therefore the sums of the same hour
+// change when the window moves.
+// The uploaded function returns the sum previously uploaded for the
+// hour, the first hour found is used as anchor.
+// If none is found the statistics are left untouched.
+func Rebase(stats []ha.StatisticValue, uploaded func(time.Time) (float64, bool)) {
+	for _, s := range stats {
+		sum, ok := uploaded(s.Start)
+		if !ok {
+			continue
+		}
+		offset := sum - s.Sum
+		for i := range stats {
+			stats[i].Sum += offset
+		}
+		return
+	}
+}
+
+// HourHash identifies the values of an hour, to know if it changed
+// since the last upload.
+//
+// Values are rounded to avoid floating point noise added by Rebase.
+func HourHash(v ha.StatisticValue) string {
+	h := sha256.Sum256(fmt.Appendf(nil, "%d|%.6f|%.6f", v.Start.Unix(), v.State, v.Sum))
+	return hex.EncodeToString(h[:])
+}
+
+// ContinueFrom returns the statistics after the last one already in Home
+// Assistant, with the sums continuing from it.
+//
+// Without last, all the statistics are returned untouched.
+func ContinueFrom(stats []ha.StatisticValue, last ha.StatisticValue, ok bool) []ha.StatisticValue {
+	if !ok || len(stats) == 0 {
+		return stats
+	}
+	var offset float64
+	switch first := stats[0]; {
+	case last.Start.Before(first.Start):
+		offset = last.Sum - (first.Sum - first.State)
+	default:
+		i := slices.IndexFunc(stats, func(s ha.StatisticValue) bool { return s.Start.Equal(last.Start) })
+		if i < 0 {
+			// Home Assistant has something newer.
+			return nil
+		}
+		offset = last.Sum - stats[i].Sum
+		stats = stats[i+1:]
+	}
+	for i := range stats {
+		stats[i].Sum += offset
+	}
+	return stats
+}
@@ -0,0 +1,124 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+func TestRebase(t *testing.T) {
+	h := func(n int) time.Time { return time.Unix(int64(n)*3600, 0) }
+	tests := []struct {
+		name     string
+		uploaded map[time.Time]float64
+		want     []float64
+	}{
+		{
+			name: "nothing uploaded",
+			want: []float64{1, 3, 6},
+		},
+		{
+			name:     "anchored on the first hour",
+			uploaded: map[time.Time]float64{h(0): 11},
+			want:     []float64{11, 13, 16},
+		},
+		{
+			name:     "anchored on a later hour",
+			uploaded: map[time.Time]float64{h(1): 103, h(2): 1000},
+			want:     []float64{101, 103, 106},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := []ha.StatisticValue{
+				{Start: h(0), State: 1, Sum: 1},
+				{Start: h(1), State: 2, Sum: 3},
+				{Start: h(2), State: 3, Sum: 6},
+			}
+			Rebase(stats, func(t time.Time) (float64, bool) {
+				s, ok := tc.uploaded[t]
+				return s, ok
+			})
+			var got []float64
+			for _, s := range stats {
+				got = append(got, s.Sum)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Rebase() unexpected diff (+got -want): %v", diff)
+			}
+		})
+	}
+}
+
+func TestHourHash(t *testing.T) {
+	v := ha.StatisticValue{Start: time.Unix(3600, 0), State: 0.1, Sum: 0.3}
+	noisy := v
+	noisy.Sum = 0.1 + 0.2 // 0.30000000000000004
+	if HourHash(v) != HourHash(noisy) {
+		t.Errorf("HourHash() differs for %v and %v", v, noisy)
+	}
+	changed := v
+	changed.State = 0.2
+	if HourHash(v) == HourHash(changed) {
+		t.Errorf("HourHash() is the same for %v and %v", v, changed)
+	}
+}
+
+func TestContinueFrom(t *testing.T) {
+	h := func(n int) time.Time { return time.Unix(int64(n)*3600, 0) }
+	stats := func() []ha.StatisticValue {
+		return []ha.StatisticValue{
+			{Start: h(10), State: 1, Sum: 1},
+			{Start: h(11), State: 2, Sum: 3},
+			{Start: h(12), State: 3, Sum: 6},
+		}
+	}
+	tests := []struct {
+		name string
+		last ha.StatisticValue
+		ok   bool
+		want []ha.StatisticValue
+	}{
+		{
+			name: "nothing in Home Assistant",
+			want: stats(),
+		},
+		{
+			name: "older data",
+			last: ha.StatisticValue{Start: h(5), Sum: 100},
+			ok:   true,
+			want: []ha.StatisticValue{
+				{Start: h(10), State: 1, Sum: 101},
+				{Start: h(11), State: 2, Sum: 103},
+				{Start: h(12), State: 3, Sum: 106},
+			},
+		},
+		{
+			name: "overlapping",
+			last: ha.StatisticValue{Start: h(11), Sum: 50},
+			ok:   true,
+			want: []ha.StatisticValue{{Start: h(12), State: 3, Sum: 53}},
+		},
+		{
+			name: "up to date",
+			last: ha.StatisticValue{Start: h(12), Sum: 50},
+			ok:   true,
+			want: []ha.StatisticValue{},
+		},
+		{
+			name: "newer data",
+			last: ha.StatisticValue{Start: h(20), Sum: 50},
+			ok:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ContinueFrom(stats(), tc.last, tc.ok)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ContinueFrom() unexpected diff (+got -want): %v", diff)
+			}
+		})
+	}
+}
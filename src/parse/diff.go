@@ -0,0 +1,71 @@
+package parse
+
+import (
+	"math"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+// Change is how importing an hour alters the statistic in Home Assistant.
+type Change int
+
+const (
+	// ChangeNew is an hour Home Assistant doesn't have.
+	ChangeNew Change = iota
+	// ChangeNone is an hour Home Assistant already has with the same values.
+	ChangeNone
+	// ChangeUpdate is an hour whose values in Home Assistant are overwritten.
+	ChangeUpdate
+)
+
+func (c Change) String() string {
+	switch c {
+	case ChangeNew:
+		return "new"
+	case ChangeNone:
+		return "identical"
+	case ChangeUpdate:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// HourDiff is the comparison of an hour to import with Home Assistant.
+type HourDiff struct {
+	Start  time.Time
+	Change Change
+	// Old is the value in Home Assistant, zero for new hours.
+	Old ha.StatisticValue
+	// New is the value to import.
+	New ha.StatisticValue
+}
+
+// Diff compares, hour by hour, the statistics to import with the ones
+// already in Home Assistant.
+//
+// Values are compared at 6 decimals, like HourHash, because Home
+// Assistant doesn't store them exactly.
+func Diff(stats, existing []ha.StatisticValue) []HourDiff {
+	old := make(map[int64]ha.StatisticValue, len(existing))
+	for _, s := range existing {
+		old[s.Start.Unix()] = s
+	}
+
+	ret := make([]HourDiff, 0, len(stats))
+	for _, s := range stats {
+		d := HourDiff{Start: s.Start, Change: ChangeNew, New: s}
+		if o, ok := old[s.Start.Unix()]; ok {
+			d.Old, d.Change = o, ChangeUpdate
+			if sameValue(o.State, s.State) && sameValue(o.Sum, s.Sum) {
+				d.Change = ChangeNone
+			}
+		}
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+func sameValue(a, b float64) bool {
+	return math.Abs(a-b) < 5e-7
+}
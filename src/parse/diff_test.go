@@ -0,0 +1,32 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+func TestDiff(t *testing.T) {
+	h := func(n int) time.Time { return time.Unix(int64(n)*3600, 0) }
+	existing := []ha.StatisticValue{
+		{Start: h(0), State: 1, Sum: 1},
+		{Start: h(1), State: 2.0000001, Sum: 3},
+		{Start: h(2), State: 3, Sum: 6},
+	}
+	stats := []ha.StatisticValue{
+		{Start: h(1), State: 2, Sum: 3},
+		{Start: h(2), State: 4, Sum: 7},
+		{Start: h(3), State: 1, Sum: 8},
+	}
+
+	want := []HourDiff{
+		{Start: h(1), Change: ChangeNone, Old: existing[1], New: stats[0]},
+		{Start: h(2), Change: ChangeUpdate, Old: existing[2], New: stats[1]},
+		{Start: h(3), Change: ChangeNew, New: stats[2]},
+	}
+	if diff := cmp.Diff(want, Diff(stats, existing)); diff != "" {
+		t.Errorf("Diff() unexpected diff (-want +got): %v", diff)
+	}
+}
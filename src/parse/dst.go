@@ -0,0 +1,105 @@
+package parse
+
+import (
+	"fmt"
+	"time"
+)
+
+// DSTFold is what to do with the hour which happens twice when the
+// clocks go back, from 01:00 to 02:00 on the last Sunday of October.
+type DSTFold int
+
+const (
+	// FoldSum keeps both the hours, so the day has 25 hours and its
+	// total includes both, like the ESB graphs.
+	FoldSum DSTFold = iota
+	// FoldFirst keeps only the first hour, still on summer time.
+	FoldFirst
+	// FoldLast keeps only the second hour, already on winter time.
+	FoldLast
+)
+
+// ParseDSTFold parses the name of a fold policy, "sum", "first" or
+// "last".
+func ParseDSTFold(s string) (DSTFold, error) {
+	switch s {
+	case "sum":
+		return FoldSum, nil
+	case "first":
+		return FoldFirst, nil
+	case "last":
+		return FoldLast, nil
+	}
+	return 0, fmt.Errorf("unknown DST fold %q, want sum, first or last", s)
+}
+
+// folded returns 1 if the half hour starting at t is in the first
+// occurrence of the repeated hour, 2 if it is in the second one and 0
+// otherwise.
+func folded(t time.Time) int {
+	wall := func(t time.Time) string { return t.In(irelandTimezone).Format(time.DateTime) }
+	switch w := wall(t); {
+	case wall(t.Add(time.Hour)) == w:
+		return 1
+	case wall(t.Add(-time.Hour)) == w:
+		return 2
+	}
+	return 0
+}
+
+// FoldDST applies the policy to the reads of the repeated hours.
+//
+// The chunks are not split where the repeated hour is removed, so the
+// days keep all their reads, see foldHole. They don't share the reads
+// with rr.
+func FoldDST(rr []Result, f DSTFold) []Result {
+	if f == FoldSum {
+		return rr
+	}
+	drop := 2
+	if f == FoldLast {
+		drop = 1
+	}
+	var ret []Result
+	for _, r := range rr {
+		reads := make([]Read, 0, len(r.Reads))
+		for _, read := range r.Reads {
+			if folded(read.EndTime.Add(-30*time.Minute)) != drop {
+				reads = append(reads, read)
+			}
+		}
+		if len(reads) == 0 {
+			continue
+		}
+		r.Reads = reads
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+// foldHole returns if the hour from the end of a read, prev, to the
+// start of the next one, ending at next, is one of the repeated hours
+// when the clocks go back, removed by FoldDST.
+func foldHole(prev, next time.Time) bool {
+	f := folded(prev)
+	return next.Sub(prev) == 90*time.Minute && f != 0 && folded(prev.Add(30*time.Minute)) == f
+}
+
+// FoldedHours returns the start of the hours of the reads which are in
+// a repeated hour, in order.
+func FoldedHours(rr []Result) []time.Time {
+	var ret []time.Time
+	for _, r := range rr {
+		for _, read := range r.Reads {
+			start := read.EndTime.Add(-30 * time.Minute)
+			if folded(start) == 0 {
+				continue
+			}
+			h := start.Truncate(time.Hour)
+			if n := len(ret); n == 0 || !ret[n-1].Equal(h) {
+				ret = append(ret, h)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,62 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFoldDST(t *testing.T) {
+	// The clocks went back at 01:00 UTC on 29 Oct 2023, the local hour
+	// from 01:00 to 02:00 starts at 00:00 and 01:00 UTC.
+	ts := func(h, m int) time.Time {
+		return time.Date(2023, 10, 28, 23, 0, 0, 0, time.UTC).Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+	var reads []Read
+	for i := 0; i < 8; i++ {
+		reads = append(reads, Read{Value: float64(i), EndTime: ts(0, 30*(i+1))})
+	}
+	rr := []Result{{MPRN: "1", Reads: reads}}
+
+	// starts returns the start time of the reads of each chunk.
+	starts := func(rr []Result) [][]time.Time {
+		var ret [][]time.Time
+		for _, r := range rr {
+			var s []time.Time
+			for _, read := range r.Reads {
+				s = append(s, read.EndTime.Add(-30*time.Minute).UTC())
+			}
+			ret = append(ret, s)
+		}
+		return ret
+	}
+
+	tests := []struct {
+		fold DSTFold
+		want [][]time.Time
+	}{
+		{FoldSum, starts(rr)},
+		// The chunk is not split where the hour is removed.
+		{FoldFirst, [][]time.Time{
+			{ts(0, 0), ts(0, 30), ts(1, 0), ts(1, 30), ts(3, 0), ts(3, 30)},
+		}},
+		{FoldLast, [][]time.Time{
+			{ts(0, 0), ts(0, 30), ts(2, 0), ts(2, 30), ts(3, 0), ts(3, 30)},
+		}},
+	}
+	for _, tc := range tests {
+		got := FoldDST(rr, tc.fold)
+		if diff := cmp.Diff(tc.want, starts(got)); diff != "" {
+			t.Errorf("FoldDST(%v) unexpected diff (-want +got):\n%s", tc.fold, diff)
+		}
+	}
+	if len(rr[0].Reads) != 8 {
+		t.Errorf("FoldDST() changed the input, got %d reads", len(rr[0].Reads))
+	}
+
+	want := []time.Time{ts(1, 0), ts(2, 0)}
+	if diff := cmp.Diff(want, FoldedHours(rr)); diff != "" {
+		t.Errorf("FoldedHours() unexpected diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,106 @@
+package parse
+
+import (
+	"cmp"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+// FromStatistics is the inverse of Translate: it converts hourly Home
+// Assistant statistics back into half an hour reads.
+//
+// Hourly values can't be split back exactly, each hour is assumed as
+// two halves with the same consumption.
+// Following Translate, the statistic starting at S contains the reads
+// ending at S and S+30m.
+func FromStatistics(mprn, serial string, stats []ha.StatisticValue) Result {
+	ret := Result{MPRN: mprn, MeterSerialNumber: serial, ReadTypes: wantReadType}
+	for _, s := range stats {
+		// Half of the energy of the hour, in half an hour, is the same value in kW.
+		kw := s.State
+		start := s.Start.In(irelandTimezone)
+		ret.Reads = append(ret.Reads,
+			Read{Value: kw, EndTime: start},
+			Read{Value: kw, EndTime: start.Add(30 * time.Minute)},
+		)
+	}
+	return ret
+}
+
+// WriteHDF writes the results in the HDF format, as downloaded from ESB.
+//
+// Like ESB files, the newest read comes first.
+func WriteHDF(w io.Writer, res []Result) error {
+	type row struct {
+		r    *Result
+		read Read
+	}
+	var rows []row
+	for i, r := range res {
+		for _, rd := range r.Reads {
+			rows = append(rows, row{&res[i], rd})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].read.EndTime.After(rows[j].read.EndTime)
+	})
+
+	hw, err := NewHDFWriter(w)
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := hw.write(r.r, r.read); err != nil {
+			return err
+		}
+	}
+	return hw.Flush()
+}
+
+// HDFWriter writes an HDF file incrementally, to export more data than
+// what fits in memory.
+type HDFWriter struct {
+	cw *csv.Writer
+}
+
+// NewHDFWriter writes the header and returns the writer.
+func NewHDFWriter(w io.Writer) (*HDFWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(headerFormat); err != nil {
+		return nil, err
+	}
+	return &HDFWriter{cw: cw}, nil
+}
+
+// Write writes the reads of the result, newest first.
+//
+// Results must be written newest first, like in the files from ESB.
+func (h *HDFWriter) Write(r Result) error {
+	for i := len(r.Reads) - 1; i >= 0; i-- {
+		if err := h.write(&r, r.Reads[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *HDFWriter) write(r *Result, rd Read) error {
+	return h.cw.Write([]string{
+		r.MPRN,
+		r.MeterSerialNumber,
+		strconv.FormatFloat(rd.Value, 'f', 6, 64),
+		cmp.Or(r.ReadTypes, wantReadType),
+		rd.EndTime.In(irelandTimezone).Format("02-01-2006 15:04"),
+	})
+}
+
+// Flush writes any buffered data.
+func (h *HDFWriter) Flush() error {
+	h.cw.Flush()
+	return h.cw.Error()
+}
@@ -0,0 +1,52 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+func TestWriteHDF(t *testing.T) {
+	start := time.Date(2023, 07, 15, 10, 0, 0, 0, irelandTimezone)
+	stats := []ha.StatisticValue{
+		{Start: start, State: 1.5, Sum: 1.5},
+		{Start: start.Add(time.Hour), State: 0.25, Sum: 1.75},
+	}
+
+	var b strings.Builder
+	if err := WriteHDF(&b, []Result{FromStatistics("123", "45", stats)}); err != nil {
+		t.Fatalf("WriteHDF() unexpected error: %v", err)
+	}
+	want := `MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time
+123,45,0.250000,Active Import Interval (kW),15-07-2023 11:30
+123,45,0.250000,Active Import Interval (kW),15-07-2023 11:00
+123,45,1.500000,Active Import Interval (kW),15-07-2023 10:30
+123,45,1.500000,Active Import Interval (kW),15-07-2023 10:00
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("WriteHDF() unexpected diff (+got -want): %v", diff)
+	}
+
+	// Translating it again gives back the same hourly values, but the
+	// first one because Translate skips the read at the hour sharp and
+	// puts 3 reads in the first hour.
+	stats = append(stats, ha.StatisticValue{Start: start.Add(2 * time.Hour), State: 0.5, Sum: 2.25})
+	b.Reset()
+	if err := WriteHDF(&b, []Result{FromStatistics("123", "45", stats)}); err != nil {
+		t.Fatalf("WriteHDF() unexpected error: %v", err)
+	}
+	parsed, err := HDF(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("HDF() unexpected error: %v", err)
+	}
+	got, err := Translate(parsed[0], Options{})
+	if err != nil {
+		t.Fatalf("Translate() unexpected error: %v", err)
+	}
+	if n := len(got.Stats); n != 2 || !got.Stats[1].Start.Equal(stats[2].Start) || got.Stats[1].State != stats[2].State {
+		t.Errorf("Translate() = %v, want the last hour to be %v", got.Stats, stats[2])
+	}
+}
@@ -0,0 +1,45 @@
+package parse
+
+import "time"
+
+// FillGaps joins the results separated by gaps up to max long, filling
+// the missing reads with a linear interpolation of the reads around
+// the gap.
+//
+// The added reads are marked as estimated, so they can be told apart
+// from the measured ones when ESB publishes them later.
+// It returns the results and the filled gaps. Results must be sorted
+// as returned by HDF.
+func FillGaps(res []Result, max time.Duration) ([]Result, []Gap) {
+	if max <= 0 || len(res) < 2 {
+		return res, nil
+	}
+	var (
+		ret    = []Result{res[0]}
+		filled []Gap
+	)
+	for i, g := range Gaps(res) {
+		next := res[i+1]
+		if g.Duration() > max {
+			ret = append(ret, next)
+			continue
+		}
+		cur := &ret[len(ret)-1]
+		// Don't append to the reads of res, HDF shares them between the results.
+		reads := make([]Read, len(cur.Reads), len(cur.Reads)+int(g.Duration()/(30*time.Minute))+len(next.Reads))
+		copy(reads, cur.Reads)
+
+		before, after := reads[len(reads)-1].Value, next.Reads[0].Value
+		steps := float64(g.Duration()/(30*time.Minute)) + 1
+		for n := 1.0; n < steps; n++ {
+			reads = append(reads, Read{
+				Value:     before + (after-before)*n/steps,
+				EndTime:   g.From.Add(time.Duration(n) * 30 * time.Minute),
+				Estimated: true,
+			})
+		}
+		cur.Reads = append(reads, next.Reads...)
+		filled = append(filled, g)
+	}
+	return ret, filled
+}
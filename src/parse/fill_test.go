@@ -0,0 +1,45 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFillGaps(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	ts := func(h, m int) time.Time { return time.Date(2023, 01, 15, h, m, 0, 0, gmt) }
+	res := []Result{
+		{MPRN: "1", Reads: []Read{{Value: 1, EndTime: ts(10, 0)}, {Value: 2, EndTime: ts(10, 30)}}},
+		// One hour missing.
+		{MPRN: "1", Reads: []Read{{Value: 5, EndTime: ts(12, 0)}}},
+		// Three hours missing.
+		{MPRN: "1", Reads: []Read{{Value: 1, EndTime: ts(15, 30)}}},
+	}
+
+	got, gaps := FillGaps(res, 2*time.Hour)
+	want := []Result{
+		{MPRN: "1", Reads: []Read{
+			{Value: 1, EndTime: ts(10, 0)},
+			{Value: 2, EndTime: ts(10, 30)},
+			{Value: 3, EndTime: ts(11, 0), Estimated: true},
+			{Value: 4, EndTime: ts(11, 30), Estimated: true},
+			{Value: 5, EndTime: ts(12, 0)},
+		}},
+		{MPRN: "1", Reads: []Read{{Value: 1, EndTime: ts(15, 30)}}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FillGaps() unexpected diff (+got -want): %v", diff)
+	}
+	if diff := cmp.Diff([]Gap{{From: ts(10, 30), To: ts(11, 30)}}, gaps); diff != "" {
+		t.Errorf("FillGaps() gaps unexpected diff (+got -want): %v", diff)
+	}
+	if len(res[0].Reads) != 2 {
+		t.Errorf("FillGaps() modified its input: %v", res[0].Reads)
+	}
+
+	if got, gaps := FillGaps(res, 0); len(got) != 3 || gaps != nil {
+		t.Errorf("FillGaps(0) = %v, %v, want the results untouched", got, gaps)
+	}
+}
@@ -0,0 +1,97 @@
+//go:build linux
+
+package parse
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// hdfRows returns a reader which generates an HDF file with n rows,
+// without keeping it in memory.
+func hdfRows(n int) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		w := bufio.NewWriter(pw)
+		w.WriteString("MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time\n")
+		end := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < n; i++ {
+			ts := end.Add(time.Duration(-i) * 30 * time.Minute)
+			fmt.Fprintf(w, "10000000000,000000000000,%.6f,Active Import Interval (kW),%s\n",
+				float64(i%7)/10, ts.In(irelandTimezone).Format("02-01-2006 15:04"))
+		}
+		pw.CloseWithError(w.Flush())
+	}()
+	return pr
+}
+
+// memoryChildEnv is set in the subprocess of TestHDF_MemoryCeiling.
+const memoryChildEnv = "ESB2HA_MEMORY_CEILING_CHILD"
+
+// TestHDF_MemoryCeiling checks that a file of about 28 years of data is
+// processed within the memory budget, which can be changed with the
+// ESB2HA_MAX_RSS_MB environment variable.
+//
+// The file is processed by the test binary re-executed in a subprocess,
+// so the peak RSS is not the one of the other tests.
+func TestHDF_MemoryCeiling(t *testing.T) {
+	if os.Getenv(memoryChildEnv) != "" {
+		parseRows(t)
+		return
+	}
+	if testing.Short() {
+		t.Skip("slow test")
+	}
+	if raceEnabled {
+		t.Skip("the race detector uses more memory")
+	}
+	maxMB := 128
+	if s := os.Getenv("ESB2HA_MAX_RSS_MB"); s != "" {
+		var err error
+		if maxMB, err = strconv.Atoi(s); err != nil {
+			t.Fatalf("invalid ESB2HA_MAX_RSS_MB: %v", err)
+		}
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHDF_MemoryCeiling$", "-test.count=1")
+	cmd.Env = append(os.Environ(), memoryChildEnv+"=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("subprocess failed: %v\n%s", err, out)
+	}
+	ru, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage)
+	if !ok {
+		t.Fatalf("SysUsage() is %T, want *syscall.Rusage", cmd.ProcessState.SysUsage())
+	}
+	// On Linux Maxrss is in KB.
+	if got := int(ru.Maxrss / 1024); got > maxMB {
+		t.Errorf("peak RSS is %d MB, want at most %d MB", got, maxMB)
+	} else {
+		t.Logf("peak RSS is %d MB", got)
+	}
+}
+
+// parseRows is the work of the subprocess of TestHDF_MemoryCeiling.
+func parseRows(t *testing.T) {
+	const rows = 500_000
+	res, err := HDF(hdfRows(rows))
+	if err != nil {
+		t.Fatalf("HDF() unexpected error: %v", err)
+	}
+	reads := 0
+	for _, r := range res {
+		reads += len(r.Reads)
+		if _, err := Translate(r, Options{}); err != nil {
+			t.Fatalf("Translate() unexpected error: %v", err)
+		}
+	}
+	if reads != rows {
+		t.Errorf("HDF() returned %d reads, want %d", reads, rows)
+	}
+}
@@ -0,0 +1,42 @@
+package parse
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Merge merges the results of more HDF files of the same MPRN, like
+// the ones downloaded over the years.
+//
+// When files overlap, the reads of the later files win, since ESB
+// sometimes revises old reads. The merged reads are split by HDF gaps
+// again, and the meter serial number is the one of the last file.
+func Merge(files ...[]Result) ([]Result, error) {
+	var (
+		merged Result
+		reads  []Read
+	)
+	for _, res := range files {
+		for _, r := range res {
+			if merged.MPRN != "" && merged.MPRN != r.MPRN {
+				return nil, fmt.Errorf("cannot merge different MPRN (%q and %q)", merged.MPRN, r.MPRN)
+			}
+			merged.MPRN, merged.MeterSerialNumber, merged.ReadTypes = r.MPRN, r.MeterSerialNumber, r.ReadTypes
+			reads = append(reads, r.Reads...)
+		}
+	}
+	if len(reads) == 0 {
+		return nil, nil
+	}
+
+	// Stable, so the later reads of the same time stay after the earlier ones.
+	slices.SortStableFunc(reads, func(a, b Read) int { return a.EndTime.Compare(b.EndTime) })
+	for i, r := range reads {
+		if n := len(merged.Reads); n > 0 && merged.Reads[n-1].EndTime.Equal(r.EndTime) {
+			merged.Reads[n-1] = reads[i]
+			continue
+		}
+		merged.Reads = append(merged.Reads, r)
+	}
+	return splitTimes(merged)
+}
@@ -0,0 +1,41 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMerge(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	ts := func(h, m int) time.Time { return time.Date(2023, 01, 15, h, m, 0, 0, gmt) }
+	older := []Result{
+		{MPRN: "1", MeterSerialNumber: "old", Reads: []Read{{Value: 1, EndTime: ts(10, 0)}, {Value: 2, EndTime: ts(10, 30)}}},
+	}
+	newer := []Result{
+		{MPRN: "1", MeterSerialNumber: "new", Reads: []Read{{Value: 3, EndTime: ts(10, 30)}, {Value: 4, EndTime: ts(11, 0)}}},
+		{MPRN: "1", MeterSerialNumber: "new", Reads: []Read{{Value: 5, EndTime: ts(12, 0)}}},
+	}
+
+	got, err := Merge(older, newer)
+	if err != nil {
+		t.Fatalf("Merge() unexpected error: %v", err)
+	}
+	want := []Result{
+		{MPRN: "1", MeterSerialNumber: "new", Reads: []Read{
+			{Value: 1, EndTime: ts(10, 0)},
+			{Value: 3, EndTime: ts(10, 30)},
+			{Value: 4, EndTime: ts(11, 0)},
+		}},
+		{MPRN: "1", MeterSerialNumber: "new", Reads: []Read{{Value: 5, EndTime: ts(12, 0)}}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Merge() unexpected diff (+got -want): %v", diff)
+	}
+
+	other := []Result{{MPRN: "2", Reads: []Read{{Value: 1, EndTime: ts(9, 0)}}}}
+	if _, err := Merge(older, other); err == nil {
+		t.Errorf("Merge() of different MPRN want error")
+	}
+}
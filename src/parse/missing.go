@@ -0,0 +1,44 @@
+package parse
+
+import (
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+// MissingHours returns the hours of stats which are not in existing,
+// like the ones Home Assistant lost or never received, joined in
+// contiguous gaps.
+//
+// The gaps go from the start of the first missing hour to the end of
+// the last one.
+func MissingHours(stats, existing []ha.StatisticValue) []Gap {
+	have := make(map[int64]bool, len(existing))
+	for _, s := range existing {
+		have[s.Start.Unix()] = true
+	}
+	var ret []Gap
+	for _, s := range stats {
+		if have[s.Start.Unix()] {
+			continue
+		}
+		end := s.Start.Add(time.Hour)
+		if n := len(ret); n > 0 && ret[n-1].To.Equal(s.Start) {
+			ret[n-1].To = end
+			continue
+		}
+		ret = append(ret, Gap{From: s.Start, To: end})
+	}
+	return ret
+}
+
+// HoursIn returns how many of the hours in existing start in the gap.
+func HoursIn(g Gap, existing []ha.StatisticValue) int {
+	n := 0
+	for _, s := range existing {
+		if !s.Start.Before(g.From) && s.Start.Before(g.To) {
+			n++
+		}
+	}
+	return n
+}
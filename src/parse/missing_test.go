@@ -0,0 +1,28 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+func TestMissingHours(t *testing.T) {
+	h := func(n int) time.Time { return time.Unix(int64(n)*3600, 0) }
+	var stats []ha.StatisticValue
+	for n := range 8 {
+		stats = append(stats, ha.StatisticValue{Start: h(n)})
+	}
+	existing := []ha.StatisticValue{{Start: h(0)}, {Start: h(3)}, {Start: h(4)}, {Start: h(6)}, {Start: h(10)}}
+
+	want := []Gap{{From: h(1), To: h(3)}, {From: h(5), To: h(6)}, {From: h(7), To: h(8)}}
+	got := MissingHours(stats, existing)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MissingHours() unexpected diff (-want +got): %v", diff)
+	}
+
+	if got := HoursIn(Gap{From: h(2), To: h(6)}, existing); got != 2 {
+		t.Errorf("HoursIn() = %d, want 2", got)
+	}
+}
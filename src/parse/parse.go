@@ -15,6 +15,10 @@ import (
 
 const wantReadType = "Active Import Interval (kW)"
 
+// exportReadType is the read type of the energy exported to the grid by
+// microgeneration, like solar panels. ESB adds it to the same file.
+const exportReadType = "Active Export Interval (kW)"
+
 var (
 	headerFormat      = []string{"MPRN", "Meter Serial Number", "Read Value", "Read Type", "Read Date and End Time"}
 	irelandTimezone   *time.Location
@@ -34,6 +38,7 @@ func init() {
 type line struct {
 	MPRN         string
 	SerialNumber string
+	ReadType     string
 	Value        float64
 	EndTime      time.Time
 }
@@ -50,13 +55,19 @@ type Result struct {
 type Read struct {
 	Value   float64
 	EndTime time.Time
+	// Estimated is set for the reads added by FillGaps, ESB didn't measure them.
+	Estimated bool
 }
 
 // HDF parses a HDF file and returns the result in ascending timestamps.
 //
 // Sometime ESB data has holes. This function breaks the result in blocks
 // which have correct half an hour increments.
-// Results are ordered by timestamp at both levels.
+// Results are ordered by timestamp at both levels. The reads of the
+// energy exported to the grid follow the imported ones, in Results of
+// their own, see Result.Export and SplitExport. So do the reads of the
+// meters which split the consumption by rate, see Result.Rate and
+// GroupRates.
 //
 // Timestamps are assumed in Europe/Dublin timezone.
 // Some heuristic is done to fix the timezone during the change from
@@ -65,8 +76,138 @@ type Read struct {
 // Without the timezone information in the source file we have to
 // guess relying on the fact that timestamps are sorted.
 func HDF(hdf io.Reader) ([]Result, error) {
-	var res Result
+	return HDFWithPolicy(hdf, Strict, Hooks{})
+}
+
+// Hooks are optional callbacks reporting the progress of the parsing.
+//
+// They are called synchronously, any of them can be nil.
+type Hooks struct {
+	// OnChunkParsed is called for every contiguous chunk of reads, in
+	// the same order they are returned.
+	OnChunkParsed func(Result)
+	// OnProgress is called every ProgressLines lines, with the number of
+	// lines parsed so far, while the file is read.
+	OnProgress func(lines int)
+	// OnUnknownReadType is called once per unknown read type, with the
+	// number of its lines, when the policy doesn't reject them.
+	OnUnknownReadType func(readType string, lines int)
+	// OnDuplicate is called for every read dropped because the file has
+	// another one with the same time, when the policy doesn't reject
+	// them, with the one kept.
+	OnDuplicate func(kept, dropped Read)
+	// OnError is called with the error returned by the parser.
+	OnError func(error)
+}
+
+// HDFWithHooks is like HDF, but it reports its progress to the hooks.
+func HDFWithHooks(hdf io.Reader, h Hooks) ([]Result, error) {
+	return HDFWithPolicy(hdf, Strict, h)
+}
+
+// ReadTypePolicy is what the parser does with the lines with a read
+// type it doesn't know, like a new register introduced by ESB.
+type ReadTypePolicy int
+
+const (
+	// Strict rejects the whole file.
+	Strict ReadTypePolicy = iota
+	// Warn skips the lines, reporting them to Hooks.OnUnknownReadType.
+	Warn
+	// Collect is like Warn, but it also returns the reads as they are,
+	// in Results of their own after the known ones, see Result.Known.
+	Collect
+)
+
+// ParseReadTypePolicy parses the name of a policy, "strict", "warn" or
+// "collect".
+func ParseReadTypePolicy(s string) (ReadTypePolicy, error) {
+	switch s {
+	case "strict":
+		return Strict, nil
+	case "warn":
+		return Warn, nil
+	case "collect":
+		return Collect, nil
+	}
+	return 0, fmt.Errorf("unknown read type policy %q, want strict, warn or collect", s)
+}
+
+// DuplicatePolicy is what the parser does with the lines with the same
+// time of the line before, which ESB sometimes repeats with the same or
+// a revised value.
+//
+// Only the consecutive lines of the same read type are duplicates: the
+// hour repeated when the clocks go back is not, see FoldDST.
+type DuplicatePolicy int
+
+const (
+	// DuplicateError rejects the whole file.
+	DuplicateError DuplicatePolicy = iota
+	// KeepFirst keeps the first read, in the order of the file.
+	KeepFirst
+	// KeepLast keeps the last read, in the order of the file.
+	KeepLast
+)
+
+// ParseDuplicatePolicy parses the name of a policy, "error",
+// "keep-first" or "keep-last".
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch s {
+	case "error":
+		return DuplicateError, nil
+	case "keep-first":
+		return KeepFirst, nil
+	case "keep-last":
+		return KeepLast, nil
+	}
+	return 0, fmt.Errorf("unknown duplicate policy %q, want error, keep-first or keep-last", s)
+}
+
+// HDFWithPolicy is like HDFWithHooks, but it handles the unknown read
+// types according to the policy. HDF is Strict.
+func HDFWithPolicy(hdf io.Reader, p ReadTypePolicy, h Hooks) ([]Result, error) {
+	return HDFWithPolicies(hdf, p, DuplicateError, h)
+}
+
+// HDFWithPolicies is like HDFWithPolicy, but it also handles the
+// duplicated reads according to d. The other functions reject them.
+func HDFWithPolicies(hdf io.Reader, p ReadTypePolicy, d DuplicatePolicy, h Hooks) ([]Result, error) {
+	res, err := parseHDF(hdf, p, d, h)
+	if err != nil {
+		if h.OnError != nil {
+			h.OnError(err)
+		}
+		return nil, err
+	}
+	if h.OnChunkParsed != nil {
+		for _, r := range res {
+			h.OnChunkParsed(r)
+		}
+	}
+	return res, nil
+}
+
+// ProgressLines is how often Hooks.OnProgress is called, about 200 days
+// of reads of a meter.
+const ProgressLines = 10_000
+
+// parseHDF reads the file line by line, only the parsed reads are kept in
+// memory.
+func parseHDF(hdf io.Reader, p ReadTypePolicy, d DuplicatePolicy, h Hooks) ([]Result, error) {
+	var (
+		res Result
+		// exported are the reads of the energy exported to the grid.
+		exported Result
+		// rated are the reads split by rate, in order of appearance.
+		rated []*Result
+		// unknown are the reads of the unknown read types, in order of
+		// appearance.
+		unknown []*Result
+	)
 	r := csv.NewReader(hdf)
+	// Only the parsed values are kept, no need to allocate a slice per line.
+	r.ReuseRecord = true
 
 	if err := validateHeader(r); err != nil {
 		return nil, err
@@ -86,9 +227,12 @@ func HDF(hdf io.Reader) ([]Result, error) {
 		if err != nil {
 			return nil, err
 		}
+		if h.OnProgress != nil && i%ProgressLines == 0 {
+			h.OnProgress(i)
+		}
 
 		if i == 1 {
-			res.MPRN, res.MeterSerialNumber, res.ReadTypes = line.MPRN, line.SerialNumber, wantReadType
+			res.MPRN, res.MeterSerialNumber = line.MPRN, line.SerialNumber
 		} else {
 			if res.MPRN != line.MPRN {
 				return nil, fmt.Errorf("invalid format: multiple MPRN found (%q and %q)", res.MPRN, line.MPRN)
@@ -98,10 +242,102 @@ func HDF(hdf io.Reader) ([]Result, error) {
 			}
 		}
 
-		res.Reads = append(res.Reads, Read{
+		read := Read{
 			Value:   line.Value,
 			EndTime: line.EndTime,
-		})
+		}
+		if _, ok := readTypes[line.ReadType]; !ok {
+			if p == Strict {
+				return nil, fmt.Errorf("invalid format: on line %d got unknown read type %q", i, line.ReadType)
+			}
+			u := findReadType(unknown, line.ReadType)
+			if u == nil {
+				u = &Result{MPRN: line.MPRN, MeterSerialNumber: line.SerialNumber, ReadTypes: line.ReadType}
+				unknown = append(unknown, u)
+			}
+			u.Reads = append(u.Reads, read)
+			continue
+		}
+		if line.ReadType == exportReadType {
+			exported.Reads = append(exported.Reads, read)
+			continue
+		}
+		if _, ok := rateReadTypes[line.ReadType]; ok {
+			r := findReadType(rated, line.ReadType)
+			if r == nil {
+				r = &Result{MPRN: line.MPRN, MeterSerialNumber: line.SerialNumber, ReadTypes: line.ReadType}
+				rated = append(rated, r)
+			}
+			r.Reads = append(r.Reads, read)
+			continue
+		}
+
+		if res.ReadTypes == "" {
+			res.ReadTypes = line.ReadType
+		} else if res.ReadTypes != line.ReadType {
+			return nil, fmt.Errorf("invalid format: multiple read types found (%q and %q)", res.ReadTypes, line.ReadType)
+		}
+		res.Reads = append(res.Reads, read)
+	}
+
+	var ret []Result
+	// A file with only exported or rate reads doesn't need an empty
+	// chunk of imported ones.
+	if len(res.Reads) > 0 || (len(exported.Reads) == 0 && len(rated) == 0) {
+		rr, err := sortAndSplit(res, d, h)
+		if err != nil {
+			return nil, err
+		}
+		ret = rr
+	}
+	if len(exported.Reads) > 0 {
+		exported.MPRN, exported.MeterSerialNumber, exported.ReadTypes = res.MPRN, res.MeterSerialNumber, exportReadType
+		rr, err := sortAndSplit(exported, d, h)
+		if err != nil {
+			return nil, fmt.Errorf("exported reads: %w", err)
+		}
+		ret = append(ret, rr...)
+	}
+	for _, r := range rated {
+		rr, err := sortAndSplit(*r, d, h)
+		if err != nil {
+			return nil, fmt.Errorf("read type %q: %w", r.ReadTypes, err)
+		}
+		ret = append(ret, rr...)
+	}
+	for _, u := range unknown {
+		if h.OnUnknownReadType != nil {
+			h.OnUnknownReadType(u.ReadTypes, len(u.Reads))
+		}
+		if p != Collect {
+			continue
+		}
+		rr, err := sortAndSplit(*u, d, h)
+		if err != nil {
+			return nil, fmt.Errorf("read type %q: %w", u.ReadTypes, err)
+		}
+		ret = append(ret, rr...)
+	}
+	return ret, nil
+}
+
+// findReadType returns the result with the given read type, nil if none.
+func findReadType(rr []*Result, readType string) *Result {
+	for _, r := range rr {
+		if r.ReadTypes == readType {
+			return r
+		}
+	}
+	return nil
+}
+
+// sortAndSplit sorts the reads of res, which are in the order of the
+// file, drops the duplicates and splits them in contiguous chunks.
+func sortAndSplit(res Result, d DuplicatePolicy, h Hooks) ([]Result, error) {
+	// Before fixing the timezone, which would move a duplicate an hour
+	// back.
+	if err := dropDuplicates(&res, d, h); err != nil {
+		return nil, err
 	}
 
 	// Reverse to have ascending timestamp order.
@@ -112,11 +348,36 @@ func HDF(hdf io.Reader) ([]Result, error) {
 
 	fixTimezone(&res)
 
-	//return []Result{res}, nil
 	// We need to check also fixTimezone, so validation has to be the last step.
 	return splitTimes(res)
 }
 
+// dropDuplicates removes the reads with the same time of the one before,
+// according to the policy, without copying them.
+func dropDuplicates(res *Result, d DuplicatePolicy, h Hooks) error {
+	reads := res.Reads[:0]
+	for _, r := range res.Reads {
+		n := len(reads)
+		if n == 0 || !r.EndTime.Equal(reads[n-1].EndTime) {
+			reads = append(reads, r)
+			continue
+		}
+		kept, dropped := reads[n-1], r
+		switch d {
+		case DuplicateError:
+			return fmt.Errorf("duplicate read at %v: values %v and %v", r.EndTime, kept.Value, r.Value)
+		case KeepLast:
+			kept, dropped = r, reads[n-1]
+			reads[n-1] = r
+		}
+		if h.OnDuplicate != nil {
+			h.OnDuplicate(kept, dropped)
+		}
+	}
+	res.Reads = reads
+	return nil
+}
+
 // fixTimezone attempts to fix the timezone when moving from summer to winter time.
 //
 // Because there is no timezone information in the data file, when transitioning
@@ -168,40 +429,42 @@ func fixTimezone(res *Result) {
 
 // splitTimes splits the result in chunks with half an hour increments
 // to workaround ESB missing data.
+//
+// The chunks share the reads of res, to avoid copying them.
 func splitTimes(res Result) ([]Result, error) {
 	var lastTs time.Time
 
 	// shallow copy
-	shallowCopyResult := func() Result {
+	subResult := func(from, to int) Result {
 		r := res
-		r.Reads = nil
+		r.Reads = res.Reads[from:to:to]
 		return r
 	}
 
-	rr := []Result{shallowCopyResult()}
-	idx := 0
+	var rr []Result
+	start := 0
 
-	for _, r := range res.Reads {
+	for i, r := range res.Reads {
 		ts := r.EndTime
 		if err := isHalfSharp(ts); err != nil {
 			return nil, err
 		}
 		if !lastTs.IsZero() {
 			switch m := ts.Sub(lastTs).Minutes(); {
-			case m == 30:
+			case m == 30 || foldHole(lastTs, ts):
 			// Expected case, nothing to do.
+			// The repeated hour removed by FoldDST is not a gap.
 			case m <= 0:
-				return rr, fmt.Errorf("data is not sorted by time: last %v, current %v", lastTs, ts)
+				return append(rr, subResult(start, i)), fmt.Errorf("data is not sorted by time: last %v, current %v", lastTs, ts)
 			default:
 				// We have a gap, let's add a new block of results.
-				rr = append(rr, shallowCopyResult())
-				idx++
+				rr = append(rr, subResult(start, i))
+				start = i
 			}
 		}
-		rr[idx].Reads = append(rr[idx].Reads, r)
 		lastTs = ts
 	}
-	return rr, nil
+	return append(rr, subResult(start, len(res.Reads))), nil
 }
 
 // isHalfSharp checks that the timestamp is at the hour or half an hour sharp.
@@ -215,15 +478,11 @@ func isHalfSharp(ts time.Time) error {
 
 func parseLine(lineNumber int, record []string) (line, error) {
 	var (
-		res        = line{MPRN: record[0], SerialNumber: record[1]}
-		recordType = record[3]
-		sval       = record[2]
-		sts        = record[4]
-		err        error
+		res  = line{MPRN: record[0], SerialNumber: record[1], ReadType: record[3]}
+		sval = record[2]
+		sts  = record[4]
+		err  error
 	)
-	if recordType != wantReadType {
-		return res, fmt.Errorf("invalid format: on line %d got read type %q, want %q", lineNumber, recordType, wantReadType)
-	}
 	res.Value, err = strconv.ParseFloat(sval, 64)
 	if err != nil {
 		return res, fmt.Errorf("invalid format: cannot parse line %d: %w", lineNumber, err)
@@ -257,36 +516,172 @@ func isRound(t time.Time) bool {
 	return t.Round(time.Hour).Equal(t)
 }
 
+// Alignment is how half hours are grouped in hours.
+type Alignment int
+
+const (
+	// AlignCenter puts the start time of the hour between its two reads:
+	// the hour starting at S contains the reads ending at S and S+30m.
+	// The graph in Home Assistant aligns better to the graph on
+	// esbnetworks.ie this way.
+	AlignCenter Alignment = iota
+	// AlignClock groups the reads by clock hour: the hour starting at S
+	// contains the reads starting at S and S+30m, like bills and most
+	// of the other tools.
+	AlignClock
+)
+
+// ParseAlignment parses the name of an alignment, "center" or "clock".
+func ParseAlignment(s string) (Alignment, error) {
+	switch s {
+	case "center":
+		return AlignCenter, nil
+	case "clock":
+		return AlignClock, nil
+	}
+	return 0, fmt.Errorf("unknown alignment %q, want center or clock", s)
+}
+
+// Interval is how long the periods of the statistics returned by
+// Translate are.
+type Interval int
+
+const (
+	// IntervalHour groups the half hours in hours, as set by the
+	// Alignment. Home Assistant only imports hourly statistics.
+	IntervalHour Interval = iota
+	// IntervalHalfHour returns the reads as they are, each starting
+	// half an hour before its end.
+	IntervalHalfHour
+	// IntervalDay groups the reads by day in Europe/Dublin timezone,
+	// shifted by half an hour like the hours with AlignCenter: the day
+	// starting at midnight contains the reads ending from midnight to
+	// 23:30. Only complete days are returned.
+	IntervalDay
+)
+
+// ParseInterval parses the name of an interval, "half_hour", "hour" or
+// "day".
+func ParseInterval(s string) (Interval, error) {
+	switch s {
+	case "half_hour":
+		return IntervalHalfHour, nil
+	case "hour":
+		return IntervalHour, nil
+	case "day":
+		return IntervalDay, nil
+	}
+	return 0, fmt.Errorf("unknown interval %q, want half_hour, hour or day", s)
+}
+
+// Options changes how Translate groups the reads.
+//
+// The zero value is the default.
+type Options struct {
+	Align    Alignment
+	Interval Interval
+}
+
 // Translate translates ESB data into Home Assistant statistics.
 //
 // ESB exports kW every half an hour, while Home Assistant wants
-// kWh every hour.
+// kWh every hour. Other quantities are summed in the unit returned
+// by Quantity.Unit, see Result.Quantity.
 //
 // Also ESB reports the timestamp at the end of the record period
 // while Home Assistant wants the start time.
 //
-// The input must be valid according to ESB().
-func Translate(raw Result) (ha.Statistics, error) {
+// The input must be valid according to ESB(). A *SkippedError is
+// returned when the reads don't complete any hour.
+func Translate(raw Result, opts Options) (ha.Statistics, error) {
+	q := raw.Quantity()
+	return translate(raw, q.Unit(), opts, func(r Read) (float64, error) {
+		return q.Amount(r.Value), nil
+	})
+}
+
+// TranslateCost translates ESB data into Home Assistant cost statistics.
+//
+// The price function returns the price per unit (kWh or m³, see
+// Quantity.Unit) at the given time, which is the start of the half an
+// hour period.
+// Hours are aligned in the same way as Translate.
+//
+// The fixed charges of a day are split evenly in its 48 half hours, so
+// the days when the clock changes are off by an hour of charges.
+func TranslateCost(raw Result, currency string, opts Options, price func(time.Time) (float64, bool), charges Charges) (ha.Statistics, error) {
+	q := raw.Quantity()
+	fixed := charges.PerDay() / 48
+	return translate(raw, currency, opts, func(r Read) (float64, error) {
+		start := r.EndTime.Add(-30 * time.Minute)
+		p, ok := price(start)
+		if !ok {
+			return 0, fmt.Errorf("missing price at %v", start)
+		}
+		return (q.Amount(r.Value)*p + fixed) * (1 + charges.VAT), nil
+	})
+}
+
+// SkippedError is returned by Translate and TranslateCost when the
+// reads don't complete any hour, like a chunk of a single read between
+// two holes. There is nothing wrong with the data, the chunk can be
+// skipped.
+type SkippedError struct {
+	// Reads is the number of reads of the chunk.
+	Reads int
+}
+
+func (e *SkippedError) Error() string {
+	return fmt.Sprintf("not enough data: %d reads don't complete an hour", e.Reads)
+}
+
+// translate aggregates the value of each read in hourly statistics.
+//
+// It returns a *SkippedError if no hour is complete.
+func translate(raw Result, unit string, opts Options, value func(Read) (float64, error)) (ha.Statistics, error) {
+	if !raw.Known() {
+		return ha.Statistics{}, fmt.Errorf("cannot translate the unknown read type %q", raw.ReadTypes)
+	}
 	ret := ha.Statistics{
 		Metadata: ha.StatisticMetadata{
 			HasSum:            true,
-			UnitOfMeasurement: "kWh",
+			UnitOfMeasurement: unit,
 		},
 	}
 
-	reads := raw.Reads
-	if len(reads) == 0 {
-		return ret, errors.New("not enough data")
+	if opts.Interval != IntervalHour {
+		return translatePeriods(raw, ret, opts, value)
+	}
+	for i := 1; i < len(raw.Reads); i++ {
+		if foldHole(raw.Reads[i-1].EndTime, raw.Reads[i].EndTime) {
+			return translateFolded(raw, i, unit, opts, value)
+		}
 	}
+
+	reads := raw.Reads
 	if len(reads) > 0 && isRound(reads[0].EndTime) {
 		// We want to start from a half an hour.
 		reads = reads[1:]
 	}
+	if len(reads) == 0 {
+		return ret, &SkippedError{Reads: len(raw.Reads)}
+	}
+
+	// Whether the i-th read completes an hour, and the offset of the
+	// start of the hour from the end of the previous read.
+	complete, offset := func(i int) bool { return i%2 == 0 && i > 0 }, time.Duration(0)
+	if opts.Align == AlignClock {
+		// The first read starts at the hour sharp, there are no reads
+		// of the previous hour to add.
+		complete, offset = func(i int) bool { return i%2 == 1 }, -30*time.Minute
+	}
+
+	ret.Stats = make([]ha.StatisticValue, 0, len(reads)/2)
 
 	var (
 		tsValidator = reads[0].EndTime.Add(-30 * time.Minute)
 		sum         float64 // Accumulator
-		value       float64 // The current hour value
+		hour        float64 // The current hour value
 	)
 
 	// My physic knowledge is a little rusted. Graph looks good
@@ -303,21 +698,144 @@ func Translate(raw Result) (ha.Statistics, error) {
 		tsValidator = r.EndTime
 		//log.Printf("ts %v %v", i, tsValidator)
 
-		value += r.Value / 2.0 // Only half an hour reading.
+		v, err := value(r)
+		if err != nil {
+			return ret, err
+		}
+		hour += v
 
-		// The graph in Home Assistant aligns better to the graph on
-		// esbnetworks.ie if we keep the start time on the center of
-		// 2 values.
-		if i%2 == 0 && i > 0 {
-			sum += value
+		if complete(i) {
+			sum += hour
 			ret.Stats = append(ret.Stats, ha.StatisticValue{
-				Start: reads[i-1].EndTime,
-				State: value,
+				Start: reads[i-1].EndTime.Add(offset),
+				State: hour,
 				Sum:   sum,
 			})
-			value = 0
+			hour = 0
+		}
+	}
+
+	// The reads after the last complete hour are left for the next
+	// download, which completes it.
+	if len(ret.Stats) == 0 {
+		return ret, &SkippedError{Reads: len(raw.Reads)}
+	}
+	return ret, nil
+}
+
+// translateFolded is translate for the hours of reads missing the
+// repeated hour before the i-th read, see FoldDST: the hours on each
+// side are translated like two chunks, the sum continuing across.
+func translateFolded(raw Result, i int, unit string, opts Options, value func(Read) (float64, error)) (ha.Statistics, error) {
+	before, after := raw, raw
+	before.Reads, after.Reads = raw.Reads[:i:i], raw.Reads[i:]
+	ret, err := translate(before, unit, opts, value)
+	var skipped *SkippedError
+	if err != nil && !errors.As(err, &skipped) {
+		return ret, err
+	}
+	rest, err := translate(after, unit, opts, value)
+	if err != nil && !errors.As(err, &skipped) {
+		return ret, err
+	}
+	var sum float64
+	if n := len(ret.Stats); n > 0 {
+		sum = ret.Stats[n-1].Sum
+	}
+	for _, s := range rest.Stats {
+		s.Sum += sum
+		ret.Stats = append(ret.Stats, s)
+	}
+	if len(ret.Stats) == 0 {
+		return ret, &SkippedError{Reads: len(raw.Reads)}
+	}
+	return ret, nil
+}
+
+// translatePeriods is translate for the intervals other than the hour,
+// which group the reads by the period containing them.
+//
+// The incomplete periods are dropped, like the hours. A day missing
+// the repeated hour, see FoldDST, is complete without its reads, like
+// DailyTotal.Folded.
+func translatePeriods(raw Result, ret ha.Statistics, opts Options, value func(Read) (float64, error)) (ha.Statistics, error) {
+	period := func(r Read) (from, to time.Time) {
+		return r.EndTime.Add(-30 * time.Minute), r.EndTime
+	}
+	if opts.Interval == IntervalDay {
+		period = func(r Read) (from, to time.Time) {
+			t := r.EndTime
+			if opts.Align == AlignClock {
+				t = t.Add(-30 * time.Minute)
+			}
+			t = t.In(irelandTimezone)
+			from = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, irelandTimezone)
+			// Days are 23 or 25 hours long when the clock changes.
+			return from, from.AddDate(0, 0, 1)
 		}
 	}
 
+	var (
+		sum   float64
+		cur   ha.StatisticValue
+		end   time.Time
+		reads int // The reads of cur.
+		// The reads of cur in the first and in the second repeated hour.
+		occurrences [3]int
+	)
+	add := func() {
+		want := int(end.Sub(cur.Start) / (30 * time.Minute))
+		if opts.Interval == IntervalDay && (occurrences[1] == 0) != (occurrences[2] == 0) {
+			want -= 2
+		}
+		if reads == want {
+			sum += cur.State
+			cur.Sum = sum
+			ret.Stats = append(ret.Stats, cur)
+		}
+	}
+	for i, r := range raw.Reads {
+		if i > 0 {
+			if prev := raw.Reads[i-1].EndTime; r.EndTime.Sub(prev) != 30*time.Minute && !foldHole(prev, r.EndTime) {
+				return ret, fmt.Errorf("value %d: entries should be recorded at 30 minutes increment, got %v (%v -> %v)", i, r.EndTime.Sub(prev).Minutes(), prev, r.EndTime)
+			}
+		}
+		v, err := value(r)
+		if err != nil {
+			return ret, err
+		}
+		if from, to := period(r); reads == 0 || !from.Equal(cur.Start) {
+			if reads > 0 {
+				add()
+			}
+			cur, end, reads, occurrences = ha.StatisticValue{Start: from}, to, 0, [3]int{}
+		}
+		cur.State += v
+		reads++
+		occurrences[folded(r.EndTime.Add(-30*time.Minute))]++
+	}
+	if reads > 0 {
+		add()
+	}
+	if len(ret.Stats) == 0 {
+		return ret, &SkippedError{Reads: len(raw.Reads)}
+	}
 	return ret, nil
 }
+
+// MeterReadings returns a copy of the statistics where the state is the
+// cumulative energy, like the reading of a physical meter, instead of
+// the energy of the hour.
+//
+// Home Assistant only uses the sum for the energy dashboard, but
+// template sensors and utility meters behave better with an ever
+// increasing state. The conversion must be the last step before
+// sending: Rebase, ContinueFrom and HourHash expect hourly states.
+func MeterReadings(stats []ha.StatisticValue) []ha.StatisticValue {
+	ret := make([]ha.StatisticValue, len(stats))
+	for i, s := range stats {
+		s.State = s.Sum
+		ret[i] = s
+	}
+	return ret
+}
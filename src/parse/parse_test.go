@@ -1,11 +1,16 @@
 package parse
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/ha"
 )
 
 func TestHDF_Errors(t *testing.T) {
@@ -58,6 +63,12 @@ func TestHDF_Errors(t *testing.T) {
 			`MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time
 123,45,0.194000,Active Import Interval (kW),15-01-2023 23:30
 123,00,0.157000,Active Import Interval (kW),15-01-2023 23:00`,
+		},
+		{
+			"Different read type",
+			`MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time
+123,45,0.194000,Active Import Interval (kW),15-01-2023 23:30
+123,45,0.157000,Gas Interval (m3),15-01-2023 23:00`,
 		},
 		{
 			"wrong order",
@@ -303,3 +314,543 @@ func TestHDF(t *testing.T) {
 		}
 	}
 }
+
+func TestHDFWithHooks(t *testing.T) {
+	data := `MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time
+123,45,0.194000,Active Import Interval (kW),15-01-2023 23:30
+123,45,0.157000,Active Import Interval (kW),15-01-2023 22:00
+123,45,0.111000,Active Import Interval (kW),15-01-2023 21:30`
+
+	var chunks []Result
+	var errs []error
+	h := Hooks{
+		OnChunkParsed: func(r Result) { chunks = append(chunks, r) },
+		OnError:       func(err error) { errs = append(errs, err) },
+	}
+
+	got, err := HDFWithHooks(strings.NewReader(data), h)
+	if err != nil {
+		t.Fatalf("HDFWithHooks() returned error: %v", err)
+	}
+	if diff := cmp.Diff(got, chunks); diff != "" {
+		t.Errorf("OnChunkParsed() got different chunks than returned, diff (-want, +got):\n%s", diff)
+	}
+	if len(chunks) != 2 {
+		t.Errorf("OnChunkParsed() called %d times, want 2", len(chunks))
+	}
+	if len(errs) != 0 {
+		t.Errorf("OnError() called with %v, want no calls", errs)
+	}
+
+	chunks = nil
+	if _, err := HDFWithHooks(strings.NewReader("invalid"), h); err == nil {
+		t.Fatal("HDFWithHooks() = nil error, want error")
+	}
+	if len(errs) != 1 {
+		t.Errorf("OnError() called %d times, want 1", len(errs))
+	}
+	if len(chunks) != 0 {
+		t.Errorf("OnChunkParsed() called %d times on error, want 0", len(chunks))
+	}
+}
+
+func TestHDFWithHooks_Progress(t *testing.T) {
+	// Winter time, the same as UTC, with import and export reads, the
+	// newest first like ESB.
+	var b strings.Builder
+	b.WriteString("MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time\n")
+	end := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 6000 {
+		ts := end.Add(-time.Duration(i) * 30 * time.Minute).Format("02-01-2006 15:04")
+		fmt.Fprintf(&b, "123,45,0.5,Active Import Interval (kW),%s\n", ts)
+		fmt.Fprintf(&b, "123,45,0.1,Active Export Interval (kW),%s\n", ts)
+	}
+
+	var progress []int
+	h := Hooks{OnProgress: func(lines int) { progress = append(progress, lines) }}
+	if _, err := HDFWithHooks(strings.NewReader(b.String()), h); err != nil {
+		t.Fatalf("HDFWithHooks() returned error: %v", err)
+	}
+	if diff := cmp.Diff([]int{ProgressLines}, progress); diff != "" {
+		t.Errorf("OnProgress() unexpected calls (-want +got):\n%s", diff)
+	}
+}
+
+func TestHDF_Export(t *testing.T) {
+	data := `MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time
+123,45,0.194000,Active Import Interval (kW),15-01-2023 23:30
+123,45,1.000000,Active Export Interval (kW),15-01-2023 23:30
+123,45,0.157000,Active Import Interval (kW),15-01-2023 23:00
+123,45,2.000000,Active Export Interval (kW),15-01-2023 23:00`
+
+	got, err := HDF(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("HDF() returned error: %v", err)
+	}
+	imported, exported := SplitExport(got)
+	if len(imported) != 1 || imported[0].Export() || len(imported[0].Reads) != 2 {
+		t.Errorf("SplitExport() imported = %+v, want a chunk of 2 reads", imported)
+	}
+	gmt := time.FixedZone("GMT", 0)
+	want := []Result{{
+		MPRN: "123", MeterSerialNumber: "45", ReadTypes: "Active Export Interval (kW)",
+		Reads: []Read{
+			{Value: 2, EndTime: time.Date(2023, 1, 15, 23, 0, 0, 0, gmt)},
+			{Value: 1, EndTime: time.Date(2023, 1, 15, 23, 30, 0, 0, gmt)},
+		},
+	}}
+	if diff := cmp.Diff(want, exported); diff != "" {
+		t.Errorf("SplitExport() exported unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestHDFWithPolicy(t *testing.T) {
+	data := `MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time
+123,45,0.194000,Active Import Interval (kW),15-01-2023 23:30
+123,45,1.000000,Reactive Import Interval (kVArh),15-01-2023 23:30
+123,45,0.157000,Active Import Interval (kW),15-01-2023 23:00
+123,45,2.000000,Reactive Import Interval (kVArh),15-01-2023 23:00`
+
+	gmt := time.FixedZone("GMT", 0)
+	known := Result{
+		MPRN: "123", MeterSerialNumber: "45", ReadTypes: "Active Import Interval (kW)",
+		Reads: []Read{
+			{Value: 0.157, EndTime: time.Date(2023, 1, 15, 23, 0, 0, 0, gmt)},
+			{Value: 0.194, EndTime: time.Date(2023, 1, 15, 23, 30, 0, 0, gmt)},
+		},
+	}
+	unknown := Result{
+		MPRN: "123", MeterSerialNumber: "45", ReadTypes: "Reactive Import Interval (kVArh)",
+		Reads: []Read{
+			{Value: 2, EndTime: time.Date(2023, 1, 15, 23, 0, 0, 0, gmt)},
+			{Value: 1, EndTime: time.Date(2023, 1, 15, 23, 30, 0, 0, gmt)},
+		},
+	}
+
+	tests := []struct {
+		policy ReadTypePolicy
+		want   []Result
+		// reported is the number of lines passed to OnUnknownReadType.
+		reported int
+	}{
+		{policy: Warn, want: []Result{known}, reported: 2},
+		{policy: Collect, want: []Result{known, unknown}, reported: 2},
+	}
+	for _, tc := range tests {
+		var reported int
+		h := Hooks{OnUnknownReadType: func(readType string, lines int) {
+			if readType != unknown.ReadTypes {
+				t.Errorf("OnUnknownReadType(%q) got unexpected read type", readType)
+			}
+			reported += lines
+		}}
+		got, err := HDFWithPolicy(strings.NewReader(data), tc.policy, h)
+		if err != nil {
+			t.Fatalf("HDFWithPolicy(%v) returned error: %v", tc.policy, err)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("HDFWithPolicy(%v) unexpected diff (-want +got):\n%s", tc.policy, diff)
+		}
+		if reported != tc.reported {
+			t.Errorf("HDFWithPolicy(%v) reported %d unknown lines, want %d", tc.policy, reported, tc.reported)
+		}
+	}
+
+	if _, err := HDFWithPolicy(strings.NewReader(data), Strict, Hooks{}); err == nil {
+		t.Error("HDFWithPolicy(Strict) = nil error, want error")
+	}
+	if _, err := Translate(unknown, Options{}); err == nil {
+		t.Error("Translate() of an unknown read type = nil error, want error")
+	}
+}
+
+func TestHDFWithPolicies(t *testing.T) {
+	// The read ending at 23:00 is repeated with a revised value.
+	data := `MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time
+123,45,0.194000,Active Import Interval (kW),15-01-2023 23:30
+123,45,0.157000,Active Import Interval (kW),15-01-2023 23:00
+123,45,0.160000,Active Import Interval (kW),15-01-2023 23:00
+123,45,0.120000,Active Import Interval (kW),15-01-2023 22:30`
+
+	gmt := time.FixedZone("GMT", 0)
+	ts := func(h, m int) time.Time { return time.Date(2023, 1, 15, h, m, 0, 0, gmt) }
+	result := func(at2300 float64) []Result {
+		return []Result{{
+			MPRN: "123", MeterSerialNumber: "45", ReadTypes: "Active Import Interval (kW)",
+			Reads: []Read{
+				{Value: 0.12, EndTime: ts(22, 30)},
+				{Value: at2300, EndTime: ts(23, 0)},
+				{Value: 0.194, EndTime: ts(23, 30)},
+			},
+		}}
+	}
+
+	tests := []struct {
+		policy  DuplicatePolicy
+		want    []Result
+		dropped float64
+	}{
+		{policy: KeepFirst, want: result(0.157), dropped: 0.16},
+		{policy: KeepLast, want: result(0.16), dropped: 0.157},
+	}
+	for _, tc := range tests {
+		var dropped []Read
+		h := Hooks{OnDuplicate: func(kept, d Read) {
+			dropped = append(dropped, d)
+		}}
+		got, err := HDFWithPolicies(strings.NewReader(data), Strict, tc.policy, h)
+		if err != nil {
+			t.Fatalf("HDFWithPolicies(%v) returned error: %v", tc.policy, err)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("HDFWithPolicies(%v) unexpected diff (-want +got):\n%s", tc.policy, diff)
+		}
+		want := []Read{{Value: tc.dropped, EndTime: ts(23, 0)}}
+		if diff := cmp.Diff(want, dropped); diff != "" {
+			t.Errorf("HDFWithPolicies(%v) OnDuplicate unexpected diff (-want +got):\n%s", tc.policy, diff)
+		}
+	}
+
+	if _, err := HDFWithPolicies(strings.NewReader(data), Strict, DuplicateError, Hooks{}); err == nil {
+		t.Error("HDFWithPolicies(DuplicateError) = nil error, want error")
+	}
+	if _, err := HDF(strings.NewReader(data)); err == nil {
+		t.Error("HDF() with duplicates = nil error, want error")
+	}
+}
+
+func TestTranslate_Align(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	ts := func(h, m int) time.Time { return time.Date(2023, 01, 15, h, m, 0, 0, gmt) }
+	raw := Result{Reads: []Read{
+		{Value: 2, EndTime: ts(22, 0)},
+		{Value: 4, EndTime: ts(22, 30)},
+		{Value: 6, EndTime: ts(23, 0)},
+		{Value: 8, EndTime: ts(23, 30)},
+		{Value: 10, EndTime: ts(0, 0).AddDate(0, 0, 1)},
+		{Value: 12, EndTime: ts(0, 30).AddDate(0, 0, 1)},
+	}}
+
+	tests := []struct {
+		align Alignment
+		want  []ha.StatisticValue
+	}{
+		{
+			// The reads ending at 22:30, 23:00 and 23:30, then the
+			// ones ending at 00:00 and 00:30.
+			align: AlignCenter,
+			want: []ha.StatisticValue{
+				{Start: ts(23, 0), State: 9, Sum: 9},
+				{Start: ts(0, 0).AddDate(0, 0, 1), State: 11, Sum: 20},
+			},
+		},
+		{
+			// The reads from 22:00 to 23:00, then from 23:00 to 00:00.
+			align: AlignClock,
+			want: []ha.StatisticValue{
+				{Start: ts(22, 0), State: 5, Sum: 5},
+				{Start: ts(23, 0), State: 9, Sum: 14},
+			},
+		},
+	}
+	for _, tc := range tests {
+		got, err := Translate(raw, Options{Align: tc.align})
+		if err != nil {
+			t.Fatalf("Translate(%v) unexpected error: %v", tc.align, err)
+		}
+		if diff := cmp.Diff(tc.want, got.Stats); diff != "" {
+			t.Errorf("Translate(%v) unexpected diff (+got -want): %v", tc.align, diff)
+		}
+	}
+}
+
+func TestTranslate_Interval(t *testing.T) {
+	// reads returns a read of 1kWh every half hour, ending from the
+	// first time to the last one.
+	reads := func(first, last time.Time) []Read {
+		var ret []Read
+		for e := first; !e.After(last); e = e.Add(30 * time.Minute) {
+			ret = append(ret, Read{Value: 2, EndTime: e})
+		}
+		return ret
+	}
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, irelandTimezone) }
+	jan15 := day(2023, 1, 15)
+
+	tests := []struct {
+		name  string
+		opts  Options
+		reads []Read
+		want  []ha.StatisticValue
+	}{
+		{
+			name:  "half hour",
+			opts:  Options{Interval: IntervalHalfHour},
+			reads: reads(jan15.Add(30*time.Minute), jan15.Add(90*time.Minute)),
+			want: []ha.StatisticValue{
+				{Start: jan15, State: 1, Sum: 1},
+				{Start: jan15.Add(30 * time.Minute), State: 1, Sum: 2},
+				{Start: jan15.Add(60 * time.Minute), State: 1, Sum: 3},
+			},
+		},
+		{
+			// The reads ending from midnight to 23:30, the next day
+			// is partial.
+			name:  "day center",
+			opts:  Options{Interval: IntervalDay, Align: AlignCenter},
+			reads: reads(jan15, day(2023, 1, 16).Add(30*time.Minute)),
+			want: []ha.StatisticValue{
+				{Start: jan15, State: 48, Sum: 48},
+			},
+		},
+		{
+			// The read ending at midnight belongs to the day before,
+			// which is partial like the next one.
+			name:  "day clock",
+			opts:  Options{Interval: IntervalDay, Align: AlignClock},
+			reads: reads(jan15, day(2023, 1, 16).Add(30*time.Minute)),
+			want: []ha.StatisticValue{
+				{Start: jan15, State: 48, Sum: 48},
+			},
+		},
+		{
+			name:  "day with summer time",
+			opts:  Options{Interval: IntervalDay},
+			reads: reads(day(2023, 3, 26), day(2023, 3, 27)),
+			want: []ha.StatisticValue{
+				{Start: day(2023, 3, 26), State: 46, Sum: 46},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Translate(Result{Reads: tc.reads}, tc.opts)
+			if err != nil {
+				t.Fatalf("Translate() unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got.Stats); diff != "" {
+				t.Errorf("Translate() unexpected diff (+got -want): %v", diff)
+			}
+		})
+	}
+
+	// A single partial day is skipped.
+	_, err := Translate(Result{Reads: reads(jan15, jan15.Add(time.Hour))}, Options{Interval: IntervalDay})
+	var skipped *SkippedError
+	if !errors.As(err, &skipped) {
+		t.Errorf("Translate(partial day) = %v, want SkippedError", err)
+	}
+}
+
+func TestTranslate_DayFolded(t *testing.T) {
+	// The clocks went back at 01:00 UTC on 27 Oct 2024, the day in
+	// Dublin is 25 hours long, from 23:00 UTC of the day before.
+	day := time.Date(2024, 10, 27, 0, 0, 0, 0, irelandTimezone)
+	var reads []Read
+	for i := 1; i <= 50; i++ {
+		reads = append(reads, Read{Value: 2, EndTime: day.Add(time.Duration(i) * 30 * time.Minute)})
+	}
+
+	tests := []struct {
+		fold  DSTFold
+		want  float64 // The kWh of the day.
+		hours int
+	}{
+		{FoldSum, 50, 25},
+		{FoldFirst, 48, 24},
+		{FoldLast, 48, 24},
+	}
+	for _, tc := range tests {
+		rr := FoldDST([]Result{{MPRN: "1", Reads: reads}}, tc.fold)
+		if len(rr) != 1 {
+			t.Fatalf("FoldDST(%v) returned %d chunks, want 1", tc.fold, len(rr))
+		}
+		got, err := Translate(rr[0], Options{Interval: IntervalDay, Align: AlignClock})
+		if err != nil {
+			t.Fatalf("Translate(FoldDST(%v)) unexpected error: %v", tc.fold, err)
+		}
+		want := []ha.StatisticValue{{Start: day, State: tc.want, Sum: tc.want}}
+		if diff := cmp.Diff(want, got.Stats); diff != "" {
+			t.Errorf("Translate(FoldDST(%v)) unexpected diff (+got -want): %v", tc.fold, diff)
+		}
+
+		// The hours around the removed one are still translated.
+		hourly, err := Translate(rr[0], Options{Align: AlignClock})
+		if err != nil {
+			t.Fatalf("Translate(FoldDST(%v), hours) unexpected error: %v", tc.fold, err)
+		}
+		if n := len(hourly.Stats); n != tc.hours {
+			t.Errorf("Translate(FoldDST(%v), hours) returned %d hours, want %d", tc.fold, n, tc.hours)
+		}
+		if last := hourly.Stats[len(hourly.Stats)-1].Sum; last != tc.want {
+			t.Errorf("Translate(FoldDST(%v), hours) sum = %v, want %v", tc.fold, last, tc.want)
+		}
+	}
+}
+
+func TestTranslate_ShortChunks(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	ts := func(h, m int) time.Time { return time.Date(2023, 01, 15, h, m, 0, 0, gmt) }
+	reads := func(ends ...time.Time) []Read {
+		var ret []Read
+		for _, e := range ends {
+			ret = append(ret, Read{Value: 2, EndTime: e})
+		}
+		return ret
+	}
+
+	tests := []struct {
+		name  string
+		align Alignment
+		reads []Read
+		hours int // 0 if the chunk is skipped.
+	}{
+		{"empty", AlignCenter, nil, 0},
+		{"a round read", AlignCenter, reads(ts(22, 0)), 0},
+		{"a half hour read", AlignCenter, reads(ts(22, 30)), 0},
+		{"a half hour read, clock", AlignClock, reads(ts(22, 30)), 0},
+		{"a round read and a half", AlignClock, reads(ts(22, 0), ts(22, 30)), 0},
+		{"an hour, clock", AlignClock, reads(ts(22, 30), ts(23, 0)), 1},
+		{"an hour and a partial one, center", AlignCenter, reads(ts(22, 30), ts(23, 0), ts(23, 30), ts(0, 0).AddDate(0, 0, 1)), 1},
+	}
+	for _, tc := range tests {
+		got, err := Translate(Result{Reads: tc.reads}, Options{Align: tc.align})
+		var skipped *SkippedError
+		if tc.hours == 0 {
+			if !errors.As(err, &skipped) || skipped.Reads != len(tc.reads) {
+				t.Errorf("%s: Translate() = %v, %v, want SkippedError of %d reads", tc.name, got.Stats, err, len(tc.reads))
+			}
+			continue
+		}
+		if err != nil || len(got.Stats) != tc.hours {
+			t.Errorf("%s: Translate() = %v, %v, want %d hours", tc.name, got.Stats, err, tc.hours)
+		}
+	}
+}
+
+func TestTranslate_Quantity(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	ts := func(h, m int) time.Time { return time.Date(2023, 01, 15, h, m, 0, 0, gmt) }
+	reads := []Read{
+		{Value: 2, EndTime: ts(22, 30)},
+		{Value: 4, EndTime: ts(23, 0)},
+		{Value: 6, EndTime: ts(23, 30)},
+	}
+
+	tests := []struct {
+		readType string
+		unit     string
+		state    float64
+	}{
+		{"Active Import Interval (kW)", "kWh", 6},
+		{"Gas Interval (kWh)", "kWh", 12},
+		{"Gas Interval (m3)", "m³", 12},
+	}
+	for _, tc := range tests {
+		got, err := Translate(Result{ReadTypes: tc.readType, Reads: reads}, Options{})
+		if err != nil {
+			t.Fatalf("Translate(%q) unexpected error: %v", tc.readType, err)
+		}
+		if got.Metadata.UnitOfMeasurement != tc.unit {
+			t.Errorf("Translate(%q) unit = %q, want %q", tc.readType, got.Metadata.UnitOfMeasurement, tc.unit)
+		}
+		want := []ha.StatisticValue{{Start: ts(23, 0), State: tc.state, Sum: tc.state}}
+		if diff := cmp.Diff(want, got.Stats); diff != "" {
+			t.Errorf("Translate(%q) unexpected diff (+got -want): %v", tc.readType, diff)
+		}
+	}
+}
+
+func TestMeterReadings(t *testing.T) {
+	h := func(n int) time.Time { return time.Unix(int64(n)*3600, 0) }
+	stats := []ha.StatisticValue{
+		{Start: h(0), State: 1, Sum: 11},
+		{Start: h(1), State: 2, Sum: 13},
+	}
+	want := []ha.StatisticValue{
+		{Start: h(0), State: 11, Sum: 11},
+		{Start: h(1), State: 13, Sum: 13},
+	}
+	if diff := cmp.Diff(want, MeterReadings(stats)); diff != "" {
+		t.Errorf("MeterReadings() unexpected diff (+got -want): %v", diff)
+	}
+	if stats[0].State != 1 {
+		t.Errorf("MeterReadings() modified its input: %v", stats)
+	}
+}
+
+func TestTranslateCost(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	ts := func(h, m int) time.Time { return time.Date(2023, 01, 15, h, m, 0, 0, gmt) }
+	raw := Result{Reads: []Read{
+		{Value: 2, EndTime: ts(22, 30)},
+		{Value: 2, EndTime: ts(23, 0)},
+		{Value: 4, EndTime: ts(23, 30)},
+	}}
+	// 0.1 EUR/kWh before 23:00, 0.2 after.
+	price := func(t time.Time) (float64, bool) {
+		if t.Before(ts(23, 0)) {
+			return 0.1, true
+		}
+		return 0.2, true
+	}
+
+	got, err := TranslateCost(raw, "EUR", Options{}, price, Charges{})
+	if err != nil {
+		t.Fatalf("TranslateCost() unexpected error: %v", err)
+	}
+	if got.Metadata.UnitOfMeasurement != "EUR" {
+		t.Errorf("TranslateCost() unit = %q, want EUR", got.Metadata.UnitOfMeasurement)
+	}
+	// Translate puts the first 3 reads in the first hour:
+	// 1 kWh at 0.1 + 2 kWh at 0.2.
+	const want = 0.6
+	if n := len(got.Stats); n != 1 || math.Abs(got.Stats[0].Sum-want) > 1e-9 {
+		t.Errorf("TranslateCost() = %+v, want a single hour costing %v", got.Stats, want)
+	}
+
+	if _, err := TranslateCost(raw, "EUR", Options{}, func(time.Time) (float64, bool) { return 0, false }, Charges{}); err == nil {
+		t.Errorf("TranslateCost() with missing prices want error")
+	}
+
+	// 0.48 per day is 0.01 per half hour, for 3 reads, plus 10% VAT.
+	charges := Charges{StandingPerDay: 0.48, VAT: 0.1}
+	got, err = TranslateCost(raw, "EUR", Options{}, price, charges)
+	if err != nil {
+		t.Fatalf("TranslateCost() with charges unexpected error: %v", err)
+	}
+	const withCharges = (0.6 + 0.03) * 1.1
+	if n := len(got.Stats); n != 1 || math.Abs(got.Stats[0].Sum-withCharges) > 1e-9 {
+		t.Errorf("TranslateCost() with charges = %+v, want a single hour costing %v", got.Stats, withCharges)
+	}
+}
+
+// hdf5Years returns an HDF file with 5 years of reads, newest first as ESB does.
+func hdf5Years() []byte {
+	var b strings.Builder
+	b.WriteString("MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time\n")
+	end := time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC)
+	for ts := end; ts.After(end.AddDate(-5, 0, 0)); ts = ts.Add(-30 * time.Minute) {
+		fmt.Fprintf(&b, "10000000000,000000000000,%.6f,Active Import Interval (kW),%s\n",
+			float64(ts.Minute()%7)/10, ts.In(irelandTimezone).Format("02-01-2006 15:04"))
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkHDF5Years(b *testing.B) {
+	data := hdf5Years()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res, err := HDF(bytes.NewReader(data))
+		if err != nil {
+			b.Fatalf("HDF() unexpected error: %v", err)
+		}
+		for _, r := range res {
+			if _, err := Translate(r, Options{}); err != nil {
+				b.Fatalf("Translate() unexpected error: %v", err)
+			}
+		}
+	}
+}
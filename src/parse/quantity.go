@@ -0,0 +1,81 @@
+package parse
+
+// Quantity is what the reads of a Result measure, it decides the unit
+// of the statistics returned by Translate.
+type Quantity int
+
+const (
+	// Power reads are the average kW of the half an hour, like the
+	// electricity files from ESB.
+	Power Quantity = iota
+	// Energy reads are the kWh used in the half an hour, like gas
+	// meters reporting energy.
+	Energy
+	// Volume reads are the m³ used in the half an hour, like gas
+	// meters reporting the volume.
+	Volume
+)
+
+// readTypes are the known values of the "Read Type" column.
+//
+// Providers of other utilities, like gas, must write the one matching
+// their reads to reuse the same pipeline.
+var readTypes = map[string]Quantity{
+	wantReadType:                   Power,
+	exportReadType:                 Power,
+	"Active Import Interval (kWh)": Energy,
+	"Gas Interval (kWh)":           Energy,
+	"Gas Interval (m3)":            Volume,
+
+	"Active Import Interval Day (kW)":   Power,
+	"Active Import Interval Peak (kW)":  Power,
+	"Active Import Interval Night (kW)": Power,
+}
+
+// Quantity returns what the reads measure, Power if unknown.
+func (r Result) Quantity() Quantity {
+	return readTypes[r.ReadTypes]
+}
+
+// Known returns if the read type is known, unknown ones are only
+// returned by the Collect policy and their reads are left as they are
+// in the file. An empty read type is the ESB electricity one.
+func (r Result) Known() bool {
+	_, ok := readTypes[r.ReadTypes]
+	return ok || r.ReadTypes == ""
+}
+
+// Export returns if the reads are of the energy exported to the grid.
+func (r Result) Export() bool {
+	return r.ReadTypes == exportReadType
+}
+
+// SplitExport splits the results of the energy imported from the grid
+// and the ones of the energy exported to it.
+func SplitExport(rr []Result) (imported, exported []Result) {
+	for _, r := range rr {
+		if r.Export() {
+			exported = append(exported, r)
+		} else {
+			imported = append(imported, r)
+		}
+	}
+	return imported, exported
+}
+
+// Unit returns the unit of the hourly statistics of the quantity, as
+// expected by the Home Assistant energy dashboard.
+func (q Quantity) Unit() string {
+	if q == Volume {
+		return "m³"
+	}
+	return "kWh"
+}
+
+// Amount returns how much of Unit a half an hour read measures.
+func (q Quantity) Amount(value float64) float64 {
+	if q == Power {
+		return value / 2.0 // Only half an hour reading.
+	}
+	return value
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package parse
+
+// raceEnabled is set when the tests run with the race detector, which
+// uses more memory.
+const raceEnabled = true
@@ -0,0 +1,101 @@
+package parse
+
+import (
+	"fmt"
+	"slices"
+)
+
+// rateReadTypes are the read types of the meters which split the
+// consumption by rate, with the name of the rate. Every half hour is
+// in the read type of its rate.
+var rateReadTypes = map[string]string{
+	"Active Import Interval Day (kW)":   "day",
+	"Active Import Interval Peak (kW)":  "peak",
+	"Active Import Interval Night (kW)": "night",
+}
+
+// Rate returns the rate of the reads, day, peak or night, or an empty
+// string if the reads are not split by rate.
+func (r Result) Rate() string {
+	return rateReadTypes[r.ReadTypes]
+}
+
+// Rates is what to do with the reads split by rate.
+type Rates int
+
+const (
+	// MergeRates sums the reads of the rates in a single series, like
+	// the files of the meters which don't split them.
+	MergeRates Rates = iota
+	// SplitRates keeps a series per rate.
+	SplitRates
+)
+
+// ParseRates parses the name of a rates policy, "merge" or "split".
+func ParseRates(s string) (Rates, error) {
+	switch s {
+	case "merge":
+		return MergeRates, nil
+	case "split":
+		return SplitRates, nil
+	}
+	return 0, fmt.Errorf("unknown rates %q, want merge or split", s)
+}
+
+// GroupRates applies the policy to the reads split by rate, as returned
+// by HDF.
+//
+// Merging, the rates become a series of imported reads before the other
+// results, split in contiguous chunks again. If the file already has
+// the imported reads, the rates are only their breakdown and they are
+// dropped.
+func GroupRates(rr []Result, r Rates) ([]Result, error) {
+	if r == SplitRates {
+		return rr, nil
+	}
+	var (
+		rated, others []Result
+		total         bool
+	)
+	for _, res := range rr {
+		switch {
+		case res.Rate() != "":
+			rated = append(rated, res)
+		case res.Known() && !res.Export() && len(res.Reads) > 0:
+			total = true
+			fallthrough
+		default:
+			others = append(others, res)
+		}
+	}
+	if len(rated) == 0 || total {
+		return others, nil
+	}
+
+	var reads []Read
+	for _, res := range rated {
+		reads = append(reads, res.Reads...)
+	}
+	slices.SortStableFunc(reads, func(a, b Read) int { return a.EndTime.Compare(b.EndTime) })
+	// A half hour in more rates, like when the rate changes, is summed.
+	merged := reads[:0]
+	for _, rd := range reads {
+		if n := len(merged); n > 0 && merged[n-1].EndTime.Equal(rd.EndTime) {
+			merged[n-1].Value += rd.Value
+			merged[n-1].Estimated = merged[n-1].Estimated && rd.Estimated
+			continue
+		}
+		merged = append(merged, rd)
+	}
+
+	chunks, err := splitTimes(Result{
+		MPRN:              rated[0].MPRN,
+		MeterSerialNumber: rated[0].MeterSerialNumber,
+		ReadTypes:         wantReadType,
+		Reads:             merged,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(chunks, others...), nil
+}
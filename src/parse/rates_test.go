@@ -0,0 +1,81 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGroupRates(t *testing.T) {
+	data := `MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time
+123,45,2.000000,Active Import Interval Day (kW),16-01-2023 08:30
+123,45,1.000000,Active Import Interval Night (kW),16-01-2023 08:00
+123,45,0.500000,Active Import Interval Night (kW),16-01-2023 07:30`
+
+	parsed, err := HDF(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("HDF() returned error: %v", err)
+	}
+	gmt := time.FixedZone("GMT", 0)
+	at := func(h, m int) time.Time { return time.Date(2023, 1, 16, h, m, 0, 0, gmt) }
+	day := Result{
+		MPRN: "123", MeterSerialNumber: "45", ReadTypes: "Active Import Interval Day (kW)",
+		Reads: []Read{{Value: 2, EndTime: at(8, 30)}},
+	}
+	night := Result{
+		MPRN: "123", MeterSerialNumber: "45", ReadTypes: "Active Import Interval Night (kW)",
+		Reads: []Read{{Value: 0.5, EndTime: at(7, 30)}, {Value: 1, EndTime: at(8, 0)}},
+	}
+	if diff := cmp.Diff([]Result{day, night}, parsed); diff != "" {
+		t.Fatalf("HDF() unexpected diff (-want +got):\n%s", diff)
+	}
+	if got := night.Rate(); got != "night" {
+		t.Errorf("Rate() = %q, want night", got)
+	}
+
+	total := Result{
+		MPRN: "123", MeterSerialNumber: "45", ReadTypes: "Active Import Interval (kW)",
+		Reads: []Read{{Value: 3, EndTime: at(7, 30)}},
+	}
+	tests := []struct {
+		name  string
+		rates Rates
+		rr    []Result
+		want  []Result
+	}{
+		{
+			name:  "merge",
+			rates: MergeRates,
+			rr:    []Result{day, night},
+			want: []Result{{
+				MPRN: "123", MeterSerialNumber: "45", ReadTypes: "Active Import Interval (kW)",
+				Reads: []Read{{Value: 0.5, EndTime: at(7, 30)}, {Value: 1, EndTime: at(8, 0)}, {Value: 2, EndTime: at(8, 30)}},
+			}},
+		},
+		{
+			name:  "split",
+			rates: SplitRates,
+			rr:    []Result{day, night},
+			want:  []Result{day, night},
+		},
+		{
+			name:  "the imported reads win",
+			rates: MergeRates,
+			rr:    []Result{total, day, night},
+			want:  []Result{total},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := GroupRates(tc.rr, tc.rates)
+			if err != nil {
+				t.Fatalf("GroupRates() returned error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("GroupRates() unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package parse
+
+import (
+	"math"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+// Correction is an hour whose energy in Home Assistant differs from the
+// one to import.
+type Correction struct {
+	Start time.Time
+	// Adjustment is what must be added to the sum of the hour, and of all
+	// the following ones, to match.
+	Adjustment float64
+}
+
+// Corrections compares the energy of every hour, the difference between
+// its sum and the one of the hour before, of the statistics to import
+// with the ones already in Home Assistant.
+//
+// Only the hours whose previous hour is in both can be compared. The
+// energy of an hour doesn't depend on the sums before it, so the
+// corrections can be applied one after the other, oldest first.
+// Differences up to tolerance, like the rounding of -precision, are
+// ignored.
+func Corrections(stats, existing []ha.StatisticValue, tolerance float64) []Correction {
+	old := make(map[int64]float64, len(existing))
+	for _, s := range existing {
+		old[s.Start.Unix()] = s.Sum
+	}
+
+	var ret []Correction
+	for i := 1; i < len(stats); i++ {
+		prev, cur := stats[i-1], stats[i]
+		if !cur.Start.Equal(prev.Start.Add(time.Hour)) {
+			continue
+		}
+		oldPrev, ok := old[prev.Start.Unix()]
+		if !ok {
+			continue
+		}
+		oldCur, ok := old[cur.Start.Unix()]
+		if !ok {
+			continue
+		}
+		adj := (cur.Sum - prev.Sum) - (oldCur - oldPrev)
+		if math.Abs(adj) > tolerance && !sameValue(adj, 0) {
+			ret = append(ret, Correction{Start: cur.Start, Adjustment: adj})
+		}
+	}
+	return ret
+}
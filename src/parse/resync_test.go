@@ -0,0 +1,83 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+func TestCorrections(t *testing.T) {
+	h := func(n int) time.Time { return time.Unix(int64(n)*3600, 0) }
+	existing := []ha.StatisticValue{
+		{Start: h(0), Sum: 10},
+		{Start: h(1), Sum: 11},
+		{Start: h(2), Sum: 13},
+		{Start: h(3), Sum: 14},
+		{Start: h(4), Sum: 15},
+		{Start: h(6), Sum: 20},
+	}
+
+	tests := []struct {
+		name      string
+		stats     []ha.StatisticValue
+		tolerance float64
+		want      []Correction
+	}{
+		{
+			name: "same energy with different sums",
+			stats: []ha.StatisticValue{
+				{Start: h(0), Sum: 1},
+				{Start: h(1), Sum: 2},
+				{Start: h(2), Sum: 4},
+			},
+		},
+		{
+			name: "revised hours",
+			stats: []ha.StatisticValue{
+				{Start: h(1), Sum: 1},
+				{Start: h(2), Sum: 2.5},
+				{Start: h(3), Sum: 3.5},
+				{Start: h(4), Sum: 5},
+			},
+			want: []Correction{
+				{Start: h(2), Adjustment: -0.5},
+				{Start: h(4), Adjustment: 0.5},
+			},
+		},
+		{
+			name: "within tolerance",
+			stats: []ha.StatisticValue{
+				{Start: h(1), Sum: 1},
+				{Start: h(2), Sum: 3.004},
+			},
+			tolerance: 0.005,
+		},
+		{
+			name: "hours missing in Home Assistant are not compared",
+			stats: []ha.StatisticValue{
+				{Start: h(4), Sum: 1},
+				{Start: h(5), Sum: 2},
+				{Start: h(6), Sum: 9},
+				{Start: h(7), Sum: 10},
+			},
+		},
+		{
+			name: "gaps in the statistics are not compared",
+			stats: []ha.StatisticValue{
+				{Start: h(0), Sum: 1},
+				{Start: h(2), Sum: 9},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Corrections(tt.stats, existing, tt.tolerance)
+			if diff := cmp.Diff(tt.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Corrections() unexpected diff (-want +got): %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package parse
+
+import (
+	"math"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+// Round rounds states and sums to the given number of decimals.
+//
+// Floating point additions leave artifacts like 1.2340000000000002,
+// which show up in Home Assistant graphs and exports, and accumulate
+// in the sums over the years.
+// States and sums are rounded independently, so the sums don't
+// accumulate the rounding errors of the states.
+// A negative number of decimals leaves the values untouched.
+func Round(stats []ha.StatisticValue, decimals int) {
+	if decimals < 0 {
+		return
+	}
+	p := math.Pow10(decimals)
+	round := func(v float64) float64 { return math.Round(v*p) / p }
+	for i := range stats {
+		stats[i].State = round(stats[i].State)
+		stats[i].Sum = round(stats[i].Sum)
+	}
+}
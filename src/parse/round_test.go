@@ -0,0 +1,59 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+func TestRound(t *testing.T) {
+	h := func(n int) time.Time { return time.Unix(int64(n)*3600, 0) }
+	tests := []struct {
+		name     string
+		decimals int
+		want     []ha.StatisticValue
+	}{
+		{
+			name:     "three decimals",
+			decimals: 3,
+			want: []ha.StatisticValue{
+				{Start: h(0), State: 1.234, Sum: 1.234},
+				{Start: h(1), State: 0.1, Sum: 1.334},
+				{Start: h(2), State: 0.001, Sum: 1.335},
+			},
+		},
+		{
+			name:     "integers",
+			decimals: 0,
+			want: []ha.StatisticValue{
+				{Start: h(0), State: 1, Sum: 1},
+				{Start: h(1), State: 0, Sum: 1},
+				{Start: h(2), State: 0, Sum: 1},
+			},
+		},
+		{
+			name:     "disabled",
+			decimals: -1,
+			want: []ha.StatisticValue{
+				{Start: h(0), State: 1.2340000000000002, Sum: 1.2340000000000002},
+				{Start: h(1), State: 0.1, Sum: 1.3340000000000003},
+				{Start: h(2), State: 0.0014, Sum: 1.3354000000000004},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := []ha.StatisticValue{
+				{Start: h(0), State: 1.2340000000000002, Sum: 1.2340000000000002},
+				{Start: h(1), State: 0.1, Sum: 1.3340000000000003},
+				{Start: h(2), State: 0.0014, Sum: 1.3354000000000004},
+			}
+			Round(stats, tc.decimals)
+			if diff := cmp.Diff(tc.want, stats); diff != "" {
+				t.Errorf("Round(%d) unexpected diff (+got -want): %v", tc.decimals, diff)
+			}
+		})
+	}
+}
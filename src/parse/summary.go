@@ -0,0 +1,86 @@
+package parse
+
+import "time"
+
+// Monthly aggregates the daily totals by calendar month.
+//
+// Days must be sorted as returned by Daily.
+func Monthly(days []DailyTotal) []CycleTotal {
+	calendar := BillingCycle{Start: time.Date(2000, 1, 1, 0, 0, 0, 0, irelandTimezone), Months: 1}
+	return calendar.Cycles(days)
+}
+
+// Weekly aggregates the daily totals by week, from Monday to Sunday.
+//
+// Days must be sorted as returned by Daily.
+func Weekly(days []DailyTotal) []CycleTotal {
+	var ret []CycleTotal
+	for _, d := range days {
+		date := d.Date.In(irelandTimezone)
+		// Sunday is 0, but it is the last day of the week.
+		from := date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+		if n := len(ret); n == 0 || !ret[n-1].From.Equal(from) {
+			ret = append(ret, CycleTotal{From: from, To: from.AddDate(0, 0, 7)})
+		}
+		c := &ret[len(ret)-1]
+		c.KWh += d.KWh
+		if d.Complete() {
+			c.Days++
+		}
+	}
+	return ret
+}
+
+// HourTotal is the average energy consumed in an hour of the day.
+type HourTotal struct {
+	// Hour is the hour of the day in Europe/Dublin timezone, from 0 to 23.
+	Hour int
+	// KWh is the average energy consumed in the hour.
+	KWh float64
+	// Days is the number of hours averaged, only the ones with both the
+	// reads count. It is one per day, but the hour repeated when the
+	// clock goes back.
+	Days int
+}
+
+// HourlyProfile returns the average energy consumed in every hour of the
+// day, the profile of a typical day.
+//
+// Reads are assigned to the hour when their half an hour period starts,
+// like Daily does with the days.
+func HourlyProfile(res []Result) []HourTotal {
+	type hour struct {
+		kwh   float64
+		reads int
+	}
+	// The reads of every hour of every day, to skip the partial hours.
+	hours := map[time.Time]hour{}
+	for _, r := range res {
+		q := r.Quantity()
+		for _, rd := range r.Reads {
+			start := rd.EndTime.Add(-30 * time.Minute).Truncate(time.Hour)
+			h := hours[start]
+			h.kwh += q.Amount(rd.Value)
+			h.reads++
+			hours[start] = h
+		}
+	}
+	ret := make([]HourTotal, 24)
+	for i := range ret {
+		ret[i].Hour = i
+	}
+	for start, h := range hours {
+		if h.reads != 2 {
+			continue
+		}
+		t := &ret[start.In(irelandTimezone).Hour()]
+		t.KWh += h.kwh
+		t.Days++
+	}
+	for i := range ret {
+		if ret[i].Days > 0 {
+			ret[i].KWh /= float64(ret[i].Days)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,66 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWeeklyMonthly(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, irelandTimezone) }
+	var days []DailyTotal
+	// From Thursday Feb 29 to Tuesday Mar 12.
+	for d := day(2, 29); d.Before(day(3, 13)); d = d.AddDate(0, 0, 1) {
+		days = append(days, DailyTotal{Date: d, KWh: 10, Reads: 48})
+	}
+	// Mar 2 misses an hour.
+	days[2].Reads = 46
+
+	weeks := Weekly(days)
+	wantWeeks := []CycleTotal{
+		{From: day(2, 26), To: day(3, 4), KWh: 40, Days: 3},
+		{From: day(3, 4), To: day(3, 11), KWh: 70, Days: 7},
+		{From: day(3, 11), To: day(3, 18), KWh: 20, Days: 2},
+	}
+	if diff := cmp.Diff(wantWeeks, weeks); diff != "" {
+		t.Errorf("Weekly() unexpected diff (+got -want): %v", diff)
+	}
+	if !weeks[1].Complete() || weeks[2].Complete() {
+		t.Errorf("Weekly() = %+v, want only the second week complete", weeks)
+	}
+
+	months := Monthly(days)
+	wantMonths := []CycleTotal{
+		{From: day(2, 1), To: day(3, 1), KWh: 10, Days: 1},
+		{From: day(3, 1), To: day(4, 1), KWh: 120, Days: 11},
+	}
+	if diff := cmp.Diff(wantMonths, months); diff != "" {
+		t.Errorf("Monthly() unexpected diff (+got -want): %v", diff)
+	}
+}
+
+func TestHourlyProfile(t *testing.T) {
+	gmt := time.FixedZone("GMT", 0)
+	read := func(v float64, d, h, m int) Read {
+		return Read{Value: v, EndTime: time.Date(2023, 01, d, h, m, 0, 0, gmt)}
+	}
+	res := []Result{{Reads: []Read{
+		// 07:00 of two days.
+		read(1, 15, 7, 30), read(3, 15, 8, 0),
+		read(3, 16, 7, 30), read(5, 16, 8, 0),
+		// Half of 08:00 only.
+		read(6, 16, 8, 30),
+	}}}
+
+	got := HourlyProfile(res)
+	if len(got) != 24 {
+		t.Fatalf("HourlyProfile() returned %d hours, want 24", len(got))
+	}
+	if diff := cmp.Diff(HourTotal{Hour: 7, KWh: 3, Days: 2}, got[7]); diff != "" {
+		t.Errorf("HourlyProfile()[7] unexpected diff (+got -want): %v", diff)
+	}
+	if diff := cmp.Diff(HourTotal{Hour: 8}, got[8]); diff != "" {
+		t.Errorf("HourlyProfile()[8] unexpected diff (+got -want): %v", diff)
+	}
+}
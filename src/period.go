@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// period is a range of whole days in Europe/Dublin, set by -from and
+// -to as YYYY-MM-DD. Both are optional, the zero value is all the data.
+type period struct {
+	from, to string
+}
+
+func (p *period) SetFlags(fs *flag.FlagSet) {
+	optionalStringVar(fs, &p.from, "from", "", "the first day of the reads to use, as YYYY-MM-DD, from the oldest one if not set")
+	optionalStringVar(fs, &p.to, "to", "", "the last day of the reads to use, as YYYY-MM-DD, until the newest one if not set")
+}
+
+func (p period) set() bool { return p.from != "" || p.to != "" }
+
+// times returns the start of the first day and the end of the last
+// one, zero when not set.
+func (p period) times() (from, to time.Time, err error) {
+	loc, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		return from, to, err
+	}
+	if p.from != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, p.from, loc); err != nil {
+			return from, to, fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if p.to != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, p.to, loc); err != nil {
+			return from, to, fmt.Errorf("invalid -to: %w", err)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, errors.New("-from must be before -to")
+	}
+	return from, to, nil
+}
+
+// filter returns the reads in the period.
+func (p period) filter(parsed []parse.Result) ([]parse.Result, error) {
+	if !p.set() {
+		return parsed, nil
+	}
+	from, to, err := p.times()
+	if err != nil {
+		return nil, err
+	}
+	return parse.Between(parsed, from, to), nil
+}
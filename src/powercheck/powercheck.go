@@ -0,0 +1,170 @@
+// Package powercheck reads the power outages reported by ESB PowerCheck.
+//
+// PowerCheck only publishes the current outages, to know about the past
+// ones they have to be recorded while they happen.
+package powercheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const outagesURL = "https://api.esb.ie/esbn/powercheck/v1.0/outages"
+
+// Outage is a power outage.
+type Outage struct {
+	ID   string
+	Type string
+	// Start is when the outage started.
+	Start time.Time
+	// EstimatedRestore is when ESB expects the power to be back.
+	EstimatedRestore time.Time
+	Lat, Lon         float64
+}
+
+// Client reads outages from PowerCheck.
+type Client struct {
+	hc      *http.Client
+	baseURL string
+	apiKey  string
+}
+
+// NewClient returns a new PowerCheck client.
+//
+// The API key is the one used by the PowerCheck website, it can be
+// found in the requests sent by the browser to api.esb.ie.
+func NewClient(apiKey string) *Client {
+	return &Client{
+		hc:      http.DefaultClient,
+		baseURL: outagesURL,
+		apiKey:  apiKey,
+	}
+}
+
+// Near returns the current outages within radiusKm from the point.
+func (c *Client) Near(ctx context.Context, lat, lon, radiusKm float64) ([]Outage, error) {
+	var list struct {
+		Outages []struct {
+			ID    string `json:"i"`
+			Type  string `json:"t"`
+			Point struct {
+				Coordinates string `json:"c"`
+			} `json:"p"`
+		} `json:"outageMessage"`
+	}
+	if err := c.get(ctx, c.baseURL, &list); err != nil {
+		return nil, err
+	}
+
+	var ret []Outage
+	for _, o := range list.Outages {
+		olat, olon, err := parseCoordinates(o.Point.Coordinates)
+		if err != nil {
+			continue // Better to miss an outage than failing for all of them.
+		}
+		if distanceKm(lat, lon, olat, olon) > radiusKm {
+			continue
+		}
+		out, err := c.details(ctx, o.ID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read outage %s: %w", o.ID, err)
+		}
+		out.Lat, out.Lon = olat, olon
+		if out.Type == "" {
+			out.Type = o.Type
+		}
+		ret = append(ret, out)
+	}
+	return ret, nil
+}
+
+// details reads the start and restore time of an outage.
+func (c *Client) details(ctx context.Context, id string) (Outage, error) {
+	var d struct {
+		ID             string `json:"outageId"`
+		Type           string `json:"outageType"`
+		StartTime      string `json:"startTime"`
+		EstRestoreTime string `json:"estRestoreTime"`
+	}
+	if err := c.get(ctx, c.baseURL+"/"+id, &d); err != nil {
+		return Outage{}, err
+	}
+	ret := Outage{ID: id, Type: d.Type}
+	var err error
+	if ret.Start, err = parseTime(d.StartTime); err != nil {
+		return ret, fmt.Errorf("invalid start time: %w", err)
+	}
+	// Restore time can be missing when ESB doesn't know yet.
+	ret.EstimatedRestore, _ = parseTime(d.EstRestoreTime)
+	return ret, nil
+}
+
+func (c *Client) get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("api-key", c.apiKey)
+	rsp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("PowerCheck status %v", rsp.Status)
+	}
+	return json.Unmarshal(b, v)
+}
+
+var dublin *time.Location
+
+func init() {
+	var err error
+	if dublin, err = time.LoadLocation("Europe/Dublin"); err != nil {
+		panic(err)
+	}
+}
+
+// parseTime parses PowerCheck timestamps, which are in Irish time without timezone.
+func parseTime(s string) (time.Time, error) {
+	for _, f := range []string{time.RFC3339, "2006-01-02T15:04:05", "02/01/2006 15:04"} {
+		if t, err := time.ParseInLocation(f, s, dublin); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", s)
+}
+
+func parseCoordinates(s string) (lat, lon float64, err error) {
+	slat, slon, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid coordinates %q", s)
+	}
+	if lat, err = strconv.ParseFloat(strings.TrimSpace(slat), 64); err != nil {
+		return 0, 0, err
+	}
+	if lon, err = strconv.ParseFloat(strings.TrimSpace(slon), 64); err != nil {
+		return 0, 0, err
+	}
+	return lat, lon, nil
+}
+
+// distanceKm returns the great-circle distance between two points.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+	dlat, dlon := rad(lat2-lat1), rad(lon2-lon1)
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
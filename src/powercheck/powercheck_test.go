@@ -0,0 +1,51 @@
+package powercheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNear(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("api-key"); got != "key" {
+			t.Errorf("got api-key %q, want key", got)
+		}
+		switch r.URL.Path {
+		case "/outages":
+			io.WriteString(w, `{"outageMessage":[
+				{"i":"1","t":"Fault","p":{"c":"53.35,-6.26"}},
+				{"i":"2","t":"Fault","p":{"c":"51.90,-8.47"}}
+			]}`)
+		case "/outages/1":
+			io.WriteString(w, `{"outageId":"1","outageType":"Fault","startTime":"2023-07-15T10:00:00","estRestoreTime":"2023-07-15T12:30:00"}`)
+		default:
+			t.Errorf("unexpected request %v", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{hc: srv.Client(), baseURL: srv.URL + "/outages", apiKey: "key"}
+	// Dublin city centre, the second outage is in Cork.
+	got, err := c.Near(context.Background(), 53.34, -6.26, 5)
+	if err != nil {
+		t.Fatalf("Near() unexpected error: %v", err)
+	}
+	want := []Outage{{
+		ID:               "1",
+		Type:             "Fault",
+		Start:            time.Date(2023, 07, 15, 10, 0, 0, 0, dublin),
+		EstimatedRestore: time.Date(2023, 07, 15, 12, 30, 0, 0, dublin),
+		Lat:              53.35,
+		Lon:              -6.26,
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Near() unexpected diff (+got -want): %v", diff)
+	}
+}
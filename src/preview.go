@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// preview prints, hour by hour, how importing the statistics would change
+// the ones in Home Assistant, without sending anything.
+func (c *uploadCmd) preview(ctx context.Context, conn *ha.Connection, stats ...ha.Statistics) error {
+	for _, stat := range stats {
+		if len(stat.Stats) == 0 {
+			continue
+		}
+		stat = c.toSend(stat)
+		id := stat.Metadata.StatisticID
+		from, to := stat.Stats[0].Start, stat.Stats[len(stat.Stats)-1].Start.Add(time.Hour)
+		existing, err := readAllStatistics(ctx, conn, id, from, to)
+		if err != nil {
+			return fmt.Errorf("cannot read statistics from Home Assistant: %w", err)
+		}
+
+		counts := map[parse.Change]int{}
+		for _, d := range parse.Diff(stat.Stats, existing) {
+			counts[d.Change]++
+			ts := d.Start.Local().Format("2006-01-02 15:04")
+			switch d.Change {
+			case parse.ChangeNew:
+				fmt.Printf("%s %s %-9s state %g, sum %g\n", id, ts, d.Change, d.New.State, d.New.Sum)
+			case parse.ChangeUpdate:
+				fmt.Printf("%s %s %-9s state %g -> %g, sum %g -> %g\n", id, ts, d.Change, d.Old.State, d.New.State, d.Old.Sum, d.New.Sum)
+			default:
+				fmt.Printf("%s %s %s\n", id, ts, d.Change)
+			}
+		}
+		fmt.Printf("%s: %d new, %d identical, %d changed hours\n", id, counts[parse.ChangeNew], counts[parse.ChangeNone], counts[parse.ChangeUpdate])
+	}
+	return nil
+}
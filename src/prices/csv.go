@@ -0,0 +1,77 @@
+package prices
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// csvHeader is the header of the price files read by ReadCSV.
+var csvHeader = []string{"start", "end", "eur_per_kwh"}
+
+// ReadCSV reads a price series, like the half hour prices of a dynamic
+// tariff exported from the supplier.
+//
+// The file has the header "start,end,eur_per_kwh", the times are in
+// RFC 3339 format, like 2024-01-15T17:00:00Z, and the periods must not
+// overlap. The lines can be in any order.
+func ReadCSV(r io.Reader) (Series, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	h, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read header: %w", err)
+	}
+	for i, want := range csvHeader {
+		if h[i] != want {
+			return nil, fmt.Errorf("invalid header: column %d is %q, want %q", i, h[i], want)
+		}
+	}
+
+	var ret Series
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		p, err := parseCSVPrice(rec)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ret = append(ret, p)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Start.Before(ret[j].Start) })
+	for i := 1; i < len(ret); i++ {
+		if ret[i].Start.Before(ret[i-1].End) {
+			return nil, fmt.Errorf("the prices at %v and %v overlap", ret[i-1].Start, ret[i].Start)
+		}
+	}
+	return ret, nil
+}
+
+func parseCSVPrice(rec []string) (Price, error) {
+	start, err := time.Parse(time.RFC3339, rec[0])
+	if err != nil {
+		return Price{}, fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := time.Parse(time.RFC3339, rec[1])
+	if err != nil {
+		return Price{}, fmt.Errorf("invalid end: %w", err)
+	}
+	if !end.After(start) {
+		return Price{}, fmt.Errorf("end %v is not after start %v", end, start)
+	}
+	eur, err := strconv.ParseFloat(rec[2], 64)
+	if err != nil {
+		return Price{}, fmt.Errorf("invalid price: %w", err)
+	}
+	return Price{Start: start, End: end, EURPerMWh: eur * 1000}, nil
+}
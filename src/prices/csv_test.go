@@ -0,0 +1,52 @@
+package prices
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadCSV(t *testing.T) {
+	data := `start,end,eur_per_kwh
+2024-01-15T17:30:00Z,2024-01-15T18:00:00Z,0.42
+2024-01-15T17:00:00Z,2024-01-15T17:30:00Z,0.35
+`
+	got, err := ReadCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadCSV() unexpected error: %v", err)
+	}
+	ts := func(h, m int) time.Time { return time.Date(2024, 1, 15, h, m, 0, 0, time.UTC) }
+	want := Series{
+		{Start: ts(17, 0), End: ts(17, 30), EURPerMWh: 350},
+		{Start: ts(17, 30), End: ts(18, 0), EURPerMWh: 420},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadCSV() unexpected diff (-want +got): %v", diff)
+	}
+	if p, ok := got.At(ts(17, 45)); !ok || p != 0.42 {
+		t.Errorf("At(17:45) = %v, %v, want 0.42, true", p, ok)
+	}
+}
+
+func TestReadCSV_Errors(t *testing.T) {
+	tests := []struct {
+		name, data string
+	}{
+		{"empty", ""},
+		{"wrong header", "from,to,price\n"},
+		{"invalid time", "start,end,eur_per_kwh\nyesterday,2024-01-15T18:00:00Z,0.4\n"},
+		{"invalid price", "start,end,eur_per_kwh\n2024-01-15T17:30:00Z,2024-01-15T18:00:00Z,cheap\n"},
+		{"end before start", "start,end,eur_per_kwh\n2024-01-15T18:00:00Z,2024-01-15T17:30:00Z,0.4\n"},
+		{"overlap", `start,end,eur_per_kwh
+2024-01-15T17:00:00Z,2024-01-15T18:00:00Z,0.4
+2024-01-15T17:30:00Z,2024-01-15T18:00:00Z,0.4
+`},
+	}
+	for _, tc := range tests {
+		if got, err := ReadCSV(strings.NewReader(tc.data)); err == nil {
+			t.Errorf("ReadCSV(%s) = %v, want error", tc.name, got)
+		}
+	}
+}
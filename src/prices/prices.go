@@ -0,0 +1,193 @@
+// Package prices downloads the day-ahead electricity prices.
+//
+// Prices are read from the ENTSO-E transparency platform, which
+// republishes the SEMOpx day-ahead auction results for Ireland, or
+// from a CSV file, see ReadCSV, or expanded from the time of use rates
+// of a supplier, see ReadRateSheet.
+package prices
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	entsoeURL = "https://web-api.tp.entsoe.eu/api"
+	// semDomain is the EIC code of the Irish Single Electricity Market bidding zone.
+	semDomain = "10Y1001A1001A59C"
+)
+
+// Price is the price of the electricity in a period.
+type Price struct {
+	Start, End time.Time
+	// EURPerMWh is the wholesale price, as published by the market.
+	EURPerMWh float64
+}
+
+// Series is a list of prices sorted by time.
+type Series []Price
+
+// At returns the price in EUR per kWh at the given time.
+func (s Series) At(t time.Time) (float64, bool) {
+	i := sort.Search(len(s), func(i int) bool { return s[i].End.After(t) })
+	if i == len(s) || s[i].Start.After(t) {
+		return 0, false
+	}
+	return s[i].EURPerMWh / 1000, true
+}
+
+// Client downloads prices from ENTSO-E.
+type Client struct {
+	hc      *http.Client
+	baseURL string
+	token   string
+}
+
+// NewClient returns a client using the ENTSO-E security token.
+//
+// The token can be requested for free registering on
+// https://transparency.entsoe.eu/ and asking for API access.
+func NewClient(token string) *Client {
+	return &Client{
+		hc:      http.DefaultClient,
+		baseURL: entsoeURL,
+		token:   token,
+	}
+}
+
+// DayAhead returns the day-ahead prices of the Irish market between from and to.
+//
+// ENTSO-E limits a request to one year, longer periods are split.
+func (c *Client) DayAhead(ctx context.Context, from, to time.Time) (Series, error) {
+	var ret Series
+	for start := from; start.Before(to); {
+		end := start.AddDate(1, 0, 0)
+		if end.After(to) {
+			end = to
+		}
+		s, err := c.dayAhead(ctx, start, end)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s...)
+		start = end
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Start.Before(ret[j].Start) })
+	return ret, nil
+}
+
+func (c *Client) dayAhead(ctx context.Context, from, to time.Time) (Series, error) {
+	const format = "200601021504"
+	q := url.Values{
+		"securityToken": {c.token},
+		"documentType":  {"A44"},
+		"in_Domain":     {semDomain},
+		"out_Domain":    {semDomain},
+		"periodStart":   {from.UTC().Format(format)},
+		"periodEnd":     {to.UTC().Format(format)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ENTSO-E status %v: %s", rsp.Status, reason(body))
+	}
+	return parseMarketDocument(body)
+}
+
+// reason extracts the error message from an ENTSO-E acknowledgement document.
+func reason(body []byte) string {
+	var ack struct {
+		Text string `xml:"Reason>text"`
+	}
+	if err := xml.Unmarshal(body, &ack); err != nil || ack.Text == "" {
+		return strings.TrimSpace(string(body))
+	}
+	return ack.Text
+}
+
+type marketDocument struct {
+	TimeSeries []struct {
+		Periods []struct {
+			Start      string `xml:"timeInterval>start"`
+			End        string `xml:"timeInterval>end"`
+			Resolution string `xml:"resolution"`
+			Points     []struct {
+				Position int     `xml:"position"`
+				Price    float64 `xml:"price.amount"`
+			} `xml:"Point"`
+		} `xml:"Period"`
+	} `xml:"TimeSeries"`
+}
+
+// parseMarketDocument parses a Publication_MarketDocument.
+func parseMarketDocument(body []byte) (Series, error) {
+	var doc marketDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("cannot parse ENTSO-E document: %w", err)
+	}
+
+	const timeFormat = "2006-01-02T15:04Z"
+	var ret Series
+	for _, ts := range doc.TimeSeries {
+		for _, p := range ts.Periods {
+			start, err := time.Parse(timeFormat, p.Start)
+			if err != nil {
+				return nil, err
+			}
+			end, err := time.Parse(timeFormat, p.End)
+			if err != nil {
+				return nil, err
+			}
+			res, err := parseResolution(p.Resolution)
+			if err != nil {
+				return nil, err
+			}
+			// Positions with the same price of the previous one may be
+			// omitted, so we fill the gaps with the last price seen.
+			prices := map[int]float64{}
+			for _, pt := range p.Points {
+				prices[pt.Position] = pt.Price
+			}
+			var last float64
+			for pos, t := 1, start; t.Before(end); pos, t = pos+1, t.Add(res) {
+				if v, ok := prices[pos]; ok {
+					last = v
+				} else if pos == 1 {
+					return nil, fmt.Errorf("missing first price of the period starting at %v", start)
+				}
+				ret = append(ret, Price{Start: t, End: t.Add(res), EURPerMWh: last})
+			}
+		}
+	}
+	return ret, nil
+}
+
+func parseResolution(r string) (time.Duration, error) {
+	switch r {
+	case "PT15M":
+		return 15 * time.Minute, nil
+	case "PT30M":
+		return 30 * time.Minute, nil
+	case "PT60M":
+		return time.Hour, nil
+	}
+	return 0, fmt.Errorf("unsupported resolution %q", r)
+}
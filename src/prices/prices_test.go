@@ -0,0 +1,51 @@
+package prices
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const document = `<?xml version="1.0" encoding="UTF-8"?>
+<Publication_MarketDocument xmlns="urn:iec62325.351:tc57wg16:451-3:publicationdocument:7:0">
+	<mRID>1</mRID>
+	<TimeSeries>
+		<mRID>1</mRID>
+		<currency_Unit.name>EUR</currency_Unit.name>
+		<Period>
+			<timeInterval>
+				<start>2023-01-14T23:00Z</start>
+				<end>2023-01-15T01:00Z</end>
+			</timeInterval>
+			<resolution>PT30M</resolution>
+			<Point><position>1</position><price.amount>100.5</price.amount></Point>
+			<Point><position>2</position><price.amount>90</price.amount></Point>
+			<Point><position>4</position><price.amount>80</price.amount></Point>
+		</Period>
+	</TimeSeries>
+</Publication_MarketDocument>`
+
+func TestParseMarketDocument(t *testing.T) {
+	got, err := parseMarketDocument([]byte(document))
+	if err != nil {
+		t.Fatalf("parseMarketDocument() unexpected error: %v", err)
+	}
+	ts := func(h, m int) time.Time { return time.Date(2023, 01, 14, h, m, 0, 0, time.UTC) }
+	want := Series{
+		{Start: ts(23, 0), End: ts(23, 30), EURPerMWh: 100.5},
+		{Start: ts(23, 30), End: ts(24, 0), EURPerMWh: 90},
+		{Start: ts(24, 0), End: ts(24, 30), EURPerMWh: 90}, // Omitted, same as before.
+		{Start: ts(24, 30), End: ts(25, 0), EURPerMWh: 80},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseMarketDocument() unexpected diff (+got -want): %v", diff)
+	}
+
+	if p, ok := got.At(ts(24, 10)); !ok || p != 0.09 {
+		t.Errorf("At(00:10) = %v, %v, want 0.09, true", p, ok)
+	}
+	if p, ok := got.At(ts(25, 0)); ok {
+		t.Errorf("At(01:00) = %v, %v, want not found", p, ok)
+	}
+}
@@ -0,0 +1,175 @@
+package prices
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// rateSheetHeader is the header of the rate sheets read by ReadRateSheet.
+var rateSheetHeader = []string{"valid_from", "days", "start", "end", "eur_per_kwh"}
+
+// rate is a line of a rate sheet.
+type rate struct {
+	validFrom time.Time
+	days      string
+	// start and end are offsets from midnight, end can be before
+	// start for rates crossing midnight.
+	start, end time.Duration
+	eurPerKWh  float64
+}
+
+// RateSheet is a time of use tariff, as published by the suppliers.
+type RateSheet struct {
+	rates []rate
+	loc   *time.Location
+}
+
+// ReadRateSheet reads a rate sheet in CSV format, with the header
+// "valid_from,days,start,end,eur_per_kwh".
+//
+// Every line is a rate valid from the given date (YYYY-MM-DD) until the
+// next valid_from, on all, weekdays or weekends days, from start to end
+// (HH:MM, end is excluded and 00:00 is midnight at the end of the day).
+// Rates can cross midnight, like a night rate from 23:00 to 08:00.
+// When more rates match, the first in the file wins, so narrow rates,
+// like the peak, can be listed before the day rate.
+//
+// Dates and times are in the local time of loc.
+func ReadRateSheet(r io.Reader, loc *time.Location) (*RateSheet, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(rateSheetHeader)
+	h, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read header: %w", err)
+	}
+	for i, want := range rateSheetHeader {
+		if h[i] != want {
+			return nil, fmt.Errorf("invalid header: column %d is %q, want %q", i, h[i], want)
+		}
+	}
+
+	ret := &RateSheet{loc: loc}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r, err := parseRate(rec, loc)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ret.rates = append(ret.rates, r)
+	}
+	if len(ret.rates) == 0 {
+		return nil, errors.New("no rates")
+	}
+	return ret, nil
+}
+
+// NewRateSheet returns the rate sheet with the given rates, each one
+// with the fields of a line of the files read by ReadRateSheet.
+func NewRateSheet(rates [][]string, loc *time.Location) (*RateSheet, error) {
+	ret := &RateSheet{loc: loc}
+	for i, rec := range rates {
+		if len(rec) != len(rateSheetHeader) {
+			return nil, fmt.Errorf("rate %d: got %d fields, want %d", i, len(rec), len(rateSheetHeader))
+		}
+		r, err := parseRate(rec, loc)
+		if err != nil {
+			return nil, fmt.Errorf("rate %d: %w", i, err)
+		}
+		ret.rates = append(ret.rates, r)
+	}
+	if len(ret.rates) == 0 {
+		return nil, errors.New("no rates")
+	}
+	return ret, nil
+}
+
+func parseRate(rec []string, loc *time.Location) (rate, error) {
+	var (
+		r   = rate{days: rec[1]}
+		err error
+	)
+	if r.validFrom, err = time.ParseInLocation(time.DateOnly, rec[0], loc); err != nil {
+		return r, fmt.Errorf("invalid valid_from: %w", err)
+	}
+	if r.days != "all" && r.days != "weekdays" && r.days != "weekends" {
+		return r, fmt.Errorf("invalid days %q, want all, weekdays or weekends", r.days)
+	}
+	if r.start, err = parseClock(rec[2]); err != nil {
+		return r, fmt.Errorf("invalid start: %w", err)
+	}
+	if r.end, err = parseClock(rec[3]); err != nil {
+		return r, fmt.Errorf("invalid end: %w", err)
+	}
+	if r.end == 0 {
+		r.end = 24 * time.Hour
+	}
+	if r.eurPerKWh, err = strconv.ParseFloat(rec[4], 64); err != nil {
+		return r, fmt.Errorf("invalid price: %w", err)
+	}
+	return r, nil
+}
+
+// parseClock parses HH:MM as offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// At returns the price in EUR per kWh at the given time.
+func (s *RateSheet) At(t time.Time) (float64, bool) {
+	t = t.In(s.loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.loc)
+	offset := t.Sub(midnight)
+	weekend := t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+
+	// The rates in force are the ones with the latest valid_from.
+	var current time.Time
+	for _, r := range s.rates {
+		if !r.validFrom.After(t) && r.validFrom.After(current) {
+			current = r.validFrom
+		}
+	}
+	for _, r := range s.rates {
+		if !r.validFrom.Equal(current) {
+			continue
+		}
+		if (r.days == "weekdays" && weekend) || (r.days == "weekends" && !weekend) {
+			continue
+		}
+		in := offset >= r.start && offset < r.end
+		if r.end <= r.start {
+			// Crossing midnight.
+			in = offset >= r.start || offset < r.end
+		}
+		if in {
+			return r.eurPerKWh, true
+		}
+	}
+	return 0, false
+}
+
+// Series returns the prices of every half an hour from from to to.
+//
+// Half hours without a rate are left out.
+func (s *RateSheet) Series(from, to time.Time) Series {
+	var ret Series
+	for t := from.Truncate(30 * time.Minute); t.Before(to); t = t.Add(30 * time.Minute) {
+		if p, ok := s.At(t); ok {
+			ret = append(ret, Price{Start: t, End: t.Add(30 * time.Minute), EURPerMWh: p * 1000})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,81 @@
+package prices
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRateSheet(t *testing.T) {
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("../../documentation/rate_sheet.example.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rs, err := ReadRateSheet(f, dublin)
+	if err != nil {
+		t.Fatalf("ReadRateSheet() unexpected error: %v", err)
+	}
+
+	ts := func(m time.Month, d, h, min int) time.Time { return time.Date(2024, m, d, h, min, 0, 0, dublin) }
+	tests := []struct {
+		name string
+		t    time.Time
+		want float64
+	}{
+		{"day", ts(1, 15, 12, 0), 0.3551},
+		{"peak before day", ts(1, 15, 17, 30), 0.4012},
+		{"night before midnight", ts(1, 15, 23, 30), 0.1805},
+		{"night after midnight", ts(1, 16, 7, 30), 0.1805},
+		{"day starts", ts(1, 16, 8, 0), 0.3551},
+		{"new prices", ts(10, 2, 12, 0), 0.3420},
+		{"weekend", ts(10, 5, 12, 0), 0.2950},
+		{"weekend night", ts(10, 5, 2, 0), 0.1740},
+		// Summer time, 12:00 in Dublin.
+		{"utc", time.Date(2024, 10, 2, 11, 0, 0, 0, time.UTC), 0.3420},
+	}
+	for _, tc := range tests {
+		if got, ok := rs.At(tc.t); !ok || got != tc.want {
+			t.Errorf("%s: At(%v) = %v, %v, want %v, true", tc.name, tc.t, got, ok, tc.want)
+		}
+	}
+
+	if _, ok := rs.At(ts(1, 1, 0, 0).Add(-time.Minute)); ok {
+		t.Error("At() before the first valid_from = true, want false")
+	}
+
+	s := rs.Series(ts(1, 15, 22, 0), ts(1, 16, 0, 0))
+	if len(s) != 4 {
+		t.Fatalf("Series() returned %d prices, want 4: %v", len(s), s)
+	}
+	if p, ok := s.At(ts(1, 15, 22, 45)); !ok || p != 0.3551 {
+		t.Errorf("Series().At(22:45) = %v, %v, want 0.3551, true", p, ok)
+	}
+	if p, ok := s.At(ts(1, 15, 23, 15)); !ok || p != 0.1805 {
+		t.Errorf("Series().At(23:15) = %v, %v, want 0.1805, true", p, ok)
+	}
+}
+
+func TestReadRateSheet_Errors(t *testing.T) {
+	tests := []struct {
+		name, data string
+	}{
+		{"empty", ""},
+		{"no rates", "valid_from,days,start,end,eur_per_kwh\n"},
+		{"wrong header", "from,days,start,end,price\n"},
+		{"invalid date", "valid_from,days,start,end,eur_per_kwh\n01/01/2024,all,08:00,23:00,0.35\n"},
+		{"invalid days", "valid_from,days,start,end,eur_per_kwh\n2024-01-01,mondays,08:00,23:00,0.35\n"},
+		{"invalid time", "valid_from,days,start,end,eur_per_kwh\n2024-01-01,all,8am,23:00,0.35\n"},
+		{"invalid price", "valid_from,days,start,end,eur_per_kwh\n2024-01-01,all,08:00,23:00,cheap\n"},
+	}
+	for _, tc := range tests {
+		if got, err := ReadRateSheet(strings.NewReader(tc.data), time.UTC); err == nil {
+			t.Errorf("ReadRateSheet(%s) = %v, want error", tc.name, got)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progress draws the progress of the downloads and of the uploads on
+// standard error, see setupProgress.
+var progress = &progressBar{}
+
+// progressRedraw is how often the bar is drawn again at most.
+const progressRedraw = 100 * time.Millisecond
+
+// progressBar is a single line on a terminal, rewritten in place.
+//
+// The text logs clear it before writing a message, the next update
+// draws it again below.
+type progressBar struct {
+	mu sync.Mutex
+	// w is nil when the bar is disabled.
+	w     io.Writer
+	drawn bool
+	last  time.Time
+}
+
+// setupProgress enables the bar if standard error is a terminal.
+func setupProgress(enabled bool) {
+	if !enabled {
+		return
+	}
+	if fi, err := os.Stderr.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	progress.w = os.Stderr
+}
+
+// download draws the bytes downloaded, total is -1 if unknown.
+func (p *progressBar) download(read, total int64) {
+	if total <= 0 {
+		p.draw(fmt.Sprintf("Downloading... %.1f MB", float64(read)/(1<<20)), false)
+		return
+	}
+	p.draw(fmt.Sprintf("Downloading %s %.1f/%.1f MB", bar(float64(read)/float64(total)), float64(read)/(1<<20), float64(total)/(1<<20)), read >= total)
+}
+
+// upload draws the batches of statistics written to Home Assistant.
+func (p *progressBar) upload(done, total int) {
+	p.draw(fmt.Sprintf("Uploading %s %d/%d batches", bar(float64(done)/float64(total)), done, total), done >= total)
+}
+
+// draw rewrites the line, unless it was drawn recently and it is not
+// the last update.
+func (p *progressBar) draw(line string, last bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.w == nil {
+		return
+	}
+	if now := time.Now(); last || now.Sub(p.last) >= progressRedraw {
+		p.last = now
+		fmt.Fprintf(p.w, "\r%s\033[K", line)
+		p.drawn = true
+	}
+}
+
+// clear deletes the line, if drawn.
+func (p *progressBar) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.w == nil || !p.drawn {
+		return
+	}
+	fmt.Fprint(p.w, "\r\033[K")
+	p.drawn = false
+}
+
+// bar returns a bar filled by the fraction, with the percentage.
+func bar(fraction float64) string {
+	const width = 30
+	fraction = min(max(fraction, 0), 1)
+	n := int(fraction * width)
+	return fmt.Sprintf("[%s%s] %3.0f%%", strings.Repeat("#", n), strings.Repeat(" ", width-n), fraction*100)
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/lorentz83/esb2ha/esblib"
+)
+
+// esb downloads the data from esbnetworks.ie, meters are identified by
+// their MPRN.
+type esb struct {
+	c *esblib.Client
+}
+
+func newESB(h Hooks) (Provider, error) {
+	c, err := esblib.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to ESB website: %w", err)
+	}
+	c.Hooks.OnLoginPhase = h.OnLoginPhase
+	c.Hooks.OnRetry = h.OnRetry
+	c.Hooks.OnDownloadProgress = h.OnDownloadProgress
+	// The downloads of many meters, or of a long history, outlast the
+	// login.
+	c.Relogin = true
+	return &esb{c}, nil
+}
+
+func (e *esb) Login(ctx context.Context, user, password string) error {
+	return e.c.Login(ctx, user, password)
+}
+
+func (e *esb) SetOneTimeCode(code func(kind string) (string, error)) {
+	e.c.OneTimeCode = code
+}
+
+func (e *esb) Session() ([]byte, error) {
+	return json.Marshal(e.c.Cookies())
+}
+
+func (e *esb) Resume(session []byte) error {
+	var cookies []*http.Cookie
+	if err := json.Unmarshal(session, &cookies); err != nil {
+		return fmt.Errorf("invalid session: %w", err)
+	}
+	e.c.SetCookies(cookies)
+	return nil
+}
+
+// ListMeters is not supported, the MPRNs are on the electricity bills.
+func (e *esb) ListMeters(context.Context) ([]string, error) {
+	return nil, fmt.Errorf("ESB cannot list the meters: %w", errors.ErrUnsupported)
+}
+
+func (e *esb) DownloadInterval(ctx context.Context, mprn string) (io.ReadCloser, error) {
+	return e.c.OpenPowerConsumption(ctx, mprn, esblib.FormatIntervalKW)
+}
@@ -0,0 +1,132 @@
+// Package provider abstracts the utility websites the consumption data
+// is downloaded from, so they can share the same parse and sink pipeline.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/lorentz83/esb2ha/esblib"
+)
+
+// Provider downloads smart meter data from a utility website.
+//
+// Implementations are not safe for concurrent calls.
+type Provider interface {
+	// Login authenticates the account, it must be called before the
+	// other methods.
+	Login(ctx context.Context, user, password string) error
+	// ListMeters returns the identifiers of the meters of the account.
+	//
+	// It returns an error wrapping errors.ErrUnsupported if the
+	// provider cannot list them.
+	ListMeters(ctx context.Context) ([]string, error)
+	// DownloadInterval streams the 30 minutes reads of the meter as an
+	// HDF file, the format understood by parse.HDF.
+	//
+	// The caller must close the returned reader.
+	DownloadInterval(ctx context.Context, meter string) (io.ReadCloser, error)
+}
+
+// RangeDownloader is implemented by the providers which can download
+// only the reads of a period, so a backfill doesn't download all the
+// history. The esb provider doesn't, the CSV download of ESB has no
+// period.
+type RangeDownloader interface {
+	// DownloadIntervalRange is like DownloadInterval, but only for the
+	// reads from from to to. A zero from is as far back as the
+	// provider goes, a zero to is now.
+	DownloadIntervalRange(ctx context.Context, meter string, from, to time.Time) (io.ReadCloser, error)
+}
+
+// Resumer is implemented by the providers whose login can be saved and
+// restored by another process, until it expires.
+type Resumer interface {
+	// Session returns the login, in a format only meant for Resume.
+	Session() ([]byte, error)
+	// Resume restores a login returned by Session, instead of calling
+	// Login. It doesn't check the login is still valid, the downloads
+	// fail with an error wrapping ErrLoginExpired if not.
+	Resume(session []byte) error
+}
+
+// MultiFactor is implemented by the providers whose accounts can have
+// multi-factor authentication.
+type MultiFactor interface {
+	// SetOneTimeCode sets how Login gets the one time code after the
+	// password, kind is esblib.MFAEmail or esblib.MFATOTP. Without it,
+	// Login fails with an error wrapping ErrMFARequired on those
+	// accounts.
+	SetOneTimeCode(code func(kind string) (string, error))
+}
+
+// ErrMFARequired is returned by Login when the account asks for a one
+// time code, but there is no way to get it, see MultiFactor.
+var ErrMFARequired = esblib.ErrMFARequired
+
+// ErrLoginExpired is wrapped by the errors of the downloads when the
+// login expired.
+var ErrLoginExpired = esblib.ErrLoginExpired
+
+// ErrInvalidCredentials is wrapped by the errors of Login when the
+// website refuses the user name or the password.
+var ErrInvalidCredentials = esblib.ErrInvalidCredentials
+
+// ErrMeterNotFound is wrapped by the errors of the downloads when the
+// meter is not linked to the account.
+var ErrMeterNotFound = esblib.ErrMPRNNotFound
+
+// ErrSiteChanged is wrapped by the errors caused by a website which is
+// not the one the provider knows anymore.
+var ErrSiteChanged = esblib.ErrPortalChanged
+
+// Hooks are optional callbacks reporting what the provider is doing.
+//
+// They are called synchronously, any of them can be nil.
+type Hooks struct {
+	// OnLoginPhase is called when a phase of the login starts, the
+	// phase names depend on the provider.
+	OnLoginPhase func(phase string)
+	// OnRetry is called when a request failed with a transient error,
+	// before waiting to retry it.
+	OnRetry func(err error, wait time.Duration)
+	// OnDownloadProgress is called while DownloadInterval is read, with
+	// the bytes read so far and the size of the file, -1 if unknown.
+	OnDownloadProgress func(read, total int64)
+}
+
+// providers are the known providers by name.
+var providers = map[string]func(Hooks) (Provider, error){
+	"esb": newESB,
+}
+
+// Default is the name of the provider used when none is configured.
+const Default = "esb"
+
+// New returns the provider with the given name.
+func New(name string, h Hooks) (Provider, error) {
+	f, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q, want one of %v", name, Names())
+	}
+	return f(h)
+}
+
+// Names returns the names of the known providers, sorted.
+func Names() []string {
+	ret := make([]string, 0, len(providers))
+	for n := range providers {
+		ret = append(ret, n)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Valid returns whether name is a known provider.
+func Valid(name string) bool {
+	return slices.Contains(Names(), name)
+}
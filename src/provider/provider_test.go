@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := New("nope", Hooks{}); err == nil {
+		t.Error(`New("nope") = nil error, want error`)
+	}
+
+	p, err := New(Default, Hooks{})
+	if err != nil {
+		t.Fatalf("New(%q) returned error: %v", Default, err)
+	}
+	if _, err := p.ListMeters(context.Background()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("ListMeters() = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, n := range Names() {
+		if !Valid(n) {
+			t.Errorf("Valid(%q) = false, want true", n)
+		}
+	}
+	if Valid("") {
+		t.Error(`Valid("") = true, want false`)
+	}
+}
+
+func TestResume(t *testing.T) {
+	for _, n := range Names() {
+		p, err := New(n, Hooks{})
+		if err != nil {
+			t.Fatalf("New(%q) returned error: %v", n, err)
+		}
+		r, ok := p.(Resumer)
+		if !ok {
+			continue
+		}
+		if err := r.Resume([]byte(`[{"Name":"session","Value":"abc"}]`)); err != nil {
+			t.Fatalf("%s: Resume() returned error: %v", n, err)
+		}
+		got, err := r.Session()
+		if err != nil {
+			t.Fatalf("%s: Session() returned error: %v", n, err)
+		}
+		if !strings.Contains(string(got), `"Value":"abc"`) {
+			t.Errorf("%s: Session() = %s, want the resumed cookie", n, got)
+		}
+		if err := r.Resume([]byte("nope")); err == nil {
+			t.Errorf("%s: Resume(nope) = nil error, want error", n)
+		}
+	}
+}
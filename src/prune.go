@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/store"
+)
+
+type pruneCmd struct {
+	storePath string
+
+	uploadsDays, outagesDays, publicationsDays, hoursDays, readsDays, archiveDays int
+}
+
+func (pruneCmd) Name() string { return "prune" }
+
+func (pruneCmd) Synopsis() string {
+	return "delete the old records of the local store"
+}
+
+func (pruneCmd) Usage() string {
+	return `prune -store <path> <flags>
+
+Deletes the records older than the given number of days and compacts the
+database file, 0 keeps them forever. With the global -archive_dir, it also
+deletes the archived downloads older than -keep_archive_days. Handy to run
+periodically on small SD cards.
+
+`
+}
+
+func (c *pruneCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.storePath, "store", "", "the path of the local database")
+	fs.IntVar(&c.uploadsDays, "keep_uploads_days", 0, "how many days of the audit log of the uploads to keep")
+	fs.IntVar(&c.outagesDays, "keep_outages_days", 0, "how many days of the outages to keep")
+	fs.IntVar(&c.publicationsDays, "keep_publications_days", 90, "how many days of the ESB publication times to keep, used by serve -schedule")
+	fs.IntVar(&c.hoursDays, "keep_hours_days", 0, "how many days of the uploaded hours to keep, older hours are uploaded again if still in the ESB file")
+	fs.IntVar(&c.readsDays, "keep_reads_days", 0, "how many days of the reads of the meters to keep, used by history and to find the revised reads")
+	fs.IntVar(&c.archiveDays, "keep_archive_days", 0, "how many days of the downloads archived in -archive_dir to keep")
+}
+
+func (c *pruneCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if c.archiveDays > 0 && archiveDir == "" {
+		slog.Error("-keep_archive_days requires the global -archive_dir")
+		return subcommands.ExitUsageError
+	}
+
+	st, err := store.Open(c.storePath)
+	if err != nil {
+		slog.Error("cannot open local store", "err", err)
+		return subcommands.ExitFailure
+	}
+	defer st.Close()
+
+	days := func(n int) time.Duration { return time.Duration(n) * 24 * time.Hour }
+	now := time.Now()
+	pruned, err := st.Prune(store.Retention{
+		Uploads:      days(c.uploadsDays),
+		Outages:      days(c.outagesDays),
+		Publications: days(c.publicationsDays),
+		Hours:        days(c.hoursDays),
+		Reads:        days(c.readsDays),
+	}, now)
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	if c.archiveDays > 0 {
+		n, err := pruneArchive(archiveDir, days(c.archiveDays), now)
+		if err != nil {
+			slog.Error("cannot prune the archive", "err", err)
+			return subcommands.ExitFailure
+		}
+		slog.Info("Deleted the old archived downloads", "files", n, "dir", archiveDir)
+	}
+
+	tables := make([]string, 0, len(pruned))
+	for t := range pruned {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	for _, t := range tables {
+		slog.Info("Deleted the old records", "records", pruned[t], "table", t)
+	}
+	return subcommands.ExitSuccess
+}
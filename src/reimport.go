@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/store"
+)
+
+// reimportWait is how long to wait for Home Assistant to apply the
+// changes, it clears and imports statistics in the background.
+const reimportWait = 30 * time.Second
+
+type reimportCmd struct {
+	ha      uploadCmd
+	confirm bool
+}
+
+func (reimportCmd) Name() string { return "reimport" }
+
+func (reimportCmd) Synopsis() string {
+	return "delete the statistics in Home Assistant and upload them again from ESB CSV files"
+}
+
+func (reimportCmd) Usage() string {
+	return `reimport -confirm <flags> [file.csv...]
+
+All the non optional flags are required, but can be provided as environment variables as well.
+The CSV files are read from the arguments, or from standard input if there are none.
+
+A recovery path after a corrupted import: it deletes all the statistics of
+-ha_sensor (and -ha_cost_sensor) in Home Assistant and in the local store,
+then uploads all the reads in the files with a single consistent sum, and
+checks that Home Assistant has them.
+Files can overlap, like the ones downloaded over the years or exported by
+dump-ha, the reads of the later files win.
+
+`
+}
+
+func (c *reimportCmd) SetFlags(fs *flag.FlagSet) {
+	c.ha.SetFlags(fs)
+	fs.BoolVar(&c.confirm, "confirm", false, "confirm that the statistics in Home Assistant can be deleted")
+}
+
+func (c *reimportCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if !c.confirm {
+		slog.Error("reimport deletes all the statistics of the sensor in Home Assistant, add -confirm to proceed", "sensor", c.ha.sensor)
+		return subcommands.ExitUsageError
+	}
+	if c.ha.period.set() {
+		slog.Error("reimport replaces all the statistics, -from and -to would drop the others")
+		return subcommands.ExitUsageError
+	}
+
+	parsed, err := readFiles(ctx, f.Args())
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	if err := c.reimport(ctx, parsed); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// readFiles parses and merges the files, or standard input if there are
+// none. The files ending with .gz are decompressed.
+func readFiles(ctx context.Context, paths []string) ([]parse.Result, error) {
+	if len(paths) == 0 {
+		slog.Info("Reading from stdin...")
+		return parseHDF(ctx, os.Stdin)
+	}
+	var files [][]parse.Result
+	for _, p := range paths {
+		res, err := func() ([]parse.Result, error) {
+			f, err := openArchived(p)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return parseHDF(ctx, f)
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		files = append(files, res)
+	}
+	return mergeFiles(files)
+}
+
+// mergeFiles merges the files like parse.Merge, keeping the energy
+// exported to the grid apart from the imported one.
+func mergeFiles(files [][]parse.Result) ([]parse.Result, error) {
+	var imported, exported [][]parse.Result
+	for _, f := range files {
+		i, e := parse.SplitExport(f)
+		imported, exported = append(imported, i), append(exported, e)
+	}
+	ret, err := parse.Merge(imported...)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parse.Merge(exported...)
+	if err != nil {
+		return nil, err
+	}
+	return append(ret, e...), nil
+}
+
+// inputFiles returns the CSV files of -input: the file, the .csv and
+// .csv.gz files in the directory or the files matching the glob
+// pattern, sorted by name.
+func inputFiles(input string) ([]string, error) {
+	var paths []string
+	if strings.ContainsAny(input, "*?[") {
+		var err error
+		if paths, err = filepath.Glob(input); err != nil {
+			return nil, fmt.Errorf("invalid -input: %w", err)
+		}
+	} else if st, err := os.Stat(input); err != nil {
+		return nil, err
+	} else if !st.IsDir() {
+		return []string{input}, nil
+	} else {
+		entries, err := os.ReadDir(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if name := e.Name(); !e.IsDir() && (strings.HasSuffix(name, ".csv") || strings.HasSuffix(name, ".csv.gz")) {
+				paths = append(paths, filepath.Join(input, name))
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no CSV files in %s", input)
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+func (c *reimportCmd) reimport(ctx context.Context, parsed []parse.Result) error {
+	up := c.ha
+	// Only the imported energy is reimported.
+	parsed, _ = parse.SplitExport(parsed)
+	parsed = up.fill(parsed)
+	// Already filled, and every chunk must continue the sum of the previous one.
+	up.fillGaps, up.missingOnly, up.forceUpload = 0, true, true
+
+	want, err := c.expected(parsed)
+	if err != nil {
+		return err
+	}
+	if want.hours == 0 {
+		return errors.New("nothing to upload")
+	}
+
+	ids := []string{up.sensor}
+	up.pairCost()
+	if up.costSensor != "" {
+		ids = append(ids, up.costSensor)
+	}
+	if err := c.clear(ctx, ids, want.from, want.to); err != nil {
+		return err
+	}
+
+	if up.uploadAll(ctx, parsed) != subcommands.ExitSuccess {
+		return errors.New("upload failed, run reimport again")
+	}
+	return c.verify(ctx, want)
+}
+
+// reimported is what Home Assistant must have after the reimport.
+type reimported struct {
+	// from and to are the start of the first and last hour.
+	from, to time.Time
+	hours    int
+	kWh      float64
+}
+
+// expected returns the hours and the energy to upload.
+func (c *reimportCmd) expected(parsed []parse.Result) (reimported, error) {
+	opts, err := c.ha.translateOptions()
+	if err != nil {
+		return reimported{}, err
+	}
+	var ret reimported
+	for _, r := range parsed {
+		stat, err := parse.Translate(r, opts)
+		var skipped *parse.SkippedError
+		if errors.As(err, &skipped) {
+			continue
+		}
+		if err != nil {
+			return ret, fmt.Errorf("cannot parse data: %w", err)
+		}
+		if ret.hours == 0 {
+			ret.from = stat.Stats[0].Start
+		}
+		ret.to = stat.Stats[len(stat.Stats)-1].Start
+		ret.hours += len(stat.Stats)
+		for _, v := range stat.Stats {
+			ret.kWh += v.State
+		}
+	}
+	return ret, nil
+}
+
+// clear deletes the statistics in Home Assistant and in the local store.
+func (c *reimportCmd) clear(ctx context.Context, ids []string, from, to time.Time) error {
+	conn, err := ha.NewConnection(ctx, c.ha.server, c.ha.token)
+	if err != nil {
+		return fmt.Errorf("cannot connect to Home Assistant: %w", err)
+	}
+	defer conn.Close()
+
+	slog.Info("Deleting the statistics...", "ids", ids)
+	if err := conn.ClearStatistics(ctx, ids...); err != nil {
+		return fmt.Errorf("cannot delete statistics: %w", err)
+	}
+	if c.ha.storePath != "" {
+		st, err := store.Open(c.ha.storePath)
+		if err != nil {
+			return fmt.Errorf("cannot open local store: %w", err)
+		}
+		defer st.Close()
+		for _, id := range ids {
+			if err := st.ForgetStatistic(id); err != nil {
+				return fmt.Errorf("cannot delete %s from the local store: %w", id, err)
+			}
+		}
+	}
+
+	// Uploading before the deletion is applied would continue the old sums.
+	for deadline := time.Now().Add(reimportWait); ; {
+		ok, err := hasStatistics(ctx, conn, c.ha.sensor, from, to.Add(time.Hour))
+		if err != nil {
+			return fmt.Errorf("cannot read statistics: %w", err)
+		}
+		if !ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Home Assistant didn't delete %s after %v", c.ha.sensor, reimportWait)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// verify checks that Home Assistant has all the hours, with the sum
+// starting from zero and never going back.
+func (c *reimportCmd) verify(ctx context.Context, want reimported) error {
+	conn, err := ha.NewConnection(ctx, c.ha.server, c.ha.token)
+	if err != nil {
+		return fmt.Errorf("cannot connect to Home Assistant: %w", err)
+	}
+	defer conn.Close()
+
+	var problem error
+	for deadline := time.Now().Add(reimportWait); ; {
+		got, err := readAllStatistics(ctx, conn, c.ha.sensor, want.from, want.to.Add(time.Hour))
+		if err != nil {
+			return fmt.Errorf("cannot read statistics: %w", err)
+		}
+		if problem = checkReimport(got, want); problem == nil {
+			slog.Info("Verified the reimport", "hours", want.hours, "kwh", math.Round(want.kWh*1000)/1000)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("verification failed: %w", problem)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// readAllStatistics reads the statistics a year at a time, to keep the
+// Home Assistant responses small.
+func readAllStatistics(ctx context.Context, conn *ha.Connection, id string, from, to time.Time) ([]ha.StatisticValue, error) {
+	var ret []ha.StatisticValue
+	for start := from; start.Before(to); start = start.AddDate(1, 0, 0) {
+		end := start.AddDate(1, 0, 0)
+		if end.After(to) {
+			end = to
+		}
+		stats, err := conn.Statistics(ctx, id, start, end)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, stats...)
+	}
+	return ret, nil
+}
+
+// hasStatistics reports whether Home Assistant has any statistic between
+// from and to, reading a year at a time like readAllStatistics.
+func hasStatistics(ctx context.Context, conn *ha.Connection, id string, from, to time.Time) (bool, error) {
+	for start := from; start.Before(to); start = start.AddDate(1, 0, 0) {
+		end := start.AddDate(1, 0, 0)
+		if end.After(to) {
+			end = to
+		}
+		_, ok, err := conn.LastStatistic(ctx, id, start, end)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+func checkReimport(got []ha.StatisticValue, want reimported) error {
+	if len(got) != want.hours {
+		return fmt.Errorf("Home Assistant has %d hours, want %d", len(got), want.hours)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Sum < got[i-1].Sum {
+			return fmt.Errorf("the sum goes back at %s", got[i].Start)
+		}
+	}
+	// Tolerate the rounding of -precision.
+	if last := got[len(got)-1].Sum; math.Abs(last-want.kWh) > 0.01 {
+		return fmt.Errorf("the sum is %.3f, want %.3f", last, want.kWh)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestInputFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2023.csv", "2024.csv.gz", "notes.txt", "2022.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "old.csv"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	empty := t.TempDir()
+	path := func(names ...string) []string {
+		var ret []string
+		for _, n := range names {
+			ret = append(ret, filepath.Join(dir, n))
+		}
+		return ret
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "file", input: filepath.Join(dir, "notes.txt"), want: path("notes.txt")},
+		{name: "directory", input: dir, want: path("2022.csv", "2023.csv", "2024.csv.gz")},
+		{name: "glob", input: filepath.Join(dir, "202[34]*"), want: path("2023.csv", "2024.csv.gz")},
+		{name: "glob without matches", input: filepath.Join(dir, "*.json"), wantErr: true},
+		{name: "directory without CSV", input: empty, wantErr: true},
+		{name: "missing", input: filepath.Join(dir, "missing.csv"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inputFiles(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("inputFiles(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("inputFiles(%q) unexpected diff (+got -want): %v", tt.input, diff)
+			}
+		})
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	h := func(n int) time.Time {
+		return time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC).Add(time.Duration(n) * 30 * time.Minute)
+	}
+	const (
+		imported = "Active Import Interval (kW)"
+		exported = "Active Export Interval (kW)"
+	)
+	result := func(mprn, readTypes string, value float64, from, to int) parse.Result {
+		r := parse.Result{MPRN: mprn, ReadTypes: readTypes}
+		for i := from; i <= to; i++ {
+			r.Reads = append(r.Reads, parse.Read{Value: value, EndTime: h(i)})
+		}
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		files   [][]parse.Result
+		want    []parse.Result
+		wantErr bool
+	}{
+		{
+			// The reads of the later file win.
+			name: "overlapping",
+			files: [][]parse.Result{
+				{result("1", imported, 1, 1, 4)},
+				{result("1", imported, 2, 3, 6)},
+			},
+			want: []parse.Result{{MPRN: "1", ReadTypes: imported, Reads: []parse.Read{
+				{Value: 1, EndTime: h(1)}, {Value: 1, EndTime: h(2)},
+				{Value: 2, EndTime: h(3)}, {Value: 2, EndTime: h(4)}, {Value: 2, EndTime: h(5)}, {Value: 2, EndTime: h(6)},
+			}}},
+		},
+		{
+			// The exported energy is merged apart, after the imported one.
+			name: "exported",
+			files: [][]parse.Result{
+				{result("1", imported, 1, 1, 2), result("1", exported, 3, 1, 2)},
+				{result("1", imported, 1, 3, 4)},
+			},
+			want: []parse.Result{
+				result("1", imported, 1, 1, 4),
+				result("1", exported, 3, 1, 2),
+			},
+		},
+		{
+			name: "mixed MPRN",
+			files: [][]parse.Result{
+				{result("1", imported, 1, 1, 2)},
+				{result("2", imported, 1, 3, 4)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeFiles(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mergeFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mergeFiles() unexpected diff (+got -want): %v", diff)
+			}
+		})
+	}
+}
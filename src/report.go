@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+type reportCmd struct {
+	esb      downloadCmd
+	download bool
+	format   string
+	// by is the comma separated list of the totals, see groupings.
+	by string
+}
+
+func (reportCmd) Name() string { return "report" }
+
+func (reportCmd) Synopsis() string {
+	return "report the electricity usage by day, week and month, and the profile of a typical day"
+}
+
+func (reportCmd) Usage() string {
+	return `report <flags> [file.csv...]
+
+The CSV files are read from the arguments, or from standard input if there are none.
+With -download the data is downloaded from ESB instead, and the ESB flags are required.
+
+Prints:
+ - the energy used in every day, week (from Monday) or month of the
+   data, as set by -by; the periods missing some data are marked with *;
+ - the complete days with the lowest and the highest usage;
+ - the average energy used in every hour of the day.
+With -from and -to only the days in the period are reported.
+With -format=csv or -format=json the report is for scripts and
+spreadsheets: the CSV file has a row per total, the section column
+tells day, week, month, lowest_day, highest_day or hour.
+
+`
+}
+
+func (c *reportCmd) SetFlags(fs *flag.FlagSet) {
+	c.esb.SetFlags(fs)
+	c.esb.period.SetFlags(fs)
+	fs.BoolVar(&c.download, "download", false, "download the data from ESB instead of reading the files")
+	fs.StringVar(&c.format, "format", "text", "the format of the report, text, csv or json")
+	fs.StringVar(&c.by, "by", "week,month", "the totals to report, a comma separated list of day, week and month")
+}
+
+// grouping is a total report can print, selected by -by.
+type grouping struct {
+	name  string
+	total func([]parse.DailyTotal) []parse.CycleTotal
+}
+
+// groupings are the totals report prints, in this order.
+var groupings = []grouping{
+	{"day", dailyTotals},
+	{"week", parse.Weekly},
+	{"month", parse.Monthly},
+}
+
+// dailyTotals returns the days as periods, to be printed like the
+// other totals.
+func dailyTotals(days []parse.DailyTotal) []parse.CycleTotal {
+	var ret []parse.CycleTotal
+	for _, d := range days {
+		t := parse.CycleTotal{From: d.Date, To: d.Date.AddDate(0, 0, 1), KWh: d.KWh}
+		if d.Complete() {
+			t.Days = 1
+		}
+		ret = append(ret, t)
+	}
+	return ret
+}
+
+func (c *reportCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.download {
+		// The ESB flags are only needed to download.
+		for _, name := range []string{"esb_user", "esb_password", "mprn"} {
+			optionalFlags[name] = true
+		}
+	}
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if c.format != "text" && c.format != "csv" && c.format != "json" {
+		slog.Error("unknown format", "format", c.format)
+		return subcommands.ExitUsageError
+	}
+	for _, by := range strings.Split(c.by, ",") {
+		if !slices.ContainsFunc(groupings, func(g grouping) bool { return g.name == strings.TrimSpace(by) }) {
+			slog.Error("unknown total in -by", "by", by)
+			return subcommands.ExitUsageError
+		}
+	}
+	if c.download && (f.NArg() > 0 || len(c.esb.mprns()) != 1) {
+		slog.Error("with -download, set a single -mprn and no files")
+		return subcommands.ExitUsageError
+	}
+	if _, _, err := c.esb.period.times(); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	parsed, err := c.read(ctx, f.Args())
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	if err := c.report(parsed, os.Stdout); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// read returns the reads of the energy imported in the period, from the
+// files or ESB.
+func (c *reportCmd) read(ctx context.Context, paths []string) ([]parse.Result, error) {
+	var parsed []parse.Result
+	var err error
+	if c.download {
+		var body io.ReadCloser
+		if body, err = c.esb.open(ctx); err != nil {
+			return nil, fmt.Errorf("cannot download: %w", err)
+		}
+		parsed, _, err = parseDownload(ctx, body)
+	} else {
+		parsed, err = readFiles(ctx, paths)
+	}
+	if err != nil {
+		return nil, err
+	}
+	imported, _ := parse.SplitExport(parsed)
+	return c.esb.period.filter(imported)
+}
+
+// summaryReport is what report prints.
+type summaryReport struct {
+	// Totals are the totals set by -by, by grouping name.
+	Totals  map[string][]periodReport `json:"totals"`
+	Lowest  *periodReport             `json:"lowest_day,omitempty"`
+	Highest *periodReport             `json:"highest_day,omitempty"`
+	Profile []hourReport              `json:"hourly_profile"`
+}
+
+// periodReport is the energy used in some days, To is the last day.
+type periodReport struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	KWh      float64 `json:"kwh"`
+	Days     int     `json:"days"`
+	Complete bool    `json:"complete"`
+}
+
+func newPeriodReport(t parse.CycleTotal) periodReport {
+	return periodReport{
+		From:     t.From.Format(time.DateOnly),
+		To:       t.To.AddDate(0, 0, -1).Format(time.DateOnly),
+		KWh:      t.KWh,
+		Days:     t.Days,
+		Complete: t.Complete(),
+	}
+}
+
+// hourReport is the average energy used in an hour of the day.
+type hourReport struct {
+	Hour string  `json:"hour"`
+	KWh  float64 `json:"kwh"`
+	Days int     `json:"days"`
+}
+
+func (c *reportCmd) summary(parsed []parse.Result) (summaryReport, error) {
+	days := parse.Daily(parsed)
+	if len(days) == 0 {
+		return summaryReport{}, errors.New("no data")
+	}
+	ret := summaryReport{Totals: map[string][]periodReport{}}
+	for _, g := range groupings {
+		if !c.groupedBy(g.name) {
+			continue
+		}
+		ret.Totals[g.name] = []periodReport{}
+		for _, t := range g.total(days) {
+			ret.Totals[g.name] = append(ret.Totals[g.name], newPeriodReport(t))
+		}
+	}
+	for _, d := range dailyTotals(days) {
+		if !d.Complete() {
+			continue
+		}
+		r := newPeriodReport(d)
+		if ret.Lowest == nil || r.KWh < ret.Lowest.KWh {
+			ret.Lowest = &r
+		}
+		if ret.Highest == nil || r.KWh > ret.Highest.KWh {
+			ret.Highest = &r
+		}
+	}
+	for _, h := range parse.HourlyProfile(parsed) {
+		ret.Profile = append(ret.Profile, hourReport{Hour: fmt.Sprintf("%02d:00", h.Hour), KWh: h.KWh, Days: h.Days})
+	}
+	return ret, nil
+}
+
+// groupedBy returns if -by includes the grouping.
+func (c *reportCmd) groupedBy(name string) bool {
+	for _, by := range strings.Split(c.by, ",") {
+		if strings.TrimSpace(by) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *reportCmd) report(parsed []parse.Result, out io.Writer) error {
+	s, err := c.summary(parsed)
+	if err != nil {
+		return err
+	}
+	switch c.format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	case "csv":
+		return c.writeCSV(out, s)
+	}
+	return c.writeText(out, s)
+}
+
+func (c *reportCmd) writeCSV(out io.Writer, s summaryReport) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"section", "from", "to", "kwh", "days", "complete"})
+	row := func(section string, p periodReport) {
+		w.Write([]string{section, p.From, p.To, fmt.Sprintf("%.3f", p.KWh), fmt.Sprint(p.Days), fmt.Sprint(p.Complete)})
+	}
+	for _, g := range groupings {
+		for _, p := range s.Totals[g.name] {
+			row(g.name, p)
+		}
+	}
+	if s.Lowest != nil {
+		row("lowest_day", *s.Lowest)
+		row("highest_day", *s.Highest)
+	}
+	for i, h := range s.Profile {
+		to := s.Profile[(i+1)%len(s.Profile)].Hour
+		w.Write([]string{"hour", h.Hour, to, fmt.Sprintf("%.3f", h.KWh), fmt.Sprint(h.Days), fmt.Sprint(h.Days > 0)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (c *reportCmd) writeText(out io.Writer, s summaryReport) error {
+	titles := map[string]string{"day": "Days", "week": "Weeks", "month": "Months"}
+	for _, g := range groupings {
+		totals, ok := s.Totals[g.name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(out, "%s:\n", titles[g.name])
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FROM\tTO\tDAYS\tKWH")
+		for _, p := range totals {
+			days := fmt.Sprint(p.Days)
+			if !p.Complete {
+				days += "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.3f\n", p.From, p.To, days, p.KWh)
+		}
+		w.Flush()
+		fmt.Fprintln(out)
+	}
+
+	if s.Lowest == nil {
+		fmt.Fprintln(out, "No complete days in the data")
+	} else {
+		fmt.Fprintf(out, "Lowest day:  %s, %.3f kWh\n", s.Lowest.From, s.Lowest.KWh)
+		fmt.Fprintf(out, "Highest day: %s, %.3f kWh\n", s.Highest.From, s.Highest.KWh)
+	}
+	fmt.Fprintln(out)
+
+	fmt.Fprintln(out, "Average day:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOUR\tDAYS\tKWH")
+	for _, h := range s.Profile {
+		fmt.Fprintf(w, "%s\t%d\t%.3f\n", h.Hour, h.Days, h.KWh)
+	}
+	return w.Flush()
+}
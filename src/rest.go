@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// meter is a smart meter as exposed by the REST API.
+type meter struct {
+	MPRN   string `json:"mprn"`
+	Sensor string `json:"sensor"`
+}
+
+// newRESTHandler returns the handler of the REST API.
+//
+// If token is not empty, all the requests but the OpenAPI spec require
+// it as bearer token.
+func newRESTHandler(svc *service, token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(openAPISpec)
+	})
+
+	mux.Handle("/", requireToken(token, newAPIMux(svc)))
+	return mux
+}
+
+// newAPIMux returns the routes of the API, the ones documented in
+// openapi.yaml.
+func newAPIMux(svc *service) *http.ServeMux {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /meters", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []meter{{MPRN: svc.esb.mprn, Sensor: svc.ha.sensor}})
+	})
+	api.HandleFunc("GET /readings", func(w http.ResponseWriter, r *http.Request) {
+		body, err := svc.open(r.Context(), r.URL.Query().Get("mprn"))
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		defer body.Close()
+		parsed, err := readRawHDF(body)
+		if err != nil {
+			// Also the errors of the download, the file is parsed
+			// while it is downloaded.
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, parsed)
+	})
+	api.HandleFunc("POST /sync", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		run, err := svc.sync(r.Context(), q.Get("mprn"), q.Get("sensor"), func(ha.Statistics, error) error { return nil })
+		switch {
+		case errors.Is(err, errSyncInProgress):
+			writeError(w, http.StatusConflict, err)
+			return
+		case errors.Is(err, errShuttingDown):
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		code := http.StatusOK
+		if run.Error != "" {
+			code = http.StatusBadGateway
+		}
+		writeJSON(w, code, run)
+	})
+	api.HandleFunc("GET /runs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, svc.history())
+	})
+	api.Handle("GET /metrics", promMetrics)
+	return api
+}
+
+// requireToken wraps the handler to check the bearer token.
+func requireToken(token string, h http.Handler) http.Handler {
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing token"})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/parse"
+	"go.yaml.in/yaml/v3"
+)
+
+// openAPI is the part of openapi.yaml checked against the code.
+type openAPI struct {
+	Paths      map[string]map[string]any `yaml:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]any `yaml:"properties"`
+		} `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+func loadOpenAPI(t *testing.T) openAPI {
+	t.Helper()
+	var spec openAPI
+	if err := yaml.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("cannot parse openapi.yaml: %v", err)
+	}
+	return spec
+}
+
+// jsonFields returns the names of the fields of the JSON encoding of the
+// struct type, also the omitempty ones, sorted.
+func jsonFields(typ reflect.Type) []string {
+	var ret []string
+	for f := range typ.Fields() {
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		ret = append(ret, name)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func TestOpenAPI_Schemas(t *testing.T) {
+	spec := loadOpenAPI(t)
+
+	rec := httptest.NewRecorder()
+	writeError(rec, http.StatusInternalServerError, errors.New("error"))
+	var served map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("cannot decode the error: %v", err)
+	}
+	var errorFields []string
+	for k := range served {
+		errorFields = append(errorFields, k)
+	}
+
+	want := map[string][]string{
+		"Error":  errorFields,
+		"Meter":  jsonFields(reflect.TypeFor[meter]()),
+		"Result": jsonFields(reflect.TypeFor[parse.Result]()),
+		"Read":   jsonFields(reflect.TypeFor[parse.Read]()),
+		"Run":    jsonFields(reflect.TypeFor[run]()),
+	}
+	got := map[string][]string{}
+	for name, s := range spec.Components.Schemas {
+		var props []string
+		for p := range s.Properties {
+			props = append(props, p)
+		}
+		slices.Sort(props)
+		got[name] = props
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("openapi.yaml schemas unexpected diff (-served +documented): %v", diff)
+	}
+}
+
+func TestOpenAPI_Paths(t *testing.T) {
+	spec := loadOpenAPI(t)
+	api := newAPIMux(nil)
+
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			req := httptest.NewRequest(strings.ToUpper(method), path, nil)
+			if _, pattern := api.Handler(req); pattern != strings.ToUpper(method)+" "+path {
+				t.Errorf("%s %s is documented, but served by %q", strings.ToUpper(method), path, pattern)
+			}
+		}
+	}
+}
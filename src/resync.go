@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+type resyncCmd struct {
+	ha uploadCmd
+}
+
+func (resyncCmd) Name() string { return "resync" }
+
+func (resyncCmd) Synopsis() string {
+	return "correct the sums in Home Assistant of the hours revised by ESB"
+}
+
+func (resyncCmd) Usage() string {
+	return `resync <flags> [file.csv...]
+
+All the non optional flags are required, but can be provided as environment variables as well.
+The CSV files are read from the arguments, or from standard input if there are none.
+
+ESB sometimes revises the reads of past hours. Uploading them again
+overwrites the hours, but the sums of the following hours in Home
+Assistant still count the old energy.
+resync compares the energy of every hour in the files with the one in
+Home Assistant and adjusts the sum of -ha_sensor from each revised hour
+on, like "Adjust a statistic" in the developer tools, so nothing is
+counted twice. Only the energy sensor is corrected.
+With -preview_diff the corrections are printed without applying them.
+
+`
+}
+
+func (c *resyncCmd) SetFlags(fs *flag.FlagSet) {
+	c.ha.SetFlags(fs)
+}
+
+func (c *resyncCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	parsed, err := readFiles(ctx, f.Args())
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	if parsed, err = c.ha.period.filter(parsed); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if err := c.resync(ctx, parsed); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *resyncCmd) resync(ctx context.Context, parsed []parse.Result) error {
+	// Only the imported energy is uploaded to -ha_sensor.
+	parsed, _ = parse.SplitExport(parsed)
+	opts, err := c.ha.translateOptions()
+	if err != nil {
+		return err
+	}
+	// Both sums are rounded to -precision.
+	var tolerance float64
+	if c.ha.precision >= 0 {
+		tolerance = math.Pow10(-c.ha.precision)
+	}
+
+	conn, err := ha.NewConnection(ctx, c.ha.server, c.ha.token)
+	if err != nil {
+		return fmt.Errorf("cannot connect to Home Assistant: %w", err)
+	}
+	defer conn.Close()
+
+	var corrections []parse.Correction
+	for _, r := range parsed {
+		stat, err := parse.Translate(r, opts)
+		var skipped *parse.SkippedError
+		if errors.As(err, &skipped) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot parse data: %w", err)
+		}
+		parse.Round(stat.Stats, c.ha.precision)
+
+		from, to := stat.Stats[0].Start, stat.Stats[len(stat.Stats)-1].Start.Add(time.Hour)
+		existing, err := readAllStatistics(ctx, conn, c.ha.sensor, from, to)
+		if err != nil {
+			return fmt.Errorf("cannot read statistics from Home Assistant: %w", err)
+		}
+		corrections = append(corrections, parse.Corrections(stat.Stats, existing, tolerance)...)
+	}
+
+	var total float64
+	for _, cr := range corrections {
+		fmt.Printf("%s %s %+g kWh\n", c.ha.sensor, cr.Start.Local().Format("2006-01-02 15:04"), cr.Adjustment)
+		total += cr.Adjustment
+	}
+	fmt.Printf("%s: %d revised hours, %+g kWh\n", c.ha.sensor, len(corrections), math.Round(total*1000)/1000)
+	if c.ha.previewDiff {
+		return nil
+	}
+
+	for _, cr := range corrections {
+		if err := conn.AdjustSumStatistics(ctx, c.ha.sensor, cr.Start, cr.Adjustment, "kWh"); err != nil {
+			return fmt.Errorf("cannot adjust the sum at %s: %w", cr.Start, err)
+		}
+	}
+	if len(corrections) > 0 {
+		slog.Info("Adjusted the sums", "sensor", c.ha.sensor, "hours", len(corrections))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/store"
+)
+
+type runsCmd struct {
+	storePath string
+	limit     int
+}
+
+func (runsCmd) Name() string { return "runs" }
+
+func (runsCmd) Synopsis() string {
+	return "list the uploads recorded in the local store"
+}
+
+func (runsCmd) Usage() string {
+	return `runs -store <path>
+
+Prints the audit log of the uploads, newest first.
+Uploads are recorded when upload, pipe, serve or sync run with a local store.
+
+`
+}
+
+func (c *runsCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.storePath, "store", "", "the path of the local database")
+	fs.IntVar(&c.limit, "limit", 20, "how many uploads to show")
+}
+
+func (c *runsCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	st, err := store.Open(c.storePath)
+	if err != nil {
+		slog.Error("cannot open local store", "err", err)
+		return subcommands.ExitFailure
+	}
+	defer st.Close()
+
+	uploads, err := st.Uploads(c.limit)
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+
+	const format = "2006-01-02 15:04"
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tTARGET\tSTATISTIC\tFROM\tTO\tPOINTS\tSUM BEFORE\tSUM AFTER\tOUTCOME")
+	for _, u := range uploads {
+		outcome := "ok"
+		if u.Error != "" {
+			outcome = "ERROR: " + u.Error
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%.3f\t%.3f\t%s\n",
+			u.ID, u.Time.Format(format), u.Target, u.StatisticID, u.From.Format(format), u.To.Format(format),
+			u.Points, u.SumBefore, u.SumAfter, outcome)
+	}
+	w.Flush()
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,82 @@
+// Package schedule decides when to check ESB for new data.
+//
+// ESB publishes the data of a day with a lag of one or two days, at
+// roughly the same time of the day. Learning that time from the past
+// publications allows to check ESB often around it, and to leave it
+// alone for the rest of the day.
+package schedule
+
+import (
+	"sort"
+	"time"
+)
+
+// minSamples is how many publications are needed to learn their time.
+const minSamples = 3
+
+// Planner plans the next check of ESB.
+type Planner struct {
+	// Retry is how often ESB is checked while waiting for new data.
+	Retry time.Duration
+	// Early is how long before the usual publication time to start checking.
+	Early time.Duration
+	// Location is the timezone of the publication times.
+	Location *time.Location
+}
+
+// PublishTime returns the usual time of the day, as offset from midnight,
+// when ESB publishes new data.
+//
+// It returns false if there are not enough publications to tell.
+func (p Planner) PublishTime(publications []time.Time) (time.Duration, bool) {
+	if len(publications) < minSamples {
+		return 0, false
+	}
+	offsets := make([]time.Duration, 0, len(publications))
+	for _, t := range publications {
+		t = t.In(p.Location)
+		offsets = append(offsets, t.Sub(midnight(t)))
+	}
+	// The median is robust to the days we were not running.
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets[(len(offsets)-1)/2], true
+}
+
+// Next returns when to check ESB again.
+//
+// Publications are the times new data was seen, in any order.
+func (p Planner) Next(now time.Time, publications []time.Time) time.Time {
+	publish, ok := p.PublishTime(publications)
+	if !ok {
+		return now.Add(p.Retry)
+	}
+	now = now.In(p.Location)
+	start := midnight(now).Add(publish - p.Early)
+
+	var latest time.Time
+	for _, t := range publications {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+
+	switch {
+	case !latest.Before(start):
+		// Today's data is already there, wait for tomorrow.
+		return nextDay(start)
+	case now.Before(start):
+		return start
+	default:
+		// Late publication, keep checking.
+		return now.Add(p.Retry)
+	}
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// nextDay returns the same wall clock time on the next day.
+func nextDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+1, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+}
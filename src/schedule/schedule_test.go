@@ -0,0 +1,57 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(d, h, m int) time.Time { return time.Date(2023, 07, d, h, m, 0, 0, dublin) }
+	p := Planner{Retry: time.Hour, Early: 30 * time.Minute, Location: dublin}
+
+	// ESB usually publishes at about 10:00.
+	usual := []time.Time{at(10, 9, 50), at(11, 10, 0), at(12, 10, 20), at(13, 23, 0)}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		publications []time.Time
+		want         time.Time
+	}{
+		{
+			name:         "not enough data to learn",
+			now:          at(14, 8, 0),
+			publications: usual[:2],
+			want:         at(14, 9, 0),
+		},
+		{
+			name:         "before the window",
+			now:          at(14, 8, 0),
+			publications: usual,
+			want:         at(14, 9, 30),
+		},
+		{
+			name:         "late publication",
+			now:          at(14, 11, 0),
+			publications: usual,
+			want:         at(14, 12, 0),
+		},
+		{
+			name:         "already published today",
+			now:          at(14, 11, 0),
+			publications: append(usual, at(14, 10, 5)),
+			want:         at(15, 9, 35),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := p.Next(tc.now, tc.publications); !got.Equal(tc.want) {
+				t.Errorf("Next() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/config"
+	"github.com/lorentz83/esb2ha/keyring"
+)
+
+// loadConfig loads the configuration file, decrypting the secrets with
+// the age identity file if needed.
+func loadConfig(path, identityPath string) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.IsEncrypted() {
+		return cfg, nil
+	}
+	if identityPath == "" {
+		return nil, fmt.Errorf("the configuration contains encrypted secrets, -age_identity is required")
+	}
+	f, err := os.Open(identityPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ids, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read age identity: %w", err)
+	}
+	if err := cfg.Decrypt(ids); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+type encryptCmd struct {
+	recipients string
+}
+
+func (encryptCmd) Name() string { return "encrypt" }
+
+func (encryptCmd) Synopsis() string {
+	return "encrypt a secret for the configuration file"
+}
+
+func (encryptCmd) Usage() string {
+	return `encrypt -recipients <age public keys>
+
+Reads a secret (password or token) from standard input and prints the
+encrypted value to put in the configuration file instead of the clear text.
+Generate the key pair with age-keygen and pass the private key file to
+sync with -age_identity.
+
+`
+}
+
+func (c *encryptCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.recipients, "recipients", "", "comma separated list of age public keys (age1...)")
+}
+
+func (c *encryptCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	rr, err := age.ParseRecipients(strings.NewReader(strings.ReplaceAll(c.recipients, ",", "\n")))
+	if err != nil {
+		slog.Error("invalid recipients", "err", err)
+		return subcommands.ExitUsageError
+	}
+
+	fmt.Fprintln(os.Stderr, "Type the secret and press enter:")
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && secret == "" {
+		slog.Error("cannot read the secret", "err", err)
+		return subcommands.ExitFailure
+	}
+	secret = strings.TrimRight(secret, "\r\n")
+
+	enc, err := config.EncryptSecret(secret, rr)
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	fmt.Println(enc)
+	return subcommands.ExitSuccess
+}
+
+// keyringSecrets are the secret flags which auth can store, the first
+// two by default.
+var keyringSecrets = []string{"esb_password", "ha_token", "esb_totp_secret"}
+
+type authCmd struct{}
+
+func (authCmd) Name() string { return "auth" }
+
+func (authCmd) Synopsis() string {
+	return "save the ESB password and the Home Assistant token in the keyring"
+}
+
+func (authCmd) Usage() string {
+	return `auth set [flag...]
+
+Reads the secrets from standard input, one per line, and saves them in
+the keyring of the operating system: the Keychain on macOS, the
+Credential Manager on Windows and the Secret Service (secret-tool) on
+Linux. The other commands read them with -use_keyring, when the flags
+are not set otherwise.
+
+The flags are esb_password and ha_token by default, esb_totp_secret can
+be saved too.
+
+`
+}
+
+func (authCmd) SetFlags(fs *flag.FlagSet) {}
+
+func (authCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() == 0 || f.Arg(0) != "set" {
+		slog.Error("unknown action, want: auth set [flag...]")
+		return subcommands.ExitUsageError
+	}
+	names := f.Args()[1:]
+	if len(names) == 0 {
+		names = keyringSecrets[:2]
+	}
+	for _, name := range names {
+		if !slices.Contains(keyringSecrets, strings.TrimPrefix(name, "-")) {
+			slog.Error("cannot save the flag in the keyring", "flag", name, "want", strings.Join(keyringSecrets, ", "))
+			return subcommands.ExitUsageError
+		}
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	for _, name := range names {
+		name = strings.TrimPrefix(name, "-")
+		fmt.Fprintf(os.Stderr, "Type -%s and press enter: ", name)
+		secret, err := in.ReadString('\n')
+		if err != nil && secret == "" {
+			slog.Error("cannot read the secret", "flag", name, "err", err)
+			return subcommands.ExitFailure
+		}
+		secret = strings.TrimRight(secret, "\r\n")
+		if secret == "" {
+			slog.Error("empty secret, not saved", "flag", name)
+			return subcommands.ExitFailure
+		}
+		if err := keyring.Set(keyring.Service, name, secret); err != nil {
+			slog.Error(err.Error())
+			return subcommands.ExitFailure
+		}
+		slog.Info("Saved in the keyring", "flag", name)
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,744 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/esb2hapb"
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/schedule"
+	"github.com/lorentz83/esb2ha/sink"
+	"github.com/lorentz83/esb2ha/systemd"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// downloadChunkSize is the size of the chunks streamed by the Download RPC.
+const downloadChunkSize = 32 * 1024
+
+type serveCmd struct {
+	ha       uploadCmd
+	esb      downloadCmd
+	cache    downloadCache
+	mqtt     mqttSink
+	notify   syncNotifications
+	addr     string
+	httpAddr string
+	apiToken string
+
+	schedule bool
+	retry    time.Duration
+	maxLag   time.Duration
+	// drainTimeout is how long the shutdown waits for the uploads in
+	// progress.
+	drainTimeout time.Duration
+
+	// syncEvent is the Home Assistant event triggering a sync, optional.
+	syncEvent string
+}
+
+func (serveCmd) Name() string { return "serve" }
+
+func (serveCmd) Synopsis() string {
+	return "run a gRPC server to download, parse and sync the electricity usage data"
+}
+
+func (serveCmd) Usage() string {
+	return `serve <flags>
+
+All the non optional flags are required, but can be provided as environment variables as well.
+The server runs until interrupted, check esb2hapb/esb2ha.proto for the gRPC service definition.
+If -http_addr is set, a REST API is served too, its OpenAPI spec is available at /openapi.yaml.
+The REST server also serves the metrics of the syncs for Prometheus at /metrics.
+If -schedule is set, the data is also synced in the background. With -store, the server learns
+when ESB usually publishes new data and checks around that time, every -retry until it comes.
+If -mqtt_sync_button is set, the same happens when the button announced via MQTT is pressed.
+If -ha_sync_event is set, firing that event in Home Assistant syncs immediately, the result
+is fired back as the same event with the _done suffix.
+Run by systemd with Type=notify, the server tells when it is ready and shows the last sync in
+systemctl status; with WatchdogSec, systemd restarts it if the scheduled sync gets stuck.
+On SIGTERM or SIGINT the server stops syncing, but lets the chunks being uploaded finish, for up
+to -drain_timeout, so Home Assistant and the local store are never left halfway.
+
+`
+}
+
+func (c *serveCmd) SetFlags(fs *flag.FlagSet) {
+	c.ha.SetFlags(fs)
+	c.esb.SetFlags(fs)
+	c.cache.SetFlags(fs)
+	c.mqtt.SetFlags(fs)
+	c.notify.SetFlags(fs)
+	fs.StringVar(&c.addr, "grpc_addr", "localhost:50051", "the address the gRPC server listens on, like :50051 for all the interfaces")
+	optionalStringVar(fs, &c.httpAddr, "http_addr", "", "the address the REST server listens on")
+	optionalStringVar(fs, &c.apiToken, "api_token", "", "the bearer token required to call the REST and the gRPC APIs, mandatory unless they only listen on localhost")
+	fs.BoolVar(&c.schedule, "schedule", false, "sync in the background when ESB usually publishes new data")
+	fs.DurationVar(&c.retry, "retry", time.Hour, "how often to check ESB while waiting for new data")
+	fs.DurationVar(&c.maxLag, "max_lag", 48*time.Hour, "warn if the latest read is older than this")
+	fs.DurationVar(&c.drainTimeout, "drain_timeout", 30*time.Second, "how long to wait for the uploads in progress when shutting down")
+	optionalStringVar(fs, &c.syncEvent, "ha_sync_event", "", "the Home Assistant event which triggers a sync, like esb2ha_sync")
+}
+
+func (c *serveCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if len(c.esb.mprns()) != 1 {
+		slog.Error("serve supports a single -mprn, use the sync command with a configuration file for more meters")
+		return subcommands.ExitUsageError
+	}
+	if c.ha.period.set() {
+		slog.Error("serve keeps all the data up to date, -from and -to are only for upload and pipe")
+		return subcommands.ExitUsageError
+	}
+	if err := c.notify.validate(); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if c.mqtt.syncButton && !c.mqtt.enabled() {
+		slog.Error("-mqtt_sync_button requires -mqtt_url")
+		return subcommands.ExitUsageError
+	}
+	if c.apiToken == "" {
+		for _, addr := range []string{c.addr, c.httpAddr} {
+			if addr != "" && !isLoopback(addr) {
+				slog.Error("-api_token is required to listen on " + addr + ", other than localhost")
+				return subcommands.ExitUsageError
+			}
+		}
+	}
+
+	lis, err := net.Listen("tcp", c.addr)
+	if err != nil {
+		slog.Error("cannot listen", "err", err)
+		return subcommands.ExitFailure
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	drain, abandon := context.WithCancel(context.WithoutCancel(ctx))
+	defer abandon()
+
+	c.cache.path = c.ha.storePath
+	svc := &service{ha: c.ha, esb: c.esb, cache: c.cache, mqtt: c.mqtt, notify: c.notify, shutdown: ctx, drain: drain}
+
+	if c.apiToken == "" {
+		slog.Warn("the APIs are not protected, only the local users can call them")
+	}
+	s := newGRPCServer(svc, c.apiToken)
+
+	var hs *http.Server
+	if c.httpAddr != "" {
+		hs = &http.Server{
+			Addr:    c.httpAddr,
+			Handler: newRESTHandler(svc, c.apiToken),
+		}
+		go func() {
+			slog.Info("REST API listening", "addr", c.httpAddr)
+			if err := hs.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("REST server", "err", err)
+				stop()
+			}
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+		slog.Info("Shutting down, waiting for the uploads in progress")
+		notify(systemd.Stopping)
+		time.AfterFunc(c.drainTimeout, abandon)
+		if hs != nil {
+			hs.Shutdown(drain)
+		}
+		s.GracefulStop()
+	}()
+
+	if c.schedule {
+		dublin, err := time.LoadLocation("Europe/Dublin")
+		if err != nil {
+			slog.Error("cannot load timezone", "err", err)
+			return subcommands.ExitFailure
+		}
+		p := schedule.Planner{Retry: c.retry, Early: 30 * time.Minute, Location: dublin}
+		go svc.scheduledSyncs(ctx, p, c.maxLag)
+	}
+	if c.syncEvent != "" {
+		go svc.eventSyncs(ctx, c.syncEvent)
+	}
+	if c.mqtt.syncButton {
+		b, err := sink.NewSyncButton(c.mqtt.url, c.mqtt.prefix, c.mqtt.topic, c.esb.mprn)
+		if err != nil {
+			slog.Error("cannot connect to MQTT", "err", err)
+			return subcommands.ExitFailure
+		}
+		go svc.buttonSyncs(ctx, b)
+	}
+
+	slog.Info("Listening", "addr", lis.Addr())
+	notify(systemd.Ready, systemd.Status("Listening on "+lis.Addr().String()))
+	svc.startWatchdog(ctx)
+	if err := s.Serve(lis); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	// Serve returns as soon as the shutdown starts.
+	svc.wait()
+	if drain.Err() != nil {
+		slog.Warn("the uploads in progress didn't finish in time, they'll be resumed")
+	}
+	return subcommands.ExitSuccess
+}
+
+// service is the implementation shared by the gRPC and the REST servers.
+type service struct {
+	ha     uploadCmd
+	esb    downloadCmd
+	cache  downloadCache
+	mqtt   mqttSink
+	notify syncNotifications
+
+	mu   sync.Mutex
+	runs []run
+	// next is when the scheduler syncs next, syncing when the scheduled
+	// sync in progress started, see hung.
+	next, syncing time.Time
+
+	// shutdown is done when the server is shutting down, no new chunk
+	// is uploaded then. drain is done when the chunks being uploaded
+	// must be abandoned too.
+	shutdown, drain context.Context
+	// syncs are the syncs in progress, busy their MPRNs and sensors,
+	// see begin. Once closed, no new sync starts.
+	syncs  sync.WaitGroup
+	busy   map[string]bool
+	closed bool
+}
+
+var (
+	// errSyncInProgress is returned by sync when the meter or the sensor
+	// is already being synced: the syncs would share the upload progress
+	// and the ESB session.
+	errSyncInProgress = errors.New("sync in progress")
+	// errShuttingDown is returned by sync once the server is shutting
+	// down.
+	errShuttingDown = errors.New("the server is shutting down")
+)
+
+// begin reserves the mprn and the sensor for a sync, it returns the
+// function to call when the sync is done.
+func (s *service) begin(mprn, sensor string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errShuttingDown
+	}
+	keys := []string{"mprn:" + mprn, "sensor:" + sensor}
+	for _, k := range keys {
+		if s.busy[k] {
+			return nil, fmt.Errorf("%w for mprn %s or sensor %s", errSyncInProgress, mprn, sensor)
+		}
+	}
+	if s.busy == nil {
+		s.busy = map[string]bool{}
+	}
+	for _, k := range keys {
+		s.busy[k] = true
+	}
+	// Under the lock, so wait can't miss it.
+	s.syncs.Add(1)
+	return func() {
+		s.mu.Lock()
+		for _, k := range keys {
+			delete(s.busy, k)
+		}
+		s.mu.Unlock()
+		s.syncs.Done()
+	}, nil
+}
+
+// wait stops new syncs from starting and waits for the ones in progress.
+func (s *service) wait() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.syncs.Wait()
+}
+
+// run is the summary of a single sync.
+type run struct {
+	ID     int       `json:"id"`
+	MPRN   string    `json:"mprn"`
+	Sensor string    `json:"sensor"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Points int       `json:"points"`
+	Error  string    `json:"error,omitempty"`
+	// Unchanged is set when the data is the same as the last download.
+	Unchanged bool `json:"unchanged,omitempty"`
+	// StaleHours is for how long ESB didn't publish new data, if known.
+	StaleHours int `json:"stale_hours,omitempty"`
+	// LagHours is how old the latest read was at the start of the sync.
+	LagHours int `json:"lag_hours,omitempty"`
+	// FoldedHours are the hours repeated when the clocks went back,
+	// handled according to -dst_fold.
+	FoldedHours []time.Time `json:"folded_hours,omitempty"`
+}
+
+// open downloads the HDF file of the given mprn, or the configured one
+// if empty, streaming it.
+//
+// The caller must close the returned reader.
+func (s *service) open(ctx context.Context, mprn string) (io.ReadCloser, error) {
+	esb := s.esb
+	if mprn != "" {
+		esb.mprn = mprn
+	}
+	return esb.open(ctx)
+}
+
+// sync downloads, parses and uploads the data to Home Assistant.
+//
+// Empty mprn and sensor are replaced with the configured ones.
+// The callback is called for every chunk uploaded, with the upload error if any.
+// The returned run is recorded in the history of runs.
+func (s *service) sync(ctx context.Context, mprn, sensor string, onChunk func(ha.Statistics, error) error) (run, error) {
+	up := s.ha
+	if mprn == "" {
+		mprn = s.esb.mprn
+	}
+	if sensor != "" {
+		up.sensor = sensor
+	}
+	r := run{MPRN: mprn, Sensor: up.sensor, Start: time.Now()}
+
+	done, err := s.begin(mprn, up.sensor)
+	if err != nil {
+		return r, err
+	}
+	defer done()
+
+	ctx, end := startSpan(ctx, "sync", attribute.String("mprn", mprn))
+	// Once started, a chunk is uploaded even if ctx is done, so the
+	// energy and the cost, Home Assistant and the local store agree.
+	work, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer context.AfterFunc(s.drain, cancel)()
+	defer cancel()
+	err = end(func() error {
+		esb := s.esb
+		esb.mprn = mprn
+		body, err := esb.open(ctx)
+		if err != nil {
+			return err
+		}
+		parsed, hash, err := parseDownload(ctx, body)
+		if err != nil {
+			return err
+		}
+		if err := s.cache.recordReads(parsed); err != nil {
+			slog.Warn(err.Error())
+		}
+		parsed = up.setAsideExport(parsed)
+		if lag, ok := up.reportLag(ctx, mprn, parsed, r.Start); ok {
+			r.LagHours = int(lag.Hours())
+		}
+		if s.mqtt.enabled() {
+			if err := publishAll(ctx, &s.mqtt, parsed); err != nil {
+				slog.Warn("cannot publish the usage sensors to MQTT", "err", err)
+			}
+		}
+		unchanged, stale, err := s.cache.unchanged(mprn, up.sensor, hash)
+		if err != nil {
+			return err
+		}
+		r.Unchanged, r.StaleHours = unchanged && !up.pending(), int(stale.Hours())
+		if s.cache.path != "" {
+			promMetrics.stale(mprn, stale)
+		}
+		if r.Unchanged {
+			return nil
+		}
+		parsed = up.fill(parsed)
+		r.FoldedHours = parse.FoldedHours(parsed)
+		if err := up.loadPrices(ctx, parsed); err != nil {
+			return err
+		}
+		up.resume()
+		var errs []error
+		for _, chunk := range parsed {
+			if ctx.Err() != nil || s.shutdown.Err() != nil {
+				errs = append(errs, errors.New("interrupted, the next sync will resume the upload"))
+				break
+			}
+			stat, err := up.upload(work, chunk)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				r.Points += len(stat.Stats)
+			}
+			if err := onChunk(stat, err); err != nil {
+				return err
+			}
+		}
+		if len(errs) == 0 {
+			n, err := up.uploadBands(work, parsed)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			r.Points += n
+		}
+		if len(errs) == 0 {
+			n, err := up.uploadExport(work)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			r.Points += n
+		}
+		if len(errs) == 0 {
+			up.finish()
+			if err := s.cache.uploaded(mprn, up.sensor, hash); err != nil {
+				// The next sync uploads the same data again, nothing lost.
+				slog.Warn(err.Error())
+			}
+		}
+		return errors.Join(errs...)
+	}())
+
+	r.End = time.Now()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	promMetrics.synced(mprn, r.End, err)
+	up.reportStatus(work, mprn, time.Duration(r.LagHours)*time.Hour, err)
+	s.notify.send(work, &up, mprn, err)
+
+	s.mu.Lock()
+	r.ID = len(s.runs) + 1
+	s.runs = append(s.runs, r)
+	s.mu.Unlock()
+	s.notifyStatus()
+
+	return r, err
+}
+
+// scheduledSyncs syncs the configured meter until the context is done,
+// at the times suggested by the planner.
+func (s *service) scheduledSyncs(ctx context.Context, p schedule.Planner, maxLag time.Duration) {
+	for {
+		s.mu.Lock()
+		s.syncing, s.next = time.Now(), time.Time{}
+		s.mu.Unlock()
+		r, err := s.sync(ctx, "", "", func(ha.Statistics, error) error { return nil })
+		if err != nil {
+			slog.Error("scheduled sync", "err", err)
+		}
+		if lag := time.Duration(r.LagHours) * time.Hour; lag > maxLag {
+			slog.Warn("the latest read is old", "mprn", r.MPRN, "hours", r.LagHours)
+		}
+
+		pubs, err := s.cache.publications(r.MPRN)
+		if err != nil {
+			slog.Error(err.Error())
+		}
+		next := p.Next(time.Now(), pubs)
+		slog.Info("Next sync", "at", next.Format(time.DateTime))
+		s.mu.Lock()
+		s.syncing, s.next = time.Time{}, next
+		s.mu.Unlock()
+		s.notifyStatus()
+
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// eventSyncRetry is how long to wait before subscribing again to the
+// Home Assistant events after an error.
+const eventSyncRetry = time.Minute
+
+// eventSyncs syncs the configured meter every time the event is fired in
+// Home Assistant, until the context is done.
+func (s *service) eventSyncs(ctx context.Context, eventType string) {
+	for {
+		err := s.listenSyncEvents(ctx, eventType)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("cannot wait for the events", "event_type", eventType, "err", err)
+
+		t := time.NewTimer(eventSyncRetry)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// listenSyncEvents syncs on every event until the connection breaks, and
+// fires the result back as the <eventType>_done event.
+func (s *service) listenSyncEvents(ctx context.Context, eventType string) error {
+	conn, err := ha.NewConnection(ctx, s.ha.server, s.ha.token)
+	if err != nil {
+		return fmt.Errorf("cannot connect to Home Assistant: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.SubscribeEvents(ctx, eventType); err != nil {
+		return err
+	}
+	slog.Info("Waiting for events", "event_type", eventType)
+	for {
+		if _, err := conn.NextEvent(ctx); err != nil {
+			return err
+		}
+		slog.Info("Sync requested by Home Assistant")
+		r, err := s.sync(ctx, "", "", func(ha.Statistics, error) error { return nil })
+		done := map[string]any{
+			"run_id":    r.ID,
+			"mprn":      r.MPRN,
+			"points":    r.Points,
+			"unchanged": r.Unchanged,
+			"success":   err == nil,
+		}
+		if err != nil {
+			slog.Error("requested sync", "err", err)
+			done["error"] = err.Error()
+		}
+		if err := ha.FireEvent(ctx, s.ha.server, s.ha.token, eventType+"_done", done); err != nil {
+			slog.Warn("cannot fire the done event", "event_type", eventType+"_done", "err", err)
+		}
+	}
+}
+
+// buttonSyncs syncs the configured meter every time the button is
+// pressed, until the context is done.
+func (s *service) buttonSyncs(ctx context.Context, b *sink.SyncButton) {
+	defer b.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.Presses():
+		}
+		slog.Info("Sync requested by the MQTT button")
+		r, err := s.sync(ctx, "", "", func(ha.Statistics, error) error { return nil })
+		if err != nil {
+			slog.Error("requested sync", "err", err)
+		}
+		if err := b.Report(r.End, r.Points, err); err != nil {
+			slog.Warn("cannot report the sync to MQTT", "err", err)
+		}
+	}
+}
+
+// history returns all the runs since the server started.
+func (s *service) history() []run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]run{}, s.runs...)
+}
+
+// isLoopback returns whether the listen address only accepts local
+// connections, like localhost:50051.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// newGRPCServer returns the gRPC server of the service, requiring the
+// token if not empty, see checkToken.
+func newGRPCServer(svc *service, token string) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+			if err := checkToken(ctx, token); err != nil {
+				return nil, err
+			}
+			return h(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			if err := checkToken(ss.Context(), token); err != nil {
+				return err
+			}
+			return h(srv, ss)
+		}),
+	)
+	esb2hapb.RegisterESB2HAServer(s, &grpcServer{svc: svc})
+	return s
+}
+
+// checkToken checks the bearer token in the authorization metadata of a
+// gRPC call, like requireToken does for the REST API.
+//
+// If token is empty, any call is accepted.
+func checkToken(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		got, ok := strings.CutPrefix(v, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing token")
+}
+
+// grpcServer implements the ESB2HA gRPC service.
+type grpcServer struct {
+	esb2hapb.UnimplementedESB2HAServer
+
+	svc *service
+}
+
+func (s *grpcServer) Download(req *esb2hapb.DownloadRequest, stream grpc.ServerStreamingServer[esb2hapb.DownloadChunk]) error {
+	body, err := s.svc.open(stream.Context(), req.GetMprn())
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer body.Close()
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := io.ReadFull(body, buf)
+		if n > 0 {
+			if err := stream.Send(&esb2hapb.DownloadChunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Unavailable, fmt.Sprintf("cannot download power consumption data: %v", err))
+		}
+	}
+}
+
+func (s *grpcServer) Parse(stream grpc.ClientStreamingServer[esb2hapb.ParseRequest, esb2hapb.ParseResponse]) error {
+	r := &parseRequestReader{stream: stream}
+	parsed, err := readRawHDF(r)
+	if r.err != nil {
+		return r.err
+	}
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	rsp := &esb2hapb.ParseResponse{}
+	for _, r := range parsed {
+		rsp.Results = append(rsp.Results, resultToProto(r))
+	}
+	return stream.SendAndClose(rsp)
+}
+
+// parseRequestReader reads the HDF file of the Parse RPC while it is
+// streamed, so the file is never kept in memory.
+type parseRequestReader struct {
+	stream grpc.ClientStreamingServer[esb2hapb.ParseRequest, esb2hapb.ParseResponse]
+	data   []byte
+	// err is the error of the stream, other than its end.
+	err error
+}
+
+func (r *parseRequestReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		req, err := r.stream.Recv()
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		r.data = req.GetData()
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (s *grpcServer) Sync(req *esb2hapb.SyncRequest, stream grpc.ServerStreamingServer[esb2hapb.SyncEvent]) error {
+	_, err := s.svc.sync(stream.Context(), req.GetMprn(), req.GetSensor(), func(stat ha.Statistics, err error) error {
+		ev := &esb2hapb.SyncEvent{}
+		if err != nil {
+			ev.Error = err.Error()
+		} else {
+			ev.Statistics = statisticsToProto(stat)
+		}
+		return stream.Send(ev)
+	})
+	if errors.Is(err, errSyncInProgress) {
+		return status.Error(codes.Aborted, err.Error())
+	}
+	if err != nil {
+		// Also the errors after the chunks, like the ones of the bands.
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return nil
+}
+
+func resultToProto(r parse.Result) *esb2hapb.Result {
+	ret := &esb2hapb.Result{
+		Mprn:              r.MPRN,
+		MeterSerialNumber: r.MeterSerialNumber,
+		ReadTypes:         r.ReadTypes,
+	}
+	for _, rd := range r.Reads {
+		ret.Reads = append(ret.Reads, &esb2hapb.Read{
+			Value:   rd.Value,
+			EndTime: timestamppb.New(rd.EndTime),
+		})
+	}
+	return ret
+}
+
+func statisticsToProto(s ha.Statistics) *esb2hapb.Statistics {
+	ret := &esb2hapb.Statistics{
+		StatisticId:       s.Metadata.StatisticID,
+		UnitOfMeasurement: s.Metadata.UnitOfMeasurement,
+	}
+	for _, v := range s.Stats {
+		ret.Stats = append(ret.Stats, &esb2hapb.StatisticValue{
+			Start: timestamppb.New(v.Start),
+			State: v.State,
+			Sum:   v.Sum,
+		})
+	}
+	return ret
+}
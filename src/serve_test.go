@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lorentz83/esb2ha/esb2hapb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestService returns a service for the meter 123 and the sensor
+// sensor.energy, which don't reach ESB or Home Assistant as long as
+// they are busy, see service.begin.
+func newTestService() *service {
+	svc := &service{}
+	svc.esb.mprn = "123"
+	svc.ha.sensor = "sensor.energy"
+	return svc
+}
+
+// dialGRPC serves the service on an in memory listener and returns a
+// client of it.
+func dialGRPC(t *testing.T, svc *service, token string) esb2hapb.ESB2HAClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := newGRPCServer(svc, token)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient() unexpected error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return esb2hapb.NewESB2HAClient(conn)
+}
+
+// syncCode returns the status code of a Sync of the configured meter.
+func syncCode(ctx context.Context, c esb2hapb.ESB2HAClient) codes.Code {
+	stream, err := c.Sync(ctx, &esb2hapb.SyncRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	return status.Code(err)
+}
+
+func TestGRPC_Token(t *testing.T) {
+	svc := newTestService()
+	done, err := svc.begin("123", "sensor.energy")
+	if err != nil {
+		t.Fatalf("begin() unexpected error: %v", err)
+	}
+	defer done()
+	c := dialGRPC(t, svc, "secret")
+
+	tests := []struct {
+		name string
+		md   []string
+		want codes.Code
+	}{
+		{"missing", nil, codes.Unauthenticated},
+		{"wrong", []string{"authorization", "Bearer wrong"}, codes.Unauthenticated},
+		{"not bearer", []string{"authorization", "secret"}, codes.Unauthenticated},
+		// The sync in progress is only found once authenticated.
+		{"right", []string{"authorization", "Bearer secret"}, codes.Aborted},
+	}
+	for _, tt := range tests {
+		ctx := metadata.AppendToOutgoingContext(t.Context(), tt.md...)
+		if got := syncCode(ctx, c); got != tt.want {
+			t.Errorf("%s token: Sync() code = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGRPC_Parse(t *testing.T) {
+	c := dialGRPC(t, newTestService(), "")
+
+	stream, err := c.Parse(t.Context())
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	// The file is split in the middle of a line.
+	for _, chunk := range []string{
+		"MPRN,Meter Serial Number,Read Value,Read Type,Read Date and End Time\n10000000000,0000",
+		"00000000,0.5,Active Import Interval (kW),01-01-2024 00:30\n",
+	} {
+		if err := stream.Send(&esb2hapb.ParseRequest{Data: []byte(chunk)}); err != nil {
+			t.Fatalf("Send() unexpected error: %v", err)
+		}
+	}
+	rsp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv() unexpected error: %v", err)
+	}
+	if len(rsp.Results) != 1 || len(rsp.Results[0].Reads) != 1 || rsp.Results[0].Reads[0].Value != 0.5 {
+		t.Errorf("Parse() = %v, want a result with a read of 0.5", rsp)
+	}
+}
+
+func TestService_Busy(t *testing.T) {
+	svc := newTestService()
+	done, err := svc.begin("123", "sensor.energy")
+	if err != nil {
+		t.Fatalf("begin() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		mprn, sensor string
+		want         error
+	}{
+		{"123", "sensor.energy", errSyncInProgress},
+		{"123", "sensor.other", errSyncInProgress},
+		{"456", "sensor.energy", errSyncInProgress},
+		{"456", "sensor.other", nil},
+	}
+	for _, tt := range tests {
+		d, err := svc.begin(tt.mprn, tt.sensor)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("begin(%s, %s) = %v, want %v", tt.mprn, tt.sensor, err, tt.want)
+		}
+		if err == nil {
+			d()
+		}
+	}
+
+	done()
+	if d, err := svc.begin("123", "sensor.energy"); err != nil {
+		t.Errorf("begin() after done() unexpected error: %v", err)
+	} else {
+		d()
+	}
+	svc.wait()
+	if _, err := svc.begin("123", "sensor.energy"); !errors.Is(err, errShuttingDown) {
+		t.Errorf("begin() after wait() = %v, want errShuttingDown", err)
+	}
+}
+
+func TestService_Wait(t *testing.T) {
+	svc := newTestService()
+	done, err := svc.begin("123", "sensor.energy")
+	if err != nil {
+		t.Fatalf("begin() unexpected error: %v", err)
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		svc.wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("wait() returned with a sync in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait() didn't return after the sync finished")
+	}
+	if _, err := svc.sync(t.Context(), "", "", nil); !errors.Is(err, errShuttingDown) {
+		t.Errorf("sync() after wait() = %v, want errShuttingDown", err)
+	}
+}
+
+func TestREST_TokenAndBusy(t *testing.T) {
+	svc := newTestService()
+	done, err := svc.begin("123", "sensor.energy")
+	if err != nil {
+		t.Fatalf("begin() unexpected error: %v", err)
+	}
+	defer done()
+	h := newRESTHandler(svc, "secret")
+
+	tests := []struct {
+		method, path, auth string
+		want               int
+	}{
+		{http.MethodGet, "/meters", "", http.StatusUnauthorized},
+		{http.MethodGet, "/meters", "Bearer wrong", http.StatusUnauthorized},
+		{http.MethodGet, "/meters", "Bearer secret", http.StatusOK},
+		{http.MethodGet, "/openapi.yaml", "", http.StatusOK},
+		{http.MethodPost, "/sync", "Bearer secret", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s with %q = %d, want %d", tt.method, tt.path, tt.auth, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"localhost:50051", true},
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{":50051", false},
+		{"0.0.0.0:50051", false},
+		{"192.168.1.2:50051", false},
+		{"esb2ha.lan:50051", false},
+		{"invalid", false},
+	}
+	for _, tt := range tests {
+		if got := isLoopback(tt.addr); got != tt.want {
+			t.Errorf("isLoopback(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
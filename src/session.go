@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/lorentz83/esb2ha/esblib"
+	"github.com/lorentz83/esb2ha/provider"
+)
+
+// sessionWorkFactor is the scrypt work factor of the session files, the
+// age default takes about a second, which is most of what a saved login
+// saves.
+const sessionWorkFactor = 15
+
+// newLogin returns a logged in provider.
+//
+// With sessionPath, it first tries the login saved there by a previous
+// run, without checking it is still valid: resumed is then true and the
+// caller has to log in again, without sessionPath, when the download
+// fails with provider.ErrLoginExpired. A new login is saved there.
+//
+// The one time codes of the multi-factor authentication are generated
+// from totpSecret, if set, see oneTimeCode.
+func newLogin(ctx context.Context, name, user, password, totpSecret, sessionPath string) (p provider.Provider, resumed bool, err error) {
+	ctx, end := startSpan(ctx, "login")
+	p, err = provider.New(cmp.Or(name, provider.Default), provider.Hooks{OnLoginPhase: loginPhases(ctx), OnRetry: warnRetry, OnDownloadProgress: progress.download})
+	if err != nil {
+		return nil, false, end(err)
+	}
+	if sessionPath != "" {
+		ok, err := loadSession(sessionPath, password, p)
+		if err != nil {
+			slog.Warn("cannot read the saved login, logging in", "err", err)
+		} else if ok {
+			slog.Debug("Reusing the saved login", "path", sessionPath)
+			return p, true, end(nil)
+		}
+	}
+	if mf, ok := p.(provider.MultiFactor); ok {
+		mf.SetOneTimeCode(oneTimeCode(totpSecret))
+	}
+	if err := end(p.Login(ctx, user, password)); err != nil {
+		promMetrics.loginFailed()
+		return nil, false, err
+	}
+	if sessionPath != "" {
+		if err := saveSession(sessionPath, password, p); err != nil {
+			slog.Warn("cannot save the login", "err", err)
+		}
+	}
+	return p, false, nil
+}
+
+// oneTimeCode returns how the logins get the codes of the multi-factor
+// authentication: generated from the secret of the authenticator app if
+// set, otherwise typed on the terminal, nil if there is none, like when
+// syncing in the background.
+func oneTimeCode(totpSecret string) func(kind string) (string, error) {
+	if totpSecret != "" {
+		return func(kind string) (string, error) {
+			if kind != esblib.MFATOTP {
+				return "", fmt.Errorf("the login asks for a code sent by %s, not for the one of an authenticator app", kind)
+			}
+			return esblib.TOTP(totpSecret, time.Now())
+		}
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return func(kind string) (string, error) {
+		what := "the code of the authenticator app"
+		if kind == esblib.MFAEmail {
+			what = "the code sent by email"
+		}
+		fmt.Fprintf(os.Stderr, "Enter %s: ", what)
+		code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		return strings.TrimSpace(code), err
+	}
+}
+
+// loadSession resumes the login saved in path, encrypted with the
+// password of the account. It returns false if there is none, or the
+// provider doesn't support it.
+func loadSession(path, password string, p provider.Provider) (bool, error) {
+	r, ok := p.(provider.Resumer)
+	if !ok {
+		return false, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	id, err := age.NewScryptIdentity(password)
+	if err != nil {
+		return false, err
+	}
+	d, err := age.Decrypt(bytes.NewReader(data), id)
+	if err != nil {
+		return false, fmt.Errorf("cannot decrypt %s, was the password changed? %w", path, err)
+	}
+	session, err := io.ReadAll(d)
+	if err != nil {
+		return false, err
+	}
+	return true, r.Resume(session)
+}
+
+// saveSession saves the login in path, encrypted with the password of
+// the account, so only who knows it can reuse the login.
+//
+// The file is replaced atomically, since more syncs can save it at the
+// same time.
+func saveSession(path, password string, p provider.Provider) error {
+	r, ok := p.(provider.Resumer)
+	if !ok {
+		return nil
+	}
+	session, err := r.Session()
+	if err != nil {
+		return err
+	}
+	rcp, err := age.NewScryptRecipient(password)
+	if err != nil {
+		return err
+	}
+	rcp.SetWorkFactor(sessionWorkFactor)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w, err := age.Encrypt(tmp, rcp)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := w.Write(session); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,114 @@
+package sink
+
+import (
+	"encoding/json"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// SyncButton is a "sync now" button in Home Assistant, announced via
+// MQTT discovery, with a companion sensor reporting the outcome of the
+// syncs.
+//
+// It is not a Sink: it stays connected to receive the presses.
+type SyncButton struct {
+	client  mqtt.Client
+	mprn    string
+	base    string
+	presses chan struct{}
+}
+
+// NewSyncButton connects to the MQTT broker at url and announces the
+// button and its status sensor, with the same format of NewMQTT.
+//
+// The announcement and the subscription are repeated at every reconnection.
+func NewSyncButton(url, prefix, topic, mprn string) (*SyncButton, error) {
+	b := &SyncButton{
+		mprn: mprn,
+		base: topic,
+		// Presses during a sync are merged in one.
+		presses: make(chan struct{}, 1),
+	}
+	opts := mqtt.NewClientOptions().AddBroker(url).SetClientID("esb2ha_button_" + mprn)
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		// Errors can't be returned from here, the next reconnection retries.
+		if b.announce(c, prefix) == nil {
+			mqttWait(c.Subscribe(b.topic("set"), 1, func(mqtt.Client, mqtt.Message) {
+				select {
+				case b.presses <- struct{}{}:
+				default:
+				}
+			}))
+		}
+	})
+	b.client = mqtt.NewClient(opts)
+	if err := mqttWait(b.client.Connect()); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Presses returns a channel receiving a value when the button is pressed.
+func (b *SyncButton) Presses() <-chan struct{} {
+	return b.presses
+}
+
+// Report updates the status sensor with the outcome of a sync.
+func (b *SyncButton) Report(end time.Time, points int, syncErr error) error {
+	state, attrs := "ok", map[string]any{
+		"last_sync": end.Format(time.RFC3339),
+		"points":    points,
+	}
+	if syncErr != nil {
+		state, attrs["error"] = "error", syncErr.Error()
+	}
+	payload, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+	if err := mqttWait(b.client.Publish(b.topic("attributes"), 1, true, payload)); err != nil {
+		return err
+	}
+	return mqttWait(b.client.Publish(b.topic("state"), 1, true, state))
+}
+
+func (b *SyncButton) Close() error {
+	b.client.Disconnect(250)
+	return nil
+}
+
+// announce publishes the discovery configuration of the button and of
+// the status sensor.
+func (b *SyncButton) announce(c mqtt.Client, prefix string) error {
+	configs := map[string]map[string]any{
+		prefix + "/button/esb2ha_" + b.mprn + "/sync/config": {
+			"name":          "Sync now",
+			"unique_id":     "esb2ha_" + b.mprn + "_sync",
+			"command_topic": b.topic("set"),
+			"device":        mqttDevice(b.mprn),
+		},
+		prefix + "/sensor/esb2ha_" + b.mprn + "/sync_status/config": {
+			"name":                  "Sync status",
+			"unique_id":             "esb2ha_" + b.mprn + "_sync_status",
+			"state_topic":           b.topic("state"),
+			"json_attributes_topic": b.topic("attributes"),
+			"entity_category":       "diagnostic",
+			"device":                mqttDevice(b.mprn),
+		},
+	}
+	for topic, cfg := range configs {
+		payload, err := json.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		if err := mqttWait(c.Publish(topic, 1, true, payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *SyncButton) topic(name string) string {
+	return b.base + "/" + b.mprn + "/sync/" + name
+}
@@ -0,0 +1,136 @@
+package sink
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// HourlyCSV writes the energy of every complete hour as CSV, with the
+// header
+//
+//	mprn,start,end,kwh,direction
+//
+// where the times are in RFC 3339 and direction is import or export.
+// This is the layout most spreadsheets and energy tools, like the
+// Home Assistant statistics import integrations, accept.
+type HourlyCSV struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewHourlyCSV returns a sink which writes on w.
+//
+// Closing the sink doesn't close w.
+func NewHourlyCSV(w io.Writer) *HourlyCSV {
+	return &HourlyCSV{w: csv.NewWriter(w)}
+}
+
+func (h *HourlyCSV) Write(ctx context.Context, r parse.Result) error {
+	if !h.header {
+		if err := h.w.Write([]string{"mprn", "start", "end", "kwh", "direction"}); err != nil {
+			return err
+		}
+		h.header = true
+	}
+	direction := "import"
+	if r.Export() {
+		direction = "export"
+	}
+	for _, t := range Hourly(r) {
+		err := h.w.Write([]string{
+			r.MPRN,
+			t.Start.Format(time.RFC3339),
+			t.Start.Add(time.Hour).Format(time.RFC3339),
+			// Summing the halves adds floating point noise.
+			strconv.FormatFloat(math.Round(t.KWh*1e6)/1e6, 'f', -1, 64),
+			direction,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	h.w.Flush()
+	return h.w.Error()
+}
+
+// Close writes the header if nothing else was written.
+func (h *HourlyCSV) Close() error {
+	if !h.header {
+		return h.Write(context.Background(), parse.Result{})
+	}
+	return nil
+}
+
+// StatisticsCSV writes the hourly statistics uploaded to Home Assistant
+// as CSV, with the header
+//
+//	timestamp,kwh,cumulative_kwh
+//
+// where timestamp is the start of the hour in RFC 3339, kwh the state
+// and cumulative_kwh the sum computed by parse.Translate, continued
+// across the chunks. Only the imported energy is written, the chunks
+// of the exported one go to another statistic.
+type StatisticsCSV struct {
+	w      *csv.Writer
+	opts   parse.Options
+	header bool
+	sum    float64
+}
+
+// NewStatisticsCSV returns a sink which writes on w the statistics
+// translated with opts.
+//
+// Closing the sink doesn't close w.
+func NewStatisticsCSV(w io.Writer, opts parse.Options) *StatisticsCSV {
+	return &StatisticsCSV{w: csv.NewWriter(w), opts: opts}
+}
+
+func (s *StatisticsCSV) Write(ctx context.Context, r parse.Result) error {
+	if !s.header {
+		if err := s.w.Write([]string{"timestamp", "kwh", "cumulative_kwh"}); err != nil {
+			return err
+		}
+		s.header = true
+	}
+	if r.Export() || len(r.Reads) == 0 {
+		return nil
+	}
+	stat, err := parse.Translate(r, s.opts)
+	var skipped *parse.SkippedError
+	if errors.As(err, &skipped) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, v := range stat.Stats {
+		err := s.w.Write([]string{
+			v.Start.Format(time.RFC3339),
+			strconv.FormatFloat(math.Round(v.State*1e6)/1e6, 'f', -1, 64),
+			strconv.FormatFloat(math.Round((s.sum+v.Sum)*1e6)/1e6, 'f', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if n := len(stat.Stats); n > 0 {
+		s.sum += stat.Stats[n-1].Sum
+	}
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// Close writes the header if nothing else was written.
+func (s *StatisticsCSV) Close() error {
+	if !s.header {
+		return s.Write(context.Background(), parse.Result{})
+	}
+	return nil
+}
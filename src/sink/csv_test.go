@@ -0,0 +1,94 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestHourlyCSV(t *testing.T) {
+	end := time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)
+	imported := parse.Result{
+		MPRN: "123",
+		Reads: []parse.Read{
+			{Value: 0.2, EndTime: end},
+			{Value: 0.4, EndTime: end.Add(30 * time.Minute)},
+			// Not a complete hour.
+			{Value: 1, EndTime: end.Add(time.Hour)},
+		},
+	}
+	exported := parse.Result{
+		MPRN:      "123",
+		ReadTypes: "Active Export Interval (kW)",
+		Reads: []parse.Read{
+			{Value: 1, EndTime: end},
+			{Value: 1, EndTime: end.Add(30 * time.Minute)},
+		},
+	}
+
+	var buf bytes.Buffer
+	s := NewHourlyCSV(&buf)
+	for _, r := range []parse.Result{imported, exported} {
+		if err := s.Write(context.Background(), r); err != nil {
+			t.Fatalf("Write() unexpected error: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	const want = `mprn,start,end,kwh,direction
+123,2023-01-15T23:00:00Z,2023-01-16T00:00:00Z,0.3,import
+123,2023-01-15T23:00:00Z,2023-01-16T00:00:00Z,1,export
+`
+	if got := buf.String(); got != want {
+		t.Errorf("HourlyCSV output = %s, want %s", got, want)
+	}
+}
+
+func TestHourlyCSV_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewHourlyCSV(&buf).Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+	const want = "mprn,start,end,kwh,direction\n"
+	if got := buf.String(); got != want {
+		t.Errorf("HourlyCSV output = %s, want %s", got, want)
+	}
+}
+
+func TestStatisticsCSV(t *testing.T) {
+	read := func(v float64, h, m int) parse.Read {
+		return parse.Read{Value: v, EndTime: time.Date(2023, 01, 16, h, m, 0, 0, time.UTC)}
+	}
+	chunks := []parse.Result{
+		{MPRN: "123", Reads: []parse.Read{read(1, 0, 30), read(2, 1, 0), read(4, 1, 30), read(6, 2, 0), read(8, 2, 30)}},
+		// Exported energy goes to another statistic.
+		{MPRN: "123", ReadTypes: "Active Export Interval (kW)", Reads: []parse.Read{read(1, 3, 30), read(1, 4, 0), read(1, 4, 30)}},
+		// The sum continues after the hole.
+		{MPRN: "123", Reads: []parse.Read{read(2, 5, 30), read(2, 6, 0), read(2, 6, 30)}},
+	}
+
+	var buf bytes.Buffer
+	s := NewStatisticsCSV(&buf, parse.Options{Align: parse.AlignCenter})
+	for _, r := range chunks {
+		if err := s.Write(context.Background(), r); err != nil {
+			t.Fatalf("Write() unexpected error: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	const want = `timestamp,kwh,cumulative_kwh
+2023-01-16T01:00:00Z,3.5,3.5
+2023-01-16T02:00:00Z,7,10.5
+2023-01-16T06:00:00Z,3,13.5
+`
+	if got := buf.String(); got != want {
+		t.Errorf("StatisticsCSV output = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// domoticzTimeFormat is the format of timestamps in the Domoticz API.
+const domoticzTimeFormat = "2006-01-02 15:04:05"
+
+// Domoticz updates a P1 smart meter virtual sensor in Domoticz.
+//
+// Domoticz wants an ever increasing counter, therefore the sink reads the
+// counter and the last update time of the device and sends only the
+// newer reads, incrementing the counter. If the counter is 0 (a new device)
+// all the reads are sent.
+// Reads are sent with their timestamp, so Domoticz records them in the past.
+type Domoticz struct {
+	client  *http.Client
+	baseURL string
+	idx     int
+	loc     *time.Location
+
+	counterWh float64
+	last      time.Time
+	loaded    bool
+}
+
+// NewDomoticz returns a sink which updates the device idx of the Domoticz at baseURL.
+//
+// The baseURL can contain user and password for basic authentication.
+// The loc is the timezone of the Domoticz server.
+func NewDomoticz(baseURL string, idx int, loc *time.Location) (*Domoticz, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Domoticz URL %q", baseURL)
+	}
+	return &Domoticz{
+		client:  http.DefaultClient,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		idx:     idx,
+		loc:     loc,
+	}, nil
+}
+
+func (d *Domoticz) Write(ctx context.Context, r parse.Result) error {
+	if !d.loaded {
+		if err := d.loadDevice(ctx); err != nil {
+			return fmt.Errorf("cannot read Domoticz device %d: %w", d.idx, err)
+		}
+		d.loaded = true
+	}
+
+	for _, i := range Intervals(r) {
+		if d.counterWh != 0 && !i.End.After(d.last) {
+			continue
+		}
+		d.counterWh += i.KWh * 1000
+		// USAGE1;USAGE2;RETURN1;RETURN2;CONS;PROD;DATE
+		sv := fmt.Sprintf("%.0f;0;0;0;%.0f;0;%s", d.counterWh, i.KW*1000, i.End.In(d.loc).Format(domoticzTimeFormat))
+		q := url.Values{
+			"type":   {"command"},
+			"param":  {"udevice"},
+			"idx":    {strconv.Itoa(d.idx)},
+			"nvalue": {"0"},
+			"svalue": {sv},
+		}
+		if _, err := d.get(ctx, q); err != nil {
+			return fmt.Errorf("cannot update Domoticz device %d: %w", d.idx, err)
+		}
+		d.last = i.End
+	}
+	return nil
+}
+
+// loadDevice reads the current counter and last update time of the device.
+func (d *Domoticz) loadDevice(ctx context.Context) error {
+	q := url.Values{
+		"type":  {"command"},
+		"param": {"getdevices"},
+		"rid":   {strconv.Itoa(d.idx)},
+	}
+	rsp, err := d.get(ctx, q)
+	if err != nil {
+		return err
+	}
+	var dev struct {
+		Result []struct {
+			Data       string
+			LastUpdate string
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(rsp, &dev); err != nil {
+		return err
+	}
+	if len(dev.Result) != 1 {
+		return fmt.Errorf("device not found")
+	}
+	res := dev.Result[0]
+	usage1, _, _ := strings.Cut(res.Data, ";")
+	if d.counterWh, err = strconv.ParseFloat(usage1, 64); err != nil {
+		return fmt.Errorf("device is not a P1 smart meter, data %q", res.Data)
+	}
+	if d.last, err = time.ParseInLocation(domoticzTimeFormat, res.LastUpdate, d.loc); err != nil {
+		return fmt.Errorf("cannot parse last update: %w", err)
+	}
+	return nil
+}
+
+func (d *Domoticz) get(ctx context.Context, q url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/json.htm?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %v", rsp.Status)
+	}
+	var st struct{ Status string }
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, err
+	}
+	if st.Status != "OK" {
+		return nil, fmt.Errorf("status %q", st.Status)
+	}
+	return b, nil
+}
+
+func (d *Domoticz) Close() error { return nil }
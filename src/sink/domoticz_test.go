@@ -0,0 +1,51 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestDomoticz(t *testing.T) {
+	var updates []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch q.Get("param") {
+		case "getdevices":
+			io.WriteString(w, `{"status":"OK","result":[{"Data":"1000;0;0;0;0;0","LastUpdate":"2023-01-15 23:00:00"}]}`)
+		case "udevice":
+			updates = append(updates, q.Get("svalue"))
+			io.WriteString(w, `{"status":"OK"}`)
+		default:
+			t.Errorf("unexpected request %v", r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	d, err := NewDomoticz(srv.URL, 7, time.UTC)
+	if err != nil {
+		t.Fatalf("NewDomoticz() unexpected error: %v", err)
+	}
+	r := parse.Result{Reads: []parse.Read{
+		{Value: 1, EndTime: time.Date(2023, 01, 15, 23, 0, 0, 0, time.UTC)}, // Already sent.
+		{Value: 1, EndTime: time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)},
+		{Value: 2, EndTime: time.Date(2023, 01, 16, 0, 0, 0, 0, time.UTC)},
+	}}
+	if err := d.Write(context.Background(), r); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+
+	want := []string{
+		"1500;0;0;0;1000;0;2023-01-15 23:30:00",
+		"2500;0;0;0;2000;0;2023-01-16 00:00:00",
+	}
+	if diff := cmp.Diff(want, updates); diff != "" {
+		t.Errorf("unexpected updates (+got -want): %v", diff)
+	}
+}
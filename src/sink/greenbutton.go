@@ -0,0 +1,170 @@
+package sink
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// GreenButton writes the data as a Green Button (NAESB ESPI) Atom feed,
+// the format of the "Download My Data" of many utilities, which energy
+// management tools can import.
+//
+// Every block of contiguous reads is a ReadingType entry followed by an
+// IntervalBlock entry with its half hours, the values are in tenths of
+// Wh, since ESPI values are integers.
+type GreenButton struct {
+	w   io.Writer
+	enc *xml.Encoder
+	now func() time.Time
+	// n is the number of entries written.
+	n int
+}
+
+// NewGreenButton returns a sink which writes on w, the feed is
+// terminated by Close.
+//
+// Closing the sink doesn't close w.
+func NewGreenButton(w io.Writer) *GreenButton {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &GreenButton{w: w, enc: enc, now: time.Now}
+}
+
+const (
+	atomNS = "http://www.w3.org/2005/Atom"
+	espiNS = "http://naesb.org/espi"
+)
+
+// ESPI codes, see the ReadingType of the standard.
+const (
+	espiDeltaData        = 4
+	espiElectricity      = 1
+	espiForward          = 1
+	espiReverse          = 19
+	espiWh               = 72
+	espiTenthMultiplier  = -1
+	espiIntervalDuration = 1800
+)
+
+type espiReadingType struct {
+	XMLName               xml.Name `xml:"ReadingType"`
+	NS                    string   `xml:"xmlns,attr"`
+	AccumulationBehaviour int      `xml:"accumulationBehaviour"`
+	Commodity             int      `xml:"commodity"`
+	FlowDirection         int      `xml:"flowDirection"`
+	IntervalLength        int      `xml:"intervalLength"`
+	PowerOfTenMultiplier  int      `xml:"powerOfTenMultiplier"`
+	UOM                   int      `xml:"uom"`
+}
+
+type espiPeriod struct {
+	Duration int64 `xml:"duration"`
+	Start    int64 `xml:"start"`
+}
+
+type espiReading struct {
+	TimePeriod espiPeriod `xml:"timePeriod"`
+	Value      int64      `xml:"value"`
+}
+
+type espiIntervalBlock struct {
+	XMLName  xml.Name      `xml:"IntervalBlock"`
+	NS       string        `xml:"xmlns,attr"`
+	Interval espiPeriod    `xml:"interval"`
+	Readings []espiReading `xml:"IntervalReading"`
+}
+
+type atomEntry struct {
+	XMLName xml.Name `xml:"entry"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Content atomContent
+}
+
+type atomContent struct {
+	XMLName xml.Name `xml:"content"`
+	Type    string   `xml:"type,attr"`
+	Value   any
+}
+
+func (g *GreenButton) Write(ctx context.Context, r parse.Result) error {
+	intervals := Intervals(r)
+	if len(intervals) == 0 {
+		return nil
+	}
+	if err := g.open(); err != nil {
+		return err
+	}
+	flow := espiForward
+	if r.Export() {
+		flow = espiReverse
+	}
+	block := espiIntervalBlock{
+		NS: espiNS,
+		Interval: espiPeriod{
+			Duration: int64(intervals[len(intervals)-1].End.Sub(intervals[0].Start) / time.Second),
+			Start:    intervals[0].Start.Unix(),
+		},
+	}
+	for _, i := range intervals {
+		block.Readings = append(block.Readings, espiReading{
+			TimePeriod: espiPeriod{Duration: espiIntervalDuration, Start: i.Start.Unix()},
+			Value:      int64(math.Round(i.KWh * 10000)),
+		})
+	}
+	title := r.MPRN + " " + intervals[0].Start.Format(time.RFC3339)
+	entries := []atomEntry{
+		{Title: "ReadingType " + title, Content: atomContent{Value: espiReadingType{
+			NS:                    espiNS,
+			AccumulationBehaviour: espiDeltaData,
+			Commodity:             espiElectricity,
+			FlowDirection:         flow,
+			IntervalLength:        espiIntervalDuration,
+			PowerOfTenMultiplier:  espiTenthMultiplier,
+			UOM:                   espiWh,
+		}}},
+		{Title: "IntervalBlock " + title, Content: atomContent{Value: block}},
+	}
+	for _, e := range entries {
+		g.n++
+		e.ID = "urn:esb2ha:" + r.MPRN + ":" + strconv.Itoa(g.n)
+		e.Updated = g.now().UTC().Format(time.RFC3339)
+		e.Content.Type = "application/xml"
+		if err := g.enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return g.enc.Flush()
+}
+
+// open writes the beginning of the feed, before the first entry.
+func (g *GreenButton) open() error {
+	if g.n > 0 {
+		return nil
+	}
+	if _, err := io.WriteString(g.w, xml.Header); err != nil {
+		return err
+	}
+	return g.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: "feed"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: atomNS}}})
+}
+
+func (g *GreenButton) Close() error {
+	if err := g.open(); err != nil {
+		return err
+	}
+	if err := g.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "feed"}}); err != nil {
+		return err
+	}
+	if err := g.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(g.w, "\n")
+	return err
+}
@@ -0,0 +1,78 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestGreenButton(t *testing.T) {
+	end := time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)
+	r := parse.Result{
+		MPRN:  "123",
+		Reads: []parse.Read{{Value: 0.123, EndTime: end}, {Value: 1, EndTime: end.Add(30 * time.Minute)}},
+	}
+
+	var buf bytes.Buffer
+	s := NewGreenButton(&buf)
+	s.now = func() time.Time { return end }
+	if err := s.Write(context.Background(), r); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	const want = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <id>urn:esb2ha:123:1</id>
+    <title>ReadingType 123 2023-01-15T23:00:00Z</title>
+    <updated>2023-01-15T23:30:00Z</updated>
+    <content type="application/xml">
+      <ReadingType xmlns="http://naesb.org/espi">
+        <accumulationBehaviour>4</accumulationBehaviour>
+        <commodity>1</commodity>
+        <flowDirection>1</flowDirection>
+        <intervalLength>1800</intervalLength>
+        <powerOfTenMultiplier>-1</powerOfTenMultiplier>
+        <uom>72</uom>
+      </ReadingType>
+    </content>
+  </entry>
+  <entry>
+    <id>urn:esb2ha:123:2</id>
+    <title>IntervalBlock 123 2023-01-15T23:00:00Z</title>
+    <updated>2023-01-15T23:30:00Z</updated>
+    <content type="application/xml">
+      <IntervalBlock xmlns="http://naesb.org/espi">
+        <interval>
+          <duration>3600</duration>
+          <start>1673823600</start>
+        </interval>
+        <IntervalReading>
+          <timePeriod>
+            <duration>1800</duration>
+            <start>1673823600</start>
+          </timePeriod>
+          <value>615</value>
+        </IntervalReading>
+        <IntervalReading>
+          <timePeriod>
+            <duration>1800</duration>
+            <start>1673825400</start>
+          </timePeriod>
+          <value>5000</value>
+        </IntervalReading>
+      </IntervalBlock>
+    </content>
+  </entry>
+</feed>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("GreenButton output = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// InfluxDB writes the data to an InfluxDB 2.x bucket, with the same
+// points of LineProtocol.
+//
+// Every block of contiguous reads is sent in a single request. Points
+// with the same timestamp overwrite each other, so sending the same
+// reads again is harmless.
+type InfluxDB struct {
+	client   *http.Client
+	writeURL string
+	token    string
+
+	buf bytes.Buffer
+	lp  *LineProtocol
+}
+
+// NewInfluxDB returns a sink which writes to the bucket of the org of
+// the InfluxDB at baseURL, authenticating with the API token.
+func NewInfluxDB(baseURL, org, bucket, token, measurement string) (*InfluxDB, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid InfluxDB URL %q", baseURL)
+	}
+	q := url.Values{"org": {org}, "bucket": {bucket}, "precision": {"ns"}}
+	ret := &InfluxDB{
+		client:   http.DefaultClient,
+		writeURL: strings.TrimSuffix(baseURL, "/") + "/api/v2/write?" + q.Encode(),
+		token:    token,
+	}
+	ret.lp = NewLineProtocol(&ret.buf, measurement)
+	return ret, nil
+}
+
+func (i *InfluxDB) Write(ctx context.Context, r parse.Result) error {
+	i.buf.Reset()
+	if err := i.lp.Write(ctx, r); err != nil {
+		return err
+	}
+	if err := i.lp.Close(); err != nil {
+		return err
+	}
+	if i.buf.Len() == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.writeURL, &i.buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Authorization", "Token "+i.token)
+
+	rsp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	// 204 when the points are written.
+	if rsp.StatusCode != http.StatusNoContent && rsp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return fmt.Errorf("status %v: %s", rsp.Status, msg)
+	}
+	return nil
+}
+
+func (i *InfluxDB) Close() error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestInfluxDB(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+		q := r.URL.Query()
+		if q.Get("org") != "home" || q.Get("bucket") != "esb" || q.Get("precision") != "ns" {
+			t.Errorf("unexpected query %v", q)
+		}
+		if got := r.Header.Get("Authorization"); got != "Token tok" {
+			t.Errorf("Authorization = %q, want Token tok", got)
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s, err := NewInfluxDB(srv.URL+"/", "home", "esb", "tok", "esb_energy")
+	if err != nil {
+		t.Fatalf("NewInfluxDB() unexpected error: %v", err)
+	}
+	end := time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)
+	r := parse.Result{MPRN: "123", MeterSerialNumber: "45", Reads: []parse.Read{{Value: 0.5, EndTime: end}}}
+	for _, r := range []parse.Result{r, {}, r} {
+		if err := s.Write(context.Background(), r); err != nil {
+			t.Fatalf("Write() unexpected error: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	const want = "esb_energy,mprn=123,meter_serial_number=45 kw=0.5,kwh=0.25 1673823600000000000\n"
+	if len(bodies) != 2 || bodies[0] != want || bodies[1] != want {
+		t.Errorf("sent %q, want twice %q, empty chunks skipped", bodies, want)
+	}
+
+	if _, err := NewInfluxDB("localhost:8086", "home", "esb", "tok", "m"); err == nil {
+		t.Error("NewInfluxDB() without scheme = nil error, want error")
+	}
+}
+
+func TestInfluxDB_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":"unauthorized"}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	s, err := NewInfluxDB(srv.URL, "home", "esb", "bad", "m")
+	if err != nil {
+		t.Fatalf("NewInfluxDB() unexpected error: %v", err)
+	}
+	r := parse.Result{Reads: []parse.Read{{Value: 1, EndTime: time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)}}}
+	if err := s.Write(context.Background(), r); err == nil {
+		t.Error("Write() = nil, want error")
+	}
+}
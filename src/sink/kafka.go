@@ -0,0 +1,65 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka publishes the data to a Kafka topic.
+//
+// Messages are JSON encoded Interval, or Chunk if configured so, keyed by MPRN.
+type Kafka struct {
+	w        *kafka.Writer
+	perChunk bool
+}
+
+// NewKafka returns a sink which writes on the topic of the given brokers.
+func NewKafka(brokers []string, topic string, perChunk bool) *Kafka {
+	return &Kafka{
+		w: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{}, // All the messages of a meter go to the same partition.
+			RequiredAcks: kafka.RequireAll,
+		},
+		perChunk: perChunk,
+	}
+}
+
+func (k *Kafka) Write(ctx context.Context, r parse.Result) error {
+	msgs, err := kafkaMessages(r, k.perChunk)
+	if err != nil {
+		return err
+	}
+	return k.w.WriteMessages(ctx, msgs...)
+}
+
+// kafkaMessages converts the reads to messages keyed by MPRN, one per
+// interval or a single one with the chunk if perChunk.
+func kafkaMessages(r parse.Result, perChunk bool) ([]kafka.Message, error) {
+	var payloads []any
+	if perChunk {
+		payloads = append(payloads, ToChunk(r))
+	} else {
+		for _, i := range Intervals(r) {
+			payloads = append(payloads, i)
+		}
+	}
+
+	msgs := make([]kafka.Message, 0, len(payloads))
+	for _, p := range payloads {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, kafka.Message{Key: []byte(r.MPRN), Value: b})
+	}
+	return msgs, nil
+}
+
+func (k *Kafka) Close() error {
+	return k.w.Close()
+}
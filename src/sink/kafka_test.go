@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestKafkaMessages(t *testing.T) {
+	r := parse.Result{
+		MPRN:              "123",
+		MeterSerialNumber: "45",
+		Reads: []parse.Read{
+			{Value: 0.5, EndTime: time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)},
+			{Value: 1, EndTime: time.Date(2023, 01, 16, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	// msg is the key and the value of a message.
+	type msg struct{ Key, Value string }
+	tests := []struct {
+		perChunk bool
+		want     []msg
+	}{
+		{false, []msg{
+			{"123", `{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:00:00Z","end":"2023-01-15T23:30:00Z","kw":0.5,"kwh":0.25}`},
+			{"123", `{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:30:00Z","end":"2023-01-16T00:00:00Z","kw":1,"kwh":0.5}`},
+		}},
+		{true, []msg{
+			{"123", `{"mprn":"123","meter_serial_number":"45","intervals":[` +
+				`{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:00:00Z","end":"2023-01-15T23:30:00Z","kw":0.5,"kwh":0.25},` +
+				`{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:30:00Z","end":"2023-01-16T00:00:00Z","kw":1,"kwh":0.5}]}`},
+		}},
+	}
+	for _, tt := range tests {
+		msgs, err := kafkaMessages(r, tt.perChunk)
+		if err != nil {
+			t.Fatalf("kafkaMessages(perChunk=%v) unexpected error: %v", tt.perChunk, err)
+		}
+		var got []msg
+		for _, m := range msgs {
+			got = append(got, msg{string(m.Key), string(m.Value)})
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("kafkaMessages(perChunk=%v) unexpected diff (-want +got): %v", tt.perChunk, diff)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// LineProtocol writes the data in InfluxDB line protocol, one point per
+// interval, suitable for `influx -import` or the Telegraf file input of
+// InfluxDB 1.x.
+//
+// Points are tagged with mprn and meter_serial_number, have the fields
+// kw and kwh and the start of the interval as timestamp in nanoseconds.
+type LineProtocol struct {
+	w           *bufio.Writer
+	c           io.Closer
+	measurement string
+}
+
+// NewLineProtocol returns a sink which writes on w.
+//
+// Closing the sink flushes the data but doesn't close w.
+func NewLineProtocol(w io.Writer, measurement string) *LineProtocol {
+	return &LineProtocol{w: bufio.NewWriter(w), measurement: measurement}
+}
+
+// NewLineProtocolFile returns a sink which appends to the file at path,
+// creating it if it doesn't exist.
+func NewLineProtocolFile(path, measurement string) (*LineProtocol, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	lp := NewLineProtocol(f, measurement)
+	lp.c = f
+	return lp, nil
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+func (l *LineProtocol) Write(ctx context.Context, r parse.Result) error {
+	for _, i := range Intervals(r) {
+		_, err := fmt.Fprintf(l.w, "%s,mprn=%s,meter_serial_number=%s kw=%v,kwh=%v %d\n",
+			measurementEscaper.Replace(l.measurement),
+			tagEscaper.Replace(i.MPRN), tagEscaper.Replace(i.MeterSerialNumber),
+			i.KW, i.KWh, i.Start.UnixNano())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *LineProtocol) Close() error {
+	err := l.w.Flush()
+	if l.c != nil {
+		if cerr := l.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
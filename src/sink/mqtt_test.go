@@ -0,0 +1,76 @@
+package sink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestUsage(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2023, m, d, 0, 0, 0, 0, dublin) }
+	days := map[time.Time]float64{
+		day(6, 30): 1,
+		day(7, 1):  2,
+		day(7, 13): 4,
+		day(7, 14): 8,
+		day(7, 15): 16,
+	}
+	tests := []struct {
+		name       string
+		now        time.Time
+		billingDay int
+		want       usageTotals
+	}{
+		{
+			name:       "cycle from the first of the month",
+			now:        time.Date(2023, 7, 15, 18, 0, 0, 0, dublin),
+			billingDay: 1,
+			want:       usageTotals{today: 16, yesterday: 8, billingCycle: 30},
+		},
+		{
+			name:       "cycle started last month",
+			now:        time.Date(2023, 7, 15, 18, 0, 0, 0, dublin),
+			billingDay: 20,
+			want:       usageTotals{today: 16, yesterday: 8, billingCycle: 31},
+		},
+		{
+			name:       "ESB didn't publish today yet",
+			now:        time.Date(2023, 7, 16, 0, 30, 0, 0, dublin),
+			billingDay: 14,
+			want:       usageTotals{yesterday: 16, billingCycle: 24},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := usage(days, tc.now, tc.billingDay)
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(usageTotals{})); diff != "" {
+				t.Errorf("usage() unexpected diff (+got -want): %v", diff)
+			}
+		})
+	}
+}
+
+func TestHourly(t *testing.T) {
+	end := time.Date(2023, 1, 15, 22, 30, 0, 0, time.UTC)
+	var reads []parse.Read
+	// From 22:00 to 00:30, the first half of midnight only.
+	for i := range 5 {
+		reads = append(reads, parse.Read{Value: float64(i + 1), EndTime: end.Add(time.Duration(i) * 30 * time.Minute)})
+	}
+	// Starting from the second half of 22:00.
+	got := Hourly(parse.Result{Reads: reads[1:]})
+	want := []HourTotal{
+		{Start: time.Date(2023, 1, 15, 23, 0, 0, 0, time.UTC), KWh: 3.5},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Hourly() unexpected diff (-want +got): %v", diff)
+	}
+
+	got = Hourly(parse.Result{Reads: reads})
+	want = append([]HourTotal{{Start: time.Date(2023, 1, 15, 22, 0, 0, 0, time.UTC), KWh: 1.5}}, want...)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Hourly() unexpected diff (-want +got): %v", diff)
+	}
+}
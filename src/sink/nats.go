@@ -0,0 +1,87 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATS publishes the data to a NATS subject, one JSON encoded Interval per message.
+//
+// The subject can contain the placeholders {mprn} and {serial}, replaced
+// with the MPRN and the meter serial number.
+type NATS struct {
+	nc      *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewNATS connects to the NATS server at url.
+//
+// If useJetStream is true messages are published to JetStream, which
+// acknowledges when they are persisted. Messages have the Nats-Msg-Id
+// header set, so the stream de-duplicates reads published twice.
+func NewNATS(url, subject string, useJetStream bool) (*NATS, error) {
+	nc, err := nats.Connect(url, nats.Name("esb2ha"))
+	if err != nil {
+		return nil, err
+	}
+	n := &NATS{nc: nc, subject: subject}
+	if useJetStream {
+		js, err := jetstream.New(nc)
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+		n.js = js
+	}
+	return n, nil
+}
+
+func (n *NATS) Write(ctx context.Context, r parse.Result) error {
+	msgs, err := natsMessages(n.subject, r)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if n.js != nil {
+			if _, err := n.js.PublishMsg(ctx, msg); err != nil {
+				return err
+			}
+		} else if err := n.nc.PublishMsg(msg); err != nil {
+			return err
+		}
+	}
+	if n.js != nil {
+		return nil
+	}
+	return n.nc.FlushWithContext(ctx)
+}
+
+// natsMessages converts the reads to messages on the subject, with the
+// placeholders replaced, and the Nats-Msg-Id header set.
+func natsMessages(subject string, r parse.Result) ([]*nats.Msg, error) {
+	subject = strings.NewReplacer("{mprn}", r.MPRN, "{serial}", r.MeterSerialNumber).Replace(subject)
+
+	var ret []*nats.Msg
+	for _, i := range Intervals(r) {
+		b, err := json.Marshal(i)
+		if err != nil {
+			return nil, err
+		}
+		msg := nats.NewMsg(subject)
+		msg.Data = b
+		msg.Header.Set(jetstream.MsgIDHeader, i.MPRN+"@"+i.End.UTC().Format(time.RFC3339))
+		ret = append(ret, msg)
+	}
+	return ret, nil
+}
+
+func (n *NATS) Close() error {
+	return n.nc.Drain()
+}
@@ -0,0 +1,41 @@
+package sink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestNATSMessages(t *testing.T) {
+	r := parse.Result{
+		MPRN:              "123",
+		MeterSerialNumber: "45",
+		Reads: []parse.Read{
+			{Value: 0.5, EndTime: time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)},
+			{Value: 1, EndTime: time.Date(2023, 01, 16, 1, 0, 0, 0, time.FixedZone("IST", 3600))},
+		},
+	}
+
+	msgs, err := natsMessages("esb.{mprn}.{serial}", r)
+	if err != nil {
+		t.Fatalf("natsMessages() unexpected error: %v", err)
+	}
+
+	// msg is the subject, the id and the data of a message.
+	type msg struct{ Subject, ID, Data string }
+	var got []msg
+	for _, m := range msgs {
+		got = append(got, msg{m.Subject, m.Header.Get(jetstream.MsgIDHeader), string(m.Data)})
+	}
+	want := []msg{
+		{"esb.123.45", "123@2023-01-15T23:30:00Z", `{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:00:00Z","end":"2023-01-15T23:30:00Z","kw":0.5,"kwh":0.25}`},
+		// The id is in UTC, so it doesn't change with the timezone.
+		{"esb.123.45", "123@2023-01-16T00:00:00Z", `{"mprn":"123","meter_serial_number":"45","start":"2023-01-16T00:30:00+01:00","end":"2023-01-16T01:00:00+01:00","kw":1,"kwh":0.5}`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("natsMessages() unexpected diff (-want +got): %v", diff)
+	}
+}
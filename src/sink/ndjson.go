@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// NDJSON writes the data as newline delimited JSON, one Interval per line.
+type NDJSON struct {
+	enc *json.Encoder
+}
+
+// NewNDJSON returns a sink which writes on w.
+//
+// Closing the sink doesn't close w.
+func NewNDJSON(w io.Writer) *NDJSON {
+	return &NDJSON{enc: json.NewEncoder(w)}
+}
+
+func (n *NDJSON) Write(ctx context.Context, r parse.Result) error {
+	for _, i := range Intervals(r) {
+		if err := n.enc.Encode(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *NDJSON) Close() error {
+	return nil
+}
+
+// JSON writes the data as a single JSON array of Interval, streaming
+// the intervals while they are written.
+type JSON struct {
+	w io.Writer
+	// n is the number of intervals written.
+	n int
+}
+
+// NewJSON returns a sink which writes on w, the array is terminated by
+// Close.
+//
+// Closing the sink doesn't close w.
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{w: w}
+}
+
+func (j *JSON) Write(ctx context.Context, r parse.Result) error {
+	for _, i := range Intervals(r) {
+		b, err := json.Marshal(i)
+		if err != nil {
+			return err
+		}
+		sep := ",\n"
+		if j.n == 0 {
+			sep = "[\n"
+		}
+		if _, err := io.WriteString(j.w, sep); err != nil {
+			return err
+		}
+		if _, err := j.w.Write(b); err != nil {
+			return err
+		}
+		j.n++
+	}
+	return nil
+}
+
+func (j *JSON) Close() error {
+	end := "\n]\n"
+	if j.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
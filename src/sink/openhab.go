@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// openHABTimeFormat is the time format accepted by the persistence REST API.
+const openHABTimeFormat = "2006-01-02T15:04:05.000Z0700"
+
+// openHABTimeout is how long to wait for every request to openHAB.
+const openHABTimeout = 30 * time.Second
+
+// OpenHAB stores the reads in the persistence service of an openHAB item.
+//
+// Every half an hour read is stored as the kWh consumed in the interval,
+// at the interval start time, like the Home Assistant statistics.
+// The REST API stores one state per request, so the states already
+// stored are not sent again.
+type OpenHAB struct {
+	client    *http.Client
+	baseURL   string
+	token     string
+	item      string
+	serviceID string
+}
+
+// NewOpenHAB returns a sink which persists the states of item using the
+// REST API of the openHAB at baseURL.
+//
+// The token is an API token created in the openHAB user profile.
+// If serviceID is empty, the default persistence service is used.
+func NewOpenHAB(baseURL, token, item, serviceID string) *OpenHAB {
+	return &OpenHAB{
+		client:    &http.Client{Timeout: openHABTimeout},
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		token:     token,
+		item:      item,
+		serviceID: serviceID,
+	}
+}
+
+func (o *OpenHAB) Write(ctx context.Context, r parse.Result) error {
+	intervals, unit := Intervals(r), r.Quantity().Unit()
+	if len(intervals) == 0 {
+		return nil
+	}
+	stored, err := o.stored(ctx, intervals[0].Start, intervals[len(intervals)-1].Start)
+	if err != nil {
+		return fmt.Errorf("cannot load the stored states: %w", err)
+	}
+	for _, i := range intervals {
+		if v, ok := stored[i.Start.UnixMilli()]; ok && v == i.KWh {
+			continue
+		}
+		q := url.Values{
+			"time":  {i.Start.Format(openHABTimeFormat)},
+			"state": {strconv.FormatFloat(i.KWh, 'f', -1, 64) + " " + unit},
+		}
+		rsp, err := o.do(ctx, http.MethodPut, q)
+		if err != nil {
+			return fmt.Errorf("cannot store %v: %w", i.Start, err)
+		}
+		rsp.Body.Close()
+	}
+	return nil
+}
+
+// stored returns the states stored from start to end included, by their
+// time in milliseconds since the epoch.
+func (o *OpenHAB) stored(ctx context.Context, start, end time.Time) (map[int64]float64, error) {
+	q := url.Values{
+		"starttime": {start.Format(openHABTimeFormat)},
+		"endtime":   {end.Format(openHABTimeFormat)},
+	}
+	rsp, err := o.do(ctx, http.MethodGet, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	var data struct {
+		Data []struct {
+			Time  int64  `json:"time"`
+			State string `json:"state"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("cannot decode the response: %w", err)
+	}
+	ret := make(map[int64]float64, len(data.Data))
+	for _, d := range data.Data {
+		// The state may have a unit, like "0.25 kWh".
+		value, _, _ := strings.Cut(d.State, " ")
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			ret[d.Time] = v
+		}
+	}
+	return ret, nil
+}
+
+// do sends a request to the persistence API of the item, the caller
+// must close the body of the response.
+func (o *OpenHAB) do(ctx context.Context, method string, q url.Values) (*http.Response, error) {
+	if o.serviceID != "" {
+		q.Set("serviceId", o.serviceID)
+	}
+	u := o.baseURL + "/rest/persistence/items/" + url.PathEscape(o.item) + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+o.token)
+	rsp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		defer rsp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return nil, fmt.Errorf("status %v: %s", rsp.Status, msg)
+	}
+	return rsp, nil
+}
+
+func (o *OpenHAB) Close() error { return nil }
@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestOpenHAB(t *testing.T) {
+	var puts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer secret"; got != want {
+			t.Errorf("Authorization = %q, want %q", got, want)
+		}
+		if r.URL.Path != "/rest/persistence/items/Energy_Meter" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if got := q.Get("serviceId"); got != "rrd4j" {
+			t.Errorf("serviceId = %q, want rrd4j", got)
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if got, want := q.Get("starttime"), "2023-01-15T23:00:00.000Z"; got != want {
+				t.Errorf("starttime = %q, want %q", got, want)
+			}
+			if got, want := q.Get("endtime"), "2023-01-16T00:00:00.000Z"; got != want {
+				t.Errorf("endtime = %q, want %q", got, want)
+			}
+			// The first one is already stored, the second one changed.
+			io.WriteString(w, `{"name":"Energy_Meter","datapoints":"2","data":[{"time":1673823600000,"state":"0.5 kWh"},{"time":1673825400000,"state":"0.1 kWh"}]}`)
+		case http.MethodPut:
+			puts = append(puts, q.Get("time")+" "+q.Get("state"))
+		default:
+			t.Errorf("unexpected method %v", r.Method)
+		}
+	}))
+	defer srv.Close()
+
+	o := NewOpenHAB(srv.URL+"/", "secret", "Energy_Meter", "rrd4j")
+	r := parse.Result{Reads: []parse.Read{
+		{Value: 1, EndTime: time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)},
+		{Value: 1, EndTime: time.Date(2023, 01, 16, 0, 0, 0, 0, time.UTC)},
+		{Value: 3, EndTime: time.Date(2023, 01, 16, 0, 30, 0, 0, time.UTC)},
+	}}
+	if err := o.Write(context.Background(), r); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+
+	want := []string{
+		"2023-01-15T23:30:00.000Z 0.5 kWh",
+		"2023-01-16T00:00:00.000Z 1.5 kWh",
+	}
+	if diff := cmp.Diff(want, puts); diff != "" {
+		t.Errorf("unexpected puts (+got -want): %v", diff)
+	}
+}
+
+func TestOpenHAB_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			io.WriteString(w, `{"data":[]}`)
+			return
+		}
+		http.Error(w, "item not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	o := NewOpenHAB(srv.URL, "secret", "Energy_Meter", "")
+	r := parse.Result{Reads: []parse.Read{{Value: 1, EndTime: time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)}}}
+	if err := o.Write(context.Background(), r); err == nil {
+		t.Error("Write() got nil error, want the status of the response")
+	}
+}
@@ -0,0 +1,162 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+	"golang.org/x/oauth2/google"
+)
+
+const sheetsURL = "https://sheets.googleapis.com/v4/spreadsheets"
+
+// Sheets appends the daily totals to a Google Sheet.
+//
+// Every row contains the date, the kWh and, if a unit rate is set, the cost.
+// Only complete days are appended, and days already present in the first
+// column of the sheet are skipped, so it is safe to publish overlapping data.
+// Rows are sent on Close.
+type Sheets struct {
+	client        *http.Client
+	baseURL       string
+	spreadsheetID string
+	sheet         string
+	unitRate      float64
+
+	days map[string]parse.DailyTotal
+}
+
+// NewSheets returns a sink which appends to the sheet of the spreadsheet.
+//
+// The credentials are the JSON key of a service account, the spreadsheet
+// must be shared with the service account email.
+// If unitRate is not 0, the cost is computed as kWh * unitRate.
+func NewSheets(ctx context.Context, credentials []byte, spreadsheetID, sheet string, unitRate float64) (*Sheets, error) {
+	cfg, err := google.JWTConfigFromJSON(credentials, "https://www.googleapis.com/auth/spreadsheets")
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account key: %w", err)
+	}
+	return &Sheets{
+		client:        cfg.Client(ctx),
+		baseURL:       sheetsURL,
+		spreadsheetID: spreadsheetID,
+		sheet:         sheet,
+		unitRate:      unitRate,
+		days:          map[string]parse.DailyTotal{},
+	}, nil
+}
+
+func (s *Sheets) Write(ctx context.Context, r parse.Result) error {
+	for _, d := range parse.Daily([]parse.Result{r}) {
+		// Gaps may split the same day in different results.
+		k := d.Date.Format("2006-01-02")
+		old := s.days[k]
+		d.KWh += old.KWh
+		d.Reads += old.Reads
+		s.days[k] = d
+	}
+	return nil
+}
+
+func (s *Sheets) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	existing, err := s.existingDates(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot read the sheet: %w", err)
+	}
+
+	var dates []string
+	for k, d := range s.days {
+		if d.Complete() && !existing[k] {
+			dates = append(dates, k)
+		}
+	}
+	if len(dates) == 0 {
+		return nil
+	}
+	sort.Strings(dates)
+
+	var rows [][]any
+	for _, k := range dates {
+		row := []any{k, round3(s.days[k].KWh)}
+		if s.unitRate != 0 {
+			row = append(row, round3(s.days[k].KWh*s.unitRate))
+		}
+		rows = append(rows, row)
+	}
+
+	body, err := json.Marshal(map[string]any{"values": rows})
+	if err != nil {
+		return err
+	}
+	u := s.rangeURL(s.sheet+"!A:C") + ":append?valueInputOption=USER_ENTERED&insertDataOption=INSERT_ROWS"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := s.do(req); err != nil {
+		return fmt.Errorf("cannot append to the sheet: %w", err)
+	}
+	return nil
+}
+
+// existingDates returns the values in the first column of the sheet.
+func (s *Sheets) existingDates(ctx context.Context) (map[string]bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.rangeURL(s.sheet+"!A:A"), nil)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	var rsp struct {
+		Values [][]string `json:"values"`
+	}
+	if err := json.Unmarshal(b, &rsp); err != nil {
+		return nil, err
+	}
+	ret := map[string]bool{}
+	for _, row := range rsp.Values {
+		if len(row) > 0 {
+			ret[row[0]] = true
+		}
+	}
+	return ret, nil
+}
+
+func (s *Sheets) rangeURL(rng string) string {
+	return s.baseURL + "/" + url.PathEscape(s.spreadsheetID) + "/values/" + url.PathEscape(rng)
+}
+
+func (s *Sheets) do(req *http.Request) ([]byte, error) {
+	rsp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %v: %s", rsp.Status, b)
+	}
+	return b, nil
+}
+
+// round3 rounds to 3 decimals, Wh precision is more than enough for a spreadsheet.
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestSheets(t *testing.T) {
+	var appended [][]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			io.WriteString(w, `{"values":[["Date"],["2023-01-14"]]}`)
+		case http.MethodPost:
+			var body struct{ Values [][]any }
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("cannot decode append request: %v", err)
+			}
+			appended = append(appended, body.Values...)
+			io.WriteString(w, `{}`)
+		}
+	}))
+	defer srv.Close()
+
+	s := &Sheets{
+		client:        srv.Client(),
+		baseURL:       srv.URL,
+		spreadsheetID: "id",
+		sheet:         "Sheet1",
+		unitRate:      0.5,
+		days:          map[string]parse.DailyTotal{},
+	}
+
+	// Two full days, the first is already in the sheet, and the beginning of a third one.
+	var reads []parse.Read
+	start := time.Date(2023, 01, 14, 0, 30, 0, 0, time.UTC)
+	for i := 0; i < 48*2+4; i++ {
+		reads = append(reads, parse.Read{Value: 1, EndTime: start.Add(time.Duration(i) * 30 * time.Minute)})
+	}
+	// Split the second day in two results, as it happens with gaps.
+	ctx := context.Background()
+	if err := s.Write(ctx, parse.Result{Reads: reads[:60]}); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	if err := s.Write(ctx, parse.Result{Reads: reads[60:]}); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	want := [][]any{{"2023-01-15", 24.0, 12.0}}
+	if diff := cmp.Diff(want, appended); diff != "" {
+		t.Errorf("unexpected rows appended (+got -want): %v", diff)
+	}
+}
@@ -0,0 +1,88 @@
+// Package sink implements destinations, other than Home Assistant, for the parsed ESB data.
+package sink
+
+import (
+	"context"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// Sink receives the parsed ESB data.
+type Sink interface {
+	// Write sends a block of contiguous reads, as returned by parse.HDF.
+	Write(ctx context.Context, r parse.Result) error
+	// Close flushes any pending data and releases the resources.
+	Close() error
+}
+
+// StatisticsSink receives the hourly statistics of the uploads, as
+// translated for Home Assistant. A ha.Connection is one.
+type StatisticsSink interface {
+	// WriteStatistics writes a batch of hours of a statistic, the hours
+	// already written are replaced.
+	WriteStatistics(ctx context.Context, stat ha.Statistics) error
+}
+
+// BatchStatisticsSink is a StatisticsSink which writes many batches
+// faster together than one at a time. A ha.Connection is one.
+type BatchStatisticsSink interface {
+	StatisticsSink
+	// WriteStatisticsBatch writes the batches and returns the error of
+	// each of them, nil if written.
+	WriteStatisticsBatch(ctx context.Context, stats []ha.Statistics) []error
+}
+
+// Interval is a single half an hour read.
+//
+// This is the unit published by the sinks which send one message per
+// read, its JSON encoding is documented in documentation/interval.schema.json.
+type Interval struct {
+	MPRN              string    `json:"mprn"`
+	MeterSerialNumber string    `json:"meter_serial_number"`
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	// KW is the average power in the interval, m³/h for the reads of
+	// the volume, see parse.Quantity.
+	KW float64 `json:"kw"`
+	// KWh is the energy consumed in the interval, m³ for the reads of
+	// the volume.
+	KWh float64 `json:"kwh"`
+}
+
+// Chunk is a block of contiguous intervals.
+//
+// This is the unit published by the sinks which send one message per chunk.
+type Chunk struct {
+	MPRN              string     `json:"mprn"`
+	MeterSerialNumber string     `json:"meter_serial_number"`
+	Intervals         []Interval `json:"intervals"`
+}
+
+// Intervals converts the reads in intervals.
+func Intervals(r parse.Result) []Interval {
+	q := r.Quantity()
+	ret := make([]Interval, 0, len(r.Reads))
+	for _, rd := range r.Reads {
+		amount := q.Amount(rd.Value)
+		ret = append(ret, Interval{
+			MPRN:              r.MPRN,
+			MeterSerialNumber: r.MeterSerialNumber,
+			Start:             rd.EndTime.Add(-30 * time.Minute),
+			End:               rd.EndTime,
+			KW:                amount * 2, // The average of half an hour.
+			KWh:               amount,
+		})
+	}
+	return ret
+}
+
+// ToChunk converts the result in a chunk.
+func ToChunk(r parse.Result) Chunk {
+	return Chunk{
+		MPRN:              r.MPRN,
+		MeterSerialNumber: r.MeterSerialNumber,
+		Intervals:         Intervals(r),
+	}
+}
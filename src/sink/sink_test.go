@@ -0,0 +1,127 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestIntervals(t *testing.T) {
+	end := time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)
+	r := parse.Result{
+		MPRN:              "123",
+		MeterSerialNumber: "45",
+		Reads:             []parse.Read{{Value: 0.5, EndTime: end}},
+	}
+
+	got, err := json.Marshal(Intervals(r))
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error: %v", err)
+	}
+	// This is a public contract, see documentation/interval.schema.json.
+	const want = `[{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:00:00Z","end":"2023-01-15T23:30:00Z","kw":0.5,"kwh":0.25}]`
+	if string(got) != want {
+		t.Errorf("Intervals() = %s, want %s", got, want)
+	}
+
+	// Energy reads are already the kWh of the half an hour.
+	r.ReadTypes = "Gas Interval (kWh)"
+	got, err = json.Marshal(Intervals(r))
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error: %v", err)
+	}
+	const wantEnergy = `[{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:00:00Z","end":"2023-01-15T23:30:00Z","kw":1,"kwh":0.5}]`
+	if string(got) != wantEnergy {
+		t.Errorf("Intervals() of energy = %s, want %s", got, wantEnergy)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	end := time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)
+	r := parse.Result{
+		MPRN:              "123",
+		MeterSerialNumber: "45",
+		Reads:             []parse.Read{{Value: 0.5, EndTime: end}, {Value: 1, EndTime: end.Add(30 * time.Minute)}},
+	}
+
+	var buf bytes.Buffer
+	s := NewNDJSON(&buf)
+	if err := s.Write(context.Background(), r); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	const want = `{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:00:00Z","end":"2023-01-15T23:30:00Z","kw":0.5,"kwh":0.25}
+{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:30:00Z","end":"2023-01-16T00:00:00Z","kw":1,"kwh":0.5}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("NDJSON output = %s, want %s", got, want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	end := time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)
+	r := parse.Result{
+		MPRN:              "123",
+		MeterSerialNumber: "45",
+		Reads:             []parse.Read{{Value: 0.5, EndTime: end}, {Value: 1, EndTime: end.Add(30 * time.Minute)}},
+	}
+
+	var buf bytes.Buffer
+	s := NewJSON(&buf)
+	if err := s.Write(context.Background(), r); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	// An empty chunk doesn't break the array.
+	if err := s.Write(context.Background(), parse.Result{}); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	const want = `[
+{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:00:00Z","end":"2023-01-15T23:30:00Z","kw":0.5,"kwh":0.25},
+{"mprn":"123","meter_serial_number":"45","start":"2023-01-15T23:30:00Z","end":"2023-01-16T00:00:00Z","kw":1,"kwh":0.5}
+]
+`
+	if got := buf.String(); got != want {
+		t.Errorf("JSON output = %s, want %s", got, want)
+	}
+
+	buf.Reset()
+	if err := NewJSON(&buf).Close(); err != nil || buf.String() != "[]\n" {
+		t.Errorf("JSON output without data = %q, %v, want []", buf.String(), err)
+	}
+}
+
+func TestLineProtocol(t *testing.T) {
+	end := time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)
+	r := parse.Result{
+		MPRN:              "123",
+		MeterSerialNumber: "4 5",
+		Reads:             []parse.Read{{Value: 0.5, EndTime: end}, {Value: 1, EndTime: end.Add(30 * time.Minute)}},
+	}
+
+	var buf bytes.Buffer
+	s := NewLineProtocol(&buf, "esb energy")
+	if err := s.Write(context.Background(), r); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	const want = `esb\ energy,mprn=123,meter_serial_number=4\ 5 kw=0.5,kwh=0.25 1673823600000000000
+esb\ energy,mprn=123,meter_serial_number=4\ 5 kw=1,kwh=0.5 1673825400000000000
+`
+	if got := buf.String(); got != want {
+		t.Errorf("LineProtocol output = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite/types"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// timestreamBatchSize is the maximum number of records per WriteRecords call.
+const timestreamBatchSize = 100
+
+// Timestream writes the reads to an Amazon Timestream table.
+//
+// Every half an hour read is a multi measure record, at the interval
+// start time, with kw and kwh measures and mprn and meter_serial_number
+// dimensions.
+type Timestream struct {
+	client          timestreamAPI
+	database, table string
+}
+
+// timestreamAPI is the part of the Timestream client used by the sink,
+// replaced by the tests.
+type timestreamAPI interface {
+	WriteRecords(context.Context, *timestreamwrite.WriteRecordsInput, ...func(*timestreamwrite.Options)) (*timestreamwrite.WriteRecordsOutput, error)
+}
+
+// NewTimestream returns a sink which writes to the table of the database.
+//
+// AWS credentials and region are loaded in the usual way, from the
+// environment or the shared configuration files.
+func NewTimestream(ctx context.Context, database, table string) (*Timestream, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load AWS configuration: %w", err)
+	}
+	return &Timestream{
+		client:   timestreamwrite.NewFromConfig(cfg),
+		database: database,
+		table:    table,
+	}, nil
+}
+
+func (t *Timestream) Write(ctx context.Context, r parse.Result) error {
+	records := timestreamRecords(r)
+	for len(records) > 0 {
+		n := min(len(records), timestreamBatchSize)
+		_, err := t.client.WriteRecords(ctx, &timestreamwrite.WriteRecordsInput{
+			DatabaseName: aws.String(t.database),
+			TableName:    aws.String(t.table),
+			CommonAttributes: &types.Record{
+				Dimensions: []types.Dimension{
+					{Name: aws.String("mprn"), Value: aws.String(r.MPRN)},
+					{Name: aws.String("meter_serial_number"), Value: aws.String(r.MeterSerialNumber)},
+				},
+				MeasureName:      aws.String("consumption"),
+				MeasureValueType: types.MeasureValueTypeMulti,
+				TimeUnit:         types.TimeUnitSeconds,
+			},
+			Records: records[:n],
+		})
+		var rejected *types.RejectedRecordsException
+		if errors.As(err, &rejected) {
+			// Records rejected because they are already there with the same
+			// values are not a problem, anything else is.
+			for _, rr := range rejected.RejectedRecords {
+				if rr.ExistingVersion == nil {
+					return fmt.Errorf("record rejected: %s", aws.ToString(rr.Reason))
+				}
+			}
+		} else if err != nil {
+			return err
+		}
+		records = records[n:]
+	}
+	return nil
+}
+
+// timestreamRecords converts the reads to records, without the common attributes.
+func timestreamRecords(r parse.Result) []types.Record {
+	var ret []types.Record
+	for _, i := range Intervals(r) {
+		ret = append(ret, types.Record{
+			Time: aws.String(strconv.FormatInt(i.Start.Unix(), 10)),
+			MeasureValues: []types.MeasureValue{
+				{Name: aws.String("kw"), Value: aws.String(strconv.FormatFloat(i.KW, 'f', -1, 64)), Type: types.MeasureValueTypeDouble},
+				{Name: aws.String("kwh"), Value: aws.String(strconv.FormatFloat(i.KWh, 'f', -1, 64)), Type: types.MeasureValueTypeDouble},
+			},
+		})
+	}
+	return ret
+}
+
+func (t *Timestream) Close() error { return nil }
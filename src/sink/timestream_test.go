@@ -0,0 +1,98 @@
+package sink
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite"
+	"github.com/aws/aws-sdk-go-v2/service/timestreamwrite/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// fakeTimestream records the inputs of WriteRecords, and fails with err.
+type fakeTimestream struct {
+	inputs []*timestreamwrite.WriteRecordsInput
+	err    error
+}
+
+func (f *fakeTimestream) WriteRecords(_ context.Context, in *timestreamwrite.WriteRecordsInput, _ ...func(*timestreamwrite.Options)) (*timestreamwrite.WriteRecordsOutput, error) {
+	f.inputs = append(f.inputs, in)
+	return &timestreamwrite.WriteRecordsOutput{}, f.err
+}
+
+var ignoreTimestreamUnexported = cmpopts.IgnoreUnexported(types.Record{}, types.Dimension{}, types.MeasureValue{})
+
+func TestTimestream(t *testing.T) {
+	start := time.Date(2023, 01, 15, 23, 0, 0, 0, time.UTC)
+	r := parse.Result{MPRN: "123", MeterSerialNumber: "45"}
+	for i := 0; i < 150; i++ {
+		r.Reads = append(r.Reads, parse.Read{Value: 0.5, EndTime: start.Add(time.Duration(i+1) * 30 * time.Minute)})
+	}
+
+	f := &fakeTimestream{}
+	ts := &Timestream{client: f, database: "db", table: "tbl"}
+	if err := ts.Write(context.Background(), r); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+
+	if len(f.inputs) != 2 {
+		t.Fatalf("WriteRecords() called %d times, want 2", len(f.inputs))
+	}
+	if got0, got1 := len(f.inputs[0].Records), len(f.inputs[1].Records); got0 != 100 || got1 != 50 {
+		t.Errorf("got batches of %d and %d records, want 100 and 50", got0, got1)
+	}
+	in := f.inputs[0]
+	if got, got2 := aws.ToString(in.DatabaseName), aws.ToString(in.TableName); got != "db" || got2 != "tbl" {
+		t.Errorf("got table %s.%s, want db.tbl", got, got2)
+	}
+	wantCommon := &types.Record{
+		Dimensions: []types.Dimension{
+			{Name: aws.String("mprn"), Value: aws.String("123")},
+			{Name: aws.String("meter_serial_number"), Value: aws.String("45")},
+		},
+		MeasureName:      aws.String("consumption"),
+		MeasureValueType: types.MeasureValueTypeMulti,
+		TimeUnit:         types.TimeUnitSeconds,
+	}
+	if diff := cmp.Diff(wantCommon, in.CommonAttributes, ignoreTimestreamUnexported); diff != "" {
+		t.Errorf("unexpected common attributes (-want +got): %v", diff)
+	}
+	// The records are at the start of the interval, in seconds.
+	wantFirst := types.Record{
+		Time: aws.String("1673823600"),
+		MeasureValues: []types.MeasureValue{
+			{Name: aws.String("kw"), Value: aws.String("0.5"), Type: types.MeasureValueTypeDouble},
+			{Name: aws.String("kwh"), Value: aws.String("0.25"), Type: types.MeasureValueTypeDouble},
+		},
+	}
+	if diff := cmp.Diff(wantFirst, in.Records[0], ignoreTimestreamUnexported); diff != "" {
+		t.Errorf("unexpected first record (-want +got): %v", diff)
+	}
+	if got, want := aws.ToString(f.inputs[1].Records[49].Time), "1674091800"; got != want {
+		t.Errorf("last record time = %s, want %s", got, want)
+	}
+}
+
+func TestTimestream_Rejected(t *testing.T) {
+	r := parse.Result{Reads: []parse.Read{{Value: 1, EndTime: time.Date(2023, 01, 15, 23, 30, 0, 0, time.UTC)}}}
+
+	tests := []struct {
+		name    string
+		record  types.RejectedRecord
+		wantErr bool
+	}{
+		{"already there", types.RejectedRecord{ExistingVersion: aws.Int64(1)}, false},
+		{"invalid", types.RejectedRecord{Reason: aws.String("invalid")}, true},
+	}
+	for _, tt := range tests {
+		f := &fakeTimestream{err: &types.RejectedRecordsException{RejectedRecords: []types.RejectedRecord{tt.record}}}
+		ts := &Timestream{client: f}
+		if err := ts.Write(context.Background(), r); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Write() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/lorentz83/esb2ha/ha"
+)
+
+// reportStatus updates the Home Assistant status sensor, if configured,
+// with the result of a sync.
+//
+// The state is "ok" or "error". The time of the last success and the
+// last error are kept from the previous state, so failures show up in
+// Home Assistant next to when it last worked.
+// A zero lag means unknown. Errors are only reported.
+func (c *uploadCmd) reportStatus(ctx context.Context, mprn string, lag time.Duration, syncErr error) {
+	if c.statusSensor == "" {
+		return
+	}
+	_, prev, _, err := ha.State(ctx, c.server, c.token, c.statusSensor)
+	if err != nil {
+		// Better losing the history than not reporting the status.
+		slog.Warn("cannot read the status sensor", "sensor", c.statusSensor, "err", err)
+	}
+
+	attrs := map[string]any{
+		"friendly_name":   "esb2ha " + mprn,
+		"icon":            "mdi:transmission-tower",
+		"entity_category": "diagnostic",
+		"mprn":            mprn,
+	}
+	for _, k := range []string{"last_success", "last_error", "last_error_time", "lag_hours"} {
+		if v, ok := prev[k]; ok {
+			attrs[k] = v
+		}
+	}
+	now := time.Now().Format(time.RFC3339)
+	state := "ok"
+	if syncErr != nil {
+		state = "error"
+		attrs["last_error"] = syncErr.Error()
+		attrs["last_error_time"] = now
+	} else {
+		attrs["last_success"] = now
+	}
+	if lag != 0 {
+		attrs["lag_hours"] = int(lag.Hours())
+	}
+
+	if err := ha.SetState(ctx, c.server, c.token, c.statusSensor, state, attrs); err != nil {
+		slog.Warn("cannot update the status sensor", "sensor", c.statusSensor, "err", err)
+	}
+}
@@ -0,0 +1,636 @@
+// Package store implements the local SQLite database where esb2ha keeps its state.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // SQLite driver, pure Go so we can still build static binaries.
+)
+
+// schema is executed every time the database is opened.
+// Every statement must be idempotent.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS downloads (
+		mprn          TEXT PRIMARY KEY,
+		hash          TEXT NOT NULL,
+		downloaded_at INTEGER NOT NULL,
+		changed_at    INTEGER NOT NULL
+	)`,
+	// The hash of the last download uploaded to each statistic.
+	`CREATE TABLE IF NOT EXISTS uploaded_downloads (
+		mprn         TEXT NOT NULL,
+		statistic_id TEXT NOT NULL,
+		hash         TEXT NOT NULL,
+		PRIMARY KEY (mprn, statistic_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS outages (
+		id         TEXT PRIMARY KEY,
+		type       TEXT NOT NULL,
+		start      INTEGER NOT NULL,
+		restore    INTEGER NOT NULL,
+		last_seen  INTEGER NOT NULL
+	)`,
+	// Append only audit log of the uploads.
+	`CREATE TABLE IF NOT EXISTS uploads (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		time         INTEGER NOT NULL,
+		target       TEXT NOT NULL,
+		statistic_id TEXT NOT NULL,
+		range_start  INTEGER NOT NULL,
+		range_end    INTEGER NOT NULL,
+		points       INTEGER NOT NULL,
+		sum_before   REAL NOT NULL,
+		sum_after    REAL NOT NULL,
+		error        TEXT NOT NULL
+	)`,
+	// The last hour acknowledged by the current upload of a statistic.
+	`CREATE TABLE IF NOT EXISTS upload_progress (
+		statistic_id TEXT PRIMARY KEY,
+		acked_until  INTEGER NOT NULL,
+		done         INTEGER NOT NULL
+	)`,
+	// When new data was first seen, to learn when ESB publishes it.
+	`CREATE TABLE IF NOT EXISTS publications (
+		mprn         TEXT NOT NULL,
+		published_at INTEGER NOT NULL
+	)`,
+	// The reads estimated by esb2ha, to tell when ESB publishes them.
+	`CREATE TABLE IF NOT EXISTS estimated_reads (
+		mprn     TEXT NOT NULL,
+		end_time INTEGER NOT NULL,
+		PRIMARY KEY (mprn, end_time)
+	)`,
+	// The hours uploaded for each statistic, to send only the changed ones.
+	`CREATE TABLE IF NOT EXISTS uploaded_hours (
+		statistic_id TEXT NOT NULL,
+		start        INTEGER NOT NULL,
+		hash         TEXT NOT NULL,
+		sum          REAL NOT NULL,
+		PRIMARY KEY (statistic_id, start)
+	)`,
+	// Every read downloaded, the local history of the meters.
+	`CREATE TABLE IF NOT EXISTS reads (
+		mprn       TEXT NOT NULL,
+		read_type  TEXT NOT NULL,
+		end_time   INTEGER NOT NULL,
+		serial     TEXT NOT NULL,
+		value      REAL NOT NULL,
+		first_seen INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (mprn, read_type, end_time)
+	)`,
+}
+
+// Store is the local database.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the database at path, creating it if it doesn't exist.
+//
+// The database can be opened more than once, also by different
+// processes: writes wait for each other instead of failing.
+func Open(path string) (*Store, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", path+sep+"_pragma=busy_timeout(10000)")
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range schema {
+		if _, err := db.Exec(s); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("cannot initialize database: %w", err)
+		}
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Download describes the last HDF file downloaded for a MPRN.
+type Download struct {
+	MPRN string
+	// Hash is the hash of the file content.
+	Hash string
+	// DownloadedAt is the last time the file was downloaded.
+	DownloadedAt time.Time
+	// ChangedAt is the first time the file with this hash was downloaded.
+	ChangedAt time.Time
+}
+
+// LastDownload returns the last download of the MPRN.
+//
+// It returns false if there is no download recorded.
+func (s *Store) LastDownload(mprn string) (Download, bool, error) {
+	d := Download{MPRN: mprn}
+	var downloaded, changed int64
+	err := s.db.QueryRow(`SELECT hash, downloaded_at, changed_at FROM downloads WHERE mprn = ?`, mprn).Scan(&d.Hash, &downloaded, &changed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, false, nil
+	}
+	if err != nil {
+		return d, false, err
+	}
+	d.DownloadedAt, d.ChangedAt = time.Unix(downloaded, 0), time.Unix(changed, 0)
+	return d, true, nil
+}
+
+// RecordDownload records a new download and returns it.
+//
+// ChangedAt is updated only if the hash differs from the last download,
+// in that case the download is also recorded as a publication.
+func (s *Store) RecordDownload(mprn, hash string, now time.Time) (Download, error) {
+	last, ok, err := s.LastDownload(mprn)
+	if err != nil {
+		return Download{}, err
+	}
+	if !ok || last.Hash != hash {
+		if _, err := s.db.Exec(`INSERT INTO publications (mprn, published_at) VALUES (?, ?)`, mprn, now.Unix()); err != nil {
+			return Download{}, err
+		}
+	}
+	_, err = s.db.Exec(`INSERT INTO downloads (mprn, hash, downloaded_at, changed_at) VALUES (?1, ?2, ?3, ?3)
+		ON CONFLICT (mprn) DO UPDATE SET
+			changed_at = CASE WHEN hash = excluded.hash THEN changed_at ELSE excluded.changed_at END,
+			hash = excluded.hash,
+			downloaded_at = excluded.downloaded_at`, mprn, hash, now.Unix())
+	if err != nil {
+		return Download{}, err
+	}
+	d, _, err := s.LastDownload(mprn)
+	return d, err
+}
+
+// UploadedHash returns the hash of the last download of the MPRN
+// uploaded to the statistic, see RecordUploadedHash.
+//
+// It returns false if there is none.
+func (s *Store) UploadedHash(mprn, statisticID string) (string, bool, error) {
+	var hash string
+	err := s.db.QueryRow(`SELECT hash FROM uploaded_downloads WHERE mprn = ? AND statistic_id = ?`, mprn, statisticID).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	return hash, err == nil, err
+}
+
+// RecordUploadedHash records that the download with the hash was
+// uploaded to the statistic, so the same data isn't uploaded again.
+func (s *Store) RecordUploadedHash(mprn, statisticID, hash string) error {
+	_, err := s.db.Exec(`INSERT INTO uploaded_downloads (mprn, statistic_id, hash) VALUES (?, ?, ?)
+		ON CONFLICT (mprn, statistic_id) DO UPDATE SET hash = excluded.hash`, mprn, statisticID, hash)
+	return err
+}
+
+// Publications returns when new data of the MPRN was first seen, newest
+// first, up to limit.
+func (s *Store) Publications(mprn string, limit int) ([]time.Time, error) {
+	rows, err := s.db.Query(`SELECT published_at FROM publications WHERE mprn = ? ORDER BY published_at DESC LIMIT ?`, mprn, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []time.Time
+	for rows.Next() {
+		var t int64
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		ret = append(ret, time.Unix(t, 0))
+	}
+	return ret, rows.Err()
+}
+
+// Outage is a power outage recorded from ESB PowerCheck.
+type Outage struct {
+	ID, Type string
+	Start    time.Time
+	// End is the estimated restore time or, if later, the last time the
+	// outage was seen in PowerCheck.
+	End time.Time
+}
+
+// RecordOutage records an outage seen at the given time.
+//
+// Recording the same outage again updates its end.
+func (s *Store) RecordOutage(id, typ string, start, restore, now time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO outages (id, type, start, restore, last_seen) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			restore = excluded.restore,
+			last_seen = excluded.last_seen`, id, typ, start.Unix(), restore.Unix(), now.Unix())
+	return err
+}
+
+// Outages returns the outages overlapping the period from-to, sorted by start.
+func (s *Store) Outages(from, to time.Time) ([]Outage, error) {
+	rows, err := s.db.Query(`SELECT id, type, start, MAX(restore, last_seen) AS end FROM outages
+		WHERE start < ? AND end > ? ORDER BY start`, to.Unix(), from.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []Outage
+	for rows.Next() {
+		var o Outage
+		var start, end int64
+		if err := rows.Scan(&o.ID, &o.Type, &start, &end); err != nil {
+			return nil, err
+		}
+		o.Start, o.End = time.Unix(start, 0), time.Unix(end, 0)
+		ret = append(ret, o)
+	}
+	return ret, rows.Err()
+}
+
+// Upload is an entry of the audit log.
+type Upload struct {
+	ID int64
+	// Time is when the upload happened.
+	Time time.Time
+	// Target is where the data was sent, like home_assistant:host:port.
+	Target      string
+	StatisticID string
+	// From and To are the time range of the uploaded data.
+	From, To time.Time
+	// Points is the number of data points uploaded.
+	Points int
+	// SumBefore and SumAfter are the cumulative values before the first
+	// and after the last data point.
+	SumBefore, SumAfter float64
+	// Error is empty if the upload succeeded.
+	Error string
+}
+
+// RecordUpload appends an upload to the audit log.
+func (s *Store) RecordUpload(u Upload) error {
+	_, err := s.db.Exec(`INSERT INTO uploads (time, target, statistic_id, range_start, range_end, points, sum_before, sum_after, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		u.Time.Unix(), u.Target, u.StatisticID, u.From.Unix(), u.To.Unix(), u.Points, u.SumBefore, u.SumAfter, u.Error)
+	return err
+}
+
+// Uploads returns the most recent uploads, newest first.
+func (s *Store) Uploads(limit int) ([]Upload, error) {
+	rows, err := s.db.Query(`SELECT id, time, target, statistic_id, range_start, range_end, points, sum_before, sum_after, error
+		FROM uploads ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []Upload
+	for rows.Next() {
+		var u Upload
+		var t, from, to int64
+		if err := rows.Scan(&u.ID, &t, &u.Target, &u.StatisticID, &from, &to, &u.Points, &u.SumBefore, &u.SumAfter, &u.Error); err != nil {
+			return nil, err
+		}
+		u.Time, u.From, u.To = time.Unix(t, 0), time.Unix(from, 0), time.Unix(to, 0)
+		ret = append(ret, u)
+	}
+	return ret, rows.Err()
+}
+
+// UploadProgress returns the start of the last hour acknowledged by an
+// upload of the statistic which didn't finish.
+//
+// It returns false if the last upload finished, or there is none.
+func (s *Store) UploadProgress(statisticID string) (time.Time, bool, error) {
+	var until int64
+	var done bool
+	err := s.db.QueryRow(`SELECT acked_until, done FROM upload_progress WHERE statistic_id = ?`, statisticID).Scan(&until, &done)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil || done {
+		return time.Time{}, false, err
+	}
+	return time.Unix(until, 0), true, nil
+}
+
+// AckUpload records that the statistic is uploaded up to the hour
+// starting at until, and the upload is not finished yet.
+func (s *Store) AckUpload(statisticID string, until time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO upload_progress (statistic_id, acked_until, done) VALUES (?, ?, 0)
+		ON CONFLICT (statistic_id) DO UPDATE SET
+			acked_until = excluded.acked_until,
+			done = 0`, statisticID, until.Unix())
+	return err
+}
+
+// FinishUpload records that the upload of the statistic is complete.
+func (s *Store) FinishUpload(statisticID string) error {
+	_, err := s.db.Exec(`UPDATE upload_progress SET done = 1 WHERE statistic_id = ?`, statisticID)
+	return err
+}
+
+// UploadedHour is an hour of a statistic uploaded to Home Assistant.
+type UploadedHour struct {
+	Start time.Time
+	// Hash identifies the uploaded values.
+	Hash string
+	// Sum is the uploaded cumulative value.
+	Sum float64
+}
+
+// UploadedHours returns the uploaded hours of the statistic starting
+// between from and to, included, by unix time of their start.
+func (s *Store) UploadedHours(statisticID string, from, to time.Time) (map[int64]UploadedHour, error) {
+	rows, err := s.db.Query(`SELECT start, hash, sum FROM uploaded_hours
+		WHERE statistic_id = ? AND start BETWEEN ? AND ?`, statisticID, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ret := map[int64]UploadedHour{}
+	for rows.Next() {
+		var h UploadedHour
+		var start int64
+		if err := rows.Scan(&start, &h.Hash, &h.Sum); err != nil {
+			return nil, err
+		}
+		h.Start = time.Unix(start, 0)
+		ret[start] = h
+	}
+	return ret, rows.Err()
+}
+
+// RecordUploadedHours records the hours as uploaded, replacing the
+// previous uploads of the same hours.
+func (s *Store) RecordUploadedHours(statisticID string, hours []UploadedHour) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO uploaded_hours (statistic_id, start, hash, sum) VALUES (?, ?, ?, ?)
+		ON CONFLICT (statistic_id, start) DO UPDATE SET
+			hash = excluded.hash,
+			sum = excluded.sum`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, h := range hours {
+		if _, err := stmt.Exec(statisticID, h.Start.Unix(), h.Hash, h.Sum); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ReplaceEstimated replaces the estimated reads of the MPRN ending
+// between from and to, included, with the given ones.
+//
+// It returns the reads which were estimated before but not anymore,
+// because ESB published them in the meanwhile.
+func (s *Store) ReplaceEstimated(mprn string, from, to time.Time, ends []time.Time) ([]time.Time, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT end_time FROM estimated_reads
+		WHERE mprn = ? AND end_time BETWEEN ? AND ? ORDER BY end_time`, mprn, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	still := map[int64]bool{}
+	for _, t := range ends {
+		still[t.Unix()] = true
+	}
+	var measured []time.Time
+	for rows.Next() {
+		var t int64
+		if err := rows.Scan(&t); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !still[t] {
+			measured = append(measured, time.Unix(t, 0))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM estimated_reads WHERE mprn = ? AND end_time BETWEEN ? AND ?`, mprn, from.Unix(), to.Unix()); err != nil {
+		return nil, err
+	}
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO estimated_reads (mprn, end_time) VALUES (?, ?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	for _, t := range ends {
+		if _, err := stmt.Exec(mprn, t.Unix()); err != nil {
+			return nil, err
+		}
+	}
+	return measured, tx.Commit()
+}
+
+// Read is a read of a meter, as downloaded from ESB.
+type Read struct {
+	MPRN, Serial, ReadType string
+	EndTime                time.Time
+	Value                  float64
+}
+
+// RevisedRead is a read whose value changed since it was recorded.
+type RevisedRead struct {
+	Read
+	// Old is the value recorded before.
+	Old float64
+}
+
+// RecordReads records the reads of a download, keeping the ones
+// recorded before which are not in it anymore.
+//
+// It returns how many reads are new, and the ones with a different
+// value than before.
+func (s *Store) RecordReads(reads []Read, now time.Time) (int, []RevisedRead, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	type key struct {
+		mprn, readType string
+		end            int64
+	}
+	// The reads recorded before, in the period of the download.
+	known := map[key]float64{}
+	type period struct{ from, to int64 }
+	periods := map[string]period{}
+	for _, r := range reads {
+		p, ok := periods[r.MPRN]
+		if !ok {
+			p = period{r.EndTime.Unix(), r.EndTime.Unix()}
+		}
+		p.from, p.to = min(p.from, r.EndTime.Unix()), max(p.to, r.EndTime.Unix())
+		periods[r.MPRN] = p
+	}
+	for mprn, p := range periods {
+		rows, err := tx.Query(`SELECT read_type, end_time, value FROM reads
+			WHERE mprn = ? AND end_time BETWEEN ? AND ?`, mprn, p.from, p.to)
+		if err != nil {
+			return 0, nil, err
+		}
+		for rows.Next() {
+			k := key{mprn: mprn}
+			var v float64
+			if err := rows.Scan(&k.readType, &k.end, &v); err != nil {
+				rows.Close()
+				return 0, nil, err
+			}
+			known[k] = v
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO reads (mprn, read_type, end_time, serial, value, first_seen, updated_at) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?6)
+		ON CONFLICT (mprn, read_type, end_time) DO UPDATE SET
+			serial = excluded.serial,
+			value = excluded.value,
+			updated_at = excluded.updated_at`)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer stmt.Close()
+	added := 0
+	var revised []RevisedRead
+	for _, r := range reads {
+		old, ok := known[key{r.MPRN, r.ReadType, r.EndTime.Unix()}]
+		switch {
+		case !ok:
+			added++
+		case old != r.Value:
+			revised = append(revised, RevisedRead{Read: r, Old: old})
+		default:
+			continue
+		}
+		if _, err := stmt.Exec(r.MPRN, r.ReadType, r.EndTime.Unix(), r.Serial, r.Value, now.Unix()); err != nil {
+			return 0, nil, err
+		}
+	}
+	return added, revised, tx.Commit()
+}
+
+// Reads returns the reads of the MPRN ending between from and to,
+// included, sorted by end time and read type.
+func (s *Store) Reads(mprn string, from, to time.Time) ([]Read, error) {
+	rows, err := s.db.Query(`SELECT read_type, end_time, serial, value FROM reads
+		WHERE mprn = ? AND end_time BETWEEN ? AND ? ORDER BY end_time, read_type`, mprn, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []Read
+	for rows.Next() {
+		r := Read{MPRN: mprn}
+		var end int64
+		if err := rows.Scan(&r.ReadType, &end, &r.Serial, &r.Value); err != nil {
+			return nil, err
+		}
+		r.EndTime = time.Unix(end, 0)
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
+
+// ForgetStatistic deletes the uploaded hours and the upload progress of
+// the statistic, like if it was never uploaded.
+func (s *Store) ForgetStatistic(statisticID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM uploaded_hours WHERE statistic_id = ?`, statisticID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM upload_progress WHERE statistic_id = ?`, statisticID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Retention is how long the records are kept, zero keeps them forever.
+type Retention struct {
+	// Uploads is for the audit log of the uploads.
+	Uploads time.Duration
+	// Outages is for the outages, counting from their restore time.
+	Outages time.Duration
+	// Publications is for the times new data was seen, the schedule
+	// only needs the recent ones.
+	Publications time.Duration
+	// Hours is for the uploaded hours and the estimated reads. Pruned
+	// hours are uploaded again if they are still in the ESB file.
+	Hours time.Duration
+	// Reads is for the reads of the meters, counting from their end
+	// time. Pruned reads are recorded again, and not seen as revised,
+	// if they are still in the ESB file.
+	Reads time.Duration
+}
+
+// Prune deletes the records older than the retention and compacts the
+// database file.
+//
+// It returns how many records were deleted, by table.
+func (s *Store) Prune(r Retention, now time.Time) (map[string]int64, error) {
+	queries := []struct {
+		table, query string
+		keep         time.Duration
+	}{
+		{"uploads", `DELETE FROM uploads WHERE time < ?`, r.Uploads},
+		{"outages", `DELETE FROM outages WHERE restore < ?`, r.Outages},
+		{"publications", `DELETE FROM publications WHERE published_at < ?`, r.Publications},
+		{"uploaded_hours", `DELETE FROM uploaded_hours WHERE start < ?`, r.Hours},
+		{"estimated_reads", `DELETE FROM estimated_reads WHERE end_time < ?`, r.Hours},
+		{"reads", `DELETE FROM reads WHERE end_time < ?`, r.Reads},
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	ret := map[string]int64{}
+	for _, q := range queries {
+		if q.keep <= 0 {
+			continue
+		}
+		res, err := tx.Exec(q.query, now.Add(-q.keep).Unix())
+		if err != nil {
+			return nil, fmt.Errorf("cannot prune %s: %w", q.table, err)
+		}
+		if ret[q.table], err = res.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// SQLite doesn't give the space of the deleted records back.
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return ret, fmt.Errorf("cannot compact the database: %w", err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,372 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func openTest(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestDownloads(t *testing.T) {
+	s := openTest(t)
+
+	if _, ok, err := s.LastDownload("123"); ok || err != nil {
+		t.Fatalf("LastDownload() on empty db = %v, %v, want false, nil", ok, err)
+	}
+
+	t1 := time.Unix(1000, 0)
+	t2 := time.Unix(2000, 0)
+	t3 := time.Unix(3000, 0)
+
+	steps := []struct {
+		hash string
+		now  time.Time
+		want Download
+	}{
+		{"aaa", t1, Download{MPRN: "123", Hash: "aaa", DownloadedAt: t1, ChangedAt: t1}},
+		{"aaa", t2, Download{MPRN: "123", Hash: "aaa", DownloadedAt: t2, ChangedAt: t1}},
+		{"bbb", t3, Download{MPRN: "123", Hash: "bbb", DownloadedAt: t3, ChangedAt: t3}},
+	}
+	for _, st := range steps {
+		got, err := s.RecordDownload("123", st.hash, st.now)
+		if err != nil {
+			t.Fatalf("RecordDownload(%q, %v) unexpected error: %v", st.hash, st.now, err)
+		}
+		if diff := cmp.Diff(st.want, got); diff != "" {
+			t.Errorf("RecordDownload(%q, %v) unexpected diff (+got -want): %v", st.hash, st.now, diff)
+		}
+	}
+
+	if _, ok, err := s.UploadedHash("123", "sensor.a"); ok || err != nil {
+		t.Fatalf("UploadedHash() on empty db = %v, %v, want false, nil", ok, err)
+	}
+	for _, h := range []string{"aaa", "bbb"} {
+		if err := s.RecordUploadedHash("123", "sensor.a", h); err != nil {
+			t.Fatalf("RecordUploadedHash(%q) unexpected error: %v", h, err)
+		}
+	}
+	if h, ok, err := s.UploadedHash("123", "sensor.a"); h != "bbb" || !ok || err != nil {
+		t.Errorf("UploadedHash(sensor.a) = %q, %v, %v, want bbb, true, nil", h, ok, err)
+	}
+	// Every statistic has its own.
+	if _, ok, err := s.UploadedHash("123", "sensor.b"); ok || err != nil {
+		t.Errorf("UploadedHash(sensor.b) = %v, %v, want false, nil", ok, err)
+	}
+
+	got, err := s.Publications("123", 10)
+	if err != nil {
+		t.Fatalf("Publications() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]time.Time{t3, t1}, got); diff != "" {
+		t.Errorf("Publications() unexpected diff (+got -want): %v", diff)
+	}
+}
+
+func TestOutages(t *testing.T) {
+	s := openTest(t)
+
+	h := func(n int) time.Time { return time.Unix(int64(n)*3600, 0) }
+	if err := s.RecordOutage("1", "Fault", h(10), h(12), h(11)); err != nil {
+		t.Fatalf("RecordOutage() unexpected error: %v", err)
+	}
+	// Seen again after the estimated restore time.
+	if err := s.RecordOutage("1", "Fault", h(10), h(12), h(14)); err != nil {
+		t.Fatalf("RecordOutage() unexpected error: %v", err)
+	}
+	if err := s.RecordOutage("2", "Planned", h(20), h(22), h(20)); err != nil {
+		t.Fatalf("RecordOutage() unexpected error: %v", err)
+	}
+
+	got, err := s.Outages(h(13), h(21))
+	if err != nil {
+		t.Fatalf("Outages() unexpected error: %v", err)
+	}
+	want := []Outage{
+		{ID: "1", Type: "Fault", Start: h(10), End: h(14)},
+		{ID: "2", Type: "Planned", Start: h(20), End: h(22)},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Outages() unexpected diff (+got -want): %v", diff)
+	}
+
+	if got, err := s.Outages(h(15), h(19)); err != nil || len(got) != 0 {
+		t.Errorf("Outages(15, 19) = %v, %v, want nothing", got, err)
+	}
+}
+
+func TestUploads(t *testing.T) {
+	s := openTest(t)
+
+	u1 := Upload{
+		Time:        time.Unix(1000, 0),
+		Target:      "home_assistant:ha:8123",
+		StatisticID: "sensor.esb",
+		From:        time.Unix(0, 0),
+		To:          time.Unix(3600, 0),
+		Points:      1,
+		SumBefore:   0,
+		SumAfter:    1.5,
+	}
+	u2 := u1
+	u2.Time = time.Unix(2000, 0)
+	u2.Error = "boom"
+
+	for _, u := range []Upload{u1, u2} {
+		if err := s.RecordUpload(u); err != nil {
+			t.Fatalf("RecordUpload() unexpected error: %v", err)
+		}
+	}
+
+	got, err := s.Uploads(10)
+	if err != nil {
+		t.Fatalf("Uploads() unexpected error: %v", err)
+	}
+	u1.ID, u2.ID = 1, 2
+	want := []Upload{u2, u1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Uploads() unexpected diff (+got -want): %v", diff)
+	}
+
+	if got, _ := s.Uploads(1); len(got) != 1 {
+		t.Errorf("Uploads(1) returned %d uploads, want 1", len(got))
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	s := openTest(t)
+	check := func(wantUntil time.Time, wantOK bool) {
+		t.Helper()
+		until, ok, err := s.UploadProgress("sensor.esb")
+		if err != nil {
+			t.Fatalf("UploadProgress() unexpected error: %v", err)
+		}
+		if ok != wantOK || !until.Equal(wantUntil) {
+			t.Errorf("UploadProgress() = %v, %v, want %v, %v", until, ok, wantUntil, wantOK)
+		}
+	}
+
+	check(time.Time{}, false)
+
+	for _, h := range []int64{3600, 7200} {
+		if err := s.AckUpload("sensor.esb", time.Unix(h, 0)); err != nil {
+			t.Fatalf("AckUpload() unexpected error: %v", err)
+		}
+	}
+	check(time.Unix(7200, 0), true)
+
+	if err := s.FinishUpload("sensor.esb"); err != nil {
+		t.Fatalf("FinishUpload() unexpected error: %v", err)
+	}
+	check(time.Time{}, false)
+
+	// A new upload starts from scratch.
+	if err := s.AckUpload("sensor.esb", time.Unix(3600, 0)); err != nil {
+		t.Fatalf("AckUpload() unexpected error: %v", err)
+	}
+	check(time.Unix(3600, 0), true)
+}
+
+func TestUploadedHours(t *testing.T) {
+	s := openTest(t)
+
+	first := []UploadedHour{
+		{Start: time.Unix(0, 0), Hash: "a", Sum: 1},
+		{Start: time.Unix(3600, 0), Hash: "b", Sum: 2},
+	}
+	if err := s.RecordUploadedHours("sensor.esb", first); err != nil {
+		t.Fatalf("RecordUploadedHours() unexpected error: %v", err)
+	}
+	second := []UploadedHour{
+		{Start: time.Unix(3600, 0), Hash: "c", Sum: 3},
+		{Start: time.Unix(7200, 0), Hash: "d", Sum: 4},
+	}
+	if err := s.RecordUploadedHours("sensor.esb", second); err != nil {
+		t.Fatalf("RecordUploadedHours() unexpected error: %v", err)
+	}
+	if err := s.RecordUploadedHours("sensor.other", first); err != nil {
+		t.Fatalf("RecordUploadedHours() unexpected error: %v", err)
+	}
+
+	got, err := s.UploadedHours("sensor.esb", time.Unix(3600, 0), time.Unix(7200, 0))
+	if err != nil {
+		t.Fatalf("UploadedHours() unexpected error: %v", err)
+	}
+	want := map[int64]UploadedHour{
+		3600: second[0],
+		7200: second[1],
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("UploadedHours() unexpected diff (+got -want): %v", diff)
+	}
+
+	if err := s.AckUpload("sensor.esb", time.Unix(3600, 0)); err != nil {
+		t.Fatalf("AckUpload() unexpected error: %v", err)
+	}
+	if err := s.ForgetStatistic("sensor.esb"); err != nil {
+		t.Fatalf("ForgetStatistic() unexpected error: %v", err)
+	}
+	if got, err := s.UploadedHours("sensor.esb", time.Unix(0, 0), time.Unix(7200, 0)); err != nil || len(got) != 0 {
+		t.Errorf("UploadedHours() after ForgetStatistic() = %v, %v, want none", got, err)
+	}
+	if _, ok, err := s.UploadProgress("sensor.esb"); ok || err != nil {
+		t.Errorf("UploadProgress() after ForgetStatistic() = %v, %v, want false, nil", ok, err)
+	}
+	if got, err := s.UploadedHours("sensor.other", time.Unix(0, 0), time.Unix(7200, 0)); err != nil || len(got) != 2 {
+		t.Errorf("UploadedHours() of another statistic = %v, %v, want 2 hours", got, err)
+	}
+}
+
+func TestReads(t *testing.T) {
+	s := openTest(t)
+
+	const kw, export = "Active Import Interval (kW)", "Active Export Interval (kW)"
+	read := func(readType string, end int64, v float64) Read {
+		return Read{MPRN: "123", Serial: "45", ReadType: readType, EndTime: time.Unix(end, 0), Value: v}
+	}
+	first := []Read{read(kw, 1800, 1), read(kw, 3600, 2), read(export, 3600, 0.5)}
+	added, revised, err := s.RecordReads(first, time.Unix(10000, 0))
+	if err != nil || added != 3 || len(revised) != 0 {
+		t.Fatalf("RecordReads() = %d, %v, %v, want 3, none, nil", added, revised, err)
+	}
+
+	// The second download has a new read, a revised one, and misses the
+	// oldest one, which is kept.
+	second := []Read{read(kw, 3600, 2.5), read(export, 3600, 0.5), read(kw, 5400, 3)}
+	added, revised, err = s.RecordReads(second, time.Unix(20000, 0))
+	if err != nil {
+		t.Fatalf("RecordReads() unexpected error: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("RecordReads() added %d reads, want 1", added)
+	}
+	if diff := cmp.Diff([]RevisedRead{{Read: second[0], Old: 2}}, revised); diff != "" {
+		t.Errorf("RecordReads() unexpected diff (+got -want): %v", diff)
+	}
+	if _, _, err := s.RecordReads([]Read{{MPRN: "456", ReadType: kw, EndTime: time.Unix(1800, 0), Value: 9}}, time.Unix(20000, 0)); err != nil {
+		t.Fatalf("RecordReads() of another meter unexpected error: %v", err)
+	}
+
+	got, err := s.Reads("123", time.Unix(0, 0), time.Unix(3600, 0))
+	if err != nil {
+		t.Fatalf("Reads() unexpected error: %v", err)
+	}
+	want := []Read{read(kw, 1800, 1), read(export, 3600, 0.5), read(kw, 3600, 2.5)}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Reads() unexpected diff (+got -want): %v", diff)
+	}
+}
+
+func TestReplaceEstimated(t *testing.T) {
+	s := openTest(t)
+	h := func(n int) time.Time { return time.Unix(int64(n)*1800, 0) }
+
+	if got, err := s.ReplaceEstimated("123", h(0), h(10), []time.Time{h(3), h(4), h(5)}); err != nil || got != nil {
+		t.Fatalf("ReplaceEstimated() = %v, %v, want nil, nil", got, err)
+	}
+	// ESB published h(3) and h(4), h(8) is a new hole.
+	got, err := s.ReplaceEstimated("123", h(2), h(12), []time.Time{h(5), h(8)})
+	if err != nil {
+		t.Fatalf("ReplaceEstimated() unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]time.Time{h(3), h(4)}, got); diff != "" {
+		t.Errorf("ReplaceEstimated() unexpected diff (+got -want): %v", diff)
+	}
+	// Outside the range, nothing is considered measured.
+	if got, err := s.ReplaceEstimated("123", h(6), h(12), nil); err != nil || len(got) != 1 || !got[0].Equal(h(8)) {
+		t.Errorf("ReplaceEstimated() = %v, %v, want [%v], nil", got, err, h(8))
+	}
+	if got, err := s.ReplaceEstimated("456", h(0), h(12), nil); err != nil || got != nil {
+		t.Errorf("ReplaceEstimated() of another MPRN = %v, %v, want nil, nil", got, err)
+	}
+}
+
+func TestConcurrentOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := Open(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer s.Close()
+			for j := range 20 {
+				if err := s.RecordUpload(Upload{Time: time.Unix(int64(i*100+j), 0)}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent write unexpected error: %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := openTest(t)
+	h := func(n int) time.Time { return time.Unix(int64(n)*3600, 0) }
+
+	for _, n := range []int{1, 10} {
+		if err := s.RecordUpload(Upload{Time: h(n), Target: "ha", StatisticID: "sensor.esb", From: h(0), To: h(1)}); err != nil {
+			t.Fatalf("RecordUpload() unexpected error: %v", err)
+		}
+	}
+	hours := []UploadedHour{{Start: h(1), Hash: "a"}, {Start: h(2), Hash: "b"}, {Start: h(10), Hash: "c"}}
+	if err := s.RecordUploadedHours("sensor.esb", hours); err != nil {
+		t.Fatalf("RecordUploadedHours() unexpected error: %v", err)
+	}
+	var reads []Read
+	for _, n := range []int{1, 3, 11} {
+		reads = append(reads, Read{MPRN: "123", Serial: "45", ReadType: "Active Import Interval (kW)", EndTime: h(n), Value: 1})
+	}
+	if _, _, err := s.RecordReads(reads, h(11)); err != nil {
+		t.Fatalf("RecordReads() unexpected error: %v", err)
+	}
+
+	// The uploads are kept forever.
+	got, err := s.Prune(Retention{Hours: 5 * time.Hour, Reads: 8 * time.Hour}, h(12))
+	if err != nil {
+		t.Fatalf("Prune() unexpected error: %v", err)
+	}
+	want := map[string]int64{"uploaded_hours": 2, "estimated_reads": 0, "reads": 2}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Prune() unexpected diff (+got -want): %v", diff)
+	}
+
+	if ups, _ := s.Uploads(10); len(ups) != 2 {
+		t.Errorf("Uploads() after Prune() returned %d uploads, want 2", len(ups))
+	}
+	left, err := s.UploadedHours("sensor.esb", h(0), h(20))
+	if err != nil {
+		t.Fatalf("UploadedHours() unexpected error: %v", err)
+	}
+	if _, ok := left[h(10).Unix()]; len(left) != 1 || !ok {
+		t.Errorf("UploadedHours() after Prune() = %v, want only %v", left, h(10))
+	}
+	leftReads, err := s.Reads("123", h(0), h(20))
+	if err != nil {
+		t.Fatalf("Reads() unexpected error: %v", err)
+	}
+	if len(leftReads) != 1 || !leftReads[0].EndTime.Equal(h(11)) {
+		t.Errorf("Reads() after Prune() = %v, want only the one ending at %v", leftReads, h(11))
+	}
+}
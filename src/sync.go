@@ -0,0 +1,256 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/config"
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/provider"
+)
+
+type syncCmd struct {
+	configPath, identityPath string
+	parallel                 int
+}
+
+func (syncCmd) Name() string { return "sync" }
+
+func (syncCmd) Synopsis() string {
+	return "download and upload the data of all the meters in the configuration file"
+}
+
+func (syncCmd) Usage() string {
+	return `sync -config <file>
+
+Like pipe, but for all the accounts and meters defined in the configuration file.
+Check documentation/config.example.json for the file format.
+Meters are synced in parallel, up to -parallel at a time.
+An error on a meter doesn't stop the others, but the exit status is a failure.
+Passwords and tokens in the file can be encrypted, see the encrypt command.
+
+`
+}
+
+func (c *syncCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.configPath, "config", "", "the path of the configuration file")
+	fs.IntVar(&c.parallel, "parallel", 4, "how many meters to sync at the same time")
+	optionalStringVar(fs, &c.identityPath, "age_identity", "", "the path of the age identity file to decrypt the secrets in the configuration")
+}
+
+func (c *syncCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	cfg, err := loadConfig(c.configPath, c.identityPath)
+	if err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	return syncAll(ctx, cfg, c.parallel)
+}
+
+// syncAll syncs all the meters of the configuration, parallel at a time.
+func syncAll(ctx context.Context, cfg *config.Config, parallel int) subcommands.ExitStatus {
+	h := cfg.HomeAssistant
+	ha.TLS = ha.TLS || h.TLS
+	if h.CACert != "" || h.InsecureSkipVerify {
+		tc, err := ha.NewTLSConfig(h.CACert, h.InsecureSkipVerify)
+		if err != nil {
+			slog.Error("cannot read home_assistant.ca_cert", "err", err)
+			return subcommands.ExitUsageError
+		}
+		ha.TLSConfig = tc
+	}
+	if cfg.DSTFold != "" {
+		// Already validated.
+		dstFold, _ = parse.ParseDSTFold(cfg.DSTFold)
+	}
+	if cfg.Duplicates != "" {
+		duplicatePolicy, _ = parse.ParseDuplicatePolicy(cfg.Duplicates)
+	}
+
+	type job struct {
+		session *accountSession
+		meter   config.Meter
+	}
+	jobs := make(chan job)
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+	)
+	for range max(parallel, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if !syncMeterSafe(ctx, cfg, j.session, j.meter) {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	for _, a := range cfg.Accounts {
+		session := &accountSession{account: a}
+		for _, m := range a.Meters {
+			jobs <- job{session, m}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if failed.Load() {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// accountSession is the ESB session of an account, shared by its meters.
+type accountSession struct {
+	account config.Account
+
+	loginOnce sync.Once
+	client    provider.Provider
+	loginErr  error
+	// resumed is set when the login was saved by a previous run, see
+	// newLogin.
+	resumed bool
+
+	// downloadMu serializes the downloads, ESB sessions are not meant
+	// to be used concurrently.
+	downloadMu sync.Mutex
+}
+
+// login logs in the first time it is called.
+func (s *accountSession) login(ctx context.Context) (provider.Provider, error) {
+	s.loginOnce.Do(func() {
+		slog.Info("Logging in...", "user", s.account.User)
+		s.client, s.resumed, s.loginErr = s.newLogin(ctx)
+	})
+	return s.client, s.loginErr
+}
+
+func (s *accountSession) newLogin(ctx context.Context) (provider.Provider, bool, error) {
+	e, resumed, err := newLogin(ctx, s.account.Provider, s.account.User, s.account.Password, s.account.TOTPSecret, s.account.SessionFile)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: cannot login: %w", s.account.User, err)
+	}
+	return e, resumed, nil
+}
+
+// download downloads and parses the data of a meter, one meter at a
+// time, logging in again if the saved login expired.
+func (s *accountSession) download(ctx context.Context, mprn string) ([]parse.Result, string, error) {
+	s.downloadMu.Lock()
+	defer s.downloadMu.Unlock()
+	body, err := openMPRN(ctx, s.client, mprn, period{})
+	if s.resumed && errors.Is(err, provider.ErrLoginExpired) {
+		slog.Info("The saved login expired, logging in again", "user", s.account.User)
+		os.Remove(s.account.SessionFile)
+		e, resumed, lerr := s.newLogin(ctx)
+		if lerr != nil {
+			return nil, "", lerr
+		}
+		s.client, s.resumed = e, resumed
+		body, err = openMPRN(ctx, s.client, mprn, period{})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return parseDownload(ctx, body)
+}
+
+// syncMeterSafe is syncMeter, but a panic only fails the meter.
+func syncMeterSafe(ctx context.Context, cfg *config.Config, s *accountSession, m config.Meter) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("unexpected failure", "mprn", m.MPRN, "err", r)
+			ok = false
+		}
+	}()
+	return syncMeter(ctx, cfg, s, m)
+}
+
+func syncMeter(ctx context.Context, cfg *config.Config, s *accountSession, m config.Meter) bool {
+	up := uploadCmd{
+		server:       cfg.HomeAssistant.Server,
+		token:        cfg.HomeAssistant.Token,
+		sensor:       m.Sensor,
+		exportSensor: m.ExportSensor,
+		bandSensors:  m.BandSensors,
+		bands:        cmp.Or(m.TariffBands, parse.DefaultBands),
+		lagSensor:    m.LagSensor,
+		statusSensor: m.StatusSensor,
+		precision:    cfg.HomeAssistant.Precision(),
+		align:        cmp.Or(cfg.HomeAssistant.Align, "center"),
+		meterState:   cfg.HomeAssistant.MeterState,
+		haTimezone:   cfg.HomeAssistant.Timezone,
+		external:     cfg.HomeAssistant.External,
+		statName:     m.Name,
+
+		storePath: cfg.Store,
+	}
+	var lag time.Duration
+	err := syncMeterData(ctx, cfg, s, m, &up, &lag)
+	up.reportStatus(ctx, m.MPRN, lag, err)
+	notifications := notificationsFromConfig(cfg.Notifications)
+	notifications.send(ctx, &up, m.MPRN, err)
+	if err != nil {
+		slog.Error("sync failed", "mprn", m.MPRN, "err", err)
+		return false
+	}
+	return true
+}
+
+// syncMeterData downloads and uploads the data of a meter, setting the
+// lag of the data once known.
+func syncMeterData(ctx context.Context, cfg *config.Config, s *accountSession, m config.Meter, up *uploadCmd, lag *time.Duration) error {
+	if _, err := s.login(ctx); err != nil {
+		return err
+	}
+
+	slog.Info("Downloading data...", "mprn", m.MPRN)
+	parsed, hash, err := s.download(ctx, m.MPRN)
+	if err != nil {
+		return err
+	}
+	cache := downloadCache{path: cfg.Store, staleDays: 3}
+	if err := cache.recordReads(parsed); err != nil {
+		slog.Warn(err.Error(), "mprn", m.MPRN)
+	}
+	parsed = up.setAsideExport(parsed)
+
+	if l, ok := up.reportLag(ctx, m.MPRN, parsed, time.Now()); ok {
+		slog.Info("The latest read is old", "mprn", m.MPRN, "hours", int(l.Hours()))
+		*lag = l
+	}
+
+	unchanged, _, err := cache.unchanged(m.MPRN, up.sensor, hash)
+	if err != nil {
+		return err
+	}
+	if unchanged && !up.pending() {
+		slog.Info("Data didn't change since the last download, nothing to upload", "mprn", m.MPRN)
+		return nil
+	}
+	if up.uploadAll(ctx, parsed) != subcommands.ExitSuccess {
+		return errors.New("the upload failed, see the errors above")
+	}
+	if err := cache.uploaded(m.MPRN, up.sensor, hash); err != nil {
+		// The next run uploads the same data again, nothing lost.
+		slog.Warn(err.Error(), "mprn", m.MPRN)
+	}
+	return nil
+}
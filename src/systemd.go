@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lorentz83/esb2ha/systemd"
+)
+
+// maxScheduledSync is how long a scheduled sync can run before the
+// scheduler is considered stuck, and schedulerSlack how late it can
+// start.
+const (
+	maxScheduledSync = time.Hour
+	schedulerSlack   = 5 * time.Minute
+)
+
+// notify sends the states to systemd, when run with Type=notify.
+func notify(states ...string) {
+	if _, err := systemd.Notify(states...); err != nil {
+		slog.Warn(err.Error())
+	}
+}
+
+// startWatchdog notifies the watchdog of systemd, if enabled, until the
+// context is done, see watchdog.
+func (s *service) startWatchdog(ctx context.Context) {
+	interval, err := systemd.WatchdogInterval()
+	if err != nil {
+		slog.Warn("the systemd watchdog is disabled", "err", err)
+		return
+	}
+	if interval > 0 {
+		go s.watchdog(ctx, interval)
+	}
+}
+
+// watchdog notifies systemd every half interval while the scheduler
+// works, so systemd restarts the server when it is stuck, see hung.
+func (s *service) watchdog(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if err := s.hung(now); err != nil {
+				slog.Error("the scheduler is stuck, systemd will restart the server", "err", err)
+				continue
+			}
+			notify(systemd.Watchdog)
+		}
+	}
+}
+
+// hung returns an error if the scheduled sync is running for longer
+// than maxScheduledSync, or it didn't start when planned.
+func (s *service) hung(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.syncing.IsZero() && now.Sub(s.syncing) > maxScheduledSync {
+		return fmt.Errorf("the sync started at %s is still running", s.syncing.Format(time.DateTime))
+	}
+	if s.syncing.IsZero() && !s.next.IsZero() && now.Sub(s.next) > schedulerSlack {
+		return fmt.Errorf("the sync planned at %s didn't start", s.next.Format(time.DateTime))
+	}
+	return nil
+}
+
+// notifyStatus shows the last sync, and the next scheduled one, in
+// systemctl status.
+func (s *service) notifyStatus() {
+	s.mu.Lock()
+	var status string
+	if len(s.runs) == 0 {
+		status = "Waiting for the first sync"
+	} else {
+		r := s.runs[len(s.runs)-1]
+		status = "Last sync " + r.End.Format(time.DateTime)
+		switch {
+		case r.Error != "":
+			status += " failed: " + r.Error
+		case r.Unchanged:
+			status += ": no new data"
+		default:
+			status += fmt.Sprintf(": %d points", r.Points)
+		}
+	}
+	if !s.next.IsZero() {
+		status += ", next sync " + s.next.Format(time.DateTime)
+	}
+	s.mu.Unlock()
+	notify(systemd.Status(status))
+}
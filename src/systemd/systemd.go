@@ -0,0 +1,70 @@
+// Package systemd implements the notifications of the services run by
+// systemd with Type=notify, see sd_notify(3): telling when the service
+// is ready, its status, and that it is alive for the watchdog.
+//
+// Outside of systemd, or with another Type, the notifications are
+// ignored.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The states of the service, see Notify.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Status returns the state setting the status shown by systemctl status,
+// on one line.
+func Status(s string) string {
+	return "STATUS=" + strings.Join(strings.Fields(s), " ")
+}
+
+// Notify sends the states to systemd. It returns false, without error,
+// when not run by systemd with Type=notify.
+func Notify(states ...string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return false, nil
+	}
+	// The names starting with @ are in the abstract namespace.
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("cannot notify systemd: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, fmt.Errorf("cannot notify systemd: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns in how long systemd restarts the service
+// without a Watchdog notification, 0 if the watchdog is disabled or
+// meant for another process. The notifications are better sent every
+// half of it.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
@@ -0,0 +1,67 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if ok, err := Notify(Ready); ok || err != nil {
+		t.Errorf("Notify() without NOTIFY_SOCKET = %v, %v, want false, nil", ok, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if ok, err := Notify(Ready, Status("Last sync\nat 07:00")); !ok || err != nil {
+		t.Fatalf("Notify() = %v, %v, want true, nil", ok, err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "READY=1\nSTATUS=Last sync at 07:00"
+	if got := string(buf[:n]); got != want {
+		t.Errorf("Notify() sent %q, want %q", got, want)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	self := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		name, usec, pid string
+		want            time.Duration
+		wantErr         bool
+	}{
+		{name: "disabled"},
+		{name: "enabled", usec: "30000000", want: 30 * time.Second},
+		{name: "this process", usec: "30000000", pid: self, want: 30 * time.Second},
+		{name: "another process", usec: "30000000", pid: "1"},
+		{name: "invalid", usec: "soon", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tc.usec)
+			t.Setenv("WATCHDOG_PID", tc.pid)
+			got, err := WatchdogInterval()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("WatchdogInterval() error = %v, want error %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("WatchdogInterval() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Package tariff implements the tariff plans of the electricity
+// suppliers, with their unit rates and fixed charges, read from a JSON
+// file.
+//
+// See documentation/tariff.example.json for an example.
+package tariff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/prices"
+)
+
+// Plan is a tariff plan.
+type Plan struct {
+	// Name is only for the humans reading the file, optional.
+	Name string `json:"name,omitempty"`
+	// Rates are the unit rates, like day, night and peak. When more
+	// rates match, the first wins, so narrow bands, like the peak, can
+	// be listed before the day rate.
+	Rates []Rate `json:"rates"`
+	// StandingPerDay is the standing charge in EUR per day, optional.
+	StandingPerDay float64 `json:"standing_charge_per_day,omitempty"`
+	// LevyPerMonth is the PSO levy in EUR per month, optional.
+	LevyPerMonth float64 `json:"pso_levy_per_month,omitempty"`
+	// VAT is the rate applied to the whole cost, like 0.09 for 9%,
+	// optional.
+	VAT float64 `json:"vat,omitempty"`
+	// Discount is taken off the unit rates, like 0.1 for 10% off,
+	// optional.
+	Discount float64 `json:"discount,omitempty"`
+
+	sheet *prices.RateSheet
+}
+
+// Rate is a unit rate, valid in a band of the day.
+type Rate struct {
+	// Name is only for the humans reading the file, like "night",
+	// optional.
+	Name string `json:"name,omitempty"`
+	// ValidFrom is the first day of the rate, YYYY-MM-DD, optional.
+	// The rates in force are the ones with the latest date, so a price
+	// change is a new set of rates.
+	ValidFrom string `json:"valid_from,omitempty"`
+	// Days is all, the default, weekdays or weekends.
+	Days string `json:"days,omitempty"`
+	// Start and End are HH:MM, end is excluded and 00:00 is midnight
+	// at the end of the day. The band can cross midnight.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// EURPerKWh is the price before VAT and discount.
+	EURPerKWh float64 `json:"eur_per_kwh"`
+}
+
+// Load reads the plan from the file, the times are in the given location.
+func Load(path string, loc *time.Location) (*Plan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := Read(f, loc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Read reads the plan in JSON format, the times are in the given
+// location.
+func Read(r io.Reader, loc *time.Location) (*Plan, error) {
+	var p Plan
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid tariff: %w", err)
+	}
+	if err := p.validate(loc); err != nil {
+		return nil, fmt.Errorf("invalid tariff: %w", err)
+	}
+	return &p, nil
+}
+
+func (p *Plan) validate(loc *time.Location) error {
+	var errs []error
+	if p.Discount < 0 || p.Discount >= 1 {
+		errs = append(errs, fmt.Errorf("discount %v must be at least 0 and less than 1", p.Discount))
+	}
+	if p.VAT < 0 || p.StandingPerDay < 0 || p.LevyPerMonth < 0 {
+		errs = append(errs, errors.New("vat, standing_charge_per_day and pso_levy_per_month can't be negative"))
+	}
+	recs := make([][]string, len(p.Rates))
+	for i, r := range p.Rates {
+		if r.ValidFrom == "" {
+			r.ValidFrom = "1970-01-01"
+		}
+		if r.Days == "" {
+			r.Days = "all"
+		}
+		recs[i] = []string{r.ValidFrom, r.Days, r.Start, r.End, strconv.FormatFloat(r.EURPerKWh, 'g', -1, 64)}
+	}
+	var err error
+	if p.sheet, err = prices.NewRateSheet(recs, loc); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// At returns the unit rate in EUR per kWh at the given time, after the
+// discount and before VAT.
+func (p *Plan) At(t time.Time) (float64, bool) {
+	r, ok := p.sheet.At(t)
+	return r * (1 - p.Discount), ok
+}
+
+// Series returns the unit rates of every half an hour from from to to,
+// after the discount and before VAT.
+func (p *Plan) Series(from, to time.Time) prices.Series {
+	s := p.sheet.Series(from, to)
+	for i := range s {
+		s[i].EURPerMWh *= 1 - p.Discount
+	}
+	return s
+}
+
+// Charges returns the fixed charges and the VAT of the plan.
+func (p *Plan) Charges() parse.Charges {
+	return parse.Charges{StandingPerDay: p.StandingPerDay, LevyPerMonth: p.LevyPerMonth, VAT: p.VAT}
+}
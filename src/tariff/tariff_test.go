@@ -0,0 +1,68 @@
+package tariff
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+func TestLoad(t *testing.T) {
+	dublin, err := time.LoadLocation("Europe/Dublin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load("../../documentation/tariff.example.json", dublin)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+
+	ts := func(m time.Month, d, h, min int) time.Time { return time.Date(2024, m, d, h, min, 0, 0, dublin) }
+	tests := []struct {
+		name string
+		t    time.Time
+		want float64
+	}{
+		{"peak", ts(1, 15, 17, 30), 0.4012 * 0.9},
+		{"night after midnight", ts(1, 16, 7, 30), 0.1805 * 0.9},
+		{"new prices", ts(10, 2, 12, 0), 0.3420 * 0.9},
+		{"weekend", ts(10, 5, 12, 0), 0.2950 * 0.9},
+	}
+	for _, tc := range tests {
+		if got, ok := p.At(tc.t); !ok || math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: At(%v) = %v, %v, want %v, true", tc.name, tc.t, got, ok, tc.want)
+		}
+	}
+
+	s := p.Series(ts(1, 15, 22, 30), ts(1, 15, 23, 30))
+	if got, ok := s.At(ts(1, 15, 23, 15)); len(s) != 2 || !ok || math.Abs(got-0.1805*0.9) > 1e-9 {
+		t.Errorf("Series() = %v, want 2 prices, the night one at 23:15", s)
+	}
+
+	want := parse.Charges{StandingPerDay: 0.6854, LevyPerMonth: 1.61, VAT: 0.09}
+	if diff := cmp.Diff(want, p.Charges()); diff != "" {
+		t.Errorf("Charges() unexpected diff (-want +got): %v", diff)
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	tests := []struct {
+		name, data string
+	}{
+		{"not json", `{`},
+		{"unknown field", `{"rates": [{"start": "00:00", "end": "00:00", "eur_per_kwh": 0.3}], "standing": 1}`},
+		{"no rates", `{"vat": 0.09}`},
+		{"invalid band", `{"rates": [{"start": "8am", "end": "00:00", "eur_per_kwh": 0.3}]}`},
+		{"invalid days", `{"rates": [{"days": "mondays", "start": "00:00", "end": "00:00", "eur_per_kwh": 0.3}]}`},
+		{"discount too big", `{"rates": [{"start": "00:00", "end": "00:00", "eur_per_kwh": 0.3}], "discount": 10}`},
+		{"negative vat", `{"rates": [{"start": "00:00", "end": "00:00", "eur_per_kwh": 0.3}], "vat": -0.1}`},
+	}
+	for _, tc := range tests {
+		if got, err := Read(strings.NewReader(tc.data), time.UTC); err == nil {
+			t.Errorf("Read(%s) = %+v, want error", tc.name, got)
+		}
+	}
+}
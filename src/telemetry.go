@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/lorentz83/esb2ha"
+
+var (
+	tracer = otel.Tracer(instrumentationName)
+
+	// Metrics are created in setupTelemetry, these are no-op until then.
+	pointsUploaded metric.Int64Counter
+	phaseErrors    metric.Int64Counter
+	dataLag        metric.Float64Gauge
+)
+
+func init() {
+	initMetrics()
+}
+
+func initMetrics() {
+	m := otel.Meter(instrumentationName)
+	pointsUploaded, _ = m.Int64Counter("esb2ha.points.uploaded",
+		metric.WithDescription("Number of statistics sent to Home Assistant."))
+	phaseErrors, _ = m.Int64Counter("esb2ha.errors",
+		metric.WithDescription("Number of errors by pipeline phase."))
+	dataLag, _ = m.Float64Gauge("esb2ha.data.lag",
+		metric.WithDescription("How far behind now the latest read from ESB is."),
+		metric.WithUnit("h"))
+}
+
+// setupTelemetry exports traces and metrics via OTLP/HTTP.
+//
+// It is enabled only when the standard OTEL_EXPORTER_OTLP_ENDPOINT
+// environment variable is set, all the other OTEL_* variables are
+// honored too.
+// The returned function flushes and shuts down the exporters.
+func setupTelemetry(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName("esb2ha")))
+	if err != nil {
+		return nil, err
+	}
+
+	te, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(te), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+
+	me, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(me)), sdkmetric.WithResource(res))
+	otel.SetMeterProvider(mp)
+	initMetrics()
+
+	return func(ctx context.Context) error {
+		return errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx))
+	}, nil
+}
+
+// spanEvent returns a function adding the named events to the span in ctx.
+func spanEvent(ctx context.Context) func(name string) {
+	span := trace.SpanFromContext(ctx)
+	return func(name string) { span.AddEvent(name) }
+}
+
+// startSpan starts a span for a phase of the pipeline.
+//
+// The returned function ends the span recording the error, if any, which
+// is returned unchanged for convenience.
+func startSpan(ctx context.Context, phase string, attrs ...attribute.KeyValue) (context.Context, func(error) error) {
+	ctx, span := tracer.Start(ctx, phase, trace.WithAttributes(attrs...))
+	return ctx, func(err error) error {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			phaseErrors.Add(ctx, 1, metric.WithAttributes(attribute.String("phase", phase)))
+		}
+		span.End()
+		return err
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lorentz83/esb2ha/parse"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// manualMetrics collects the OpenTelemetry metrics in the test.
+func manualMetrics(t *testing.T) *sdkmetric.ManualReader {
+	prev := otel.GetMeterProvider()
+	r := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(r)))
+	initMetrics()
+	t.Cleanup(func() {
+		otel.SetMeterProvider(prev)
+		initMetrics()
+	})
+	return r
+}
+
+func TestReportLag(t *testing.T) {
+	reader := manualMetrics(t)
+	now := time.Date(2024, 1, 16, 6, 0, 0, 0, time.UTC)
+	parsed := []parse.Result{{MPRN: "100", Reads: []parse.Read{
+		{EndTime: now.Add(-31 * time.Hour)},
+		{EndTime: now.Add(-30 * time.Hour)},
+	}}}
+
+	var c uploadCmd
+	lag, ok := c.reportLag(t.Context(), "100", parsed, now)
+	if !ok || lag != 30*time.Hour {
+		t.Fatalf("reportLag() = %v, %v, want 30h, true", lag, ok)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(t.Context(), &rm); err != nil {
+		t.Fatalf("Collect() unexpected error: %v", err)
+	}
+	var got []metricdata.DataPoint[float64]
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "esb2ha.data.lag" {
+				continue
+			}
+			if m.Unit != "h" {
+				t.Errorf("esb2ha.data.lag unit = %q, want h", m.Unit)
+			}
+			got = m.Data.(metricdata.Gauge[float64]).DataPoints
+		}
+	}
+	if len(got) != 1 || got[0].Value != 30 {
+		t.Fatalf("esb2ha.data.lag = %+v, want a point of 30", got)
+	}
+	if mprn, _ := got[0].Attributes.Value(attribute.Key("mprn")); mprn.AsString() != "100" {
+		t.Errorf("esb2ha.data.lag mprn = %q, want 100", mprn.AsString())
+	}
+
+	var b strings.Builder
+	if err := promMetrics.write(&b); err != nil {
+		t.Fatalf("write() unexpected error: %v", err)
+	}
+	if want := `esb2ha_data_lag_seconds{mprn="100"} 108000` + "\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("promMetrics = %q, want it to contain %q", b.String(), want)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/grafana"
+	"github.com/lorentz83/esb2ha/parse"
+	"github.com/lorentz83/esb2ha/powercheck"
+	"github.com/lorentz83/esb2ha/store"
+)
+
+// outageWatch records the power outages near the meter reported by ESB PowerCheck.
+type outageWatch struct {
+	apiKey             string
+	lat, lon, radiusKm float64
+}
+
+func (o *outageWatch) SetFlags(fs *flag.FlagSet) {
+	optionalStringVar(fs, &o.apiKey, "powercheck_api_key", "", "the ESB PowerCheck API key, to record power outages near the meter in the local store")
+	fs.Float64Var(&o.lat, "latitude", 0, "the latitude of the meter, to find the outages nearby")
+	fs.Float64Var(&o.lon, "longitude", 0, "the longitude of the meter, to find the outages nearby")
+	fs.Float64Var(&o.radiusKm, "outage_radius_km", 5, "how far from the meter an outage is considered relevant")
+}
+
+func (o *outageWatch) enabled() bool {
+	return o.apiKey != ""
+}
+
+// record saves the current outages near the meter in the store.
+func (o *outageWatch) record(ctx context.Context, st *store.Store) error {
+	if o.lat == 0 && o.lon == 0 {
+		return errors.New("-latitude and -longitude are required to check PowerCheck")
+	}
+	outages, err := powercheck.NewClient(o.apiKey).Near(ctx, o.lat, o.lon, o.radiusKm)
+	if err != nil {
+		return fmt.Errorf("cannot read PowerCheck outages: %w", err)
+	}
+	now := time.Now()
+	for _, out := range outages {
+		if err := st.RecordOutage(out.ID, out.Type, out.Start, out.EstimatedRestore, now); err != nil {
+			return fmt.Errorf("cannot record outage: %w", err)
+		}
+	}
+	return nil
+}
+
+// grafanaAnnotations creates Grafana annotations for the holes and
+// the clock changes in the data.
+type grafanaAnnotations struct {
+	url, token, dashboardUID string
+}
+
+// grafanaTag tags all the annotations created by esb2ha.
+const grafanaTag = "esb2ha"
+
+func (g *grafanaAnnotations) SetFlags(fs *flag.FlagSet) {
+	optionalStringVar(fs, &g.url, "grafana_url", "", "the Grafana URL, like http://host:3000, to annotate the holes and the clock changes")
+	optionalStringVar(fs, &g.token, "grafana_token", "", "the Grafana service account token, with permission to write annotations")
+	optionalStringVar(fs, &g.dashboardUID, "grafana_dashboard_uid", "", "the dashboard to annotate, all the dashboards of the organization if not set")
+}
+
+func (g *grafanaAnnotations) enabled() bool {
+	return g.url != ""
+}
+
+func (g *grafanaAnnotations) annotation(start, end time.Time, text string, tags ...string) grafana.Annotation {
+	a := grafana.NewAnnotation(start, end, text, append([]string{grafanaTag}, tags...)...)
+	a.DashboardUID = g.dashboardUID
+	return a
+}
+
+// create creates the annotations which don't exist yet.
+func (g *grafanaAnnotations) create(ctx context.Context, as []grafana.Annotation) (int, error) {
+	if g.token == "" {
+		return 0, errors.New("-grafana_token is required to annotate Grafana")
+	}
+	return grafana.NewClient(g.url, g.token).CreateMissing(ctx, as, grafanaTag)
+}
+
+type validateCmd struct {
+	storePath string
+	outages   outageWatch
+	grafana   grafanaAnnotations
+}
+
+func (validateCmd) Name() string { return "validate" }
+
+func (validateCmd) Synopsis() string {
+	return "report the holes in the electricity usage data"
+}
+
+func (validateCmd) Usage() string {
+	return `validate <flags>
+
+The CSV file is read from standard input.
+
+With a local store, holes are annotated with the power outages
+reported by ESB PowerCheck, to tell apart data lost by ESB from
+periods when your power was out.
+PowerCheck only reports the current outages, so they are known only
+if esb2ha was running (pipe or validate with -powercheck_api_key)
+while they happened.
+
+With -grafana_url, the holes and the clock changes are also added as
+annotations in Grafana, tagged esb2ha, to explain why charts have
+missing or odd hours. Annotations already there are not duplicated.
+
+`
+}
+
+func (c *validateCmd) SetFlags(fs *flag.FlagSet) {
+	optionalStringVar(fs, &c.storePath, "store", "", "the path of the local database with the recorded power outages")
+	c.outages.SetFlags(fs)
+	c.grafana.SetFlags(fs)
+}
+
+func (c *validateCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+	if c.outages.enabled() && c.storePath == "" {
+		slog.Error("-powercheck_api_key requires -store")
+		return subcommands.ExitUsageError
+	}
+
+	slog.Info("Reading from stdin...")
+
+	if err := c.validate(ctx, os.Stdin, os.Stdout); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *validateCmd) validate(ctx context.Context, data io.Reader, out io.Writer) error {
+	parsed, err := readHDF(data)
+	if err != nil {
+		return err
+	}
+
+	var st *store.Store
+	if c.storePath != "" {
+		if st, err = store.Open(c.storePath); err != nil {
+			return fmt.Errorf("cannot open local store: %w", err)
+		}
+		defer st.Close()
+		if c.outages.enabled() {
+			if err := c.outages.record(ctx, st); err != nil {
+				return err
+			}
+		}
+	}
+
+	var annotations []grafana.Annotation
+	const format = "2006-01-02 15:04"
+
+	gaps := parse.Gaps(parsed)
+	if len(gaps) == 0 {
+		fmt.Fprintln(out, "No holes in the data")
+	}
+	for _, g := range gaps {
+		reason, err := explainGap(st, g)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s -> %s (%v): %s\n", g.From.Format(format), g.To.Format(format), g.Duration(), reason)
+		annotations = append(annotations, c.grafana.annotation(g.From, g.To, "ESB data missing: "+reason, "gap"))
+	}
+
+	if !c.grafana.enabled() {
+		return nil
+	}
+	for _, cc := range parse.ClockChanges(parsed) {
+		text := "Clock moved back: the hour after this happens twice, the day is 25 hours long"
+		if cc.Forward {
+			text = "Clock moved forward: an hour is skipped, the day is 23 hours long"
+		}
+		annotations = append(annotations, c.grafana.annotation(cc.At, time.Time{}, text, "dst"))
+	}
+	n, err := c.grafana.create(ctx, annotations)
+	if err != nil {
+		return fmt.Errorf("cannot annotate Grafana: %w", err)
+	}
+	fmt.Fprintf(out, "Created %d Grafana annotations\n", n)
+	return nil
+}
+
+// explainGap returns why the data is missing, looking for power outages
+// in the store if not nil.
+func explainGap(st *store.Store, g parse.Gap) (string, error) {
+	if st == nil {
+		return "missing data", nil
+	}
+	outages, err := st.Outages(g.From, g.To)
+	if err != nil {
+		return "", err
+	}
+	if len(outages) == 0 {
+		return "ESB lost data, no power outage recorded", nil
+	}
+	const format = "2006-01-02 15:04"
+	var ret []string
+	for _, o := range outages {
+		ret = append(ret, fmt.Sprintf("power outage %s (%s) from %s to %s", o.ID, o.Type, o.Start.Format(format), o.End.Format(format)))
+	}
+	return strings.Join(ret, ", "), nil
+}
@@ -0,0 +1,158 @@
+// Package weather reads the historical daily temperatures from Open-Meteo
+// and computes heating degree days.
+//
+// Open-Meteo doesn't require an API key and its archive covers Ireland
+// with data derived from the Met Éireann models.
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const archiveURL = "https://archive-api.open-meteo.com/v1/archive"
+
+// Day is the mean temperature of a day.
+type Day struct {
+	// Date is the midnight of the day in Europe/Dublin timezone.
+	Date time.Time
+	// MeanC is the mean temperature in Celsius.
+	MeanC float64
+}
+
+// HDD returns the heating degree days of the day for the base temperature.
+func (d Day) HDD(baseC float64) float64 {
+	return max(0, baseC-d.MeanC)
+}
+
+// Client reads the temperatures from Open-Meteo.
+type Client struct {
+	hc      *http.Client
+	baseURL string
+}
+
+// NewClient returns a new Open-Meteo client.
+func NewClient() *Client {
+	return &Client{
+		hc:      http.DefaultClient,
+		baseURL: archiveURL,
+	}
+}
+
+// Daily returns the mean temperatures of the days between from and to, included.
+//
+// The archive lags a few days behind, days without data are omitted.
+func (c *Client) Daily(ctx context.Context, lat, lon float64, from, to time.Time) ([]Day, error) {
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', -1, 64))
+	q.Set("longitude", strconv.FormatFloat(lon, 'f', -1, 64))
+	q.Set("start_date", from.In(dublin).Format(time.DateOnly))
+	q.Set("end_date", to.In(dublin).Format(time.DateOnly))
+	q.Set("daily", "temperature_2m_mean")
+	q.Set("timezone", "Europe/Dublin")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Open-Meteo status %v: %s", rsp.Status, b)
+	}
+	return parseDaily(b)
+}
+
+func parseDaily(b []byte) ([]Day, error) {
+	var data struct {
+		Daily struct {
+			Time []string   `json:"time"`
+			Mean []*float64 `json:"temperature_2m_mean"`
+		} `json:"daily"`
+	}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("cannot parse Open-Meteo response: %w", err)
+	}
+	if len(data.Daily.Time) != len(data.Daily.Mean) {
+		return nil, fmt.Errorf("invalid Open-Meteo response: %d days but %d temperatures", len(data.Daily.Time), len(data.Daily.Mean))
+	}
+
+	var ret []Day
+	for i, s := range data.Daily.Time {
+		if data.Daily.Mean[i] == nil {
+			continue
+		}
+		d, err := time.ParseInLocation(time.DateOnly, s, dublin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q: %w", s, err)
+		}
+		ret = append(ret, Day{Date: d, MeanC: *data.Daily.Mean[i]})
+	}
+	return ret, nil
+}
+
+// Fit is the linear regression of the consumption over the heating degree days.
+type Fit struct {
+	// BaseKWh is the daily consumption not related to heating.
+	BaseKWh float64
+	// KWhPerHDD is how much the consumption grows for each degree day.
+	KWhPerHDD float64
+	// R is the Pearson correlation coefficient.
+	R float64
+}
+
+// LinearFit fits kwh = BaseKWh + KWhPerHDD*hdd with the least squares method.
+//
+// It returns false if there are less than 2 points or hdd is constant.
+func LinearFit(hdd, kwh []float64) (Fit, bool) {
+	n := float64(len(hdd))
+	if len(hdd) < 2 || len(hdd) != len(kwh) {
+		return Fit{}, false
+	}
+	var mx, my float64
+	for i := range hdd {
+		mx += hdd[i]
+		my += kwh[i]
+	}
+	mx, my = mx/n, my/n
+
+	var sxy, sxx, syy float64
+	for i := range hdd {
+		dx, dy := hdd[i]-mx, kwh[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return Fit{}, false
+	}
+	f := Fit{KWhPerHDD: sxy / sxx}
+	f.BaseKWh = my - f.KWhPerHDD*mx
+	if syy != 0 {
+		f.R = sxy / math.Sqrt(sxx*syy)
+	}
+	return f, true
+}
+
+var dublin *time.Location
+
+func init() {
+	var err error
+	if dublin, err = time.LoadLocation("Europe/Dublin"); err != nil {
+		panic(err)
+	}
+}
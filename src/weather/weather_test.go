@@ -0,0 +1,101 @@
+package weather
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestDaily(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if got := q.Get("start_date") + " " + q.Get("end_date"); got != "2023-01-01 2023-01-03" {
+			t.Errorf("got dates %q, want 2023-01-01 2023-01-03", got)
+		}
+		io.WriteString(w, `{"daily":{
+			"time":["2023-01-01","2023-01-02","2023-01-03"],
+			"temperature_2m_mean":[5.5,-1.2,null]
+		}}`)
+	}))
+	defer srv.Close()
+
+	c := &Client{hc: srv.Client(), baseURL: srv.URL}
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, dublin)
+	got, err := c.Daily(context.Background(), 53.34, -6.26, from, from.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("Daily() unexpected error: %v", err)
+	}
+	want := []Day{
+		{Date: from, MeanC: 5.5},
+		{Date: from.AddDate(0, 0, 1), MeanC: -1.2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Daily() unexpected diff (+got -want): %v", diff)
+	}
+}
+
+func TestHDD(t *testing.T) {
+	tests := []struct {
+		mean, want float64
+	}{
+		{mean: 5.5, want: 10},
+		{mean: 15.5, want: 0},
+		{mean: 20, want: 0},
+	}
+	for _, tc := range tests {
+		if got := (Day{MeanC: tc.mean}).HDD(15.5); got != tc.want {
+			t.Errorf("HDD(15.5) with mean %v = %v, want %v", tc.mean, got, tc.want)
+		}
+	}
+}
+
+func TestLinearFit(t *testing.T) {
+	tests := []struct {
+		name     string
+		hdd, kwh []float64
+		want     Fit
+		wantOK   bool
+	}{
+		{
+			name:   "perfect fit",
+			hdd:    []float64{0, 5, 10},
+			kwh:    []float64{8, 18, 28},
+			want:   Fit{BaseKWh: 8, KWhPerHDD: 2, R: 1},
+			wantOK: true,
+		},
+		{
+			name:   "no correlation",
+			hdd:    []float64{0, 10, 0, 10},
+			kwh:    []float64{5, 5, 5, 5},
+			want:   Fit{BaseKWh: 5},
+			wantOK: true,
+		},
+		{
+			name: "constant hdd",
+			hdd:  []float64{0, 0, 0},
+			kwh:  []float64{1, 2, 3},
+		},
+		{
+			name: "too few points",
+			hdd:  []float64{1},
+			kwh:  []float64{1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := LinearFit(tc.hdd, tc.kwh)
+			if ok != tc.wantOK {
+				t.Fatalf("LinearFit() ok = %v, want %v", ok, tc.wantOK)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("LinearFit() unexpected diff (+got -want): %v", diff)
+			}
+		})
+	}
+}
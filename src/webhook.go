@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/lorentz83/esb2ha/ha"
+	"github.com/lorentz83/esb2ha/parse"
+)
+
+// webhookPayload is the JSON sent to the Home Assistant webhook.
+type webhookPayload struct {
+	Date   string  `json:"date"`
+	Energy float64 `json:"energy"`
+	Unit   string  `json:"unit_of_measurement"`
+}
+
+type webhookCmd struct {
+	server, webhookID string
+	days              int
+}
+
+func (webhookCmd) Name() string { return "webhook" }
+
+func (webhookCmd) Synopsis() string {
+	return "push the daily electricity usage to a Home Assistant webhook"
+}
+
+func (webhookCmd) Usage() string {
+	return `webhook <flags>
+
+All the flags are required, but can be provided as environment variables as well.
+The CSV file is read from standard input.
+
+This is an alternative to upload which doesn't require an admin token.
+Only complete days are sent, oldest first, one request per day.
+Check the documentation for the template sensor to configure in Home Assistant.
+
+`
+}
+
+func (c *webhookCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.server, "ha_server", "", "Home Assistant server name or IP and optionally the port")
+	fs.StringVar(&c.webhookID, "ha_webhook_id", "", "the ID of the webhook which triggers the template sensor")
+	fs.IntVar(&c.days, "webhook_days", 1, "how many of the most recent days to send")
+}
+
+func (c *webhookCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := ensureFlagsAreSet(f); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitUsageError
+	}
+
+	slog.Info("Reading from stdin...")
+
+	if err := c.push(ctx, os.Stdin); err != nil {
+		slog.Error(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *webhookCmd) push(ctx context.Context, data io.Reader) error {
+	parsed, err := readHDF(data)
+	if err != nil {
+		return err
+	}
+
+	var days []parse.DailyTotal
+	for _, d := range parse.Daily(parsed) {
+		if d.Complete() {
+			days = append(days, d)
+		}
+	}
+	if len(days) == 0 {
+		return fmt.Errorf("nothing to send: no complete day in the data")
+	}
+	if len(days) > c.days {
+		days = days[len(days)-c.days:]
+	}
+
+	for _, d := range days {
+		p := webhookPayload{
+			Date:   d.Date.Format("2006-01-02"),
+			Energy: d.KWh,
+			Unit:   "kWh",
+		}
+		if err := ha.SendWebhook(ctx, c.server, c.webhookID, p); err != nil {
+			return fmt.Errorf("cannot send %s to Home Assistant: %w", p.Date, err)
+		}
+		slog.Info("Sent the daily energy", "kwh", p.Energy, "date", p.Date)
+	}
+	return nil
+}